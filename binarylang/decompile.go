@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Binarylang is goofylang with every token replaced by an 8 digit binary code, so a
+// binarylang program can be turned back into goofylang source and that source into the
+// same binary again. Binarylang does not encode the names of identifiers or the values
+// of integers, so every identifier decompiles to "x" and every integer to "0". Strings
+// keep their contents, which follow the STRING code as a length and one group per byte.
+//
+// Comments survive both directions: a binarylang "#" comment becomes a goofylang "//"
+// comment on a line of its own, and every line of a goofylang comment becomes a "#" line.
+
+// symbols maps the goofylang spelling of every token written with punctuation to its
+// binary code. Longer spellings are matched first when assembling.
+var symbols = map[string]TokenType{
+	";":  TOKEN_SEMICOLON,
+	"(":  TOKEN_LPAREN,
+	")":  TOKEN_RPAREN,
+	"{":  TOKEN_LBRACE,
+	"}":  TOKEN_RBRACE,
+	"[":  TOKEN_LBRACKET,
+	"]":  TOKEN_RBRACKET,
+	",":  TOKEN_COMMA,
+	":":  TOKEN_COLON,
+	"==": TOKEN_EQ,
+	"!=": TOKEN_NOT_EQ,
+	"<":  TOKEN_LT,
+	">":  TOKEN_GT,
+	"<=": TOKEN_LT_EQ,
+	">=": TOKEN_GT_EQ,
+	"!":  TOKEN_BANG,
+	"=":  TOKEN_ENCHILADA,
+	"+":  TOKEN_APPLE,
+	"-":  TOKEN_SALMON,
+	"*":  TOKEN_PANCAKES,
+	"/":  TOKEN_PIE,
+	"%":  TOKEN_LEFTOVERS,
+	"+=": TOKEN_APPLE_ENCHILADA,
+	"-=": TOKEN_SALMON_ENCHILADA,
+	"*=": TOKEN_PANCAKES_ENCHILADA,
+	"/=": TOKEN_PIE_ENCHILADA,
+	"%=": TOKEN_LEFTOVERS_ENCHILADA,
+}
+
+// compoundAssignments maps each arithmetic operator to the code of its compound
+// assignment form, which goofylang spells as the operator followed by '=', as in
+// "apple=" or "+=".
+var compoundAssignments = map[TokenType]TokenType{
+	TOKEN_APPLE:     TOKEN_APPLE_ENCHILADA,
+	TOKEN_SALMON:    TOKEN_SALMON_ENCHILADA,
+	TOKEN_PANCAKES:  TOKEN_PANCAKES_ENCHILADA,
+	TOKEN_PIE:       TOKEN_PIE_ENCHILADA,
+	TOKEN_LEFTOVERS: TOKEN_LEFTOVERS_ENCHILADA,
+}
+
+// spelling returns the goofylang source for a token type. Keywords win over
+// punctuation, so "apple" is written rather than "+" and "apple=" rather than "+=".
+func spelling(t TokenType) (string, bool) {
+	switch t {
+	case TOKEN_IDENT:
+		return "x", true
+	case TOKEN_INT:
+		return "0", true
+	}
+	for operator, compound := range compoundAssignments {
+		if compound == t {
+			word, ok := spelling(operator)
+			return word + "=", ok
+		}
+	}
+	for word, tok := range keywords {
+		if tok == t {
+			return word, true
+		}
+	}
+	for symbol, tok := range symbols {
+		if tok == t {
+			return symbol, true
+		}
+	}
+	return "", false
+}
+
+// Decompile turns a binarylang program into goofylang source, with a line break after
+// every semicolon and brace.
+func Decompile(input string) (string, error) {
+	var out strings.Builder
+	l := &Lexer{input: input}
+
+	for {
+		seen := len(l.comments)
+		l.skipWhitespace()
+		for _, c := range l.comments[seen:] {
+			if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+				out.WriteString("\n")
+			}
+			out.WriteString(strings.TrimSpace("// "+strings.TrimSpace(strings.TrimPrefix(c.Literal, "#"))) + "\n")
+		}
+		if l.position >= len(l.input) {
+			break
+		}
+
+		code := l.input[l.position:]
+		if len(code) > 8 {
+			code = code[:8]
+		}
+		t := l.determineTokenType(code)
+		if len(code) < 8 || t == TOKEN_ILLEGAL || t == TOKEN_EOF {
+			return "", fmt.Errorf("unknown binary code %q at offset %d", code, l.position)
+		}
+		l.position += 8
+
+		if t == TOKEN_STRING {
+			value, ok := l.readStringOperand()
+			if !ok {
+				return "", fmt.Errorf("truncated string at offset %d", l.position)
+			}
+			out.WriteString(quoteString(value) + " ")
+			continue
+		}
+
+		word, _ := spelling(t)
+		switch t {
+		case TOKEN_SEMICOLON, TOKEN_LBRACE, TOKEN_RBRACE:
+			out.WriteString(word + "\n")
+		default:
+			out.WriteString(word + " ")
+		}
+	}
+
+	return out.String(), nil
+}
+
+// Assemble turns goofylang source into a binarylang program. Every identifier and
+// integer becomes the bare IDENT or INT code.
+func Assemble(source string) (string, error) {
+	var out strings.Builder
+
+	for i := 0; i < len(source); {
+		ch := source[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case strings.HasPrefix(source[i:], "//"):
+			end := strings.IndexByte(source[i:], '\n')
+			if end < 0 {
+				end = len(source) - i
+			}
+			writeComment(&out, source[i+2:i+end])
+			i += end
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated comment at offset %d", i)
+			}
+			for _, line := range strings.Split(source[i+2:i+2+end], "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					writeComment(&out, line)
+				}
+			}
+			i += end + 4
+		case isLetter(ch):
+			start := i
+			for i < len(source) && isLetter(source[i]) {
+				i++
+			}
+			tok := LookupIdent(source[start:i])
+			// As in goofylang, "apple=" and friends are single tokens.
+			if compound, ok := compoundAssignments[tok]; ok && i < len(source) && source[i] == '=' {
+				tok = compound
+				i++
+			}
+			out.WriteString(string(tok))
+		case '0' <= ch && ch <= '9':
+			for i < len(source) && '0' <= source[i] && source[i] <= '9' {
+				i++
+			}
+			out.WriteString(TOKEN_INT)
+		case ch == '"':
+			value, end, err := readQuoted(source, i)
+			if err != nil {
+				return "", err
+			}
+			if len(value) > 0xffff {
+				return "", fmt.Errorf("string at offset %d is longer than %d bytes", i, 0xffff)
+			}
+			out.WriteString(TOKEN_STRING)
+			fmt.Fprintf(&out, "%08b%08b", len(value)>>8, len(value)&0xff)
+			for j := 0; j < len(value); j++ {
+				fmt.Fprintf(&out, "%08b", value[j])
+			}
+			i = end
+		default:
+			if i+1 < len(source) {
+				if tok, ok := symbols[source[i:i+2]]; ok {
+					out.WriteString(string(tok))
+					i += 2
+					continue
+				}
+			}
+			tok, ok := symbols[source[i:i+1]]
+			if !ok {
+				return "", fmt.Errorf("unexpected character %q at offset %d", ch, i)
+			}
+			out.WriteString(string(tok))
+			i++
+		}
+	}
+
+	return out.String(), nil
+}
+
+// writeComment writes text as a "#" comment on a line of its own.
+func writeComment(out *strings.Builder, text string) {
+	if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+		out.WriteString("\n")
+	}
+	out.WriteString(strings.TrimSpace("# "+strings.TrimSpace(text)) + "\n")
+}
+
+// isLetter checks if the character can appear in a goofylang identifier.
+func isLetter(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
+
+// stringEscapes maps the character after a backslash in a goofylang string to the byte it
+// stands for.
+var stringEscapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+}
+
+// readQuoted decodes the goofylang string literal starting at the quote at source[start],
+// returning its contents and the offset just past the closing quote.
+func readQuoted(source string, start int) (string, int, error) {
+	var out strings.Builder
+
+	for i := start + 1; i < len(source); i++ {
+		switch ch := source[i]; ch {
+		case '"':
+			return out.String(), i + 1, nil
+		case '\n':
+			return "", 0, fmt.Errorf("unterminated string at offset %d", start)
+		case '\\':
+			if i+1 >= len(source) {
+				return "", 0, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			decoded, ok := stringEscapes[source[i+1]]
+			if !ok {
+				return "", 0, fmt.Errorf("unknown escape %q at offset %d", source[i:i+2], i)
+			}
+			out.WriteByte(decoded)
+			i++
+		default:
+			out.WriteByte(ch)
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+// quoteString writes s as a goofylang string literal.
+func quoteString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(ch)
+		default:
+			out.WriteByte(ch)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecompile(t *testing.T) {
+	// waffles IDENT >= INT { pizza cake ; } fries { pizza ! broccoli ; }
+	input := "10010110" + "00000001" + "10010010" + "00000011" + "01000011" +
+		"10000010" + "10010100" + "10000001" + "01000100" +
+		"10010111" + "01000011" + "10000010" + "10010011" + "10010101" + "10000001" + "01000100"
+
+	expected := "waffles x >= 0 {\npizza cake ;\n}\nfries {\npizza ! broccoli ;\n}\n"
+
+	source, err := Decompile(input)
+	if err != nil {
+		t.Fatalf("Decompile returned an error: %s", err)
+	}
+	if source != expected {
+		t.Fatalf("wrong source.\nexpected=%q\ngot=%q", expected, source)
+	}
+
+	binary, err := Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble returned an error: %s", err)
+	}
+	if binary != input {
+		t.Errorf("program did not round-trip.\nexpected=%s\ngot=%s", input, binary)
+	}
+
+	// pizza "tab\there" apple "q\"uote" ;
+	input = "10000010" + "00000101" + "00000000" + "00001000" +
+		"01110100" + "01100001" + "01100010" + "00001001" + "01101000" + "01100101" + "01110010" + "01100101" +
+		"10000111" + "00000101" + "00000000" + "00000110" +
+		"01110001" + "00100010" + "01110101" + "01101111" + "01110100" + "01100101" + "10000001"
+	expected = "pizza \"tab\\there\" apple \"q\\\"uote\" ;\n"
+
+	source, err = Decompile(input)
+	if err != nil {
+		t.Fatalf("Decompile returned an error: %s", err)
+	}
+	if source != expected {
+		t.Fatalf("wrong source.\nexpected=%q\ngot=%q", expected, source)
+	}
+	if binary, err := Assemble(source); err != nil || binary != input {
+		t.Errorf("string program did not round-trip. got=%s, err=%v", binary, err)
+	}
+}
+
+func TestDecompileCompoundAssignments(t *testing.T) {
+	// IDENT apple= INT ; IDENT leftovers= IDENT ;
+	input := "00000001" + "10011110" + "00000011" + "10000001" + "00000001" + "10100010" + "00000001" + "10000001"
+	expected := "x apple= 0 ;\nx leftovers= x ;\n"
+
+	source, err := Decompile(input)
+	if err != nil {
+		t.Fatalf("Decompile returned an error: %s", err)
+	}
+	if source != expected {
+		t.Fatalf("wrong source.\nexpected=%q\ngot=%q", expected, source)
+	}
+	if binary, err := Assemble(source); err != nil || binary != input {
+		t.Errorf("compound assignments did not round-trip. got=%s, err=%v", binary, err)
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"cheese total = 10;", "10000011" + "00000001" + "10000110" + "00000011" + "10000001"},
+		{"a != b", "00000001" + "10001110" + "00000001"},
+		{"a<=b", "00000001" + "10010001" + "00000001"},
+		{"x + 1 apple y", "00000001" + "10000111" + "00000011" + "10000111" + "00000001"},
+		{"noodles cake { dessert; }", "10011000" + "10010100" + "01000011" + "10011010" + "10000001" + "01000100"},
+		{"burrito(a) { takeout a; }", "10011100" + "01000001" + "00000001" + "01000010" + "01000011" + "10011101" + "00000001" + "10000001" + "01000100"},
+		{`pizza "hi";`, "10000010" + "00000101" + "00000000" + "00000010" + "01101000" + "01101001" + "10000001"},
+		{`"a\"\n" apple ""`, "00000101" + "00000000" + "00000011" + "01100001" + "00100010" + "00001010" + "10000111" + "00000101" + "00000000" + "00000000"},
+		{"{x: [1]}[x]", "01000011" + "00000001" + "01001000" + "01000101" + "00000011" + "01000110" + "01000100" + "01000101" + "00000001" + "01000110"},
+		{"tacos x { nuggets { } }", "10000100" + "00000001" + "01000011" + "10000101" + "01000011" + "01000100" + "01000100"},
+		{"cheese nuggets n = nuggets(s);", "10000011" + "10000101" + "00000001" + "10000110" + "10000101" + "01000001" + "00000001" + "01000010" + "10000001"},
+		{"x += 1; x apple= y;", "00000001" + "10011110" + "00000011" + "10000001" + "00000001" + "10011110" + "00000001" + "10000001"},
+		{"x-=1 salmon=pancakes= *= pie= /= leftovers= %=", "00000001" + "10011111" + "00000011" + "10011111" + "10100000" + "10100000" + "10100001" + "10100001" + "10100010" + "10100010"},
+		{"x pie = 2 / =", "00000001" + "10001011" + "10000110" + "00000011" + "10001011" + "10000110"},
+		{"donuts i = 0, n { seconds; }", "10011001" + "00000001" + "10000110" + "00000011" + "01000111" + "00000001" + "01000011" + "10011011" + "10000001" + "01000100"},
+	}
+
+	for _, tt := range tests {
+		binary, err := Assemble(tt.input)
+		if err != nil {
+			t.Errorf("%q: Assemble returned an error: %s", tt.input, err)
+			continue
+		}
+		if binary != tt.expected {
+			t.Errorf("%q: expected=%s, got=%s", tt.input, tt.expected, binary)
+		}
+	}
+
+	if _, err := Assemble("pizza 1 @ 2;"); err == nil || !strings.Contains(err.Error(), "'@'") {
+		t.Errorf("expected an error for '@'. got=%v", err)
+	}
+	if _, err := Assemble(`pizza "open;`); err == nil || !strings.Contains(err.Error(), "unterminated string") {
+		t.Errorf("expected an error for an unterminated string. got=%v", err)
+	}
+	if _, err := Assemble(`pizza "\q";`); err == nil || !strings.Contains(err.Error(), "unknown escape") {
+		t.Errorf("expected an error for an unknown escape. got=%v", err)
+	}
+	if _, err := Assemble(`"` + strings.Repeat("a", 70000) + `"`); err == nil {
+		t.Errorf("expected an error for a string longer than 65535 bytes")
+	}
+	if _, err := Decompile("00000101" + "00000000" + "00000010" + "01101000"); err == nil {
+		t.Errorf("expected an error for a truncated string")
+	}
+	if _, err := Decompile("10000010" + "11111111"); err == nil {
+		t.Errorf("expected an error for an unknown binary code")
+	}
+}
+
+func TestComments(t *testing.T) {
+	source := "// prints one\npizza 1; // the answer\n/* a\n   block */ dessert;"
+	binary, err := Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble returned an error: %s", err)
+	}
+	expected := "# prints one\n" +
+		"10000010" + "00000011" + "10000001" + "\n# the answer\n" +
+		"# a\n# block\n" +
+		"10011010" + "10000001"
+	if binary != expected {
+		t.Fatalf("wrong binary.\nexpected=%q\ngot=%q", expected, binary)
+	}
+
+	decompiled, err := Decompile(binary)
+	if err != nil {
+		t.Fatalf("Decompile returned an error: %s", err)
+	}
+	expectedSource := "// prints one\npizza 0 ;\n// the answer\n// a\n// block\ndessert ;\n"
+	if decompiled != expectedSource {
+		t.Fatalf("wrong source.\nexpected=%q\ngot=%q", expectedSource, decompiled)
+	}
+	if again, err := Assemble(decompiled); err != nil || again != binary {
+		t.Errorf("comments did not round-trip.\nexpected=%q\ngot=%q (%v)", binary, again, err)
+	}
+
+	if _, err := Assemble("pizza 1; /* open"); err == nil || !strings.Contains(err.Error(), "unterminated comment") {
+		t.Errorf("expected an error for an unterminated comment. got=%v", err)
+	}
+	if binary, err := Assemble("pizza 4 / 2;"); err != nil || binary != "10000010"+"00000011"+"10001011"+"00000011"+"10000001" {
+		t.Errorf("division was taken for a comment: %q (%v)", binary, err)
+	}
+}
@@ -31,4 +31,204 @@ func TestLexer(t *testing.T) {
 			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestLexerProductOperators(t *testing.T) {
+	input := "00000011" + "10001010" + "00000011" + "10001011" + "00000011" + "10001100" + "00000011" // INT * INT / INT % INT
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_INT, "00000011"},
+		{TOKEN_PANCAKES, "10001010"},
+		{TOKEN_INT, "00000011"},
+		{TOKEN_PIE, "10001011"},
+		{TOKEN_INT, "00000011"},
+		{TOKEN_LEFTOVERS, "10001100"},
+		{TOKEN_INT, "00000011"},
+	}
+
+	l := NewLexer(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLexerDelimiters(t *testing.T) {
+	input := "01000001" + "00000001" + "01000010" + "01000011" + "01000100" + "01000101" + "01000111" + "01000110" + "01001000" // ( IDENT ) { } [ , ] :
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_LPAREN, "01000001"},
+		{TOKEN_IDENT, "00000001"},
+		{TOKEN_RPAREN, "01000010"},
+		{TOKEN_LBRACE, "01000011"},
+		{TOKEN_RBRACE, "01000100"},
+		{TOKEN_LBRACKET, "01000101"},
+		{TOKEN_COMMA, "01000111"},
+		{TOKEN_RBRACKET, "01000110"},
+		{TOKEN_COLON, "01001000"},
+		{TOKEN_EOF, ""},
+	}
+
+	l := NewLexer(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLexerConditionals(t *testing.T) {
+	input := "10010110" + "00000001" + "10001111" + "00000011" + "01000011" + "01000100" + "10010111" + "10010100" // waffles IDENT < INT { } fries cake
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_WAFFLES, "10010110"},
+		{TOKEN_IDENT, "00000001"},
+		{TOKEN_LT, "10001111"},
+		{TOKEN_INT, "00000011"},
+		{TOKEN_LBRACE, "01000011"},
+		{TOKEN_RBRACE, "01000100"},
+		{TOKEN_FRIES, "10010111"},
+		{TOKEN_CAKE, "10010100"},
+	}
+
+	l := NewLexer(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLexerLoops(t *testing.T) {
+	input := "10011000" + "10011001" + "10011010" + "10011011" // noodles donuts dessert seconds
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_NOODLES, "10011000"},
+		{TOKEN_DONUTS, "10011001"},
+		{TOKEN_DESSERT, "10011010"},
+		{TOKEN_SECONDS, "10011011"},
+	}
+
+	l := NewLexer(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLexerStrings(t *testing.T) {
+	input := "00000101" + "00000000" + "00000010" + "01101000" + "01101001" + // "hi"
+		"00000101" + "00000000" + "00000000" + // ""
+		"10000001" + // ;
+		"00000101" + "00000000" + "00000011" + "01100001" // "a with two bytes missing
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_STRING, "hi"},
+		{TOKEN_STRING, ""},
+		{TOKEN_SEMICOLON, "10000001"},
+		{TOKEN_ILLEGAL, "00000101"},
+		{TOKEN_EOF, ""},
+	}
+
+	l := NewLexer(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLexerComments(t *testing.T) {
+	input := "# declare a\n" +
+		"10000011 00000001 # cheese x\n" +
+		"10000110 00000011 10000001#;\n" +
+		"#"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_CHEESE, "10000011"},
+		{TOKEN_IDENT, "00000001"},
+		{TOKEN_ENCHILADA, "10000110"},
+		{TOKEN_INT, "00000011"},
+		{TOKEN_SEMICOLON, "10000001"},
+		{TOKEN_EOF, ""},
+	}
+
+	l := NewLexer(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	expected := []string{"# declare a", "# cheese x", "#;", "#"}
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expected), len(comments))
+	}
+	for i, literal := range expected {
+		if comments[i].Type != TOKEN_COMMENT || comments[i].Literal != literal {
+			t.Errorf("comments[%d] - expected %q, got %+v", i, literal, comments[i])
+		}
+	}
+}
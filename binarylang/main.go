@@ -10,12 +10,16 @@ type TokenType string
 
 // Pizza for printing, Cheese for var declaration, Tacos for identifying, Nuggets for number
 // Enchilada for equal, Apple for add, Salmon for subtract, Icaco for inputs
+// Pancakes for multiply, Pie for divide, Leftovers for remainder
+// Cake for true, Broccoli for false, Waffles for if, Fries for else
+// A string is its code, a 16 bit length as two 8 digit groups, then one 8 digit group per byte
 // h
 const (
 	TOKEN_IDENT   = "00000001" // Unique binary code for IDENT
     TOKEN_EOF     = "00000010" // Unique binary code for EOF
     TOKEN_INT     = "00000011" // Unique binary code for INT
     TOKEN_ILLEGAL = "00000100" // Unique binary code for ILLEGAL
+    TOKEN_STRING  = "00000101" // Unique binary code for STRING, followed by its length and bytes
 	TOKEN_SEMICOLON = "10000001" // Arbitrary unique binary code for semicolon
     TOKEN_PIZZA     = "10000010" // Arbitrary unique binary code for pizza
     TOKEN_CHEESE    = "10000011" // Arbitrary unique binary code for cheese
@@ -25,6 +29,40 @@ const (
     TOKEN_APPLE     = "10000111" // Arbitrary unique binary code for apple
     TOKEN_SALMON    = "10001000" // Arbitrary unique binary code for salmon
     TOKEN_ICACO     = "10001001" // Arbitrary unique binary code for icaco
+    TOKEN_PANCAKES  = "10001010" // Arbitrary unique binary code for pancakes
+    TOKEN_PIE       = "10001011" // Arbitrary unique binary code for pie
+    TOKEN_LEFTOVERS = "10001100" // Arbitrary unique binary code for leftovers
+    TOKEN_LPAREN    = "01000001" // Binary code for (
+    TOKEN_RPAREN    = "01000010" // Binary code for )
+    TOKEN_LBRACE    = "01000011" // Binary code for {
+    TOKEN_RBRACE    = "01000100" // Binary code for }
+    TOKEN_LBRACKET  = "01000101" // Binary code for [
+    TOKEN_RBRACKET  = "01000110" // Binary code for ]
+    TOKEN_COMMA     = "01000111" // Binary code for ,
+    TOKEN_COLON     = "01001000" // Binary code for :
+    TOKEN_EQ        = "10001101" // Binary code for ==
+    TOKEN_NOT_EQ    = "10001110" // Binary code for !=
+    TOKEN_LT        = "10001111" // Binary code for <
+    TOKEN_GT        = "10010000" // Binary code for >
+    TOKEN_LT_EQ     = "10010001" // Binary code for <=
+    TOKEN_GT_EQ     = "10010010" // Binary code for >=
+    TOKEN_BANG      = "10010011" // Binary code for !
+    TOKEN_CAKE      = "10010100" // Arbitrary unique binary code for cake
+    TOKEN_BROCCOLI  = "10010101" // Arbitrary unique binary code for broccoli
+    TOKEN_WAFFLES   = "10010110" // Arbitrary unique binary code for waffles
+    TOKEN_FRIES     = "10010111" // Arbitrary unique binary code for fries
+    TOKEN_NOODLES   = "10011000" // Arbitrary unique binary code for noodles
+    TOKEN_DONUTS    = "10011001" // Arbitrary unique binary code for donuts
+    TOKEN_DESSERT   = "10011010" // Arbitrary unique binary code for dessert
+    TOKEN_SECONDS   = "10011011" // Arbitrary unique binary code for seconds
+    TOKEN_BURRITO   = "10011100" // Arbitrary unique binary code for burrito
+    TOKEN_TAKEOUT   = "10011101" // Arbitrary unique binary code for takeout
+    TOKEN_APPLE_ENCHILADA     = "10011110" // Arbitrary unique binary code for apple= (+=)
+    TOKEN_SALMON_ENCHILADA    = "10011111" // Arbitrary unique binary code for salmon= (-=)
+    TOKEN_PANCAKES_ENCHILADA  = "10100000" // Arbitrary unique binary code for pancakes= (*=)
+    TOKEN_PIE_ENCHILADA       = "10100001" // Arbitrary unique binary code for pie= (/=)
+    TOKEN_LEFTOVERS_ENCHILADA = "10100010" // Arbitrary unique binary code for leftovers= (%=)
+    TOKEN_COMMENT   = "comment"  // Not a binary code: a # comment, kept aside by the lexer and never returned by NextToken
 )
 
 const (
@@ -47,6 +85,7 @@ type Lexer struct {
 	input        string
 	position     int  // current position in input
 	currentToken Token
+	comments     []Token // comments skipped so far
 }
 
 func NewLexer(input string) *Lexer {
@@ -77,6 +116,14 @@ func (l *Lexer) NextToken() Token {
 
     // Determine the token type based on the binary string.
     tok = newToken(tokenType, binaryString)
+    if tokenType == TOKEN_STRING {
+        // The literal of a string token is its decoded contents.
+        if value, ok := l.readStringOperand(); ok {
+            tok.Literal = value
+        } else {
+            tok.Type = TOKEN_ILLEGAL
+        }
+    }
 
     l.currentToken = tok
 
@@ -94,6 +141,8 @@ func (l *Lexer) determineTokenType(binaryString string) TokenType {
 		return TOKEN_INT
 	case "00000100": // Binary representation for TOKEN_ILLEGAL
 		return TOKEN_ILLEGAL
+	case "00000101": // Binary representation for TOKEN_STRING
+		return TOKEN_STRING
 	case "10000001": // Binary representation for TOKEN_SEMICOLON
 		return TOKEN_SEMICOLON
 	case "10000010": // Binary representation for TOKEN_PIZZA
@@ -112,6 +161,72 @@ func (l *Lexer) determineTokenType(binaryString string) TokenType {
 		return TOKEN_SALMON
 	case "10001001": // Binary representation for TOKEN_ICACO
 		return TOKEN_ICACO
+	case "10001010": // Binary representation for TOKEN_PANCAKES
+		return TOKEN_PANCAKES
+	case "10001011": // Binary representation for TOKEN_PIE
+		return TOKEN_PIE
+	case "10001100": // Binary representation for TOKEN_LEFTOVERS
+		return TOKEN_LEFTOVERS
+	case "01000001": // Binary representation for TOKEN_LPAREN
+		return TOKEN_LPAREN
+	case "01000010": // Binary representation for TOKEN_RPAREN
+		return TOKEN_RPAREN
+	case "01000011": // Binary representation for TOKEN_LBRACE
+		return TOKEN_LBRACE
+	case "01000100": // Binary representation for TOKEN_RBRACE
+		return TOKEN_RBRACE
+	case "01000101": // Binary representation for TOKEN_LBRACKET
+		return TOKEN_LBRACKET
+	case "01000110": // Binary representation for TOKEN_RBRACKET
+		return TOKEN_RBRACKET
+	case "01000111": // Binary representation for TOKEN_COMMA
+		return TOKEN_COMMA
+	case "01001000": // Binary representation for TOKEN_COLON
+		return TOKEN_COLON
+	case "10001101": // Binary representation for TOKEN_EQ
+		return TOKEN_EQ
+	case "10001110": // Binary representation for TOKEN_NOT_EQ
+		return TOKEN_NOT_EQ
+	case "10001111": // Binary representation for TOKEN_LT
+		return TOKEN_LT
+	case "10010000": // Binary representation for TOKEN_GT
+		return TOKEN_GT
+	case "10010001": // Binary representation for TOKEN_LT_EQ
+		return TOKEN_LT_EQ
+	case "10010010": // Binary representation for TOKEN_GT_EQ
+		return TOKEN_GT_EQ
+	case "10010011": // Binary representation for TOKEN_BANG
+		return TOKEN_BANG
+	case "10010100": // Binary representation for TOKEN_CAKE
+		return TOKEN_CAKE
+	case "10010101": // Binary representation for TOKEN_BROCCOLI
+		return TOKEN_BROCCOLI
+	case "10010110": // Binary representation for TOKEN_WAFFLES
+		return TOKEN_WAFFLES
+	case "10010111": // Binary representation for TOKEN_FRIES
+		return TOKEN_FRIES
+	case "10011000": // Binary representation for TOKEN_NOODLES
+		return TOKEN_NOODLES
+	case "10011001": // Binary representation for TOKEN_DONUTS
+		return TOKEN_DONUTS
+	case "10011010": // Binary representation for TOKEN_DESSERT
+		return TOKEN_DESSERT
+	case "10011011": // Binary representation for TOKEN_SECONDS
+		return TOKEN_SECONDS
+	case "10011100": // Binary representation for TOKEN_BURRITO
+		return TOKEN_BURRITO
+	case "10011101": // Binary representation for TOKEN_TAKEOUT
+		return TOKEN_TAKEOUT
+	case "10011110": // Binary representation for TOKEN_APPLE_ENCHILADA
+		return TOKEN_APPLE_ENCHILADA
+	case "10011111": // Binary representation for TOKEN_SALMON_ENCHILADA
+		return TOKEN_SALMON_ENCHILADA
+	case "10100000": // Binary representation for TOKEN_PANCAKES_ENCHILADA
+		return TOKEN_PANCAKES_ENCHILADA
+	case "10100001": // Binary representation for TOKEN_PIE_ENCHILADA
+		return TOKEN_PIE_ENCHILADA
+	case "10100010": // Binary representation for TOKEN_LEFTOVERS_ENCHILADA
+		return TOKEN_LEFTOVERS_ENCHILADA
 	// ... additional cases if any ...
 	default:
 		return TOKEN_ILLEGAL
@@ -129,11 +244,70 @@ func (l *Lexer) readBinaryString() string {
     return binaryString
 }
 
-// skipWhitespace advances the lexer's position past any whitespace.
+// readStringOperand reads the length and bytes that follow a STRING code. It reports
+// false if the operand is truncated or a group is not made of binary digits.
+func (l *Lexer) readStringOperand() (string, bool) {
+    high, ok := l.readByteGroup()
+    if !ok {
+        return "", false
+    }
+    low, ok := l.readByteGroup()
+    if !ok {
+        return "", false
+    }
+
+    length := int(high)<<8 | int(low)
+    value := make([]byte, length)
+    for i := range value {
+        if value[i], ok = l.readByteGroup(); !ok {
+            return "", false
+        }
+    }
+    return string(value), true
+}
+
+// readByteGroup reads one 8 digit group as a byte value.
+func (l *Lexer) readByteGroup() (byte, bool) {
+    l.skipWhitespace()
+    if l.position+8 > len(l.input) {
+        return 0, false
+    }
+    n, err := strconv.ParseUint(l.input[l.position:l.position+8], 2, 8)
+    if err != nil {
+        return 0, false
+    }
+    l.position += 8
+    return byte(n), true
+}
+
+// skipWhitespace advances the lexer's position past any whitespace and comments.
 func (l *Lexer) skipWhitespace() {
-    for l.position < len(l.input) && (l.input[l.position] == ' ' || l.input[l.position] == '\t' || l.input[l.position] == '\n' || l.input[l.position] == '\r') {
+    for l.position < len(l.input) {
+        switch l.input[l.position] {
+        case ' ', '\t', '\n', '\r':
+            l.position++
+        case '#':
+            l.readComment()
+        default:
+            return
+        }
+    }
+}
+
+// readComment reads a comment, which runs from "#" to the end of the line. Binary digits
+// never include "#", so a comment can follow a code on the same line. Comments are not
+// tokens, but the lexer keeps them for tools such as the decompiler.
+func (l *Lexer) readComment() {
+    start := l.position
+    for l.position < len(l.input) && l.input[l.position] != '\n' {
         l.position++
     }
+    l.comments = append(l.comments, Token{Type: TOKEN_COMMENT, Literal: strings.TrimRight(l.input[start:l.position], " \t\r")})
+}
+
+// Comments returns the comments read so far, in the order they appear.
+func (l *Lexer) Comments() []Token {
+    return l.comments
 }
 
 func newToken(tokenType TokenType, binaryString string) Token {
@@ -164,20 +338,33 @@ func (l *Lexer) currentTokenIs(tokenType TokenType) bool {
     return l.currentToken.Type == tokenType
 }
 
+// keywords maps the goofylang spelling of every keyword to its binary code.
+var keywords = map[string]TokenType{
+	"pizza":     TOKEN_PIZZA,
+	"cheese":    TOKEN_CHEESE,
+	"apple":     TOKEN_APPLE,
+	"enchilada": TOKEN_ENCHILADA,
+	"icaco":     TOKEN_ICACO,
+	"nuggets":   TOKEN_NUGGETS,
+	"salmon":    TOKEN_SALMON,
+	"tacos":     TOKEN_TACOS,
+	"pancakes":  TOKEN_PANCAKES,
+	"pie":       TOKEN_PIE,
+	"leftovers": TOKEN_LEFTOVERS,
+	"cake":      TOKEN_CAKE,
+	"broccoli":  TOKEN_BROCCOLI,
+	"waffles":   TOKEN_WAFFLES,
+	"fries":     TOKEN_FRIES,
+	"noodles":   TOKEN_NOODLES,
+	"donuts":    TOKEN_DONUTS,
+	"dessert":   TOKEN_DESSERT,
+	"seconds":   TOKEN_SECONDS,
+	"burrito":   TOKEN_BURRITO,
+	"takeout":   TOKEN_TAKEOUT,
+}
+
 // LookupIdent checks if an identifier is a keyword or just a regular identifier.
 func LookupIdent(ident string) TokenType {
-	keywords := map[string]TokenType{
-		// Mapping of keyword strings to their respective token types.
-		"pizza":     TOKEN_PIZZA,
-		"cheese":    TOKEN_CHEESE,
-		"apple":     TOKEN_APPLE,
-		"enchilada": TOKEN_ENCHILADA,
-		"icaco":     TOKEN_ICACO,
-		"nuggets":   TOKEN_NUGGETS,
-		"salmon":    TOKEN_SALMON,
-		"tacos":     TOKEN_TACOS,
-	}
-
 	if tok, ok := keywords[ident]; ok {
 		// If the identifier is a keyword, return the corresponding token type.
 		return tok
@@ -375,6 +562,8 @@ func (p *Parser) parseExpression(precedence int) Expression {
         leftExp = p.parseIntegralLiteral()
     case TOKEN_IDENT:
         leftExp = p.parseIdentifier()
+    case TOKEN_LPAREN:
+        leftExp = p.parseGroupedExpression()
     // Add cases for other types of expressions
     // ...
     default:
@@ -384,6 +573,19 @@ func (p *Parser) parseExpression(precedence int) Expression {
     return leftExp
 }
 
+// parseGroupedExpression parses the expression between a LPAREN and its RPAREN.
+func (p *Parser) parseGroupedExpression() Expression {
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(TOKEN_RPAREN) {
+		return nil
+	}
+
+	return exp
+}
+
 func (p *Parser) parseIntegralLiteral() Expression {
 	lit := &IntegralLiteral{Token: p.curToken}
 
@@ -0,0 +1,47 @@
+package main
+
+// builtins are the functions every program can call without declaring them. A cheese
+// with the same name shadows a builtin like any other binding.
+var builtins = map[string]*Builtin{
+	"len": {Name: "len", Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments: want=1, got=%d", len(args))
+		}
+		switch arg := args[0].(type) {
+		case *String:
+			return &Integer{Value: int64(len(arg.Value))}
+		case *Array:
+			return &Integer{Value: int64(len(arg.Elements))}
+		case *Hash:
+			return &Integer{Value: int64(len(arg.Order))}
+		default:
+			return newError("argument to len not supported, got %s", typeName(args[0]))
+		}
+	}},
+	"push": {Name: "push", Fn: func(args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments: want=2, got=%d", len(args))
+		}
+		array, ok := args[0].(*Array)
+		if !ok {
+			return newError("first argument to push must be array, got %s", typeName(args[0]))
+		}
+		elements := make([]Object, len(array.Elements), len(array.Elements)+1)
+		copy(elements, array.Elements)
+		return &Array{Elements: append(elements, args[1])}
+	}},
+	"keys": {Name: "keys", Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments: want=1, got=%d", len(args))
+		}
+		hash, ok := args[0].(*Hash)
+		if !ok {
+			return newError("argument to keys must be hash, got %s", typeName(args[0]))
+		}
+		keys := make([]Object, len(hash.Order))
+		for i, key := range hash.Order {
+			keys[i] = hash.Pairs[key].Key
+		}
+		return &Array{Elements: keys}
+	}},
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The C backend turns a parsed Program into a portable C99 translation unit so goofylang
+// scripts can be built into standalone executables with any system C compiler.
+// Every value is an int64_t, with cake stored as 1 and broccoli as 0. Arithmetic traps
+// on overflow instead of wrapping, pizza prints with printf and icaco reads with scanf.
+// Noodles and donuts become C for loops, so dessert and seconds are plain break and continue.
+
+// cPrelude is emitted at the top of every generated file.
+const cPrelude = `#include <inttypes.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+
+static void goofy_fail(const char *msg) {
+    fprintf(stderr, "goofy: %s\n", msg);
+    exit(1);
+}
+
+static int64_t goofy_add(int64_t a, int64_t b) {
+    if ((b > 0 && a > INT64_MAX - b) || (b < 0 && a < INT64_MIN - b)) {
+        goofy_fail("integer overflow in apple");
+    }
+    return a + b;
+}
+
+static int64_t goofy_sub(int64_t a, int64_t b) {
+    if ((b < 0 && a > INT64_MAX + b) || (b > 0 && a < INT64_MIN + b)) {
+        goofy_fail("integer overflow in salmon");
+    }
+    return a - b;
+}
+
+static int64_t goofy_neg(int64_t a) {
+    if (a == INT64_MIN) {
+        goofy_fail("integer overflow in salmon");
+    }
+    return -a;
+}
+
+static int64_t goofy_mul(int64_t a, int64_t b) {
+    if (a > 0 ? (b > 0 ? a > INT64_MAX / b : b < INT64_MIN / a)
+              : (b > 0 ? a < INT64_MIN / b : a != 0 && b < INT64_MAX / a)) {
+        goofy_fail("integer overflow in pancakes");
+    }
+    return a * b;
+}
+
+static int64_t goofy_div(int64_t a, int64_t b) {
+    if (b == 0) {
+        goofy_fail("division by zero");
+    }
+    if (a == INT64_MIN && b == -1) {
+        goofy_fail("integer overflow in pie");
+    }
+    return a / b;
+}
+
+static int64_t goofy_mod(int64_t a, int64_t b) {
+    if (b == 0) {
+        goofy_fail("division by zero");
+    }
+    if (b == -1) {
+        return 0;
+    }
+    return a % b;
+}
+
+static void goofy_pizza(int64_t v) {
+    printf("%" PRId64 "\n", v);
+}
+
+static void goofy_pizza_bool(int64_t v) {
+    puts(v ? "cake" : "broccoli");
+}
+
+static int64_t goofy_icaco(void) {
+    int64_t v;
+    if (scanf("%" SCNd64, &v) != 1) {
+        goofy_fail("icaco expected an integer");
+    }
+    return v;
+}
+`
+
+// cCompiler holds the state needed while emitting a single C file.
+type cCompiler struct {
+	scope *symbolTable    // The variables visible at the current point.
+	vars  map[string]bool // The storage name of every variable in the program.
+	temps int             // Number of temporaries allocated so far.
+	depth int             // How many C blocks the next line is nested in.
+	loops []*loopTypes    // The loops enclosing the current point, innermost last.
+	body  strings.Builder // Statements emitted for main.
+}
+
+// CompileC translates a goofylang program into C99 source code.
+func CompileC(program *Program) (string, error) {
+	c := &cCompiler{scope: newSymbolTable(), vars: map[string]bool{}}
+
+	for _, stmt := range program.Statements {
+		if err := c.compileStatement(stmt); err != nil {
+			return "", err
+		}
+	}
+
+	return c.file(), nil
+}
+
+// file assembles the prelude, the variable declarations and main into the final text.
+func (c *cCompiler) file() string {
+	var out strings.Builder
+
+	out.WriteString(cPrelude)
+	out.WriteString("\nint main(void) {\n")
+
+	// Sort the variables so the output is stable between runs.
+	names := make([]string, 0, len(c.vars))
+	for name := range c.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&out, "    int64_t %s = 0;\n", cName(name))
+	}
+
+	out.WriteString(c.body.String())
+	out.WriteString("    return 0;\n")
+	out.WriteString("}\n")
+
+	return out.String()
+}
+
+// cName mangles a goofylang identifier so it can never collide with C keywords or the prelude.
+func cName(name string) string {
+	return "v_" + name
+}
+
+// cMangle names the variable of a cheese binding made inside a block. Identifiers never
+// contain digits, so the result cannot clash with a top level variable.
+func cMangle(name string, n int) string {
+	return fmt.Sprintf("%s_%d", name, n)
+}
+
+// emit writes a single line into the body of main.
+func (c *cCompiler) emit(format string, args ...interface{}) {
+	c.body.WriteString(strings.Repeat("    ", c.depth+1))
+	fmt.Fprintf(&c.body, format, args...)
+	c.body.WriteString("\n")
+}
+
+// compileStatement emits the C statement for one goofylang statement.
+func (c *cCompiler) compileStatement(stmt Statement) error {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		value, typ, err := c.compileExpression(stmt.Value)
+		if err != nil {
+			return err
+		}
+		sym, err := c.scope.declareLet(stmt, typ, cMangle)
+		if err != nil {
+			return fmt.Errorf("c: %s", err)
+		}
+		c.vars[sym.storage] = true
+		c.emit("%s = %s;", cName(sym.storage), value)
+	case *AssignStatement:
+		sym, ok := c.scope.lookup(stmt.Name.Value)
+		if !ok {
+			return fmt.Errorf("c: cannot assign to undeclared identifier: %s", stmt.Name.Value)
+		}
+		value, typ, err := c.compileExpression(stmt.Value)
+		if err != nil {
+			return err
+		}
+		if operator, ok := stmt.Operator(); ok {
+			value, typ, err = c.compileOperator(operator, cName(sym.storage), sym.typ, value, typ)
+			if err != nil {
+				return err
+			}
+		}
+		if err := sym.check(typ); err != nil {
+			return fmt.Errorf("c: %s", err)
+		}
+		sym.typ = typ
+		c.emit("%s = %s;", cName(sym.storage), value)
+	case *PrintStatement:
+		value, typ, err := c.compileExpression(stmt.Value)
+		if err != nil {
+			return err
+		}
+		if typ == BOOLEAN_OBJ {
+			c.emit("goofy_pizza_bool(%s);", value)
+		} else {
+			c.emit("goofy_pizza(%s);", value)
+		}
+	case *ExpressionStatement:
+		value, _, err := c.compileExpression(stmt.Expression)
+		if err != nil {
+			return err
+		}
+		c.emit("(void)%s;", value)
+	case *BlockStatement:
+		c.emit("{")
+		if err := c.compileBlock(stmt); err != nil {
+			return err
+		}
+		c.emit("}")
+	case *IfStatement:
+		return c.compileIfStatement(stmt)
+	case *WhileStatement:
+		return c.compileWhileStatement(stmt)
+	case *ForStatement:
+		return c.compileForStatement(stmt)
+	case *LoopControlStatement:
+		if len(c.loops) == 0 {
+			return fmt.Errorf("c: %s outside of a loop", stmt.Token.Literal)
+		}
+		c.loops[len(c.loops)-1].exit(c.scope)
+		if stmt.IsBreak() {
+			c.emit("break;")
+		} else {
+			c.emit("continue;")
+		}
+	case *TypeSwitchStatement:
+		return fmt.Errorf("c: tacos is not supported")
+	default:
+		return fmt.Errorf("c: unsupported statement %T", stmt)
+	}
+	return nil
+}
+
+// compileBlock emits the statements of a block, one level deeper and in a scope of
+// their own. The caller writes the braces around them.
+func (c *cCompiler) compileBlock(block *BlockStatement) error {
+	outer := c.scope
+	c.scope = outer.enclose()
+	c.depth++
+	defer func() {
+		c.scope = outer
+		c.depth--
+	}()
+
+	for _, stmt := range block.Statements {
+		if err := c.compileStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileIfStatement emits a C if statement. C already treats 0 as false, which matches
+// goofylang's truthiness for both integers and booleans.
+func (c *cCompiler) compileIfStatement(stmt *IfStatement) error {
+	condition, _, err := c.compileExpression(stmt.Condition)
+	if err != nil {
+		return err
+	}
+
+	before := c.scope.snapshot()
+	c.emit("if (%s) {", condition)
+	if err := c.compileBlock(stmt.Consequence); err != nil {
+		return err
+	}
+	afterThen := c.scope.snapshot()
+	before.restore()
+
+	if stmt.Alternative != nil {
+		c.emit("} else {")
+		var err error
+		if block, ok := stmt.Alternative.(*BlockStatement); ok {
+			err = c.compileBlock(block)
+		} else {
+			// A chained waffles goes inside the else block, since its condition may need
+			// temporaries of its own before its if.
+			c.depth++
+			err = c.compileStatement(stmt.Alternative)
+			c.depth--
+		}
+		if err != nil {
+			return err
+		}
+	}
+	c.emit("}")
+
+	if err := mergeTypes(afterThen, c.scope.snapshot()); err != nil {
+		return fmt.Errorf("c: %s", err)
+	}
+	return nil
+}
+
+// compileLoopBody emits the body of a loop whose opening line has already been written,
+// running enter first in the scope of the iteration, and closes the loop. The C variable
+// of a goofylang variable has one type for the whole program as far as pizza is
+// concerned, so the body must leave every variable holding the type it held before.
+func (c *cCompiler) compileLoopBody(body *BlockStatement, enter func()) error {
+	loop := newLoopTypes(c.scope)
+	outer := c.scope
+	c.scope = outer.enclose()
+	c.depth++
+	enter()
+	c.depth--
+
+	c.loops = append(c.loops, loop)
+	err := c.compileBlock(body)
+	c.loops = c.loops[:len(c.loops)-1]
+	if err != nil {
+		return err
+	}
+	loop.exit(c.scope)
+	c.scope = outer
+	c.emit("}")
+
+	if err := loop.check(); err != nil {
+		return fmt.Errorf("c: %s", err)
+	}
+	return nil
+}
+
+// compileWhileStatement emits a noodles loop. The condition may need temporaries, so it
+// is tested inside the loop rather than in its header.
+func (c *cCompiler) compileWhileStatement(stmt *WhileStatement) error {
+	c.emit("for (;;) {")
+	c.depth++
+	condition, _, err := c.compileExpression(stmt.Condition)
+	if err == nil {
+		c.emit("if (!%s) break;", condition)
+	}
+	c.depth--
+	if err != nil {
+		return err
+	}
+	return c.compileLoopBody(stmt.Body, func() {})
+}
+
+// compileForStatement emits a donuts loop. An end held in a variable is copied into a
+// temporary first, since the body may assign to it, and the counter is copied into the loop variable at the start of every
+// iteration so assigning to it does not change how often the loop runs.
+func (c *cCompiler) compileForStatement(stmt *ForStatement) error {
+	start, typ, err := c.compileExpression(stmt.Start)
+	if err != nil {
+		return err
+	}
+	if typ != INTEGER_OBJ {
+		return fmt.Errorf("c: donuts expected an integer start, got %s", typeNameOf(typ))
+	}
+	end, typ, err := c.compileExpression(stmt.End)
+	if err != nil {
+		return err
+	}
+	if typ != INTEGER_OBJ {
+		return fmt.Errorf("c: donuts expected an integer end, got %s", typeNameOf(typ))
+	}
+	if strings.HasPrefix(end, "v_") {
+		end = c.temp(end)
+	}
+
+	c.temps++
+	counter := fmt.Sprintf("t%d", c.temps)
+	c.emit("for (int64_t %s = %s; %s < %s; %s++) {", counter, start, counter, end, counter)
+	return c.compileLoopBody(stmt.Body, func() {
+		sym := c.scope.declare(stmt.Variable.Value, INTEGER_OBJ, cMangle)
+		c.vars[sym.storage] = true
+		c.emit("%s = %s;", cName(sym.storage), counter)
+	})
+}
+
+// temp stores value in a fresh temporary and returns its name. C leaves the order in which
+// function arguments are evaluated unspecified, so every call goes through a temporary to
+// keep icaco reads and overflow checks in source order.
+func (c *cCompiler) temp(value string) string {
+	c.temps++
+	name := fmt.Sprintf("t%d", c.temps)
+	c.emit("const int64_t %s = %s;", name, value)
+	return name
+}
+
+// compileExpression returns a C operand holding the value of exp together with its type,
+// emitting any temporaries it needs first.
+func (c *cCompiler) compileExpression(exp Expression) (string, ObjectType, error) {
+	switch exp := exp.(type) {
+	case *IntegralLiteral:
+		// INT64_C keeps large literals from being truncated to int on small platforms.
+		return fmt.Sprintf("INT64_C(%d)", exp.Value), INTEGER_OBJ, nil
+	case *BooleanLiteral:
+		if exp.Value {
+			return "INT64_C(1)", BOOLEAN_OBJ, nil
+		}
+		return "INT64_C(0)", BOOLEAN_OBJ, nil
+	case *Identifier:
+		sym, ok := c.scope.lookup(exp.Value)
+		if !ok {
+			return "", "", fmt.Errorf("c: identifier not found: %s", exp.Value)
+		}
+		return cName(sym.storage), sym.typ, nil
+	case *InputExpression:
+		return c.temp("goofy_icaco()"), INTEGER_OBJ, nil
+	case *PrefixExpression:
+		right, typ, err := c.compileExpression(exp.Right)
+		if err != nil {
+			return "", "", err
+		}
+		if typ, err = prefixResultType(exp.Token.Type, typ); err != nil {
+			return "", "", fmt.Errorf("c: %s", err)
+		}
+		if exp.Token.Type == TOKEN_BANG {
+			return c.temp(fmt.Sprintf("!%s", right)), typ, nil
+		}
+		return c.temp(fmt.Sprintf("goofy_neg(%s)", right)), typ, nil
+	case *InfixExpression:
+		left, leftType, err := c.compileExpression(exp.Left)
+		if err != nil {
+			return "", "", err
+		}
+		right, rightType, err := c.compileExpression(exp.Right)
+		if err != nil {
+			return "", "", err
+		}
+		return c.compileOperator(exp.Token.Type, left, leftType, right, rightType)
+	case *FunctionLiteral, *CallExpression:
+		return "", "", fmt.Errorf("c: burritos are not supported")
+	case *StringLiteral:
+		return "", "", fmt.Errorf("c: strings are not supported")
+	case *ArrayLiteral, *HashLiteral, *IndexExpression:
+		return "", "", fmt.Errorf("c: arrays and hashes are not supported")
+	case *TypeofExpression:
+		return "", "", fmt.Errorf("c: tacos is not supported")
+	case *NuggetsExpression:
+		// Booleans are already the int64_t values 1 and 0, so only the type changes.
+		value, _, err := c.compileExpression(exp.Value)
+		if err != nil {
+			return "", "", err
+		}
+		return value, INTEGER_OBJ, nil
+	case nil:
+		return "", "", fmt.Errorf("c: missing expression")
+	default:
+		return "", "", fmt.Errorf("c: unsupported expression %T", exp)
+	}
+}
+
+// cComparisons maps each comparison operator to its C spelling.
+var cComparisons = map[TokenType]string{
+	TOKEN_EQ:     "==",
+	TOKEN_NOT_EQ: "!=",
+	TOKEN_LT:     "<",
+	TOKEN_GT:     ">",
+	TOKEN_LT_EQ:  "<=",
+	TOKEN_GT_EQ:  ">=",
+}
+
+// compileOperator applies an infix operator to two operands and returns the temporary
+// holding the result together with its type.
+func (c *cCompiler) compileOperator(operator TokenType, left string, leftType ObjectType, right string, rightType ObjectType) (string, ObjectType, error) {
+	typ, err := infixResultType(operator, leftType, rightType)
+	if err != nil {
+		return "", "", fmt.Errorf("c: %s", err)
+	}
+
+	switch operator {
+	case TOKEN_APPLE:
+		return c.temp(fmt.Sprintf("goofy_add(%s, %s)", left, right)), typ, nil
+	case TOKEN_SALMON:
+		return c.temp(fmt.Sprintf("goofy_sub(%s, %s)", left, right)), typ, nil
+	case TOKEN_PANCAKES:
+		return c.temp(fmt.Sprintf("goofy_mul(%s, %s)", left, right)), typ, nil
+	case TOKEN_PIE:
+		return c.temp(fmt.Sprintf("goofy_div(%s, %s)", left, right)), typ, nil
+	case TOKEN_LEFTOVERS:
+		return c.temp(fmt.Sprintf("goofy_mod(%s, %s)", left, right)), typ, nil
+	default:
+		return c.temp(fmt.Sprintf("%s %s %s", left, cComparisons[operator], right)), typ, nil
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileC(t *testing.T) {
+	input := `
+    cheese x = 7;
+    cheese y = icaco;
+    cheese z = x apple y - 2;
+    pizza salmon z;
+    `
+
+	l := NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	code, err := CompileC(program)
+	if err != nil {
+		t.Fatalf("CompileC returned an error: %s", err)
+	}
+
+	expected := []string{
+		"int64_t v_x = 0;",
+		"const int64_t t1 = goofy_icaco();",
+		"v_y = t1;",
+		"goofy_add(v_x, v_y)",
+		"goofy_pizza(",
+	}
+	for _, want := range expected {
+		if !strings.Contains(code, want) {
+			t.Errorf("C output does not contain %q\n%s", want, code)
+		}
+	}
+}
+
+func TestCompileCUndefinedIdentifier(t *testing.T) {
+	l := NewLexer("pizza nope;")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err := CompileC(program)
+	if err == nil || !strings.Contains(err.Error(), "identifier not found: nope") {
+		t.Fatalf("expected an undefined identifier error. got=%v", err)
+	}
+
+	l = NewLexer("pizza burrito(x) { takeout x; }(1);")
+	p = NewParser(l)
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err = CompileC(program)
+	if err == nil || err.Error() != "c: burritos are not supported" {
+		t.Errorf("expected burritos to be rejected. got=%v", err)
+	}
+}
+
+func TestCompileCBooleans(t *testing.T) {
+	l := NewLexer("cheese b = icaco < 3; pizza !b; pizza cake != b; pizza 1 + cake;")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err := CompileC(program)
+	if err == nil || err.Error() != "c: type mismatch: nuggets + boolean" {
+		t.Fatalf("expected a type mismatch error. got=%v", err)
+	}
+
+	l = NewLexer("cheese nuggets n = nuggets(cake); n = n < 1;")
+	p = NewParser(l)
+	annotated := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err = CompileC(annotated)
+	if err == nil || err.Error() != "c: nuggets n cannot hold boolean" {
+		t.Fatalf("expected a nuggets error. got=%v", err)
+	}
+
+	program.Statements = program.Statements[:3]
+	code, err := CompileC(program)
+	if err != nil {
+		t.Fatalf("CompileC returned an error: %s", err)
+	}
+
+	expected := []string{
+		"const int64_t t2 = t1 < INT64_C(3);",
+		"const int64_t t3 = !v_b;",
+		"goofy_pizza_bool(t3);",
+		"const int64_t t4 = INT64_C(1) != v_b;",
+	}
+	for _, want := range expected {
+		if !strings.Contains(code, want) {
+			t.Errorf("C output does not contain %q\n%s", want, code)
+		}
+	}
+}
+
+func TestCompileCIfStatements(t *testing.T) {
+	l := NewLexer("cheese x = icaco; waffles x { cheese x = 2; pizza x; } fries waffles x > 1 { pizza cake; } fries { x = 1; }")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	code, err := CompileC(program)
+	if err != nil {
+		t.Fatalf("CompileC returned an error: %s", err)
+	}
+
+	expected := `    v_x = t1;
+    if (v_x) {
+        v_x_1 = INT64_C(2);
+        goofy_pizza(v_x_1);
+    } else {
+        const int64_t t2 = v_x > INT64_C(1);
+        if (t2) {
+            goofy_pizza_bool(INT64_C(1));
+        } else {
+            v_x = INT64_C(1);
+        }
+    }
+`
+	if !strings.Contains(code, expected) {
+		t.Errorf("C output does not contain\n%s\n%s", expected, code)
+	}
+}
+
+func TestCompileCLoops(t *testing.T) {
+	l := NewLexer("cheese n = icaco; noodles n > 0 { n salmon= 1; waffles n == 5 { seconds; } } donuts i = 1, n { waffles i > 3 { dessert; } pizza i; }")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	code, err := CompileC(program)
+	if err != nil {
+		t.Fatalf("CompileC returned an error: %s", err)
+	}
+
+	expected := `    for (;;) {
+        const int64_t t2 = v_n > INT64_C(0);
+        if (!t2) break;
+        const int64_t t3 = goofy_sub(v_n, INT64_C(1));
+        v_n = t3;
+        const int64_t t4 = v_n == INT64_C(5);
+        if (t4) {
+            continue;
+        }
+    }
+    const int64_t t5 = v_n;
+    for (int64_t t6 = INT64_C(1); t6 < t5; t6++) {
+        v_i_1 = t6;
+        const int64_t t7 = v_i_1 > INT64_C(3);
+        if (t7) {
+            break;
+        }
+        goofy_pizza(v_i_1);
+    }
+`
+	if !strings.Contains(code, expected) {
+		t.Errorf("C output does not contain\n%s\n%s", expected, code)
+	}
+
+	l = NewLexer("cheese x = 1; donuts i = 0, 3 { x = i > 1; }")
+	p = NewParser(l)
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err = CompileC(program)
+	if err == nil || err.Error() != "c: x holds INTEGER on one path and BOOLEAN on another" {
+		t.Errorf("expected a type error for x. got=%v", err)
+	}
+}
+
+func TestBuildCommand(t *testing.T) {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("no cc on PATH")
+	}
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "sum.goofy")
+	program := "cheese a = icaco; cheese b = icaco; pizza a apple b; a salmon= b; pizza a; pizza a * b % 5; pizza a pie b; pizza a > b; pizza !(a == b); waffles a < b { pizza 1; } fries waffles b { cheese a = 7; pizza a; } donuts i = 0, b { waffles i == 1 { seconds; } pizza i; } cheese n = 3; noodles cake { n salmon= 1; waffles n == 0 { dessert; } } pizza n;"
+	if err := os.WriteFile(source, []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runGoofy([]string{"build", source}, &stdout, &stderr); code != 0 {
+		t.Fatalf("goofy build exited with %d: %s", code, stderr.String())
+	}
+
+	exe := filepath.Join(dir, "sum")
+	if _, err := os.Stat(exe + ".c"); err != nil {
+		t.Fatalf("C file was not written: %s", err)
+	}
+
+	cmd := exec.Command(exe)
+	cmd.Stdin = strings.NewReader("40 2\n")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running the executable failed: %s", err)
+	}
+	if string(out) != "42\n38\n1\n19\ncake\ncake\n7\n0\n0\n" {
+		t.Errorf("wrong output. expected=%q, got=%q", "42\n38\n1\n19\ncake\ncake\n7\n0\n0\n", string(out))
+	}
+
+	for _, stdin := range []string{"9223372036854775807 1\n", "1 0\n"} {
+		cmd = exec.Command(exe)
+		cmd.Stdin = strings.NewReader(stdin)
+		if err := cmd.Run(); err == nil {
+			t.Errorf("expected the executable to fail for input %q", stdin)
+		}
+	}
+}
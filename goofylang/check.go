@@ -0,0 +1,640 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The checker looks for runtime errors that a program is bound to hit before it runs,
+// such as apple between a string and a burrito or a call with the wrong number of
+// arguments. It follows the type of every variable through the program the way the
+// compilers do, but it never rejects a program it is unsure about: whenever it cannot
+// tell what type a value has, it treats the value as unknown and checks nothing that
+// depends on it. Every error it reports carries the span of the source it is about and
+// uses the message the evaluator would raise at runtime.
+
+// Span is the stretch of source a node was parsed from. It starts at the first character
+// of the node and ends just past its last one.
+type Span struct {
+	Line, Column       int
+	EndLine, EndColumn int
+}
+
+// String formats the span as "line:column-line:column".
+func (s Span) String() string {
+	return fmt.Sprintf("%d:%d-%d:%d", s.Line, s.Column, s.EndLine, s.EndColumn)
+}
+
+// tokenSpan returns the span of a single token.
+func tokenSpan(tok Token) Span {
+	return Span{Line: tok.Line, Column: tok.Column, EndLine: tok.EndLine, EndColumn: tok.EndColumn}
+}
+
+// to returns a span from the start of s to the end of end. Nodes built by hand have no
+// positions, so an end without one leaves s as it is.
+func (s Span) to(end Span) Span {
+	if end.EndLine == 0 {
+		return s
+	}
+	s.EndLine, s.EndColumn = end.EndLine, end.EndColumn
+	return s
+}
+
+// spanOf returns the span of a node, from its first token to its last one.
+func spanOf(node Node) Span {
+	switch node := node.(type) {
+	case *Program:
+		if len(node.Statements) == 0 {
+			return Span{}
+		}
+		return spanOf(node.Statements[0]).to(spanOf(node.Statements[len(node.Statements)-1]))
+	case *LetStatement:
+		return tokenSpan(node.Token).to(spanOf(node.Value))
+	case *AssignStatement:
+		return spanOf(node.Name).to(spanOf(node.Value))
+	case *ExpressionStatement:
+		return spanOf(node.Expression)
+	case *BlockStatement:
+		return tokenSpan(node.Token).to(tokenSpan(node.Rbrace))
+	case *IfStatement:
+		if node.Alternative != nil {
+			return tokenSpan(node.Token).to(spanOf(node.Alternative))
+		}
+		return tokenSpan(node.Token).to(spanOf(node.Consequence))
+	case *WhileStatement:
+		return tokenSpan(node.Token).to(spanOf(node.Body))
+	case *ForStatement:
+		return tokenSpan(node.Token).to(spanOf(node.Body))
+	case *TypeSwitchStatement:
+		return tokenSpan(node.Token).to(tokenSpan(node.Rbrace))
+	case *ReturnStatement:
+		if node.Value == nil {
+			return tokenSpan(node.Token)
+		}
+		return tokenSpan(node.Token).to(spanOf(node.Value))
+	case *PrintStatement:
+		return tokenSpan(node.Token).to(spanOf(node.Value))
+	case *LoopControlStatement:
+		return tokenSpan(node.Token)
+	case *Identifier:
+		return tokenSpan(node.Token)
+	case *IntegralLiteral:
+		return tokenSpan(node.Token)
+	case *StringLiteral:
+		return tokenSpan(node.Token)
+	case *BooleanLiteral:
+		return tokenSpan(node.Token)
+	case *InputExpression:
+		return tokenSpan(node.Token)
+	case *PrefixExpression:
+		return tokenSpan(node.Token).to(spanOf(node.Right))
+	case *InfixExpression:
+		return spanOf(node.Left).to(spanOf(node.Right))
+	case *TypeofExpression:
+		return tokenSpan(node.Token).to(spanOf(node.Right))
+	case *NuggetsExpression:
+		return tokenSpan(node.Token).to(tokenSpan(node.Rparen))
+	case *FunctionLiteral:
+		return tokenSpan(node.Token).to(spanOf(node.Body))
+	case *CallExpression:
+		return spanOf(node.Function).to(tokenSpan(node.Rparen))
+	case *ArrayLiteral:
+		return tokenSpan(node.Token).to(tokenSpan(node.Rbracket))
+	case *HashLiteral:
+		return tokenSpan(node.Token).to(tokenSpan(node.Rbrace))
+	case *IndexExpression:
+		return spanOf(node.Left).to(tokenSpan(node.Rbracket))
+	default:
+		return Span{}
+	}
+}
+
+// Diagnostic is a problem found in a program, with the span of the source it is about.
+type Diagnostic struct {
+	Span    Span
+	Message string
+	Related []Diagnostic // Other places in the source that explain the problem.
+}
+
+// String formats the diagnostic as "line:column-line:column: message", followed by the
+// related places in parentheses.
+func (d Diagnostic) String() string {
+	out := d.Span.String() + ": " + d.Message
+	if len(d.Related) > 0 {
+		related := make([]string, len(d.Related))
+		for i, r := range d.Related {
+			related[i] = r.String()
+		}
+		out += " (" + strings.Join(related, "; ") + ")"
+	}
+	return out
+}
+
+// checkType is what the checker knows about the type of a value.
+type checkType struct {
+	object ObjectType      // The type of the value, or "" if it is unknown.
+	fn     *checkSignature // For a burrito or builtin, its signature if it is known.
+}
+
+// checkSignature describes how a function can be called.
+type checkSignature struct {
+	arity  int
+	result checkType // The type of the value a call returns.
+}
+
+// unknown is the type of a value the checker cannot tell anything about.
+var unknown = checkType{}
+
+// known returns the type of values of type typ.
+func known(typ ObjectType) checkType {
+	return checkType{object: typ}
+}
+
+// same reports whether a and b describe the same type.
+func (a checkType) same(b checkType) bool {
+	if a.object != b.object || (a.fn == nil) != (b.fn == nil) {
+		return false
+	}
+	return a.fn == nil || (a.fn.arity == b.fn.arity && a.fn.result.same(b.fn.result))
+}
+
+// join returns what is known of a value that has type a on one path through the program
+// and type b on another.
+func (a checkType) join(b checkType) checkType {
+	switch {
+	case a.same(b):
+		return a
+	case a.object == b.object:
+		// Two different functions: still a function, but calls cannot be checked.
+		return known(a.object)
+	default:
+		return unknown
+	}
+}
+
+// builtinSignatures gives the signature of every builtin.
+var builtinSignatures = map[string]*checkSignature{
+	"len":  {arity: 1, result: known(INTEGER_OBJ)},
+	"push": {arity: 2, result: known(ARRAY_OBJ)},
+	"keys": {arity: 1, result: known(ARRAY_OBJ)},
+}
+
+// checkVar is a variable as the checker sees it.
+type checkVar struct {
+	name  string
+	typ   checkType  // The type of the value it holds at the current point of the program.
+	fixed ObjectType // The type it was declared with, as in cheese nuggets x, or "".
+}
+
+// checkScope holds the variables declared in one scope.
+type checkScope struct {
+	vars     map[string]*checkVar
+	outer    *checkScope
+	function bool // Whether this is the outermost scope of a burrito body.
+}
+
+// lookup finds the innermost binding of name. A variable from outside the burrito being
+// checked can change between the moment the burrito is created and every moment it is
+// called, so it is returned as a fresh variable of unknown type.
+func (s *checkScope) lookup(name string) (*checkVar, bool) {
+	captured := false
+	for scope := s; scope != nil; scope = scope.outer {
+		if v, ok := scope.vars[name]; ok {
+			if captured {
+				return &checkVar{name: name}, true
+			}
+			return v, true
+		}
+		captured = captured || scope.function
+	}
+	return nil, false
+}
+
+// checkSnapshot records the type every variable visible from a scope holds at some point.
+type checkSnapshot map[*checkVar]checkType
+
+// snapshot returns the types every variable of the burrito being checked, or of the
+// top level, currently holds.
+func (s *checkScope) snapshot() checkSnapshot {
+	snap := checkSnapshot{}
+	for scope := s; scope != nil; scope = scope.outer {
+		for _, v := range scope.vars {
+			if _, shadowed := snap[v]; !shadowed {
+				snap[v] = v.typ
+			}
+		}
+		if scope.function {
+			break
+		}
+	}
+	return snap
+}
+
+// restore puts the types recorded in snap back.
+func (snap checkSnapshot) restore() {
+	for v, typ := range snap {
+		v.typ = typ
+	}
+}
+
+// joinSnapshots returns what is known of every variable in a after control has come
+// from either a or b.
+func joinSnapshots(a, b checkSnapshot) checkSnapshot {
+	joined := checkSnapshot{}
+	for v, typ := range a {
+		if other, ok := b[v]; ok {
+			joined[v] = typ.join(other)
+		} else {
+			joined[v] = typ
+		}
+	}
+	return joined
+}
+
+// Checker finds type errors in a program without running it.
+type Checker struct {
+	scope       *checkScope
+	diagnostics []Diagnostic
+	quiet       int              // While above zero, errors are not reported.
+	escaped     map[string]bool  // Names assigned inside some burrito, see Check.
+	exits       *[]checkSnapshot // The types at each dessert and seconds of the innermost loop.
+	returns     *[]checkType     // The type of each takeout of the innermost burrito.
+}
+
+// NewChecker creates a Checker.
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Check checks a whole program and returns the diagnostics found, which Errors also
+// returns formatted.
+func (c *Checker) Check(program *Program) []Diagnostic {
+	// A burrito that assigns to a variable it captured can change that variable whenever
+	// it is called, which may be in the middle of any expression, so variables with the
+	// name of one are never assumed to keep the type they were given.
+	c.escaped = map[string]bool{}
+	walkAST(program, func(node Node) {
+		if fn, ok := node.(*FunctionLiteral); ok {
+			walkAST(fn.Body, func(node Node) {
+				if assign, ok := node.(*AssignStatement); ok {
+					c.escaped[assign.Name.Value] = true
+				}
+			})
+		}
+	})
+
+	c.scope = &checkScope{vars: map[string]*checkVar{}}
+	c.checkStatements(program.Statements)
+	return c.diagnostics
+}
+
+// Errors returns every error found, formatted like the ones Parser.Errors returns but
+// starting with the span they are about.
+func (c *Checker) Errors() []string {
+	errs := make([]string, len(c.diagnostics))
+	for i, d := range c.diagnostics {
+		errs[i] = d.String()
+	}
+	return errs
+}
+
+// errorAt reports an error about node.
+func (c *Checker) errorAt(node Node, format string, a ...interface{}) {
+	if c.quiet > 0 {
+		return
+	}
+	c.diagnostics = append(c.diagnostics, Diagnostic{Span: spanOf(node), Message: fmt.Sprintf(format, a...)})
+}
+
+// enter opens a scope nested in the current one.
+func (c *Checker) enter() {
+	c.scope = &checkScope{vars: map[string]*checkVar{}, outer: c.scope}
+}
+
+// leave closes the scope opened by the matching enter.
+func (c *Checker) leave() {
+	c.scope = c.scope.outer
+}
+
+// set gives v a value of type typ, after checking it against v's declared type.
+func (c *Checker) set(v *checkVar, typ checkType, node Node) {
+	if v.fixed != "" {
+		if typ.object != "" && typ.object != v.fixed {
+			c.errorAt(node, "%s %s cannot hold %s", typeNames[v.fixed], v.name, typeNameOf(typ.object))
+		}
+		// Whatever happens, the variable never holds anything else while the program runs.
+		v.typ = known(v.fixed)
+		return
+	}
+	if c.escaped[v.name] {
+		typ = unknown
+	}
+	v.typ = typ
+}
+
+func (c *Checker) checkStatements(stmts []Statement) {
+	for _, stmt := range stmts {
+		c.checkStatement(stmt)
+	}
+}
+
+func (c *Checker) checkBlock(block *BlockStatement) {
+	if block == nil {
+		return
+	}
+	c.enter()
+	c.checkStatements(block.Statements)
+	c.leave()
+}
+
+func (c *Checker) checkStatement(stmt Statement) {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		typ := c.checkExpression(stmt.Value)
+		v := &checkVar{name: stmt.Name.Value}
+		if fixed, ok := stmt.AnnotatedType(); ok {
+			v.fixed = fixed
+		}
+		c.scope.vars[v.name] = v
+		c.set(v, typ, stmt.Name)
+	case *AssignStatement:
+		typ := c.checkExpression(stmt.Value)
+		v, ok := c.scope.lookup(stmt.Name.Value)
+		if !ok {
+			return
+		}
+		if operator, ok := stmt.Operator(); ok {
+			typ = c.infixType(operator, v.typ, typ, stmt)
+		}
+		c.set(v, typ, stmt.Name)
+	case *ExpressionStatement:
+		c.checkExpression(stmt.Expression)
+	case *PrintStatement:
+		c.checkExpression(stmt.Value)
+	case *BlockStatement:
+		c.checkBlock(stmt)
+	case *IfStatement:
+		c.checkExpression(stmt.Condition)
+		before := c.scope.snapshot()
+		c.checkBlock(stmt.Consequence)
+		afterThen := c.scope.snapshot()
+		before.restore()
+		if stmt.Alternative != nil {
+			c.checkStatement(stmt.Alternative)
+		}
+		joinSnapshots(afterThen, c.scope.snapshot()).restore()
+	case *TypeSwitchStatement:
+		c.checkExpression(stmt.Subject)
+		before := c.scope.snapshot()
+		after := before
+		if stmt.Default != nil {
+			c.checkBlock(stmt.Default)
+			after = c.scope.snapshot()
+		}
+		for _, tc := range stmt.Cases {
+			before.restore()
+			c.checkBlock(tc.Body)
+			after = joinSnapshots(after, c.scope.snapshot())
+		}
+		after.restore()
+	case *WhileStatement:
+		c.checkLoop(func() {
+			c.checkExpression(stmt.Condition)
+			c.checkBlock(stmt.Body)
+		})
+	case *ForStatement:
+		for _, bound := range []struct {
+			exp  Expression
+			name string
+		}{{stmt.Start, "start"}, {stmt.End, "end"}} {
+			if typ := c.checkExpression(bound.exp); typ.object != "" && typ.object != INTEGER_OBJ {
+				c.errorAt(bound.exp, "donuts expected an integer %s, got %s", bound.name, typeNameOf(typ.object))
+			}
+		}
+		c.checkLoop(func() {
+			c.enter()
+			v := &checkVar{name: stmt.Variable.Value}
+			c.scope.vars[v.name] = v
+			c.set(v, known(INTEGER_OBJ), stmt.Variable)
+			c.checkBlock(stmt.Body)
+			c.leave()
+		})
+	case *LoopControlStatement:
+		if c.exits != nil {
+			*c.exits = append(*c.exits, c.scope.snapshot())
+		}
+	case *ReturnStatement:
+		typ := known(NULL_OBJ)
+		if stmt.Value != nil {
+			typ = c.checkExpression(stmt.Value)
+		}
+		if c.returns != nil {
+			*c.returns = append(*c.returns, typ)
+		}
+	}
+}
+
+// checkLoop checks a loop whose condition and body body checks. The body may run any
+// number of times, so it is first checked quietly, again and again, forgetting the type
+// of every variable that changes, until the types stop changing. Then it is checked once
+// more, reporting errors, with the types that hold in every iteration.
+func (c *Checker) checkLoop(body func()) {
+	outerExits := c.exits
+	defer func() { c.exits = outerExits }()
+
+	c.quiet++
+	for {
+		before := c.scope.snapshot()
+		exits := []checkSnapshot{}
+		c.exits = &exits
+		body()
+
+		after := joinSnapshots(before, c.scope.snapshot())
+		for _, exit := range exits {
+			after = joinSnapshots(after, exit)
+		}
+		after.restore()
+
+		stable := true
+		for v, typ := range after {
+			if !typ.same(before[v]) {
+				stable = false
+			}
+		}
+		if stable {
+			break
+		}
+	}
+	c.quiet--
+
+	before := c.scope.snapshot()
+	exits := []checkSnapshot{}
+	c.exits = &exits
+	body()
+	before.restore()
+}
+
+// checkExpression checks an expression and returns the type of its value.
+func (c *Checker) checkExpression(exp Expression) checkType {
+	switch exp := exp.(type) {
+	case *IntegralLiteral, *InputExpression:
+		return known(INTEGER_OBJ)
+	case *StringLiteral:
+		return known(STRING_OBJ)
+	case *BooleanLiteral:
+		return known(BOOLEAN_OBJ)
+	case *Identifier:
+		if v, ok := c.scope.lookup(exp.Value); ok {
+			return v.typ
+		}
+		if sig, ok := builtinSignatures[exp.Value]; ok {
+			return checkType{object: BUILTIN_OBJ, fn: sig}
+		}
+		return unknown
+	case *PrefixExpression:
+		right := c.checkExpression(exp.Right)
+		if right.object == "" {
+			return unknown
+		}
+		typ, err := prefixResultType(exp.Token.Type, right.object)
+		if err != nil {
+			c.errorAt(exp, "%s", err)
+			return unknown
+		}
+		return known(typ)
+	case *InfixExpression:
+		left := c.checkExpression(exp.Left)
+		right := c.checkExpression(exp.Right)
+		return c.infixType(exp.Token.Type, left, right, exp)
+	case *TypeofExpression:
+		c.checkExpression(exp.Right)
+		return known(STRING_OBJ)
+	case *NuggetsExpression:
+		switch typ := c.checkExpression(exp.Value).object; typ {
+		case "", INTEGER_OBJ, BOOLEAN_OBJ:
+		case STRING_OBJ:
+			if str, ok := exp.Value.(*StringLiteral); ok {
+				if _, err := strconv.ParseInt(str.Value, 10, 64); err != nil {
+					c.errorAt(exp, "cannot convert %s to nuggets", quoteString(str.Value))
+				}
+			}
+		default:
+			c.errorAt(exp, "cannot convert %s to nuggets", typeNameOf(typ))
+		}
+		return known(INTEGER_OBJ)
+	case *ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.checkExpression(el)
+		}
+		return known(ARRAY_OBJ)
+	case *HashLiteral:
+		for i, key := range exp.Keys {
+			if typ := c.checkExpression(key).object; !isHashableType(typ) {
+				c.errorAt(key, "unusable as hash key: %s", typeNameOf(typ))
+			}
+			c.checkExpression(exp.Values[i])
+		}
+		return known(HASH_OBJ)
+	case *IndexExpression:
+		left := c.checkExpression(exp.Left).object
+		index := c.checkExpression(exp.Index).object
+		switch {
+		case left == "" || index == "":
+		case left == ARRAY_OBJ:
+			if index != INTEGER_OBJ {
+				c.errorAt(exp, "array index must be nuggets, got %s", typeNameOf(index))
+			}
+		case left == HASH_OBJ:
+			if !isHashableType(index) {
+				c.errorAt(exp, "unusable as hash key: %s", typeNameOf(index))
+			}
+		default:
+			c.errorAt(exp, "index operator not supported: %s", typeNameOf(left))
+		}
+		return unknown
+	case *FunctionLiteral:
+		return c.checkFunction(exp)
+	case *CallExpression:
+		return c.checkCall(exp)
+	default:
+		return unknown
+	}
+}
+
+// isHashableType reports whether values of type typ can be hash keys. Unknown types
+// are assumed to be.
+func isHashableType(typ ObjectType) bool {
+	switch typ {
+	case "", INTEGER_OBJ, BOOLEAN_OBJ, STRING_OBJ:
+		return true
+	default:
+		return false
+	}
+}
+
+// infixType returns the type of the value an infix operator produces, reporting an
+// error about node if it cannot apply to operands of these types.
+func (c *Checker) infixType(operator TokenType, left, right checkType, node Node) checkType {
+	if left.object == "" || right.object == "" {
+		return unknown
+	}
+	typ, err := infixResultType(operator, left.object, right.object)
+	if err != nil {
+		c.errorAt(node, "%s", err)
+		return unknown
+	}
+	return known(typ)
+}
+
+// checkFunction checks the body of a burrito and returns its type. The parameters and
+// every captured variable are of unknown type, and a call returns whatever all of its
+// takeouts, and falling off the end of the body, have in common.
+func (c *Checker) checkFunction(fn *FunctionLiteral) checkType {
+	outerScope, outerExits, outerReturns := c.scope, c.exits, c.returns
+	defer func() { c.scope, c.exits, c.returns = outerScope, outerExits, outerReturns }()
+
+	c.scope = &checkScope{vars: map[string]*checkVar{}, outer: outerScope, function: true}
+	for _, param := range fn.Parameters {
+		c.scope.vars[param.Value] = &checkVar{name: param.Value}
+	}
+	returns := []checkType{}
+	c.exits, c.returns = nil, &returns
+	c.checkStatements(fn.Body.Statements)
+
+	n := len(fn.Body.Statements)
+	if n == 0 {
+		returns = append(returns, known(NULL_OBJ))
+	} else if _, ok := fn.Body.Statements[n-1].(*ReturnStatement); !ok {
+		returns = append(returns, known(NULL_OBJ))
+	}
+	result := returns[0]
+	for _, typ := range returns[1:] {
+		result = result.join(typ)
+	}
+
+	return checkType{object: FUNCTION_OBJ, fn: &checkSignature{arity: len(fn.Parameters), result: result}}
+}
+
+// checkCall checks a call and returns the type of its result.
+func (c *Checker) checkCall(call *CallExpression) checkType {
+	callee := c.checkExpression(call.Function)
+	for _, arg := range call.Arguments {
+		c.checkExpression(arg)
+	}
+
+	switch {
+	case callee.object == "":
+		return unknown
+	case callee.object != FUNCTION_OBJ && callee.object != BUILTIN_OBJ:
+		c.errorAt(call, "not a function: %s", typeNameOf(callee.object))
+		return unknown
+	case callee.fn == nil:
+		return unknown
+	case callee.fn.arity != len(call.Arguments):
+		c.errorAt(call, "wrong number of arguments: want=%d, got=%d", callee.fn.arity, len(call.Arguments))
+		return unknown
+	default:
+		return callee.fn.result
+	}
+}
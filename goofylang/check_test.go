@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func checkProgram(t *testing.T, input string) []string {
+	t.Helper()
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parser errors for %q: %v", input, errs)
+	}
+	c := NewChecker()
+	c.Check(program)
+	return c.Errors()
+}
+
+func TestChecker(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`cheese f = burrito() { takeout 1; }; pizza "a" apple f;`, []string{"1:44-1:55: type mismatch: string + function"}},
+		{`pizza len apple "a";`, []string{"1:7-1:20: type mismatch: function + string"}},
+		{"cheese x = 5; x(1);", []string{"1:15-1:19: not a function: nuggets"}},
+		{`"a"();`, []string{"1:1-1:6: not a function: string"}},
+		{"cheese add = burrito(a, b) { takeout a + b; }; add(1);", []string{"1:48-1:54: wrong number of arguments: want=2, got=1"}},
+		{"pizza push([1]);", []string{"1:7-1:16: wrong number of arguments: want=2, got=1"}},
+		{`cheese nuggets x = "5";`, []string{"1:16-1:17: nuggets x cannot hold string"}},
+		{"cheese nuggets x = 5; x = cake;", []string{"1:23-1:24: nuggets x cannot hold boolean"}},
+		{`cheese nuggets x = 5; waffles icaco { x = "a"; }`, []string{"1:39-1:40: nuggets x cannot hold string"}},
+		{"pizza -cake;", []string{"1:7-1:12: unknown operator: -boolean"}},
+		{"pizza cake < broccoli;", []string{"1:7-1:22: unknown operator: boolean < boolean"}},
+		{"pizza [1][cake];", []string{"1:7-1:16: array index must be nuggets, got boolean"}},
+		{"pizza 5[0];", []string{"1:7-1:11: index operator not supported: nuggets"}},
+		{"pizza ({[1]: 2});", []string{"1:9-1:12: unusable as hash key: array"}},
+		{`pizza nuggets("x");`, []string{`1:7-1:19: cannot convert "x" to nuggets`}},
+		{"donuts i = 0, cake { pizza i; }", []string{"1:15-1:19: donuts expected an integer end, got boolean"}},
+		// Results of calls are known when every takeout agrees.
+		{"cheese f = burrito() { takeout 1; }; pizza f() apple cake;", []string{"1:44-1:58: type mismatch: nuggets + boolean"}},
+		{"cheese f = burrito() { pizza 1; }; pizza f() apple 1;", []string{"1:42-1:53: type mismatch: nothing + nuggets"}},
+		{"cheese f = burrito() { takeout burrito(a) { takeout a; }; }; f()();", []string{"1:62-1:67: wrong number of arguments: want=1, got=0"}},
+		// Several errors are all reported, across lines.
+		{"cheese s = \"a\";\ns + 1;\ns();", []string{"2:1-2:6: type mismatch: string + nuggets", "3:1-3:4: not a function: string"}},
+		// A loop is checked with the types that hold in every iteration.
+		{"cheese x = 1; noodles icaco { x(); }", []string{"1:31-1:34: not a function: nuggets"}},
+		{"cheese x = 1; noodles icaco { x + 1; x = \"a\"; }", nil},
+		{"cheese x = 1; noodles icaco { x = \"a\"; dessert; } pizza x + 1;", nil},
+		{"cheese x = 1; donuts i = 0, 3 { x = i apple x; } pizza x(1);", []string{"1:56-1:60: not a function: nuggets"}},
+		// A variable without a declared type can be given a value of any type.
+		{"cheese x = 1; x = \"a\"; pizza x apple \"b\";", nil},
+		{"cheese x = 1; x = \"a\"; pizza x + 1;", []string{"1:30-1:35: type mismatch: string + nuggets"}},
+		// Nothing is reported where the checker cannot tell.
+		{"cheese x = 1; waffles icaco { x = \"a\"; } pizza x + 1;", nil},
+		{"cheese x = 1; tacos x { nuggets { x = cake; } } pizza x + 1;", nil},
+		{"cheese f = burrito(a) { takeout a + 1; }; pizza f(cake);", nil},
+		{"cheese x = 1; cheese f = burrito() { x = \"a\"; }; f(); pizza x apple \"b\";", nil},
+		{"cheese x = 1; cheese f = burrito() { takeout x(); };", nil},
+		{"cheese f = burrito(n) { waffles n { takeout 1; } takeout cake; }; pizza f(1) + 1;", nil},
+		{"cheese len = 5; pizza len + 1;", nil},
+		{"pizza nope(1) + [1][0];", nil},
+		{`cheese nuggets x = nuggets("5"); x += 1; cheese x = "a"; x = "b";`, nil},
+	}
+
+	for _, tt := range tests {
+		errs := checkProgram(t, tt.input)
+		if len(errs) == 0 {
+			errs = nil
+		}
+		if !reflect.DeepEqual(errs, tt.expected) {
+			t.Errorf("wrong errors for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestSpanOf(t *testing.T) {
+	input := "cheese a = [1, 2];\npizza f(a[0], {\"k\": a}) apple nuggets(cake);"
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parser errors: %v", errs)
+	}
+
+	tests := []struct {
+		node     Node
+		expected string
+	}{
+		{program, "1:1-2:44"},
+		{program.Statements[0], "1:1-1:18"},
+		{program.Statements[0].(*LetStatement).Value, "1:12-1:18"},
+		{program.Statements[1].(*PrintStatement).Value, "2:7-2:44"},
+	}
+	call := program.Statements[1].(*PrintStatement).Value.(*InfixExpression).Left.(*CallExpression)
+	tests = append(tests, []struct {
+		node     Node
+		expected string
+	}{
+		{call, "2:7-2:24"},
+		{call.Arguments[0], "2:9-2:13"},
+		{call.Arguments[1], "2:15-2:23"},
+	}...)
+
+	for _, tt := range tests {
+		if got := spanOf(tt.node).String(); got != tt.expected {
+			t.Errorf("wrong span for %s. expected=%s, got=%s", tt.node, tt.expected, got)
+		}
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.goofy")
+	bad := filepath.Join(dir, "bad.goofy")
+	if err := os.WriteFile(good, []byte("cheese x = 1; pizza x + 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("cheese x = 1;\npizza x();"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkCommand([]string{good}); err != nil {
+		t.Errorf("check of a correct program failed: %s", err)
+	}
+
+	err := checkCommand([]string{bad})
+	if err == nil {
+		t.Fatalf("check of a wrong program succeeded")
+	}
+	if expected := bad + ": 2:7-2:10: not a function: nuggets"; err.Error() != expected {
+		t.Errorf("wrong error.\nexpected=%q\ngot=%q", expected, err.Error())
+	}
+	if !strings.Contains(goofyUsage, "check file.goofy") {
+		t.Errorf("usage does not mention check")
+	}
+}
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// goofyUsage is printed when the command line does not name a known subcommand.
+const goofyUsage = `usage: goofy <command> [arguments]
+
+commands:
+  run [-O level] file.goofy                      run a program with the interpreter
+  repl                                           read and run lines interactively
+  build [-O level] [-o output] [-c] file.goofy   compile to C and, if cc is on PATH, to a native executable
+  wat [-O level] file.goofy                      print the WebAssembly text module for a program
+  ssa [-O level] file.goofy                      print the verified SSA form of a program
+  check file.goofy                               report undefined names and type errors without running the program
+  types file.goofy                               print the inferred type of every cheese binding
+  lint [-config file] [-format text|json] file.goofy...
+                                                 report suspicious code, as text or as a SARIF log
+  fmt [-l] [-w] [-d] [file.goofy...]             print programs in the canonical layout, reading stdin without files
+  lsp                                            serve the Language Server Protocol on stdin and stdout
+  dap                                            serve the Debug Adapter Protocol on stdin and stdout
+
+optimization levels: 0 none, 1 constant folding, 2 also constant propagation and dead-store elimination
+`
+
+func main() {
+	os.Exit(runGoofy(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// runGoofy dispatches a goofy subcommand and returns the process exit code.
+func runGoofy(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, goofyUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "run":
+		err = runCommand(args[1:], os.Stdin, stdout, stderr)
+	case "repl":
+		err = replCommand(os.Stdin, stdout)
+	case "build":
+		err = buildCommand(args[1:], stdout, stderr)
+	case "wat":
+		err = watCommand(args[1:], stdout, stderr)
+	case "ssa":
+		err = ssaCommand(args[1:], stdout, stderr)
+	case "check":
+		err = checkCommand(args[1:])
+	case "types":
+		err = typesCommand(args[1:], stdout)
+	case "lint":
+		err = lintCommand(args[1:], stdout, stderr)
+	case "fmt":
+		err = fmtCommand(args[1:], os.Stdin, stdout, stderr)
+	case "lsp":
+		err = NewLSPServer(os.Stdin, stdout).Serve()
+	case "dap":
+		err = NewDAPServer(os.Stdin, stdout).Serve()
+	default:
+		fmt.Fprintf(stderr, "goofy: unknown command %q\n\n%s", args[0], goofyUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "goofy: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseFile reads and parses a goofylang source file, turning parser errors into one error.
+func parseFile(path string) (*Program, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewParser(NewLexer(string(source)))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fileErrors(path, errs)
+	}
+	return program, nil
+}
+
+// fileErrors turns the errors found in a source file into one error.
+func fileErrors(path string, errs []string) error {
+	return fmt.Errorf("%s: %s", path, strings.Join(errs, "\n\t"))
+}
+
+// optLevelFlag registers the -O flag shared by every command that takes a program.
+func optLevelFlag(flags *flag.FlagSet) *int {
+	return flags.Int("O", OptNone, "optimization level (0, 1 or 2)")
+}
+
+// runCommand implements "goofy run".
+func runCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	level := optLevelFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("run expects exactly one file")
+	}
+
+	program, err := parseFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	r := NewResolver(nil)
+	r.Resolve(program)
+	if errs := r.Errors(); len(errs) > 0 {
+		return fileErrors(flags.Arg(0), errs)
+	}
+
+	// The optimizer rebuilds the program, so the result is resolved again for the evaluator.
+	program = Optimize(program, *level)
+	NewResolver(nil).Resolve(program)
+
+	result := NewEvaluator(stdin, stdout).Eval(program, NewEnvironment())
+	if errObj, ok := result.(*Error); ok {
+		return fmt.Errorf("%s", errObj.Message)
+	}
+	return nil
+}
+
+// replPrompt is printed before every line the REPL reads.
+const replPrompt = ">> "
+
+// replContinuePrompt is printed instead while a block is still open.
+const replContinuePrompt = ".. "
+
+// openBraces returns how many more { than } the source contains.
+func openBraces(source string) int {
+	depth := 0
+	l := NewLexer(source)
+	for tok := l.NextToken(); tok.Type != TOKEN_EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case TOKEN_LBRACE:
+			depth++
+		case TOKEN_RBRACE:
+			depth--
+		}
+	}
+	return depth
+}
+
+// replCommand implements "goofy repl". Every line runs in one shared environment, and
+// the value of the last expression statement on the line is printed.
+func replCommand(stdin io.Reader, stdout io.Writer) error {
+	// The evaluator reuses this reader for icaco, so input lines and icaco reads interleave.
+	in := bufio.NewReader(stdin)
+	evaluator := NewEvaluator(in, stdout)
+	env := NewEnvironment()
+
+	for {
+		fmt.Fprint(stdout, replPrompt)
+		line, err := in.ReadString('\n')
+
+		// Keep reading while a block is open, so it can be spread over several lines.
+		for err == nil && openBraces(line) > 0 {
+			fmt.Fprint(stdout, replContinuePrompt)
+			var more string
+			more, err = in.ReadString('\n')
+			line += more
+		}
+
+		if line != "" {
+			p := NewParser(NewLexer(line))
+			program := p.ParseProgram()
+			if errs := p.Errors(); len(errs) > 0 {
+				for _, msg := range errs {
+					fmt.Fprintf(stdout, "parse error: %s\n", msg)
+				}
+			} else {
+				// A later line may still declare the names this one uses, so they are left
+				// for the evaluator to report instead of the resolver.
+				NewResolver(env).Resolve(program)
+				if result := evaluator.Eval(program, env); result != nil {
+					fmt.Fprintln(stdout, result.Inspect())
+				}
+			}
+		}
+
+		if err == io.EOF {
+			fmt.Fprintln(stdout)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkCommand implements "goofy check", which reports the errors of both the resolver and
+// the checker. It prints nothing for a program without any.
+func checkCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("check expects exactly one file")
+	}
+
+	program, err := parseFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	r := NewResolver(nil)
+	r.Resolve(program)
+	c := NewChecker()
+	c.Check(program)
+	if errs := append(r.Errors(), c.Errors()...); len(errs) > 0 {
+		return fileErrors(args[0], errs)
+	}
+	return nil
+}
+
+// typesCommand implements "goofy types". It prints one line per cheese binding, in
+// source order. A binding with a unification error inside it is left out, and the
+// errors are returned once the other bindings are printed.
+func typesCommand(args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("types expects exactly one file")
+	}
+
+	program, err := parseFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	in := NewInferrer()
+	in.Infer(program)
+	for _, b := range in.Bindings() {
+		if !in.Failed(b) {
+			fmt.Fprintln(stdout, b)
+		}
+	}
+	if errs := in.Errors(); len(errs) > 0 {
+		return fileErrors(args[0], errs)
+	}
+	return nil
+}
+
+// fmtCommand implements "goofy fmt". Like gofmt, it prints the formatted source unless
+// -l or -w is given, and -d prints a diff instead.
+func fmtCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	write := flags.Bool("w", false, "write the result back to the file instead of printing it")
+	diff := flags.Bool("d", false, "print a diff of the changes instead of the result")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			return fmt.Errorf("cannot use -w with standard input")
+		}
+		source, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		return formatSource("<standard input>", string(source), stdout, *list, false, *diff)
+	}
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := formatSource(path, string(source), stdout, *list, *write, *diff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatSource formats the source read from path and reports the result as the fmt
+// flags ask.
+func formatSource(path, source string, stdout io.Writer, list, write, diff bool) error {
+	formatted, err := Format(source)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	if list && formatted != source {
+		fmt.Fprintln(stdout, path)
+	}
+	if write && formatted != source {
+		if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+			return err
+		}
+	}
+	if diff {
+		io.WriteString(stdout, unifiedDiff(path+".orig", path, source, formatted))
+	}
+	if !list && !write && !diff {
+		io.WriteString(stdout, formatted)
+	}
+	return nil
+}
+
+// lintConfigFile is the config file lint uses when -config is not given, if there is one
+// in the directory of the file being linted.
+const lintConfigFile = "goofylint.json"
+
+// lintCommand implements "goofy lint". It fails when any file has findings, so scripts
+// can tell a clean program from one with problems.
+func lintCommand(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", "", "config file enabling and disabling rules (defaults to "+lintConfigFile+" next to each file)")
+	format := flags.String("format", "text", "output format: text or json (SARIF)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("lint expects at least one file")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown lint format %q", *format)
+	}
+
+	var rules []LintRule
+	findings := map[string][]LintFinding{}
+	count := 0
+	for _, path := range flags.Args() {
+		linter := NewLinter()
+		config, err := lintConfig(*configPath, path)
+		if err != nil {
+			return err
+		}
+		if err := linter.Configure(config); err != nil {
+			return err
+		}
+		rules = linter.Rules()
+
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		p := NewParser(NewLexer(string(source)))
+		program := p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			return fileErrors(path, errs)
+		}
+		findings[path] = linter.Lint(program, p.Comments())
+		count += len(findings[path])
+	}
+
+	if *format == "json" {
+		if err := writeSARIF(stdout, rules, flags.Args(), findings); err != nil {
+			return err
+		}
+	} else {
+		for _, path := range flags.Args() {
+			for _, f := range findings[path] {
+				fmt.Fprintf(stdout, "%s:%s\n", path, f)
+			}
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("%d lint findings", count)
+	}
+	return nil
+}
+
+// lintConfig loads the config file at path, or else the default one next to file if it
+// exists. Without either, every rule is enabled.
+func lintConfig(path, file string) (LintConfig, error) {
+	if path != "" {
+		return LoadLintConfig(path)
+	}
+	path = filepath.Join(filepath.Dir(file), lintConfigFile)
+	if _, err := os.Stat(path); err != nil {
+		return LintConfig{}, nil
+	}
+	return LoadLintConfig(path)
+}
+
+// watCommand implements "goofy wat".
+func watCommand(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("wat", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	level := optLevelFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("wat expects exactly one file")
+	}
+
+	program, err := parseFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	program = Optimize(program, *level)
+
+	wat, err := CompileWAT(program)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(stdout, wat)
+	return err
+}
+
+// ssaCommand implements "goofy ssa".
+func ssaCommand(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("ssa", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	level := optLevelFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("ssa expects exactly one file")
+	}
+
+	program, err := parseFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fn, err := BuildSSA(Optimize(program, *level))
+	if err != nil {
+		return err
+	}
+	if err := VerifySSA(fn); err != nil {
+		return err
+	}
+	_, err = io.WriteString(stdout, fn.String())
+	return err
+}
+
+// buildCommand implements "goofy build". It always writes the generated C file next to the
+// output and then hands it to cc unless -c was given or no compiler can be found.
+func buildCommand(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	level := optLevelFlag(flags)
+	output := flags.String("o", "", "name of the executable (defaults to the source name without .goofy)")
+	cOnly := flags.Bool("c", false, "only write the C file, do not invoke cc")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("build expects exactly one file")
+	}
+
+	source := flags.Arg(0)
+	program, err := parseFile(source)
+	if err != nil {
+		return err
+	}
+
+	code, err := CompileC(Optimize(program, *level))
+	if err != nil {
+		return err
+	}
+
+	exe := *output
+	if exe == "" {
+		exe = strings.TrimSuffix(source, filepath.Ext(source))
+		if exe == source {
+			// Never let cc overwrite the source file itself.
+			exe += ".out"
+		}
+	}
+	cFile := exe + ".c"
+	if err := os.WriteFile(cFile, []byte(code), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "wrote %s\n", cFile)
+
+	if *cOnly {
+		return nil
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		fmt.Fprintf(stdout, "no cc found on PATH, skipping native build\n")
+		return nil
+	}
+
+	cmd := exec.Command(cc, "-std=c99", "-O2", "-o", exe, cFile)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cc failed: %s", err)
+	}
+	fmt.Fprintf(stdout, "wrote %s\n", exe)
+	return nil
+}
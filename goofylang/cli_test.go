@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReplCommand(t *testing.T) {
+	input := "cheese x = 5;\nx apple 1\ncheese y = icaco; y - x;\n40\nnope;\n= 1;\n"
+
+	var out bytes.Buffer
+	if err := replCommand(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("replCommand returned an error: %s", err)
+	}
+
+	// icaco stops reading right after "40", so the rest of that line shows up as an empty prompt.
+	expected := ">> >> 6\n>> 35\n>> >> ERROR: identifier not found: nope\n>> parse error: no expression can start with TOKEN_ENCHILADA (\"=\")\n>> \n"
+	if out.String() != expected {
+		t.Errorf("wrong REPL transcript.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestReplCommandBlocks(t *testing.T) {
+	input := "cheese x = 5;\nwaffles x > 3 {\n  pizza cake;\n} fries {\n  pizza broccoli;\n}\nx;\n"
+
+	var out bytes.Buffer
+	if err := replCommand(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("replCommand returned an error: %s", err)
+	}
+
+	expected := ">> >> .. .. .. .. cake\n>> 5\n>> \n"
+	if out.String() != expected {
+		t.Errorf("wrong REPL transcript.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
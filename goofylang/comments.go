@@ -0,0 +1,47 @@
+package main
+
+import "strings"
+
+// Comments are kept as trivia beside the syntax tree: the lexer collects them with their
+// positions, and tools that need them, such as the formatter, the linter's directives and
+// documentation lookups, match them to nodes by position.
+
+// CommentText returns the text of a comment without its markers. The lines of a block
+// comment lose their indentation and, if they have one, a leading "*".
+func CommentText(comment Token) string {
+	if !strings.HasPrefix(comment.Literal, "/*") {
+		return strings.TrimSpace(strings.TrimPrefix(comment.Literal, "//"))
+	}
+
+	body := strings.TrimSuffix(strings.TrimPrefix(comment.Literal, "/*"), "*/")
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "*" && strings.HasPrefix(line, "*") && !strings.HasPrefix(line, "**") {
+			line = strings.TrimSpace(line[1:])
+		}
+		lines[i] = line
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// DocComment returns the documentation of node: the text of the comments right above
+// it, without a blank line in between and starting in the same column, so a comment at
+// the end of the line before is not mistaken for one. It returns "" if there are none.
+func DocComment(node Node, comments []Token) string {
+	span := spanOf(node)
+	line := span.Line
+	var doc []string
+	for i := len(comments) - 1; i >= 0; i-- {
+		c := comments[i]
+		if c.Line >= span.Line {
+			continue
+		}
+		if c.EndLine != line-1 || c.Column != span.Column {
+			break
+		}
+		doc = append([]string{CommentText(c)}, doc...)
+		line = c.Line
+	}
+	return strings.Join(doc, "\n")
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestCommentText(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected string
+	}{
+		{"// hello", "hello"},
+		{"//hello  ", "hello"},
+		{"/* hello */", "hello"},
+		{"/*\n * first\n * second\n */", "first\nsecond"},
+		{"/* a\n   b */", "a\nb"},
+	}
+
+	for _, tt := range tests {
+		if text := CommentText(Token{Type: TOKEN_COMMENT, Literal: tt.literal}); text != tt.expected {
+			t.Errorf("wrong text for %q. expected=%q, got=%q", tt.literal, tt.expected, text)
+		}
+	}
+}
+
+func TestDocComment(t *testing.T) {
+	input := `cheese a = 1; // about a
+
+// counts things
+/* in two parts */
+cheese count = 0;
+
+// separated by a blank line
+
+cheese b = 2;
+waffles cake {
+    // inside
+    cheese c = 3;
+  // misaligned
+    cheese d = 4;
+}`
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	docs := map[string]string{}
+	walkAST(program, func(node Node) {
+		if let, ok := node.(*LetStatement); ok {
+			docs[let.Name.Value] = DocComment(let, p.Comments())
+		}
+	})
+	expected := map[string]string{
+		"a":     "",
+		"count": "counts things\nin two parts",
+		"b":     "",
+		"c":     "inside",
+		"d":     "",
+	}
+	for name, doc := range expected {
+		if docs[name] != doc {
+			t.Errorf("wrong doc for %s. expected=%q, got=%q", name, doc, docs[name])
+		}
+	}
+}
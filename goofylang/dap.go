@@ -0,0 +1,650 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The debugger speaks the Debug Adapter Protocol over a pair of streams, normally stdin
+// and stdout, so any editor that can drive a debug adapter can run a goofylang program
+// with line breakpoints, step through it, look at its variables and watch expressions.
+//
+// The program runs on its own goroutine in the tree-walking evaluator, which calls the
+// debugger before every statement. When the debugger decides to stop there, that
+// goroutine waits until the client resumes it, and the requests that inspect the
+// program are answered from the scopes it stopped in. Lines and columns count from 1.
+//
+// Stepping works on lines: a step stops at the first statement of the next line to run,
+// so the statements nested in a one-line waffles are stepped over with it, while a loop
+// whose body is one line stops there on every iteration. Stepping out of a burrito stops
+// at the next statement its caller runs, since the rest of the calling statement has no
+// statements of its own to stop at.
+
+// dapMessage is a request, response or event as it is read.
+type dapMessage struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// dapResponse answers a request.
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// dapEvent tells the client something happened without being asked.
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// The protocol types the debugger writes, with only the fields it uses.
+type dapSource struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type dapBreakpoint struct {
+	Verified bool   `json:"verified"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+type dapStackFrame struct {
+	ID     int       `json:"id"`
+	Name   string    `json:"name"`
+	Source dapSource `json:"source"`
+	Line   int       `json:"line"`
+	Column int       `json:"column"`
+}
+
+type dapScope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// dapThreadID is the one thread a goofylang program has.
+const dapThreadID = 1
+
+// dapStepMode is how the program runs after it was resumed.
+type dapStepMode int
+
+const (
+	dapContinue dapStepMode = iota // Run until a breakpoint.
+	dapStepIn                      // Stop at the next line, in whichever burrito it is.
+	dapStepOver                    // Stop at the next line of this burrito or a caller.
+	dapStepOut                     // Stop back in the caller.
+)
+
+// dapFrame is a call in progress, or the top level of the program, and the statement it
+// is running.
+type dapFrame struct {
+	stmt Statement
+	env  *Environment
+}
+
+// dapScopeHandle refers to the variables of a frame: the local ones, in every scope out
+// to the top level, or the ones at the top level.
+type dapScopeHandle struct {
+	env    *Environment
+	global bool
+}
+
+// errDebugStopped ends a program the client terminated.
+var errDebugStopped = newError("stopped by the debugger")
+
+// DAPServer is a debug adapter for goofylang programs.
+type DAPServer struct {
+	in *bufio.Reader
+
+	writing sync.Mutex // Guards out and seq, which both goroutines write with.
+	out     io.Writer
+	seq     int
+
+	path      string        // The absolute path of the program.
+	program   *Program      // The program, resolved and not optimized.
+	lines     map[int]bool  // The lines a statement starts on.
+	evaluator *Evaluator    // The evaluator running the program.
+	debug     bool          // Whether to stop at all, which launching with noDebug turns off.
+	started   bool          // Whether the program has started to run.
+	resume    chan struct{} // Wakes the program up after it stopped.
+	release   bool          // Whether to wake the program up once the response is sent.
+
+	mu          sync.Mutex // Guards the fields below, which the program's goroutine shares.
+	breakpoints map[int]bool
+	mode        dapStepMode
+	reason      string            // Why the program stops when stepping brings it to a halt.
+	stepDepth   int               // The frame a step started in.
+	pause       bool              // Whether the client asked the program to stop anywhere.
+	terminated  bool              // Whether the client asked the program to end.
+	stopped     bool              // Whether the program is waiting to be resumed.
+	frames      []dapFrame        // The frames of the program, outermost first.
+	calls       []*CallExpression // The calls that made each frame after the first.
+	line, depth int               // The line and frame the last statement ran on.
+	lineStart   Statement         // The first statement that ran on that line.
+	handles     []interface{}     // What each variables reference refers to, from 1.
+}
+
+// NewDAPServer creates a debug adapter that reads from in and writes to out.
+func NewDAPServer(in io.Reader, out io.Writer) *DAPServer {
+	return &DAPServer{
+		in:          bufio.NewReader(in),
+		out:         out,
+		debug:       true,
+		resume:      make(chan struct{}),
+		breakpoints: map[int]bool{},
+	}
+}
+
+// Serve handles requests until the client disconnects or closes the input.
+func (s *DAPServer) Serve() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			s.terminate()
+			s.wake()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req dapMessage
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("bad message: %s", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		result, herr := s.handle(req)
+		if err := s.respond(req, result, herr); err != nil {
+			return err
+		}
+		if req.Command == "launch" && herr == nil {
+			// Breakpoints can only be checked against the program once it is loaded.
+			s.event("initialized", nil)
+		}
+		s.wake()
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+// respond answers a request with a body or, if err is not nil, a failure.
+func (s *DAPServer) respond(req dapMessage, body interface{}, err error) error {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	s.seq++
+	resp := dapResponse{Seq: s.seq, Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+		resp.Body = nil
+	}
+	return writeMessage(s.out, resp)
+}
+
+// event sends an event to the client. Errors are dropped: the program's goroutine has no
+// one to report them to, and the next response fails the same way.
+func (s *DAPServer) event(name string, body interface{}) {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	s.seq++
+	writeMessage(s.out, dapEvent{Seq: s.seq, Type: "event", Event: name, Body: body})
+}
+
+// dapOutput sends what the program prints to the client as output events.
+type dapOutput struct {
+	server   *DAPServer
+	category string
+}
+
+func (o dapOutput) Write(p []byte) (int, error) {
+	o.server.event("output", map[string]string{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
+
+// handle runs one request and returns the body of its response.
+func (s *DAPServer) handle(req dapMessage) (interface{}, error) {
+	var args struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+		NoDebug     bool   `json:"noDebug"`
+		Input       string `json:"input"`
+		Source      struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+		FrameID            int    `json:"frameId"`
+		VariablesReference int    `json:"variablesReference"`
+		Expression         string `json:"expression"`
+	}
+	if len(req.Arguments) > 0 {
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, fmt.Errorf("bad arguments: %s", err)
+		}
+	}
+
+	switch req.Command {
+	case "initialize":
+		return map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		return nil, s.launch(args.Program, args.Input, args.StopOnEntry, !args.NoDebug)
+	case "setBreakpoints":
+		lines := make([]int, len(args.Breakpoints))
+		for i, bp := range args.Breakpoints {
+			lines[i] = bp.Line
+		}
+		return s.setBreakpoints(args.Source.Path, lines)
+	case "setExceptionBreakpoints":
+		return nil, nil
+	case "configurationDone":
+		return nil, s.start()
+	case "threads":
+		return map[string]interface{}{"threads": []map[string]interface{}{{"id": dapThreadID, "name": "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return s.scopes(args.FrameID)
+	case "variables":
+		return s.variables(args.VariablesReference)
+	case "evaluate":
+		return s.evaluate(args.Expression, args.FrameID)
+	case "continue":
+		return map[string]bool{"allThreadsContinued": true}, s.step(dapContinue)
+	case "next":
+		return nil, s.step(dapStepOver)
+	case "stepIn":
+		return nil, s.step(dapStepIn)
+	case "stepOut":
+		return nil, s.step(dapStepOut)
+	case "pause":
+		s.mu.Lock()
+		s.pause = true
+		s.mu.Unlock()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.terminate()
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported request %q", req.Command)
+	}
+}
+
+// launch loads the program at path, which runs once the client is done configuring it.
+// It is resolved like goofy run would, but not optimized, so every statement still runs
+// where it was written.
+func (s *DAPServer) launch(path, input string, stopOnEntry, debug bool) error {
+	if s.program != nil {
+		return fmt.Errorf("a program is already launched")
+	}
+	if path == "" {
+		return fmt.Errorf("launch needs the path of a program")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	program, err := parseFile(abs)
+	if err != nil {
+		return err
+	}
+	r := NewResolver(nil)
+	r.Resolve(program)
+	if errs := r.Errors(); len(errs) > 0 {
+		return fileErrors(abs, errs)
+	}
+
+	s.path, s.program, s.debug = abs, program, debug
+	s.lines = map[int]bool{}
+	walkAST(program, func(node Node) {
+		if stmt, ok := node.(Statement); ok {
+			if _, block := stmt.(*BlockStatement); !block {
+				s.lines[spanOf(stmt).Line] = true
+			}
+		}
+	})
+	s.evaluator = NewEvaluator(strings.NewReader(input), dapOutput{s, "stdout"})
+	if stopOnEntry {
+		s.mode, s.reason = dapStepIn, "entry"
+	}
+	return nil
+}
+
+// setBreakpoints replaces the breakpoints of the program. A breakpoint on a line no
+// statement starts on moves to the next line one does.
+func (s *DAPServer) setBreakpoints(path string, lines []int) (interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	breakpoints := make([]dapBreakpoint, len(lines))
+	set := map[int]bool{}
+	for i, line := range lines {
+		if s.program == nil || abs != s.path {
+			breakpoints[i] = dapBreakpoint{Message: "not the program being debugged"}
+			continue
+		}
+		moved, ok := s.statementLine(line)
+		if !ok {
+			breakpoints[i] = dapBreakpoint{Line: line, Message: "no statement on or after this line"}
+			continue
+		}
+		breakpoints[i] = dapBreakpoint{Verified: true, Line: moved}
+		set[moved] = true
+	}
+
+	if abs == s.path {
+		s.mu.Lock()
+		s.breakpoints = set
+		s.mu.Unlock()
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// statementLine returns the first line from line on that a statement starts on.
+func (s *DAPServer) statementLine(line int) (int, bool) {
+	var found []int
+	for l := range s.lines {
+		if l >= line {
+			found = append(found, l)
+		}
+	}
+	if len(found) == 0 {
+		return 0, false
+	}
+	sort.Ints(found)
+	return found[0], true
+}
+
+// start runs the program on its own goroutine. When it ends, the client is told how, and
+// that the debugging session is over.
+func (s *DAPServer) start() error {
+	if s.program == nil {
+		return fmt.Errorf("no program is launched")
+	}
+	if s.started {
+		return nil
+	}
+	s.started = true
+	if s.debug {
+		s.evaluator.SetStatementHook(s.beforeStatement)
+	}
+
+	go func() {
+		result := s.evaluator.Eval(s.program, NewEnvironment())
+		code := 0
+		if errObj, ok := result.(*Error); ok {
+			code = 1
+			if errObj != errDebugStopped {
+				s.event("output", map[string]string{"category": "stderr", "output": errObj.Message + "\n"})
+			}
+		}
+		s.event("exited", map[string]int{"exitCode": code})
+		s.event("terminated", nil)
+	}()
+	return nil
+}
+
+// beforeStatement is the statement hook of the evaluator. It stops the program if a
+// breakpoint, a step or the client asks for it, and waits there until it is resumed.
+func (s *DAPServer) beforeStatement(stmt Statement, env *Environment) *Error {
+	line := spanOf(stmt).Line
+	depth := len(s.evaluator.calls)
+
+	s.mu.Lock()
+	if s.terminated {
+		s.mu.Unlock()
+		return errDebugStopped
+	}
+	s.frames = append(s.frames[:min(depth, len(s.frames))], dapFrame{stmt, env})
+	if line != s.line || depth != s.depth {
+		s.line, s.depth, s.lineStart = line, depth, stmt
+	}
+	// The statements after the first one on a line run along with it, unless the line
+	// runs again, as the body of a loop does.
+	fresh := stmt == s.lineStart
+
+	reason := ""
+	switch {
+	case s.pause:
+		reason = "pause"
+	case s.mode == dapStepIn && fresh,
+		s.mode == dapStepOver && fresh && depth <= s.stepDepth,
+		s.mode == dapStepOut && depth < s.stepDepth:
+		reason = s.reason
+	case s.breakpoints[line] && fresh:
+		reason = "breakpoint"
+	}
+	if reason == "" {
+		s.mu.Unlock()
+		return nil
+	}
+	s.pause, s.mode, s.stopped = false, dapContinue, true
+	s.calls = s.evaluator.Calls()
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]interface{}{"reason": reason, "threadId": dapThreadID, "allThreadsStopped": true})
+	<-s.resume
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.terminated {
+		return errDebugStopped
+	}
+	return nil
+}
+
+// step resumes a stopped program in mode.
+func (s *DAPServer) step(mode dapStepMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return fmt.Errorf("the program is not stopped")
+	}
+	s.mode, s.reason, s.stepDepth = mode, "step", len(s.frames)-1
+	s.stopped, s.handles, s.release = false, nil, true
+	return nil
+}
+
+// terminate makes the program end at its next statement, waking it up if it is stopped.
+func (s *DAPServer) terminate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.terminated = true
+	if s.stopped {
+		s.stopped, s.release = false, true
+	}
+}
+
+// wake resumes the program if a request released it. It waits until the response was
+// sent, so the client hears that the program continued before it hears it stopped again.
+func (s *DAPServer) wake() {
+	if s.release {
+		s.release = false
+		s.resume <- struct{}{}
+	}
+}
+
+// stoppedFrame returns the frame with a frame id, which the frames are numbered with from
+// 1 at the top level. The program must be stopped.
+func (s *DAPServer) stoppedFrame(id int) (dapFrame, error) {
+	if !s.stopped {
+		return dapFrame{}, fmt.Errorf("the program is not stopped")
+	}
+	if id < 1 || id > len(s.frames) {
+		return dapFrame{}, fmt.Errorf("no frame %d", id)
+	}
+	return s.frames[id-1], nil
+}
+
+// stackTrace lists the frames of the stopped program, innermost first. Each one is named
+// after the burrito it runs, as the call wrote it.
+func (s *DAPServer) stackTrace() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return nil, fmt.Errorf("the program is not stopped")
+	}
+
+	source := dapSource{Name: filepath.Base(s.path), Path: s.path}
+	frames := []dapStackFrame{}
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		name := "main"
+		if i > 0 {
+			name = s.calls[i-1].Function.String()
+		}
+		span := spanOf(s.frames[i].stmt)
+		frames = append(frames, dapStackFrame{ID: i + 1, Name: name, Source: source, Line: span.Line, Column: span.Column})
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// handleFor returns a variables reference for v, which stays valid until the program resumes.
+func (s *DAPServer) handleFor(v interface{}) int {
+	s.handles = append(s.handles, v)
+	return len(s.handles)
+}
+
+// scopes lists the local and top-level variables of a frame.
+func (s *DAPServer) scopes(id int) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frame, err := s.stoppedFrame(id)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []dapScope{}
+	if frame.env.Outer() != nil {
+		scopes = append(scopes, dapScope{Name: "Locals", VariablesReference: s.handleFor(dapScopeHandle{env: frame.env})})
+	}
+	global := frame.env
+	for global.Outer() != nil {
+		global = global.Outer()
+	}
+	scopes = append(scopes, dapScope{Name: "Globals", VariablesReference: s.handleFor(dapScopeHandle{env: global, global: true})})
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+// variables lists the variables of a scope or the elements of an array or hash.
+func (s *DAPServer) variables(ref int) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return nil, fmt.Errorf("the program is not stopped")
+	}
+	if ref < 1 || ref > len(s.handles) {
+		return nil, fmt.Errorf("no variables reference %d", ref)
+	}
+
+	variables := []dapVariable{}
+	switch v := s.handles[ref-1].(type) {
+	case dapScopeHandle:
+		// Local scopes are listed from the innermost out; a name an inner scope binds
+		// hides the same name further out.
+		seen := map[string]bool{}
+		for env := v.env; env != nil && (v.global || env.Outer() != nil); env = env.Outer() {
+			for _, name := range env.Names() {
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				val, _ := env.Get(name)
+				variables = append(variables, s.variable(name, val))
+			}
+		}
+	case *Array:
+		for i, element := range v.Elements {
+			variables = append(variables, s.variable(fmt.Sprintf("[%d]", i), element))
+		}
+	case *Hash:
+		for _, key := range v.Order {
+			pair := v.Pairs[key]
+			variables = append(variables, s.variable(inspectElement(pair.Key), pair.Value))
+		}
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+// variable describes a value, with a reference to its elements if it has any.
+func (s *DAPServer) variable(name string, val Object) dapVariable {
+	v := dapVariable{Name: name, Value: inspectElement(val), Type: typeName(val)}
+	switch val := val.(type) {
+	case *Array:
+		if len(val.Elements) > 0 {
+			v.VariablesReference = s.handleFor(val)
+		}
+	case *Hash:
+		if len(val.Order) > 0 {
+			v.VariablesReference = s.handleFor(val)
+		}
+	}
+	return v
+}
+
+// evaluate evaluates an expression, such as a watch expression or the name under the
+// mouse, in the scope of a frame. It runs in an evaluator of its own, so it does not stop
+// at breakpoints, but a burrito it calls can change the program's variables.
+func (s *DAPServer) evaluate(expression string, id int) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 {
+		id = len(s.frames)
+	}
+	frame, err := s.stoppedFrame(id)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewParser(NewLexer(expression))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	if len(program.Statements) != 1 {
+		return nil, fmt.Errorf("not an expression: %s", expression)
+	}
+	stmt, ok := program.Statements[0].(*ExpressionStatement)
+	if !ok {
+		return nil, fmt.Errorf("not an expression: %s", expression)
+	}
+
+	val := NewEvaluator(strings.NewReader(""), io.Discard).Eval(stmt.Expression, frame.env)
+	if errObj, ok := val.(*Error); ok {
+		return nil, fmt.Errorf("%s", errObj.Message)
+	}
+	v := s.variable(expression, val)
+	return map[string]interface{}{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDebugger drives a DAPServer in process, the way an editor would over stdio.
+type fakeDebugger struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan dapReceived
+	done     chan error
+	seq      int
+	events   []dapReceived // Events that arrived while waiting for a response.
+	output   strings.Builder
+}
+
+// dapReceived is a response or event from the server.
+type dapReceived struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+func newFakeDebugger(t *testing.T) *fakeDebugger {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	d := &fakeDebugger{t: t, in: clientOut, messages: make(chan dapReceived, 100), done: make(chan error, 1)}
+
+	go func() {
+		d.done <- NewDAPServer(serverIn, serverOut).Serve()
+	}()
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			body, err := readMessage(r)
+			if err != nil {
+				return
+			}
+			var msg dapReceived
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("server sent bad JSON %q: %s", body, err)
+			}
+			d.messages <- msg
+		}
+	}()
+	return d
+}
+
+// receive returns the next message from the server, collecting program output on the way.
+func (d *fakeDebugger) receive() dapReceived {
+	for {
+		select {
+		case msg := <-d.messages:
+			if msg.Event == "output" {
+				var body struct{ Output string }
+				json.Unmarshal(msg.Body, &body)
+				d.output.WriteString(body.Output)
+				continue
+			}
+			return msg
+		case <-time.After(5 * time.Second):
+			d.t.Fatalf("timed out waiting for the debugger")
+			return dapReceived{}
+		}
+	}
+}
+
+// request sends a request and decodes the body of its response into result. Events that
+// arrive in the meantime are kept for event.
+func (d *fakeDebugger) request(command string, arguments interface{}, result interface{}) (bool, string) {
+	d.seq++
+	msg := map[string]interface{}{"seq": d.seq, "type": "request", "command": command, "arguments": arguments}
+	if err := writeMessage(d.in, msg); err != nil {
+		d.t.Fatalf("could not send %s: %s", command, err)
+	}
+	for {
+		resp := d.receive()
+		if resp.Type != "response" || resp.RequestSeq != d.seq {
+			d.events = append(d.events, resp)
+			continue
+		}
+		if resp.Success && result != nil {
+			if err := json.Unmarshal(resp.Body, result); err != nil {
+				d.t.Fatalf("%s: bad body %s: %s", command, resp.Body, err)
+			}
+		}
+		return resp.Success, resp.Message
+	}
+}
+
+func (d *fakeDebugger) mustRequest(command string, arguments interface{}, result interface{}) {
+	if ok, message := d.request(command, arguments, result); !ok {
+		d.t.Fatalf("%s failed: %s", command, message)
+	}
+}
+
+// event waits for an event and decodes its body into body.
+func (d *fakeDebugger) event(name string, body interface{}) {
+	for {
+		var msg dapReceived
+		if len(d.events) > 0 {
+			msg, d.events = d.events[0], d.events[1:]
+		} else {
+			msg = d.receive()
+		}
+		if msg.Event != name {
+			continue
+		}
+		if body != nil {
+			json.Unmarshal(msg.Body, body)
+		}
+		return
+	}
+}
+
+// stoppedAt waits for the program to stop and checks why and on which line.
+func (d *fakeDebugger) stoppedAt(reason string, line int) []dapStackFrame {
+	d.t.Helper()
+	var stopped struct{ Reason string }
+	d.event("stopped", &stopped)
+	if stopped.Reason != reason {
+		d.t.Errorf("expected to stop for %s. got=%s", reason, stopped.Reason)
+	}
+	var trace struct{ StackFrames []dapStackFrame }
+	d.mustRequest("stackTrace", map[string]int{"threadId": dapThreadID}, &trace)
+	if len(trace.StackFrames) == 0 || trace.StackFrames[0].Line != line {
+		d.t.Fatalf("expected to stop on line %d. got=%+v", line, trace.StackFrames)
+	}
+	return trace.StackFrames
+}
+
+// variables returns the variables of a reference as name=value pairs.
+func (d *fakeDebugger) variables(ref int) map[string]dapVariable {
+	var result struct{ Variables []dapVariable }
+	d.mustRequest("variables", map[string]int{"variablesReference": ref}, &result)
+	variables := map[string]dapVariable{}
+	for _, v := range result.Variables {
+		variables[v.Name] = v
+	}
+	return variables
+}
+
+const dapProgram = `cheese total = 0;
+cheese add = burrito(n) {
+    cheese doubled = n pancakes 2;
+    takeout doubled;
+};
+donuts i = 1, 4 {
+    total += add(i);
+}
+cheese list = [1, "two"];
+pizza total;
+`
+
+// launch starts a debugging session for source, stopping at its first statement.
+func launch(t *testing.T, source string, breakpoints ...int) (*fakeDebugger, string) {
+	path := filepath.Join(t.TempDir(), "debug.goofy")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := newFakeDebugger(t)
+	var capabilities map[string]bool
+	d.mustRequest("initialize", map[string]string{"adapterID": "goofy"}, &capabilities)
+	if !capabilities["supportsConfigurationDoneRequest"] {
+		t.Errorf("configurationDone is not supported: %v", capabilities)
+	}
+	d.mustRequest("launch", map[string]interface{}{"program": path, "stopOnEntry": true}, nil)
+	d.event("initialized", nil)
+
+	var lines []map[string]int
+	for _, line := range breakpoints {
+		lines = append(lines, map[string]int{"line": line})
+	}
+	var result struct{ Breakpoints []dapBreakpoint }
+	d.mustRequest("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": lines}, &result)
+	for i, bp := range result.Breakpoints {
+		if !bp.Verified {
+			t.Errorf("breakpoint on line %d was not verified: %s", breakpoints[i], bp.Message)
+		}
+	}
+	d.mustRequest("configurationDone", nil, nil)
+	d.stoppedAt("entry", 1)
+	return d, path
+}
+
+// finish runs the program to its end and disconnects.
+func (d *fakeDebugger) finish(output string) {
+	d.t.Helper()
+	d.mustRequest("continue", map[string]int{"threadId": dapThreadID}, nil)
+	var exited struct{ ExitCode int }
+	d.event("exited", &exited)
+	d.event("terminated", nil)
+	if exited.ExitCode != 0 || d.output.String() != output {
+		d.t.Errorf("expected exit code 0 and output %q. got=%d, %q", output, exited.ExitCode, d.output.String())
+	}
+	d.mustRequest("disconnect", nil, nil)
+	if err := <-d.done; err != nil {
+		d.t.Errorf("Serve returned an error: %s", err)
+	}
+}
+
+func TestDAPBreakpoints(t *testing.T) {
+	d, path := launch(t, dapProgram, 3, 8)
+
+	d.mustRequest("continue", map[string]int{"threadId": dapThreadID}, nil)
+	frames := d.stoppedAt("breakpoint", 3)
+	if len(frames) != 2 || frames[0].Name != "add" || frames[1].Name != "main" || frames[1].Line != 7 {
+		t.Fatalf("wrong frames: %+v", frames)
+	}
+	if frames[0].Source.Path != path || frames[0].Column != 5 {
+		t.Errorf("wrong frame position: %+v", frames[0])
+	}
+
+	var scopes struct{ Scopes []dapScope }
+	d.mustRequest("scopes", map[string]int{"frameId": frames[0].ID}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes: %+v", scopes.Scopes)
+	}
+	locals := d.variables(scopes.Scopes[0].VariablesReference)
+	if len(locals) != 1 || locals["n"].Value != "1" || locals["n"].Type != "nuggets" {
+		t.Errorf("wrong locals: %+v", locals)
+	}
+	globals := d.variables(scopes.Scopes[1].VariablesReference)
+	if globals["total"].Value != "0" || globals["add"].Type != "function" {
+		t.Errorf("wrong globals: %+v", globals)
+	}
+
+	// The loop variable belongs to the frame of the caller.
+	d.mustRequest("scopes", map[string]int{"frameId": frames[1].ID}, &scopes)
+	if locals := d.variables(scopes.Scopes[0].VariablesReference); locals["i"].Value != "1" {
+		t.Errorf("wrong locals of the caller: %+v", locals)
+	}
+
+	// Every iteration stops at the breakpoint again.
+	d.mustRequest("continue", map[string]int{"threadId": dapThreadID}, nil)
+	d.stoppedAt("breakpoint", 3)
+	var watch struct {
+		Result string
+		Type   string
+	}
+	d.mustRequest("evaluate", map[string]interface{}{"expression": "n pancakes 10", "frameId": 2, "context": "watch"}, &watch)
+	if watch.Result != "20" || watch.Type != "nuggets" {
+		t.Errorf("wrong watch result: %+v", watch)
+	}
+	d.mustRequest("evaluate", map[string]interface{}{"expression": "total apple i", "frameId": 1, "context": "watch"}, &watch)
+	if watch.Result != "4" {
+		t.Errorf("wrong watch result in the caller: %+v", watch)
+	}
+	if ok, message := d.request("evaluate", map[string]interface{}{"expression": "missing", "frameId": 2}, nil); ok || !strings.Contains(message, "identifier not found") {
+		t.Errorf("expected an error for an unknown name. got=%v, %q", ok, message)
+	}
+
+	// Setting the breakpoints again replaces them; line 8 moves to line 9.
+	var result struct{ Breakpoints []dapBreakpoint }
+	d.mustRequest("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": []map[string]int{{"line": 8}}}, &result)
+	if len(result.Breakpoints) != 1 || result.Breakpoints[0].Line != 9 {
+		t.Errorf("wrong breakpoints: %+v", result.Breakpoints)
+	}
+	d.mustRequest("continue", map[string]int{"threadId": dapThreadID}, nil)
+	frames = d.stoppedAt("breakpoint", 9)
+	if len(frames) != 1 {
+		t.Errorf("expected to be back at the top level: %+v", frames)
+	}
+
+	d.mustRequest("next", map[string]int{"threadId": dapThreadID}, nil)
+	d.stoppedAt("step", 10)
+	d.mustRequest("scopes", map[string]int{"frameId": 1}, &scopes)
+	if len(scopes.Scopes) != 1 {
+		t.Fatalf("expected only globals at the top level: %+v", scopes.Scopes)
+	}
+	list := d.variables(scopes.Scopes[0].VariablesReference)["list"]
+	if list.Value != `[1, "two"]` || list.VariablesReference == 0 {
+		t.Fatalf("wrong list: %+v", list)
+	}
+	if elements := d.variables(list.VariablesReference); elements["[1]"].Value != `"two"` {
+		t.Errorf("wrong elements: %+v", elements)
+	}
+
+	d.finish("12\n")
+}
+
+func TestDAPStepping(t *testing.T) {
+	d, _ := launch(t, dapProgram)
+
+	steps := []struct {
+		command string
+		line    int
+		depth   int
+	}{
+		{"next", 2, 1},
+		{"next", 6, 1},
+		{"next", 7, 1},
+		{"stepIn", 3, 2},
+		{"next", 4, 2},
+		{"stepOut", 7, 1},
+		{"stepIn", 3, 2},
+		{"stepOut", 7, 1}, // The statement of the next iteration, as the call was the last thing on its line.
+		{"next", 9, 1},
+		{"stepIn", 10, 1},
+	}
+	for _, step := range steps {
+		d.mustRequest(step.command, map[string]int{"threadId": dapThreadID}, nil)
+		frames := d.stoppedAt("step", step.line)
+		if len(frames) != step.depth {
+			t.Fatalf("%s to line %d: expected %d frames. got=%+v", step.command, step.line, step.depth, frames)
+		}
+	}
+
+	if ok, _ := d.request("next", map[string]int{"threadId": dapThreadID}, nil); !ok {
+		t.Fatalf("next failed")
+	}
+	d.event("terminated", nil)
+	if ok, _ := d.request("next", map[string]int{"threadId": dapThreadID}, nil); ok {
+		t.Errorf("expected stepping a finished program to fail")
+	}
+	d.mustRequest("disconnect", nil, nil)
+}
+
+func TestDAPTerminate(t *testing.T) {
+	d, _ := launch(t, "pizza 1;\nnoodles (cake) {\n    pizza 2;\n}\n", 3)
+	d.mustRequest("continue", map[string]int{"threadId": dapThreadID}, nil)
+	d.stoppedAt("breakpoint", 3)
+
+	d.mustRequest("terminate", nil, nil)
+	var exited struct{ ExitCode int }
+	d.event("exited", &exited)
+	d.event("terminated", nil)
+	if d.output.String() != "1\n" || exited.ExitCode != 1 {
+		t.Errorf("wrong output or exit code: %q, %d", d.output.String(), exited.ExitCode)
+	}
+	d.mustRequest("disconnect", nil, nil)
+}
+
+func TestDAPLaunchErrors(t *testing.T) {
+	d := newFakeDebugger(t)
+	d.mustRequest("initialize", nil, nil)
+	if ok, message := d.request("launch", map[string]string{"program": filepath.Join(t.TempDir(), "missing.goofy")}, nil); ok || message == "" {
+		t.Errorf("expected launching a missing file to fail")
+	}
+
+	path := filepath.Join(t.TempDir(), "broken.goofy")
+	os.WriteFile(path, []byte("pizza x;"), 0644)
+	if ok, message := d.request("launch", map[string]string{"program": path}, nil); ok || !strings.Contains(message, "x") {
+		t.Errorf("expected launching a program with an undefined name to fail. got=%q", message)
+	}
+	os.WriteFile(path, []byte("cheese x = 99999999999999999999;\npizza x;"), 0644)
+	if ok, message := d.request("launch", map[string]string{"program": path}, nil); ok || !strings.Contains(message, "does not fit") {
+		t.Errorf("expected launching a program with an integer that overflows to fail. got=%q", message)
+	}
+	if ok, _ := d.request("configurationDone", nil, nil); ok {
+		t.Errorf("expected configurationDone without a program to fail")
+	}
+	if ok, message := d.request("stepBack", nil, nil); ok || !strings.Contains(message, "unsupported") {
+		t.Errorf("expected an unsupported request to fail. got=%q", message)
+	}
+	d.mustRequest("disconnect", nil, nil)
+}
+
+func TestDAPRuntimeError(t *testing.T) {
+	d, _ := launch(t, "pizza 1;\npizza 1 pie 0;\n")
+	d.mustRequest("continue", map[string]int{"threadId": dapThreadID}, nil)
+	var exited struct{ ExitCode int }
+	d.event("exited", &exited)
+	if exited.ExitCode != 1 || !strings.Contains(d.output.String(), "division by zero") {
+		t.Errorf("expected the error in the output. got=%d, %q", exited.ExitCode, d.output.String())
+	}
+	d.mustRequest("disconnect", nil, nil)
+}
+
+func TestDAPPause(t *testing.T) {
+	d, _ := launch(t, "cheese n = 0;\nnoodles (cake) {\n    n += 1;\n}\n")
+	d.mustRequest("continue", map[string]int{"threadId": dapThreadID}, nil)
+	d.mustRequest("pause", map[string]int{"threadId": dapThreadID}, nil)
+	var stopped struct{ Reason string }
+	d.event("stopped", &stopped)
+	if stopped.Reason != "pause" {
+		t.Errorf("expected to stop for pause. got=%s", stopped.Reason)
+	}
+
+	var watch struct{ Result string }
+	d.mustRequest("evaluate", map[string]interface{}{"expression": "n >= 0", "context": "hover"}, &watch)
+	if watch.Result != "cake" {
+		t.Errorf("wrong hover result: %+v", watch)
+	}
+	d.mustRequest("disconnect", nil, nil)
+	if err := <-d.done; err != nil {
+		t.Errorf("Serve returned an error: %s", err)
+	}
+}
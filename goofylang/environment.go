@@ -0,0 +1,158 @@
+package main
+
+import "fmt"
+
+// Environment is one lexical scope: the names bound in it and the scope it is nested in.
+// Lookups walk outwards until they find the name, so an inner scope can shadow a name
+// from an enclosing one without changing it.
+//
+// Declaring a name that already exists in the same scope rebinds it. The resolver rejects
+// a program that declares a name twice in one scope, but the REPL declares names again
+// from one line to the next, so that keeps working; it is only ever the innermost scope
+// that changes.
+//
+// A binding declared with a type, such as cheese nuggets x, remembers that type so every
+// later assignment can be checked against it.
+//
+// Each scope keeps its bindings in slots, numbered in the order the names were first
+// declared. The resolver works out those numbers before the program runs, so the
+// evaluator can reach a variable by how many scopes out it is and its slot, without
+// searching for its name. Slots are always checked to really hold the name, and a slot
+// that does not is an error rather than a reason to search by name: the evaluator makes
+// the scopes the resolver planned, so it means the resolver and the evaluator disagree.
+// Lookups by name, which the REPL and anything else running unresolved code make, go
+// through an index of the slots by name.
+type Environment struct {
+	names []string       // The name bound in each slot.
+	slots []Object       // The value bound in each slot.
+	types []ObjectType   // The declared type of each slot, or "" if it has none.
+	index map[string]int // The slot of each name, made when the first name is declared.
+	outer *Environment
+}
+
+// NewEnvironment creates an empty top-level scope.
+func NewEnvironment() *Environment {
+	return &Environment{}
+}
+
+// NewEnclosedEnvironment creates an empty scope nested inside outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// slot returns the slot name is bound in, in this scope itself.
+func (e *Environment) slot(name string) (int, bool) {
+	i, ok := e.index[name]
+	return i, ok
+}
+
+// find returns the innermost scope that binds name and its slot there.
+func (e *Environment) find(name string) (*Environment, int, bool) {
+	for env := e; env != nil; env = env.outer {
+		if i, ok := env.slot(name); ok {
+			return env, i, true
+		}
+	}
+	return nil, 0, false
+}
+
+// at returns the scope depth scopes out from e, which must hold name in slot. A slot
+// past the ones the scope has so far belongs to a name that is not declared yet, as when
+// a burrito is called before a variable it uses is declared.
+func (e *Environment) at(depth, slot int, name string) (*Environment, error) {
+	env := e
+	for i := depth; i > 0 && env != nil; i-- {
+		env = env.outer
+	}
+	switch {
+	case env == nil:
+		return nil, fmt.Errorf("internal error: %s was resolved %d scopes out, past the top level", name, depth)
+	case slot >= len(env.names):
+		return nil, fmt.Errorf("%s is used before it is declared", name)
+	case env.names[slot] != name:
+		return nil, fmt.Errorf("internal error: %s was resolved to slot %d, which holds %s", name, slot, env.names[slot])
+	}
+	return env, nil
+}
+
+// Get looks name up in this scope and then in every enclosing one.
+func (e *Environment) Get(name string) (Object, bool) {
+	if env, i, ok := e.find(name); ok {
+		return env.slots[i], true
+	}
+	return nil, false
+}
+
+// Declare binds name to val in this scope, shadowing any binding in an enclosing scope.
+// Rebinding a typed name this way drops its type.
+func (e *Environment) Declare(name string, val Object) {
+	e.DeclareTyped(name, val, "")
+}
+
+// DeclareTyped binds name to val like Declare, and records that name must always hold
+// values of type typ. An empty typ declares an untyped binding.
+func (e *Environment) DeclareTyped(name string, val Object, typ ObjectType) {
+	if i, ok := e.slot(name); ok {
+		e.slots[i], e.types[i] = val, typ
+		return
+	}
+	if e.index == nil {
+		e.index = map[string]int{}
+	}
+	e.index[name] = len(e.names)
+	e.names = append(e.names, name)
+	e.slots = append(e.slots, val)
+	e.types = append(e.types, typ)
+}
+
+// DeclareAt binds name like DeclareTyped, in the slot the resolver gave it, which must
+// either hold name already or be the next slot of the scope. Any other slot means the
+// scope does not match what the resolver planned, and is an error.
+func (e *Environment) DeclareAt(slot int, name string, val Object, typ ObjectType) error {
+	if slot < len(e.names) && e.names[slot] == name {
+		e.slots[slot], e.types[slot] = val, typ
+		return nil
+	}
+	if _, ok := e.slot(name); ok || slot != len(e.names) {
+		return fmt.Errorf("internal error: %s was resolved to slot %d of a scope with %d slots", name, slot, len(e.names))
+	}
+	e.DeclareTyped(name, val, typ)
+	return nil
+}
+
+// DeclaredType returns the type the innermost binding of name was declared with, if it
+// was declared with one.
+func (e *Environment) DeclaredType(name string) (ObjectType, bool) {
+	if env, i, ok := e.find(name); ok {
+		return env.types[i], env.types[i] != ""
+	}
+	return "", false
+}
+
+// Assign updates the innermost existing binding of name. It returns false, changing
+// nothing, if no scope declares name.
+func (e *Environment) Assign(name string, val Object) bool {
+	if env, i, ok := e.find(name); ok {
+		env.slots[i] = val
+		return true
+	}
+	return false
+}
+
+// DeclaredHere reports whether name is bound in this scope itself, ignoring enclosing ones.
+func (e *Environment) DeclaredHere(name string) bool {
+	_, ok := e.slot(name)
+	return ok
+}
+
+// Outer returns the enclosing scope, or nil for the top level.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names returns the names bound in this scope itself, in slot order.
+func (e *Environment) Names() []string {
+	return append([]string(nil), e.names...)
+}
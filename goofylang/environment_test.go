@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentScoping(t *testing.T) {
+	global := NewEnvironment()
+	global.Declare("x", &Integer{Value: 1})
+	global.Declare("y", &Integer{Value: 2})
+
+	inner := NewEnclosedEnvironment(global)
+	inner.Declare("x", &Integer{Value: 10})
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		expected int64
+	}{
+		{inner, "x", 10}, // shadowed by the inner scope
+		{inner, "y", 2},  // found in the enclosing scope
+		{global, "x", 1}, // the outer binding is untouched
+	}
+
+	for _, tt := range tests {
+		val, ok := tt.env.Get(tt.name)
+		if !ok {
+			t.Errorf("%s not found", tt.name)
+			continue
+		}
+		testIntegerObject(t, val, tt.expected)
+	}
+
+	if _, ok := inner.Get("z"); ok {
+		t.Errorf("z should not be found")
+	}
+	if !inner.DeclaredHere("x") || inner.DeclaredHere("y") {
+		t.Errorf("DeclaredHere should only report names bound in the scope itself")
+	}
+	if inner.Outer() != global || global.Outer() != nil {
+		t.Errorf("Outer does not return the enclosing scope")
+	}
+}
+
+func TestEnvironmentRedeclare(t *testing.T) {
+	env := NewEnvironment()
+	env.Declare("x", &Integer{Value: 1})
+	env.Declare("x", &Integer{Value: 2})
+
+	val, _ := env.Get("x")
+	testIntegerObject(t, val, 2)
+}
+
+func testIntegerObject(t *testing.T, obj Object, expected int64) bool {
+	result, ok := obj.(*Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestEnvironmentSlots(t *testing.T) {
+	env := NewEnvironment()
+	env.Declare("a", &Integer{Value: 1})
+	for _, d := range []struct {
+		slot int
+		name string
+		val  int64
+		typ  ObjectType
+	}{{1, "b", 2, INTEGER_OBJ}, {0, "a", 3, ""}, {2, "c", 4, ""}} {
+		if err := env.DeclareAt(d.slot, d.name, &Integer{Value: d.val}, d.typ); err != nil {
+			t.Fatalf("DeclareAt(%d, %s): %s", d.slot, d.name, err)
+		}
+	}
+	// A slot that does not match is an error, and changes nothing.
+	for _, d := range []struct {
+		slot int
+		name string
+	}{{0, "d"}, {4, "d"}, {3, "a"}} {
+		if err := env.DeclareAt(d.slot, d.name, &Integer{Value: 5}, ""); err == nil || !strings.Contains(err.Error(), "internal error") {
+			t.Errorf("DeclareAt(%d, %s) did not fail: %v", d.slot, d.name, err)
+		}
+	}
+
+	if names := env.Names(); len(names) != 3 || names[0] != "a" || names[1] != "b" || names[2] != "c" {
+		t.Fatalf("wrong slots: %v", names)
+	}
+	for name, expected := range map[string]int64{"a": 3, "b": 2, "c": 4} {
+		val, _ := env.Get(name)
+		testIntegerObject(t, val, expected)
+	}
+	if i, ok := env.slot("c"); !ok || i != 2 {
+		t.Errorf("the index has c in slot %d (%t)", i, ok)
+	}
+	if _, ok := env.slot("d"); ok {
+		t.Errorf("the index has a slot for d")
+	}
+	if typ, ok := env.DeclaredType("b"); !ok || typ != INTEGER_OBJ {
+		t.Errorf("b lost its declared type")
+	}
+
+	inner := NewEnclosedEnvironment(env)
+	if scope, err := inner.at(1, 1, "b"); err != nil || scope != env {
+		t.Errorf("at did not find b one scope out: %v", err)
+	}
+	if _, err := inner.at(1, 1, "a"); err == nil || !strings.Contains(err.Error(), "internal error") {
+		t.Errorf("at found a in the slot of b: %v", err)
+	}
+	if _, err := inner.at(2, 0, "a"); err == nil || !strings.Contains(err.Error(), "internal error") {
+		t.Errorf("at went past the top level: %v", err)
+	}
+	if _, err := inner.at(1, 3, "d"); err == nil || err.Error() != "d is used before it is declared" {
+		t.Errorf("wrong error for a slot not declared yet: %v", err)
+	}
+}
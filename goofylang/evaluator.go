@@ -0,0 +1,807 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// maxCallDepth limits how deeply calls may nest. Every call is a Go call as well, so
+// runaway recursion becomes a goofylang error long before it can exhaust the Go stack.
+const maxCallDepth = 10000
+
+// StatementHook is called before the evaluator runs each statement, with the scope the
+// statement runs in. Blocks are not statements of their own here; the statements in them
+// are. Returning an error stops the program with that error, as if the statement had
+// failed.
+type StatementHook func(stmt Statement, env *Environment) *Error
+
+// Evaluator runs a parsed program directly by walking its AST.
+type Evaluator struct {
+	in    *bufio.Reader     // Where icaco reads integers from.
+	out   io.Writer         // Where pizza prints to.
+	depth int               // How many calls are in progress.
+	hook  StatementHook     // Called before every statement, if set.
+	calls []*CallExpression // The calls in progress while a hook is set, outermost first.
+}
+
+// NewEvaluator creates an Evaluator that reads input from in and prints to out.
+func NewEvaluator(in io.Reader, out io.Writer) *Evaluator {
+	return &Evaluator{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+// SetStatementHook makes the evaluator call hook before every statement it runs, which is
+// how a debugger follows and pauses a program. A nil hook removes it.
+func (e *Evaluator) SetStatementHook(hook StatementHook) {
+	e.hook = hook
+}
+
+// Calls returns the calls in progress, outermost first. They are only tracked while a
+// statement hook is set.
+func (e *Evaluator) Calls() []*CallExpression {
+	return append([]*CallExpression(nil), e.calls...)
+}
+
+// Eval evaluates a node in env and returns its value. Statements that do not produce a
+// value return nil; runtime errors are returned as *Error. For a whole Program the result
+// is the value of the last expression statement that ran, which is what a REPL or an
+// embedding host shows to the user.
+func (e *Evaluator) Eval(node Node, env *Environment) Object {
+	if e.hook != nil {
+		if err := e.runHook(node, env); err != nil {
+			return err
+		}
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		return e.evalProgram(node, env)
+	case *LetStatement:
+		val := e.Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		typ, typed := node.AnnotatedType()
+		if typed && val.Type() != typ {
+			return newErrorAt(node.Name.Token, "%s %s cannot hold %s", typeNames[typ], node.Name.Value, typeName(val))
+		}
+		if err := declare(env, node.Name, val, typ); err != nil {
+			return err
+		}
+		return nil
+	case *AssignStatement:
+		return e.evalAssignStatement(node, env)
+	case *ExpressionStatement:
+		return e.Eval(node.Expression, env)
+	case *BlockStatement:
+		return e.evalBlockStatement(node, NewEnclosedEnvironment(env))
+	case *IfStatement:
+		return e.evalIfStatement(node, env)
+	case *WhileStatement:
+		return e.evalWhileStatement(node, env)
+	case *ForStatement:
+		return e.evalForStatement(node, env)
+	case *LoopControlStatement:
+		if node.IsBreak() {
+			return BREAK
+		}
+		return CONTINUE
+	case *ReturnStatement:
+		if node.Value == nil {
+			return &ReturnValue{Value: NULL}
+		}
+		val := e.Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return &ReturnValue{Value: val}
+	case *PrintStatement:
+		val := e.Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		fmt.Fprintln(e.out, val.Inspect())
+		return nil
+	case *IntegralLiteral:
+		return &Integer{Value: node.Value}
+	case *StringLiteral:
+		return &String{Value: node.Value}
+	case *BooleanLiteral:
+		return nativeBoolToBooleanObject(node.Value)
+	case *Identifier:
+		return evalIdentifier(node, env)
+	case *InputExpression:
+		return e.evalInputExpression()
+	case *PrefixExpression:
+		right := e.Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Token, right)
+	case *InfixExpression:
+		left := e.Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := e.Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExpression(node.Token, left, right)
+	case *FunctionLiteral:
+		return &Closure{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *CallExpression:
+		return e.evalCallExpression(node, env)
+	case *TypeofExpression:
+		right := e.Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return &String{Value: typeName(right)}
+	case *TypeSwitchStatement:
+		return e.evalTypeSwitchStatement(node, env)
+	case *NuggetsExpression:
+		val := e.Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return convertToNuggets(node.Token, val)
+	case *ArrayLiteral:
+		elements, err := e.evalExpressions(node.Elements, env)
+		if err != nil {
+			return err
+		}
+		return &Array{Elements: elements}
+	case *HashLiteral:
+		return e.evalHashLiteral(node, env)
+	case *IndexExpression:
+		left := e.Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := e.Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(node.Token, left, index)
+	case nil:
+		return newError("missing expression")
+	}
+
+	return newError("cannot evaluate %T", node)
+}
+
+// runHook calls the statement hook if node is a statement other than a block.
+func (e *Evaluator) runHook(node Node, env *Environment) *Error {
+	stmt, ok := node.(Statement)
+	if !ok {
+		return nil
+	}
+	if _, ok := stmt.(*BlockStatement); ok {
+		return nil
+	}
+	return e.hook(stmt, env)
+}
+
+// evalProgram runs every statement in order and stops at the first runtime error.
+func (e *Evaluator) evalProgram(program *Program, env *Environment) Object {
+	var result Object
+
+	for _, stmt := range program.Statements {
+		val := e.Eval(stmt, env)
+		if isError(val) {
+			return val
+		}
+		if signal, ok := val.(*LoopSignal); ok {
+			// The parser rejects these outside of loops, but hand-built programs may not.
+			return newError("%s outside of a loop", signal.Inspect())
+		}
+		if _, ok := val.(*ReturnValue); ok {
+			return newError("takeout outside of a burrito")
+		}
+		if _, ok := stmt.(*ExpressionStatement); ok {
+			result = val
+		}
+	}
+
+	return result
+}
+
+// evalBlockStatement runs the statements of a block in env, which the caller has already
+// enclosed in the surrounding scope. It stops at the first runtime error, loop signal or
+// takeout and returns it so it can travel further up.
+func (e *Evaluator) evalBlockStatement(block *BlockStatement, env *Environment) Object {
+	for _, stmt := range block.Statements {
+		val := e.Eval(stmt, env)
+		if interruptsBlock(val) {
+			return val
+		}
+	}
+	return nil
+}
+
+// interruptsBlock reports whether a statement's result stops the rest of its block.
+func interruptsBlock(val Object) bool {
+	if val == nil {
+		return false
+	}
+	switch val.Type() {
+	case ERROR_OBJ, LOOP_SIGNAL_OBJ, RETURN_VALUE_OBJ:
+		return true
+	default:
+		return false
+	}
+}
+
+// evalWhileStatement runs the body of a noodles loop for as long as its condition is
+// truthy. The iterations run in a plain Go loop, so long loops do not grow the stack.
+func (e *Evaluator) evalWhileStatement(node *WhileStatement, env *Environment) Object {
+	for {
+		condition := e.Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		val := e.evalBlockStatement(node.Body, NewEnclosedEnvironment(env))
+		if isError(val) || isReturnValue(val) {
+			return val
+		}
+		if val == BREAK {
+			return nil
+		}
+	}
+}
+
+// evalForStatement runs the body of a donuts loop once for every integer in its range.
+// The loop variable is bound afresh for each iteration, so assigning to it in the body
+// does not change how often the loop runs.
+func (e *Evaluator) evalForStatement(node *ForStatement, env *Environment) Object {
+	start := e.Eval(node.Start, env)
+	if isError(start) {
+		return start
+	}
+	end := e.Eval(node.End, env)
+	if isError(end) {
+		return end
+	}
+
+	from, ok := start.(*Integer)
+	if !ok {
+		return newError("donuts expected an integer start, got %s", typeName(start))
+	}
+	to, ok := end.(*Integer)
+	if !ok {
+		return newError("donuts expected an integer end, got %s", typeName(end))
+	}
+
+	for i := from.Value; i < to.Value; i++ {
+		scope := NewEnclosedEnvironment(env)
+		if err := declare(scope, node.Variable, &Integer{Value: i}, ""); err != nil {
+			return err
+		}
+
+		val := e.evalBlockStatement(node.Body, NewEnclosedEnvironment(scope))
+		if isError(val) || isReturnValue(val) {
+			return val
+		}
+		if val == BREAK {
+			return nil
+		}
+	}
+	return nil
+}
+
+// evalCallExpression evaluates the function and then the arguments of a call from left to
+// right, and runs the body with the parameters bound in a scope enclosed by the one the
+// function was created in. Errors about the call itself carry its position.
+func (e *Evaluator) evalCallExpression(node *CallExpression, env *Environment) Object {
+	callee := e.Eval(node.Function, env)
+	if isError(callee) {
+		return callee
+	}
+
+	args, err := e.evalExpressions(node.Arguments, env)
+	if err != nil {
+		return err
+	}
+
+	if builtin, ok := callee.(*Builtin); ok {
+		val := builtin.Fn(args...)
+		if errObj, ok := val.(*Error); ok {
+			return newErrorAt(node.Token, "%s", errObj.Message)
+		}
+		return val
+	}
+
+	fn, ok := callee.(*Closure)
+	if !ok {
+		return newErrorAt(node.Token, "not a function: %s", typeName(callee))
+	}
+	if len(args) != len(fn.Parameters) {
+		return newErrorAt(node.Token, "wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+	}
+	if e.depth >= maxCallDepth {
+		return newErrorAt(node.Token, "too many nested calls (more than %d)", maxCallDepth)
+	}
+
+	scope := NewEnclosedEnvironment(fn.Env)
+	for i, param := range fn.Parameters {
+		if err := declare(scope, param, args[i], ""); err != nil {
+			return err
+		}
+	}
+
+	e.depth++
+	if e.hook != nil {
+		e.calls = append(e.calls, node)
+	}
+	val := e.evalBlockStatement(fn.Body, scope)
+	if e.hook != nil {
+		e.calls = e.calls[:len(e.calls)-1]
+	}
+	e.depth--
+
+	switch val := val.(type) {
+	case *Error:
+		return val
+	case *ReturnValue:
+		return val.Value
+	case *LoopSignal:
+		// The parser keeps these inside loops in the body, but hand-built programs may not.
+		return newErrorAt(node.Token, "%s outside of a loop", val.Inspect())
+	default:
+		return NULL
+	}
+}
+
+// evalExpressions evaluates a list of expressions from left to right, stopping at the
+// first runtime error.
+func (e *Evaluator) evalExpressions(exps []Expression, env *Environment) ([]Object, *Error) {
+	values := make([]Object, 0, len(exps))
+	for _, exp := range exps {
+		val := e.Eval(exp, env)
+		if errObj, ok := val.(*Error); ok {
+			return nil, errObj
+		}
+		values = append(values, val)
+	}
+	return values, nil
+}
+
+// evalHashLiteral evaluates each key and then its value, in the order they are written.
+// A key written twice keeps its first position and its last value.
+func (e *Evaluator) evalHashLiteral(node *HashLiteral, env *Environment) Object {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+
+	for i, keyNode := range node.Keys {
+		key := e.Eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+		hashable, ok := key.(Hashable)
+		if !ok {
+			return newErrorAt(node.Token, "unusable as hash key: %s", typeName(key))
+		}
+
+		value := e.Eval(node.Values[i], env)
+		if isError(value) {
+			return value
+		}
+
+		hashKey := hashable.HashKey()
+		if _, seen := hash.Pairs[hashKey]; !seen {
+			hash.Order = append(hash.Order, hashKey)
+		}
+		hash.Pairs[hashKey] = HashPair{Key: key, Value: value}
+	}
+
+	return hash
+}
+
+// evalIndexExpression looks up an element of an array by position or of a hash by key.
+// Errors carry the position of the [.
+func evalIndexExpression(tok Token, left, index Object) Object {
+	switch left := left.(type) {
+	case *Array:
+		i, ok := index.(*Integer)
+		if !ok {
+			return newErrorAt(tok, "array index must be nuggets, got %s", typeName(index))
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return newErrorAt(tok, "index out of range: %d with length %d", i.Value, len(left.Elements))
+		}
+		return left.Elements[i.Value]
+	case *Hash:
+		hashable, ok := index.(Hashable)
+		if !ok {
+			return newErrorAt(tok, "unusable as hash key: %s", typeName(index))
+		}
+		pair, ok := left.Pairs[hashable.HashKey()]
+		if !ok {
+			return newErrorAt(tok, "key not found: %s", inspectElement(index))
+		}
+		return pair.Value
+	default:
+		return newErrorAt(tok, "index operator not supported: %s", typeName(left))
+	}
+}
+
+// convertToNuggets converts a value to an integer for nuggets(...). Strings must hold a
+// decimal integer, optionally signed; cake and broccoli become 1 and 0.
+func convertToNuggets(tok Token, val Object) Object {
+	switch val := val.(type) {
+	case *Integer:
+		return val
+	case *Boolean:
+		if val.Value {
+			return &Integer{Value: 1}
+		}
+		return &Integer{Value: 0}
+	case *String:
+		value, err := strconv.ParseInt(val.Value, 10, 64)
+		if err != nil {
+			return newErrorAt(tok, "cannot convert %s to nuggets", quoteString(val.Value))
+		}
+		return &Integer{Value: value}
+	default:
+		return newErrorAt(tok, "cannot convert %s to nuggets", typeName(val))
+	}
+}
+
+// evalIfStatement runs the consequence of a conditional if its condition is truthy and
+// the alternative, if there is one, otherwise.
+func (e *Evaluator) evalIfStatement(node *IfStatement, env *Environment) Object {
+	condition := e.Eval(node.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	switch {
+	case isTruthy(condition):
+		return e.Eval(node.Consequence, env)
+	case node.Alternative != nil:
+		return e.Eval(node.Alternative, env)
+	default:
+		return nil
+	}
+}
+
+// evalTypeSwitchStatement runs the first case of a tacos statement that names the type of
+// its subject, or the fries case if there is one and no case matches.
+func (e *Evaluator) evalTypeSwitchStatement(node *TypeSwitchStatement, env *Environment) Object {
+	subject := e.Eval(node.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	name := typeName(subject)
+	for _, c := range node.Cases {
+		for _, t := range c.Types {
+			if t == name {
+				return e.Eval(c.Body, env)
+			}
+		}
+	}
+	if node.Default != nil {
+		return e.Eval(node.Default, env)
+	}
+	return nil
+}
+
+// isTruthy reports whether a value counts as true in a condition: broccoli and 0 are
+// false, every other value is true.
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Integer:
+		return obj.Value != 0
+	default:
+		return true
+	}
+}
+
+// evalAssignStatement updates an existing variable, applying the operator of a compound
+// assignment to its current value first.
+func (e *Evaluator) evalAssignStatement(node *AssignStatement, env *Environment) Object {
+	scope, slot, err := binding(env, node.Name)
+	if err != nil {
+		return err
+	}
+	if scope == nil {
+		return newError("cannot assign to undeclared identifier: %s", node.Name.Value)
+	}
+	current := scope.slots[slot]
+
+	val := e.Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if operator, ok := node.Operator(); ok {
+		tok := node.Token
+		tok.Type = operator
+		val = evalInfixExpression(tok, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+	if typ := scope.types[slot]; typ != "" && val.Type() != typ {
+		return newErrorAt(node.Name.Token, "%s %s cannot hold %s", typeNames[typ], node.Name.Value, typeName(val))
+	}
+
+	scope.slots[slot] = val
+	return nil
+}
+
+// binding returns the scope and slot of the binding an identifier refers to, or a nil
+// scope if there is none. A resolved identifier is reached through the resolver's
+// annotation alone; only unresolved ones are looked up by name.
+func binding(env *Environment, ident *Identifier) (*Environment, int, *Error) {
+	if !ident.Resolved {
+		scope, slot, _ := env.find(ident.Value)
+		return scope, slot, nil
+	}
+	scope, err := env.at(ident.Depth, ident.Slot, ident.Value)
+	if err != nil {
+		return nil, 0, newErrorAt(ident.Token, "%s", err)
+	}
+	return scope, ident.Slot, nil
+}
+
+// declare binds the name ident declares in env, in the slot the resolver gave it if it
+// was resolved.
+func declare(env *Environment, ident *Identifier, val Object, typ ObjectType) *Error {
+	if !ident.Resolved {
+		env.DeclareTyped(ident.Value, val, typ)
+		return nil
+	}
+	if err := env.DeclareAt(ident.Slot, ident.Value, val, typ); err != nil {
+		return newErrorAt(ident.Token, "%s", err)
+	}
+	return nil
+}
+
+// evalIdentifier looks up the value bound to an identifier in the innermost scope that has it.
+func evalIdentifier(node *Identifier, env *Environment) Object {
+	scope, slot, err := binding(env, node)
+	if err != nil {
+		return err
+	}
+	if scope != nil {
+		return scope.slots[slot]
+	}
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+// evalInputExpression reads the next integer from the input for icaco.
+func (e *Evaluator) evalInputExpression() Object {
+	var value int64
+	if _, err := fmt.Fscan(e.in, &value); err != nil {
+		return newError("icaco expected an integer")
+	}
+	return &Integer{Value: value}
+}
+
+// operatorSymbols gives the symbol error messages use for each operator token.
+var operatorSymbols = map[TokenType]string{
+	TOKEN_APPLE:     "+",
+	TOKEN_SALMON:    "-",
+	TOKEN_PANCAKES:  "*",
+	TOKEN_PIE:       "/",
+	TOKEN_LEFTOVERS: "%",
+	TOKEN_EQ:        "==",
+	TOKEN_NOT_EQ:    "!=",
+	TOKEN_LT:        "<",
+	TOKEN_GT:        ">",
+	TOKEN_LT_EQ:     "<=",
+	TOKEN_GT_EQ:     ">=",
+	TOKEN_BANG:      "!",
+}
+
+// operatorSymbol returns the symbol for an operator token, falling back to its name.
+func operatorSymbol(operator TokenType) string {
+	if symbol, ok := operatorSymbols[operator]; ok {
+		return symbol
+	}
+	return operator.String()
+}
+
+// evalPrefixExpression applies the prefix operator tok to an already evaluated operand.
+// Errors start with the position of tok, as do the ones of evalInfixExpression.
+func evalPrefixExpression(tok Token, right Object) Object {
+	operator := tok.Type
+	switch {
+	case operator == TOKEN_SALMON && right.Type() == INTEGER_OBJ:
+		value := right.(*Integer).Value
+		if value == math.MinInt64 {
+			return newErrorAt(tok, "integer overflow in salmon")
+		}
+		return &Integer{Value: -value}
+	case operator == TOKEN_BANG && right.Type() == BOOLEAN_OBJ:
+		return nativeBoolToBooleanObject(!right.(*Boolean).Value)
+	default:
+		return newErrorAt(tok, "unknown operator: %s%s", operatorSymbol(operator), typeName(right))
+	}
+}
+
+// evalInfixExpression applies the infix operator tok to two already evaluated operands.
+func evalInfixExpression(tok Token, left, right Object) Object {
+	operator := tok.Type
+	switch {
+	case left.Type() == INTEGER_OBJ && right.Type() == INTEGER_OBJ:
+		return evalIntegerInfixExpression(tok, left.(*Integer), right.(*Integer))
+	case left.Type() == BOOLEAN_OBJ && right.Type() == BOOLEAN_OBJ:
+		return evalBooleanInfixExpression(tok, left.(*Boolean), right.(*Boolean))
+	case left.Type() == STRING_OBJ && right.Type() == STRING_OBJ:
+		return evalStringInfixExpression(tok, left.(*String), right.(*String))
+	case left.Type() != right.Type():
+		return newErrorAt(tok, "type mismatch: %s %s %s", typeName(left), operatorSymbol(operator), typeName(right))
+	default:
+		return newErrorAt(tok, "unknown operator: %s %s %s", typeName(left), operatorSymbol(operator), typeName(right))
+	}
+}
+
+// evalBooleanInfixExpression compares two booleans; they support nothing else.
+func evalBooleanInfixExpression(tok Token, l, r *Boolean) Object {
+	switch operator := tok.Type; operator {
+	case TOKEN_EQ:
+		return nativeBoolToBooleanObject(l == r)
+	case TOKEN_NOT_EQ:
+		return nativeBoolToBooleanObject(l != r)
+	default:
+		return newErrorAt(tok, "unknown operator: %s %s %s", typeName(l), operatorSymbol(operator), typeName(r))
+	}
+}
+
+// evalStringInfixExpression joins two strings with apple and compares them byte by byte.
+func evalStringInfixExpression(tok Token, l, r *String) Object {
+	switch operator := tok.Type; operator {
+	case TOKEN_APPLE:
+		return &String{Value: l.Value + r.Value}
+	case TOKEN_EQ:
+		return nativeBoolToBooleanObject(l.Value == r.Value)
+	case TOKEN_NOT_EQ:
+		return nativeBoolToBooleanObject(l.Value != r.Value)
+	case TOKEN_LT:
+		return nativeBoolToBooleanObject(l.Value < r.Value)
+	case TOKEN_GT:
+		return nativeBoolToBooleanObject(l.Value > r.Value)
+	case TOKEN_LT_EQ:
+		return nativeBoolToBooleanObject(l.Value <= r.Value)
+	case TOKEN_GT_EQ:
+		return nativeBoolToBooleanObject(l.Value >= r.Value)
+	default:
+		return newErrorAt(tok, "unknown operator: %s %s %s", typeName(l), operatorSymbol(operator), typeName(r))
+	}
+}
+
+// evalIntegerInfixExpression applies arithmetic and comparison operators to two integers.
+func evalIntegerInfixExpression(tok Token, l, r *Integer) Object {
+	switch operator := tok.Type; operator {
+	case TOKEN_APPLE:
+		sum := l.Value + r.Value
+		// Overflow happened if both operands share a sign that the result does not.
+		if (l.Value >= 0) == (r.Value >= 0) && (sum >= 0) != (l.Value >= 0) {
+			return newErrorAt(tok, "integer overflow in apple")
+		}
+		return &Integer{Value: sum}
+	case TOKEN_SALMON:
+		diff := l.Value - r.Value
+		if (l.Value >= 0) != (r.Value >= 0) && (diff >= 0) != (l.Value >= 0) {
+			return newErrorAt(tok, "integer overflow in salmon")
+		}
+		return &Integer{Value: diff}
+	case TOKEN_PANCAKES:
+		product := l.Value * r.Value
+		// Overflow happened if dividing the product back out does not give the operand,
+		// which also catches MinInt64 * -1 since that product wraps to MinInt64.
+		if l.Value != 0 && (product/l.Value != r.Value || (l.Value == -1 && r.Value == math.MinInt64)) {
+			return newErrorAt(tok, "integer overflow in pancakes")
+		}
+		return &Integer{Value: product}
+	case TOKEN_PIE:
+		if r.Value == 0 {
+			return newErrorAt(tok, "division by zero")
+		}
+		if l.Value == math.MinInt64 && r.Value == -1 {
+			return newErrorAt(tok, "integer overflow in pie")
+		}
+		return &Integer{Value: l.Value / r.Value}
+	case TOKEN_LEFTOVERS:
+		if r.Value == 0 {
+			return newErrorAt(tok, "division by zero")
+		}
+		if r.Value == -1 {
+			// Avoids MinInt64 % -1, which overflows on some hardware; the remainder is always 0.
+			return &Integer{Value: 0}
+		}
+		return &Integer{Value: l.Value % r.Value}
+	case TOKEN_EQ:
+		return nativeBoolToBooleanObject(l.Value == r.Value)
+	case TOKEN_NOT_EQ:
+		return nativeBoolToBooleanObject(l.Value != r.Value)
+	case TOKEN_LT:
+		return nativeBoolToBooleanObject(l.Value < r.Value)
+	case TOKEN_GT:
+		return nativeBoolToBooleanObject(l.Value > r.Value)
+	case TOKEN_LT_EQ:
+		return nativeBoolToBooleanObject(l.Value <= r.Value)
+	case TOKEN_GT_EQ:
+		return nativeBoolToBooleanObject(l.Value >= r.Value)
+	default:
+		return newErrorAt(tok, "unknown operator: %s %s %s", typeName(l), operatorSymbol(operator), typeName(r))
+	}
+}
+
+// prefixResultType returns the type of the value a prefix operator produces for an
+// operand of the given type, or the error evaluating it would raise. The compilers use
+// it to type a program before it runs.
+func prefixResultType(operator TokenType, right ObjectType) (ObjectType, error) {
+	switch {
+	case operator == TOKEN_SALMON && right == INTEGER_OBJ:
+		return INTEGER_OBJ, nil
+	case operator == TOKEN_BANG && right == BOOLEAN_OBJ:
+		return BOOLEAN_OBJ, nil
+	default:
+		return "", fmt.Errorf("unknown operator: %s%s", operatorSymbol(operator), typeNameOf(right))
+	}
+}
+
+// infixResultType is the static counterpart of evalInfixExpression.
+func infixResultType(operator TokenType, left, right ObjectType) (ObjectType, error) {
+	switch {
+	case left == INTEGER_OBJ && right == INTEGER_OBJ:
+		switch operator {
+		case TOKEN_APPLE, TOKEN_SALMON, TOKEN_PANCAKES, TOKEN_PIE, TOKEN_LEFTOVERS:
+			return INTEGER_OBJ, nil
+		case TOKEN_EQ, TOKEN_NOT_EQ, TOKEN_LT, TOKEN_GT, TOKEN_LT_EQ, TOKEN_GT_EQ:
+			return BOOLEAN_OBJ, nil
+		}
+	case left == BOOLEAN_OBJ && right == BOOLEAN_OBJ:
+		if operator == TOKEN_EQ || operator == TOKEN_NOT_EQ {
+			return BOOLEAN_OBJ, nil
+		}
+	case left == STRING_OBJ && right == STRING_OBJ:
+		switch operator {
+		case TOKEN_APPLE:
+			return STRING_OBJ, nil
+		case TOKEN_EQ, TOKEN_NOT_EQ, TOKEN_LT, TOKEN_GT, TOKEN_LT_EQ, TOKEN_GT_EQ:
+			return BOOLEAN_OBJ, nil
+		}
+	case left != right:
+		return "", fmt.Errorf("type mismatch: %s %s %s", typeNameOf(left), operatorSymbol(operator), typeNameOf(right))
+	}
+	return "", fmt.Errorf("unknown operator: %s %s %s", typeNameOf(left), operatorSymbol(operator), typeNameOf(right))
+}
+
+// newError builds a runtime error object from a format string.
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// newErrorAt builds a runtime error object that starts with the line and column of tok.
+func newErrorAt(tok Token, format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf("%d:%d: ", tok.Line, tok.Column) + fmt.Sprintf(format, a...)}
+}
+
+// isReturnValue reports whether obj is a takeout on its way to the call that returns it.
+func isReturnValue(obj Object) bool {
+	return obj != nil && obj.Type() == RETURN_VALUE_OBJ
+}
+
+// isError reports whether obj is a runtime error.
+func isError(obj Object) bool {
+	return obj != nil && obj.Type() == ERROR_OBJ
+}
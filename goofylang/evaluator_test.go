@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEvalPrograms(t *testing.T) {
+	tests := []struct {
+		input    string
+		stdin    string
+		expected string
+	}{
+		{"cheese x = 5; pizza x;", "", "5\n"},
+		{"cheese x = 5; cheese y = x apple 10 - 3; pizza y;", "", "12\n"},
+		{"cheese x = icaco; cheese y = icaco; pizza x - y; pizza salmon x;", "10 4", "6\n-10\n"},
+		{"pizza 1 + 2 + 3;", "", "6\n"},
+		{"pizza 10 - (3 - 2);", "", "9\n"},
+		{"pizza 2 + 3 * 4; pizza (2 + 3) pancakes 4;", "", "14\n20\n"},
+		{"pizza 7 / 2; pizza -7 pie 2; pizza 7 % 3; pizza -7 leftovers 3;", "", "3\n-3\n1\n-1\n"},
+		{"pizza -9223372036854775807 - 1 % -1;", "", "-9223372036854775807\n"},
+		{"cheese x = 6; x *= 7; x pie= 4; x %= 4; pizza x;", "", "2\n"},
+		{"cheese x = 1; cheese x = x + 1; pizza x;", "", "2\n"},
+		{"cheese x = 1; x = x apple 1; pizza x;", "", "2\n"},
+		{"cheese x = 10; x += 5; x salmon= 3; x -= 1; x apple= icaco; pizza x;", "100", "111\n"},
+		{"pizza cake; pizza broccoli; pizza !cake;", "", "cake\nbroccoli\nbroccoli\n"},
+		{"pizza 1 < 2; pizza 2 > 3; pizza 2 <= 2; pizza 1 >= 2;", "", "cake\nbroccoli\ncake\nbroccoli\n"},
+		{"pizza 010; pizza 08;", "", "10\n8\n"}, // leading zeros do not make a literal octal
+		{"pizza 1 + 1 == 2; pizza 3 != 3; pizza cake == broccoli; pizza cake != broccoli;", "", "cake\nbroccoli\nbroccoli\ncake\n"},
+		{"cheese big = icaco > 10; pizza big == (5 < 3);", "42", "broccoli\n"},
+		{"waffles 1 < 2 { pizza 1; } fries { pizza 2; }", "", "1\n"},
+		{"waffles 1 > 2 { pizza 1; } fries { pizza 2; }", "", "2\n"},
+		{"waffles broccoli { pizza 1; } pizza 3;", "", "3\n"},
+		{"waffles 0 { pizza 1; } waffles -5 { pizza 2; } waffles cake { pizza 3; }", "", "2\n3\n"},
+		{"cheese x = icaco; waffles x > 10 { pizza 1; } fries waffles x > 5 { pizza 2; } fries { pizza 3; }", "7", "2\n"},
+		{"cheese x = 1; waffles cake { cheese x = 2; pizza x; x = 3; } pizza x;", "", "2\n1\n"},
+		{"cheese x = 1; waffles cake { x = 2; cheese y = x; } pizza x;", "", "2\n"},
+		{"cheese x = 1; { cheese x = x + 1; { x += 10; pizza x; } } pizza x;", "", "12\n1\n"},
+		{"cheese n = 3; noodles n > 0 { pizza n; n -= 1; }", "", "3\n2\n1\n"},
+		{"donuts i = 0, 3 { pizza i; }", "", "0\n1\n2\n"},
+		{"donuts i = 5, icaco { pizza i; } pizza 0;", "2", "0\n"},
+		{"cheese n = 2; donuts i = 0, n { n = 10; i = 100; pizza i; }", "", "100\n100\n"},
+		{"cheese i = 7; donuts i = 0, 2 { } pizza i;", "", "7\n"},
+		{"donuts i = 0, 10 { waffles i % 2 == 0 { seconds; } waffles i > 6 { dessert; } pizza i; }", "", "1\n3\n5\n"},
+		{"cheese x = 0; noodles cake { x += 1; waffles x == 3 { dessert; } } pizza x;", "", "3\n"},
+		{"donuts i = 0, 3 { donuts j = 0, 3 { waffles j == 1 { dessert; } pizza i * 10 + j; } }", "", "0\n10\n20\n"},
+		{"cheese n = 0; noodles n < 1000000 { n += 1; } pizza n;", "", "1000000\n"},
+		{"cheese add = burrito(a, b) { takeout a + b; }; pizza add(2, 3);", "", "5\n"},
+		{"pizza burrito(x) { takeout x * 2; }(21);", "", "42\n"},
+		{"cheese fact = burrito(n) { waffles n < 2 { takeout 1; } takeout n * fact(n - 1); }; pizza fact(20);", "", "2432902008176640000\n"},
+		{"cheese counter = burrito() { cheese c = 0; takeout burrito() { c += 1; takeout c; }; }; cheese next = counter(); next(); pizza next(); pizza counter()();", "", "2\n1\n"},
+		{"cheese twice = burrito(f, x) { takeout f(f(x)); }; pizza twice(burrito(n) { takeout n + 3; }, 1);", "", "7\n"},
+		{"cheese x = 1; cheese bump = burrito() { x += 1; }; bump(); bump(); pizza x;", "", "3\n"},
+		{"cheese x = 1; cheese f = burrito(x) { x = 5; takeout x; }; pizza f(2); pizza x;", "", "5\n1\n"},
+		{"cheese first = burrito(limit) { donuts i = 0, 100 { waffles i * i > limit { takeout i; } } takeout 0; }; pizza first(50);", "", "8\n"},
+		{"cheese f = burrito() { pizza 1; takeout; pizza 2; }; pizza f();", "", "1\nnothing\n"},
+		{"noodles cake { cheese f = burrito() { takeout 1; }; f(); dessert; } pizza 3;", "", "3\n"},
+		{"cheese deep = burrito(n) { waffles n == 0 { takeout 0; } takeout deep(n - 1); }; pizza deep(5000);", "", "0\n"},
+		{`pizza "hi" apple " there";`, "", "hi there\n"},
+		{`cheese s = "a"; s += "b"; s = s + "c"; pizza s;`, "", "abc\n"},
+		{`pizza "say \"hi\"\tnow\\";`, "", "say \"hi\"\tnow\\\n"},
+		{`pizza "abc" == "abc"; pizza "abc" != "abd"; pizza "abc" < "abd"; pizza "b" <= "a"; pizza "" < "a";`, "", "cake\ncake\ncake\nbroccoli\ncake\n"},
+		{`cheese greet = burrito(name) { takeout "hello " + name; }; pizza greet("goofy");`, "", "hello goofy\n"},
+		{"cheese a = [1, 2 + 3, cake]; pizza a; pizza a[1]; pizza len(a);", "", "[1, 5, cake]\n5\n3\n"},
+		{`cheese a = ["x"]; cheese b = push(a, "y"); pizza a; pizza b; pizza len("four");`, "", "[\"x\"]\n[\"x\", \"y\"]\n4\n"},
+		{`cheese h = {"one": 1, 2: "two", cake: [3]}; pizza h["one"]; pizza h[1 + 1]; pizza h[cake][0]; pizza h;`, "", "1\ntwo\n3\n{\"one\": 1, 2: \"two\", cake: [3]}\n"},
+		{`cheese h = {"b": 1, "a": 2, "b": 3}; pizza keys(h); pizza len(h); pizza h["b"];`, "", "[\"b\", \"a\"]\n2\n3\n"},
+		{"cheese sum = 0; cheese a = [4, 5, 6]; donuts i = 0, len(a) { sum += a[i]; } pizza sum;", "", "15\n"},
+		{"cheese a = []; donuts i = 0, 3 { a = push(a, i * i); } pizza a; pizza len({});", "", "[0, 1, 4]\n0\n"},
+		{"cheese len = burrito(x) { takeout 7; }; pizza len([1]);", "", "7\n"},
+		{"pizza [burrito(x) { takeout x + 1; }][0](1); pizza len;", "", "2\nbuiltin len\n"},
+		{`pizza tacos 1; pizza tacos "s"; pizza tacos cake; pizza tacos burrito() { }; pizza tacos len; pizza tacos []; pizza tacos {}; pizza tacos burrito() { }();`, "", "nuggets\nstring\nboolean\nfunction\nfunction\narray\nhash\nnothing\n"},
+		{`pizza tacos 1 == "nuggets"; pizza tacos tacos 1;`, "", "cake\nstring\n"},
+		{"cheese show = burrito(v) { tacos v { nuggets { takeout v + 1; } string, array { takeout len(v); } fries { takeout tacos v; } } }; pizza show(1); pizza show(\"abc\"); pizza show([1, 2]); pizza show(cake);", "", "2\n3\n2\nboolean\n"},
+		{"tacos cake { nuggets { pizza 1; } } pizza 2;", "", "2\n"},
+		{"cheese x = 1; tacos x { nuggets { cheese x = 2; x = 3; pizza x; } } pizza x;", "", "3\n1\n"},
+		{`cheese nuggets x = 5; x += 2; pizza x; pizza nuggets("42") + 1; pizza nuggets("-7"); pizza nuggets(cake) + nuggets(broccoli); pizza nuggets(9);`, "", "7\n43\n-7\n1\n9\n"},
+		{`cheese nuggets x = 1; cheese x = "now a string"; pizza x;`, "", "now a string\n"},
+		{`cheese nuggets x = 1; waffles cake { cheese x = "inner"; x = "still inner"; pizza x; } pizza x;`, "", "still inner\n1\n"},
+		{"cheese nuggets n = nuggets(icaco); pizza tacos n;", "12", "nuggets\n"},
+		{"donuts i = 0, 5 { tacos i { nuggets { waffles i == 2 { dessert; } } } pizza i; }", "", "0\n1\n"},
+	}
+
+	for _, tt := range tests {
+		out, result := testEval(t, tt.input, tt.stdin)
+		if isError(result) {
+			t.Errorf("%q: unexpected error %s", tt.input, result.Inspect())
+			continue
+		}
+		if out != tt.expected {
+			t.Errorf("%q: wrong output. expected=%q, got=%q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		stdin    string
+		expected string
+	}{
+		{"pizza y;", "", "identifier not found: y"},
+		{"cheese x = icaco;", "", "icaco expected an integer"},
+		{"cheese x = icaco;", "pie", "icaco expected an integer"},
+		{"pizza 9223372036854775807 + 1;", "", "1:27: integer overflow in apple"},
+		{"pizza salmon 9223372036854775807 - 2;", "", "1:34: integer overflow in salmon"},
+		{"x = 1;", "", "cannot assign to undeclared identifier: x"},
+		{"pizza 1 / 0;", "", "1:9: division by zero"},
+		{"pizza 1 leftovers (2 - 2);", "", "1:9: division by zero"},
+		{"cheese x = 5; x pie= 0;", "", "1:17: division by zero"},
+		{"pizza 4611686018427387904 * 2;", "", "1:27: integer overflow in pancakes"},
+		{"pizza (-9223372036854775807 - 1) / -1;", "", "1:34: integer overflow in pie"},
+		{"x += 1;", "", "cannot assign to undeclared identifier: x"},
+		{"cheese x = 9223372036854775807; x apple= 1;", "", "1:35: integer overflow in apple"},
+		{"pizza 1 + cake;", "", "1:9: type mismatch: nuggets + boolean"},
+		{"pizza broccoli == 0;", "", "1:16: type mismatch: boolean == nuggets"},
+		{"pizza cake < broccoli;", "", "1:12: unknown operator: boolean < boolean"},
+		{"pizza -cake;", "", "1:7: unknown operator: -boolean"},
+		{"pizza !1;", "", "1:7: unknown operator: !nuggets"},
+		{"cheese b = cake; b += 1;", "", "1:20: type mismatch: boolean + nuggets"},
+		{"waffles cake { cheese y = 1; } pizza y;", "", "identifier not found: y"},
+		{"waffles nope { pizza 1; }", "", "identifier not found: nope"},
+		{"waffles cake { pizza 1 / 0; pizza 2; }", "", "1:24: division by zero"},
+		{"donuts i = cake, 3 { }", "", "donuts expected an integer start, got boolean"},
+		{"donuts i = 0, broccoli { }", "", "donuts expected an integer end, got boolean"},
+		{"noodles cake { pizza 1 / 0; }", "", "1:24: division by zero"},
+		{"donuts i = 0, 3 { cheese y = i; } pizza y;", "", "identifier not found: y"},
+		{"cheese f = 3; pizza f(1);", "", "1:22: not a function: nuggets"},
+		{"cheese f = burrito(a, b) { takeout a; };\n  f(1);", "", "2:4: wrong number of arguments: want=2, got=1"},
+		{"cake();", "", "1:5: not a function: boolean"},
+		{"cheese f = burrito(x) { takeout f(x); }; f(1);", "", "1:34: too many nested calls (more than 10000)"},
+		{"cheese f = burrito() { takeout 1 / 0; }; pizza f() + 1;", "", "1:34: division by zero"},
+		{"cheese f = burrito() { }; pizza f() + 1;", "", "1:37: type mismatch: nothing + nuggets"},
+		{`pizza "a" + 1;`, "", "1:11: type mismatch: string + nuggets"},
+		{"pizza [1, 2][2];", "", "1:13: index out of range: 2 with length 2"},
+		{"pizza [1][-1];", "", "1:10: index out of range: -1 with length 1"},
+		{"pizza [1][cake];", "", "1:10: array index must be nuggets, got boolean"},
+		{`pizza {"a": 1}["b"];`, "", `1:15: key not found: "b"`},
+		{"pizza {[1]: 2};", "", "1:7: unusable as hash key: array"},
+		{"pizza {1: 2}[[1]];", "", "1:13: unusable as hash key: array"},
+		{"pizza 5[0];", "", "1:8: index operator not supported: nuggets"},
+		{"pizza len(1);", "", "1:10: argument to len not supported, got nuggets"},
+		{"pizza len([1], [2]);", "", "1:10: wrong number of arguments: want=1, got=2"},
+		{"pizza push(1, 2);", "", "1:11: first argument to push must be array, got nuggets"},
+		{"pizza keys([1]);", "", "1:11: argument to keys must be hash, got array"},
+		{"pizza [1, 1 / 0];", "", "1:13: division by zero"},
+		{"pizza tacos nope;", "", "identifier not found: nope"},
+		{`cheese nuggets x = "5";`, "", "1:16: nuggets x cannot hold string"},
+		{"cheese nuggets x = 1;\nx = cake;", "", "2:1: nuggets x cannot hold boolean"},
+		{"cheese nuggets x = 1; waffles cake { x = [x]; }", "", "1:38: nuggets x cannot hold array"},
+		{`pizza nuggets("12a");`, "", `1:7: cannot convert "12a" to nuggets`},
+		{`pizza nuggets("99999999999999999999");`, "", `1:7: cannot convert "99999999999999999999" to nuggets`},
+		{"pizza nuggets([1]);", "", "1:7: cannot convert array to nuggets"},
+		{"tacos (1 / 0) { fries { pizza 1; } }", "", "1:10: division by zero"},
+		{`pizza "a" - "b";`, "", "1:11: unknown operator: string - string"},
+		{`pizza -"a";`, "", "1:7: unknown operator: -string"},
+		{"cheese f = burrito() { takeout y; }; cheese y = 1; f(); cheese g = burrito() { cheese z = 1; }; g(); pizza z;", "", "identifier not found: z"},
+	}
+
+	for _, tt := range tests {
+		_, result := testEval(t, tt.input, tt.stdin)
+		errObj, ok := result.(*Error)
+		if !ok {
+			t.Errorf("%q: expected an error, got=%v", tt.input, result)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestEvalProgramResult(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"5;", int64(5)},
+		{"cheese x = 2; x apple 3;", int64(5)},
+		{"cheese x = 2; x + 1; cheese y = 7;", int64(3)}, // later bindings do not reset the result
+		{"cheese x = 2; pizza x;", nil},
+		{"1; 2; 3;", int64(3)},
+		{"3 > 2;", true},
+		{"cheese x = cake; !x;", false},
+		{"1; waffles cake { 2; }", int64(1)}, // only top level expression statements count
+	}
+
+	for _, tt := range tests {
+		_, result := testEval(t, tt.input, "")
+		if tt.expected == nil {
+			if result != nil {
+				t.Errorf("%q: expected no result, got=%s", tt.input, result.Inspect())
+			}
+			continue
+		}
+		switch expected := tt.expected.(type) {
+		case int64:
+			testIntegerObject(t, result, expected)
+		case bool:
+			testBooleanObject(t, result, expected)
+		}
+	}
+}
+
+func TestStatementHook(t *testing.T) {
+	input := `cheese f = burrito(n) {
+    takeout n pancakes 2;
+};
+donuts i = 0, 2 { pizza f(i); }
+pizza "done";`
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	var visited []string
+	var out bytes.Buffer
+	e := NewEvaluator(strings.NewReader(""), &out)
+	e.SetStatementHook(func(stmt Statement, env *Environment) *Error {
+		visited = append(visited, fmt.Sprintf("%d@%d", spanOf(stmt).Line, len(e.Calls())))
+		if _, ok := env.Get("f"); ok && spanOf(stmt).Line == 5 {
+			return newError("stopped")
+		}
+		return nil
+	})
+	result := e.Eval(program, NewEnvironment())
+
+	expected := "1@0 4@0 4@0 2@1 4@0 2@1 5@0"
+	if got := strings.Join(visited, " "); got != expected {
+		t.Errorf("wrong statements visited.\nexpected=%s\ngot=%s", expected, got)
+	}
+	if errObj, ok := result.(*Error); !ok || errObj.Message != "stopped" {
+		t.Errorf("expected the hook to stop the program. got=%v", result)
+	}
+	if out.String() != "0\n2\n" {
+		t.Errorf("wrong output: %q", out.String())
+	}
+	if calls := e.Calls(); len(calls) != 0 {
+		t.Errorf("expected no calls in progress. got=%d", len(calls))
+	}
+}
+
+func testEval(t *testing.T, input, stdin string) (string, Object) {
+	l := NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	var out bytes.Buffer
+	result := NewEvaluator(strings.NewReader(stdin), &out).Eval(program, NewEnvironment())
+
+	// Resolved identifiers are found by slot instead of by name, which must not change
+	// what the program does.
+	NewResolver(nil).Resolve(program)
+	var resolvedOut bytes.Buffer
+	resolved := NewEvaluator(strings.NewReader(stdin), &resolvedOut).Eval(program, NewEnvironment())
+	if resolvedOut.String() != out.String() || inspect(resolved) != inspect(result) {
+		t.Errorf("resolving changed the result of %q.\nbefore=%q, %s\nafter=%q, %s", input, out.String(), inspect(result), resolvedOut.String(), inspect(resolved))
+	}
+	return out.String(), result
+}
+
+// inspect formats a result of Eval, which may be nil.
+func inspect(obj Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
+
+func testBooleanObject(t *testing.T, obj Object, expected bool) bool {
+	result, ok := obj.(*Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestHashKeys(t *testing.T) {
+	// Keys are equal exactly when the values are ==, whatever their hashes would be.
+	keys := []Hashable{&String{Value: "a"}, &String{Value: "b"}, &String{Value: ""}, &Integer{Value: 0}, &Boolean{Value: false}}
+	for i, a := range keys {
+		for j, b := range keys {
+			if same := a.HashKey() == b.HashKey(); same != (i == j) {
+				t.Errorf("HashKey of %s == HashKey of %s is %t", a.(Object).Inspect(), b.(Object).Inspect(), same)
+			}
+		}
+	}
+	if (&String{Value: "a"}).HashKey() != (&String{Value: "a"}).HashKey() {
+		t.Errorf("equal strings have different keys")
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The formatter prints a program back as source in one canonical layout: one statement
+// per line, blocks indented by four spaces, a space around every infix operator and
+// only the parentheses the precedence of the operators needs. Operators keep the
+// spelling they were written with, and so do blank lines between statements, though
+// several in a row become one.
+//
+// Comments are not part of the syntax tree, so the formatter places them by position. A
+// comment on the line where a statement ends stays at the end of it, and every other
+// comment between statements goes on its own line before the statement or closing brace
+// that follows it. A comment inside a statement, such as in the middle of an array
+// literal, stays next to the expression or closing bracket that follows it; after a line
+// comment there, the statement goes on in the next line, indented one level more.
+
+// Format parses source and returns it formatted. Formatting its own output changes
+// nothing. Source the parser did not fully accept is refused rather than formatted, as
+// the formatted program would lose whatever could not be read.
+func Format(source string) (string, error) {
+	p := NewParser(NewLexer(source))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(errs, "\n\t"))
+	}
+	if node, ok := incompleteNode(program); ok {
+		span := spanOf(node)
+		return "", fmt.Errorf("%d:%d: the parser could not read all of %s", span.Line, span.Column, node.String())
+	}
+	return FormatProgram(program, p.Comments()), nil
+}
+
+// FormatProgram prints a parsed program with the comments of its source.
+func FormatProgram(program *Program, comments []Token) string {
+	f := &formatter{comments: comments}
+	f.statements(program.Statements, 0, Token{})
+	f.flushComments(Span{Line: int(^uint(0) >> 1)}, 0)
+	return f.out.String()
+}
+
+// formatter holds the state of printing one program.
+type formatter struct {
+	out      strings.Builder
+	comments []Token    // The comments not printed yet.
+	lastLine int        // The source line the last thing printed ended on, or 0 at the start of a block.
+	grouped  Expression // An expression to print in parentheses whatever its precedence.
+}
+
+// before reports whether a token starts before the position of span.
+func before(tok Token, span Span) bool {
+	return tok.Line < span.Line || tok.Line == span.Line && tok.Column < span.Column
+}
+
+// line starts a new line at depth, keeping one blank line if the source had at least one
+// before line. The first line of a block never gets one.
+func (f *formatter) line(depth, line int) {
+	if f.lastLine != 0 && line > f.lastLine+1 {
+		f.out.WriteString("\n")
+	}
+	f.out.WriteString(strings.Repeat("    ", depth))
+}
+
+// flushComments prints, each on its own line, the comments that start before span.
+func (f *formatter) flushComments(span Span, depth int) {
+	for len(f.comments) > 0 && before(f.comments[0], span) {
+		c := f.comments[0]
+		f.comments = f.comments[1:]
+		f.line(depth, c.Line)
+		f.out.WriteString(strings.TrimRight(c.Literal, " \t\r") + "\n")
+		if c.EndLine > f.lastLine {
+			f.lastLine = c.EndLine
+		}
+	}
+}
+
+// trailingComment prints a comment that starts on line after what was just printed, as
+// long as it comes before the token end. An end without a position sets no limit.
+func (f *formatter) trailingComment(line int, end Token) {
+	if len(f.comments) > 0 && f.comments[0].Line == line && (end.Line == 0 || before(f.comments[0], tokenSpan(end))) {
+		f.out.WriteString(" " + strings.TrimRight(f.comments[0].Literal, " \t\r"))
+		f.comments = f.comments[1:]
+	}
+}
+
+// statements prints a list of statements at depth, followed by the comments that come
+// before end, the closing brace of the block holding them.
+func (f *formatter) statements(stmts []Statement, depth int, end Token) {
+	for _, stmt := range stmts {
+		span := spanOf(stmt)
+		f.flushComments(span, depth)
+		f.line(depth, span.Line)
+		f.statement(stmt, depth)
+		// A comment before the last token of the statement that no expression or bracket
+		// followed, as in cheese /* here */ x = 1;, stays in the statement too.
+		f.inlineComments(Span{Line: span.EndLine, Column: span.EndColumn}, depth+1, false)
+		f.trailingComment(span.EndLine, end)
+		f.out.WriteString("\n")
+		f.lastLine = span.EndLine
+	}
+	if end.Line != 0 {
+		f.flushComments(tokenSpan(end), depth)
+	}
+}
+
+// inlineComments prints, in the middle of the line being printed, the comments that
+// start before span. A line comment ends the line, so what follows goes on the next one
+// at depth. leading tells whether an expression follows the comments, which then need a
+// space after them.
+func (f *formatter) inlineComments(span Span, depth int, leading bool) {
+	for len(f.comments) > 0 && before(f.comments[0], span) {
+		c := f.comments[0]
+		f.comments = f.comments[1:]
+		if out := f.out.String(); out != "" && !strings.ContainsRune(" ([{\n", rune(out[len(out)-1])) {
+			f.out.WriteString(" ")
+		}
+		f.out.WriteString(strings.TrimRight(c.Literal, " \t\r"))
+		if strings.HasPrefix(c.Literal, "//") {
+			f.out.WriteString("\n" + strings.Repeat("    ", depth))
+		} else if leading {
+			f.out.WriteString(" ")
+		}
+	}
+}
+
+// closing prints the closing bracket tok, after the comments that come before it.
+func (f *formatter) closing(tok Token, depth int) {
+	if tok.Line != 0 {
+		f.inlineComments(tokenSpan(tok), depth, false)
+	}
+	f.out.WriteString(tok.Literal)
+}
+
+func (f *formatter) statement(stmt Statement, depth int) {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		f.out.WriteString("cheese ")
+		if stmt.Annotation != nil {
+			f.out.WriteString(stmt.Annotation.Literal + " ")
+		}
+		f.out.WriteString(stmt.Name.Value + " = ")
+		f.expression(stmt.Value, LOWEST, depth)
+		f.out.WriteString(";")
+	case *AssignStatement:
+		f.out.WriteString(stmt.Name.Value + " " + stmt.Token.Literal + " ")
+		f.expression(stmt.Value, LOWEST, depth)
+		f.out.WriteString(";")
+	case *ExpressionStatement:
+		if hash, ok := leftmost(stmt.Expression).(*HashLiteral); ok {
+			// A brace opening a statement would be read as a block.
+			f.grouped = hash
+		}
+		f.expression(stmt.Expression, LOWEST, depth)
+		f.out.WriteString(";")
+	case *PrintStatement:
+		f.out.WriteString("pizza ")
+		f.expression(stmt.Value, LOWEST, depth)
+		f.out.WriteString(";")
+	case *ReturnStatement:
+		f.out.WriteString("takeout")
+		if stmt.Value != nil {
+			f.out.WriteString(" ")
+			f.expression(stmt.Value, LOWEST, depth)
+		}
+		f.out.WriteString(";")
+	case *LoopControlStatement:
+		f.out.WriteString(stmt.Token.Literal + ";")
+	case *BlockStatement:
+		f.block(stmt, depth)
+	case *IfStatement:
+		f.out.WriteString("waffles ")
+		f.expression(stmt.Condition, LOWEST, depth)
+		f.out.WriteString(" ")
+		f.block(stmt.Consequence, depth)
+		if stmt.Alternative != nil {
+			f.out.WriteString(" fries ")
+			f.statement(stmt.Alternative, depth)
+		}
+	case *WhileStatement:
+		f.out.WriteString("noodles ")
+		f.expression(stmt.Condition, LOWEST, depth)
+		f.out.WriteString(" ")
+		f.block(stmt.Body, depth)
+	case *ForStatement:
+		f.out.WriteString("donuts " + stmt.Variable.Value + " = ")
+		f.expression(stmt.Start, LOWEST, depth)
+		f.out.WriteString(", ")
+		f.expression(stmt.End, LOWEST, depth)
+		f.out.WriteString(" ")
+		f.block(stmt.Body, depth)
+	case *TypeSwitchStatement:
+		f.out.WriteString("tacos ")
+		f.expression(stmt.Subject, PREFIX, depth)
+		f.out.WriteString(" {")
+		first := stmt.Rbrace
+		if len(stmt.Cases) > 0 {
+			first = stmt.Cases[0].Token
+		} else if stmt.Default != nil {
+			first = stmt.Default.Token
+		}
+		f.trailingComment(stmt.Token.Line, first)
+		f.out.WriteString("\n")
+		f.lastLine = 0
+		for _, c := range stmt.Cases {
+			f.flushComments(tokenSpan(c.Token), depth+1)
+			f.line(depth+1, c.Token.Line)
+			f.out.WriteString(strings.Join(c.Types, ", ") + " ")
+			f.block(c.Body, depth+1)
+			f.trailingComment(c.Body.Rbrace.Line, stmt.Rbrace)
+			f.out.WriteString("\n")
+			f.lastLine = c.Body.Rbrace.Line
+		}
+		if stmt.Default != nil {
+			f.flushComments(tokenSpan(stmt.Default.Token), depth+1)
+			f.line(depth+1, stmt.Default.Token.Line)
+			f.out.WriteString("fries ")
+			f.block(stmt.Default, depth+1)
+			f.trailingComment(stmt.Default.Rbrace.Line, stmt.Rbrace)
+			f.out.WriteString("\n")
+			f.lastLine = stmt.Default.Rbrace.Line
+		}
+		f.out.WriteString(strings.Repeat("    ", depth) + "}")
+	}
+}
+
+// block prints a block whose opening brace goes at the end of the current line.
+func (f *formatter) block(block *BlockStatement, depth int) {
+	if len(block.Statements) == 0 && (len(f.comments) == 0 || !before(f.comments[0], tokenSpan(block.Rbrace))) {
+		f.out.WriteString("{}")
+		return
+	}
+	f.out.WriteString("{")
+	first := block.Rbrace
+	if len(block.Statements) > 0 {
+		first = Token{Line: spanOf(block.Statements[0]).Line, Column: spanOf(block.Statements[0]).Column}
+	}
+	f.trailingComment(block.Token.Line, first)
+	f.out.WriteString("\n")
+	f.lastLine = 0
+	f.statements(block.Statements, depth+1, block.Rbrace)
+	f.out.WriteString(strings.Repeat("    ", depth) + "}")
+}
+
+// leftmost returns the expression an expression starts with when printed.
+func leftmost(exp Expression) Expression {
+	switch exp := exp.(type) {
+	case *InfixExpression:
+		return leftmost(exp.Left)
+	case *CallExpression:
+		return leftmost(exp.Function)
+	case *IndexExpression:
+		return leftmost(exp.Left)
+	default:
+		return exp
+	}
+}
+
+// expressionPrecedence returns how tightly an expression binds as printed.
+func expressionPrecedence(exp Expression) int {
+	switch exp := exp.(type) {
+	case *InfixExpression:
+		return precedences[exp.Token.Type]
+	case *PrefixExpression, *TypeofExpression:
+		return PREFIX
+	default:
+		return INDEX
+	}
+}
+
+// expression prints exp, in parentheses if it binds less tightly than the context needs:
+// prec is the lowest precedence exp can have without them.
+func (f *formatter) expression(exp Expression, prec, depth int) {
+	if exp == nil {
+		return
+	}
+	if span := spanOf(exp); span.Line != 0 {
+		f.inlineComments(span, depth+1, true)
+	}
+	if expressionPrecedence(exp) < prec || exp == f.grouped {
+		f.out.WriteString("(")
+		defer f.out.WriteString(")")
+	}
+
+	switch exp := exp.(type) {
+	case *Identifier:
+		f.out.WriteString(exp.Value)
+	case *IntegralLiteral:
+		f.out.WriteString(fmt.Sprint(exp.Value))
+	case *StringLiteral:
+		f.out.WriteString(quoteString(exp.Value))
+	case *BooleanLiteral:
+		if exp.Value {
+			f.out.WriteString("cake")
+		} else {
+			f.out.WriteString("broccoli")
+		}
+	case *InputExpression:
+		f.out.WriteString("icaco")
+	case *PrefixExpression:
+		f.out.WriteString(exp.Operator)
+		if right, ok := exp.Right.(*PrefixExpression); isLetter(exp.Operator[0]) || ok && !isLetter(right.Operator[0]) {
+			// Keep words apart, and - -x from reading like a decrement.
+			f.out.WriteString(" ")
+		}
+		f.expression(exp.Right, PREFIX, depth)
+	case *TypeofExpression:
+		f.out.WriteString("tacos ")
+		f.expression(exp.Right, PREFIX, depth)
+	case *InfixExpression:
+		// Operators group to the left, so a right operand of the same precedence needs
+		// parentheses and a left one does not.
+		p := precedences[exp.Token.Type]
+		f.expression(exp.Left, p, depth)
+		f.out.WriteString(" " + exp.Operator + " ")
+		f.expression(exp.Right, p+1, depth)
+	case *NuggetsExpression:
+		f.out.WriteString("nuggets(")
+		f.expression(exp.Value, LOWEST, depth)
+		f.closing(exp.Rparen, depth)
+	case *CallExpression:
+		f.expression(exp.Function, CALL, depth)
+		f.out.WriteString("(")
+		f.list(exp.Arguments, depth)
+		f.closing(exp.Rparen, depth)
+	case *IndexExpression:
+		f.expression(exp.Left, INDEX, depth)
+		f.out.WriteString("[")
+		f.expression(exp.Index, LOWEST, depth)
+		f.closing(exp.Rbracket, depth)
+	case *ArrayLiteral:
+		f.out.WriteString("[")
+		f.list(exp.Elements, depth)
+		f.closing(exp.Rbracket, depth)
+	case *HashLiteral:
+		f.out.WriteString("{")
+		for i := range exp.Keys {
+			if i > 0 {
+				f.out.WriteString(", ")
+			}
+			f.expression(exp.Keys[i], LOWEST, depth)
+			f.out.WriteString(": ")
+			f.expression(exp.Values[i], LOWEST, depth)
+		}
+		f.closing(exp.Rbrace, depth)
+	case *FunctionLiteral:
+		params := make([]string, len(exp.Parameters))
+		for i, param := range exp.Parameters {
+			params[i] = param.Value
+		}
+		f.out.WriteString("burrito(" + strings.Join(params, ", ") + ") ")
+		f.block(exp.Body, depth)
+	}
+}
+
+// list prints expressions separated by commas.
+func (f *formatter) list(exps []Expression, depth int) {
+	for i, exp := range exps {
+		if i > 0 {
+			f.out.WriteString(", ")
+		}
+		f.expression(exp, LOWEST, depth)
+	}
+}
+
+// unifiedDiff returns the differences between two texts as a unified diff with three
+// lines of context, or "" if they are the same.
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	a := diffLines(oldText)
+	b := diffLines(newText)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Every line of the diff, with the line numbers it has in both texts.
+	type diffLine struct {
+		kind byte // ' ', '-' or '+'
+		text string
+		i, j int
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(lines); {
+		if lines[start].kind == ' ' {
+			start++
+			continue
+		}
+		// Grow the hunk until the changes are more than two contexts apart.
+		end := start
+		for k := start; k < len(lines) && k <= end+2*context; k++ {
+			if lines[k].kind != ' ' {
+				end = k
+			}
+		}
+		from := max(start-context, 0)
+		to := min(end+context+1, len(lines))
+		oldCount, newCount := 0, 0
+		for _, l := range lines[from:to] {
+			if l.kind != '+' {
+				oldCount++
+			}
+			if l.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", lines[from].i+1, oldCount, lines[from].j+1, newCount)
+		for _, l := range lines[from:to] {
+			out.WriteByte(l.kind)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// diffLines splits text into lines, each keeping its newline.
+func diffLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"cheese x=1 apple 2*3;pizza x;", "cheese x = 1 apple 2 * 3;\npizza x;\n"},
+		{"cheese nuggets n = 007;", "cheese nuggets n = 7;\n"},
+		{"pizza (1+2)*3 - (4-5) - 6;", "pizza (1 + 2) * 3 - (4 - 5) - 6;\n"},
+		{"pizza -(a+b) == !cake;", "pizza -(a + b) == !cake;\n"},
+		{"pizza salmon -(-x);", "pizza salmon - -x;\n"},
+		{"pizza (tacos x) == \"nuggets\";", "pizza tacos x == \"nuggets\";\n"},
+		{"pizza (-a)[0] + f(1,2)(3);", "pizza (-a)[0] + f(1, 2)(3);\n"},
+		{"pizza [1,\"a\\n\",{\"k\":[]}, {}];", "pizza [1, \"a\\n\", {\"k\": []}, {}];\n"},
+		{"({\"a\": 1})[\"a\"];", "({\"a\": 1})[\"a\"];\n"},
+		{"x+=1;x apple=2;", "x += 1;\nx apple= 2;\n"},
+		{"cheese f=burrito(a,b){takeout a;};f(1,2);", "cheese f = burrito(a, b) {\n    takeout a;\n};\nf(1, 2);\n"},
+		{"cheese g = burrito() {};", "cheese g = burrito() {};\n"},
+		{
+			"waffles x>1{pizza 1;}fries waffles x<0{pizza 2;}fries{pizza 3;}",
+			"waffles x > 1 {\n    pizza 1;\n} fries waffles x < 0 {\n    pizza 2;\n} fries {\n    pizza 3;\n}\n",
+		},
+		{
+			"noodles cake{donuts i=0,3{waffles i==1{seconds;}dessert;}}",
+			"noodles cake {\n    donuts i = 0, 3 {\n        waffles i == 1 {\n            seconds;\n        }\n        dessert;\n    }\n}\n",
+		},
+		{
+			"tacos x{nuggets,string{pizza 1;}fries{}}",
+			"tacos x {\n    nuggets, string {\n        pizza 1;\n    }\n    fries {}\n}\n",
+		},
+		{"{pizza 1;}", "{\n    pizza 1;\n}\n"},
+		// One blank line is kept between statements, but none at the start of a block.
+		{"pizza 1;\n\n\n\npizza 2;\nwaffles cake {\n\n  pizza 3;\n\n}", "pizza 1;\n\npizza 2;\nwaffles cake {\n    pizza 3;\n}\n"},
+		// Comments stay where they were, on lines of their own or after a statement.
+		{
+			"// header\n\ncheese x = 1;   // one\n// before\npizza x;\n// end\n",
+			"// header\n\ncheese x = 1; // one\n// before\npizza x;\n// end\n",
+		},
+		{
+			"cheese f = burrito() { // opener\n  pizza 1;\n  // closing\n};\nwaffles cake {\n  // only\n}",
+			"cheese f = burrito() { // opener\n    pizza 1;\n    // closing\n};\nwaffles cake {\n    // only\n}\n",
+		},
+		{
+			"tacos x { // subject\n  // first\n  nuggets { pizza 1; } // after\n  fries { pizza 2; }\n}",
+			"tacos x { // subject\n    // first\n    nuggets {\n        pizza 1;\n    } // after\n    fries {\n        pizza 2;\n    }\n}\n",
+		},
+		{"waffles cake { pizza 1; } // note\npizza 2;", "waffles cake {\n    pizza 1;\n} // note\npizza 2;\n"},
+		// Block comments keep their lines as they were written.
+		{"/* a\n   b */ pizza 1; /* after */\npizza 2 /* inside */ + 3;", "/* a\n   b */\npizza 1; /* after */\npizza 2 + /* inside */ 3;\n"},
+		// A comment inside an expression stays before what follows it.
+		{"cheese a = [1, // one\n  2];", "cheese a = [1, // one\n    2];\n"},
+		{"f(1,/* two */2, 3 /* end */);", "f(1, /* two */ 2, 3 /* end */);\n"},
+		{"pizza {\"k\": 1 // last\n};", "pizza {\"k\": 1 // last\n};\n"},
+		{"cheese g = burrito() {\ntakeout h(a, // why\nb);\n};", "cheese g = burrito() {\n    takeout h(a, // why\n        b);\n};\n"},
+		{"cheese /* name */ x = 1;", "cheese x = /* name */ 1;\n"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Format(tt.input)
+		if err != nil {
+			t.Fatalf("Format(%q) failed: %s", tt.input, err)
+		}
+		if formatted != tt.expected {
+			t.Errorf("wrong result for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, formatted)
+		}
+		again, err := Format(formatted)
+		if err != nil || again != formatted {
+			t.Errorf("formatting %q again changed it to %q (%v)", formatted, again, err)
+		}
+	}
+
+	if _, err := Format("cheese = 1;"); err == nil {
+		t.Errorf("expected an error for a program that does not parse")
+	}
+}
+
+// Formatting must never change what a program means, so the formatted source has to
+// parse back to the same tree.
+func TestFormatRandomPrograms(t *testing.T) {
+	rng := rand.New(rand.NewSource(47))
+
+	for i := 0; i < 300; i++ {
+		input := randomProgram(rng)
+		p := NewParser(NewLexer(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		formatted := FormatProgram(program, nil)
+		q := NewParser(NewLexer(formatted))
+		reparsed := q.ParseProgram()
+		if errs := q.Errors(); len(errs) > 0 {
+			t.Fatalf("formatted %q does not parse: %v\n%s", input, errs, formatted)
+		}
+		if reparsed.String() != program.String() {
+			t.Fatalf("formatting changed %q.\nexpected=%s\ngot=%s", input, program.String(), reparsed.String())
+		}
+		if again := FormatProgram(reparsed, nil); again != formatted {
+			t.Fatalf("formatting %q is not idempotent:\n%s\n%s", input, formatted, again)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	if diff := unifiedDiff("a", "b", "x\n", "x\n"); diff != "" {
+		t.Errorf("expected no diff for equal texts, got %q", diff)
+	}
+
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	new := "1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\nthirteen"
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+		"@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+thirteen\n\\ No newline at end of file\n"
+	if diff := unifiedDiff("a", "b", old, new); diff != expected {
+		t.Errorf("wrong diff.\nexpected=%q\ngot=%q", expected, diff)
+	}
+}
+
+func TestFmtCommand(t *testing.T) {
+	dir := t.TempDir()
+	messy := filepath.Join(dir, "messy.goofy")
+	tidy := filepath.Join(dir, "tidy.goofy")
+	if err := os.WriteFile(messy, []byte("pizza 1+2;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tidy, []byte("pizza 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	if err := fmtCommand([]string{messy}, nil, &out, &errOut); err != nil || out.String() != "pizza 1 + 2;\n" {
+		t.Errorf("wrong output: %q (%v)", out.String(), err)
+	}
+
+	out.Reset()
+	if err := fmtCommand([]string{"-l", messy, tidy}, nil, &out, &errOut); err != nil || out.String() != messy+"\n" {
+		t.Errorf("wrong -l output: %q (%v)", out.String(), err)
+	}
+
+	out.Reset()
+	if err := fmtCommand([]string{"-d", messy}, nil, &out, &errOut); err != nil || !strings.Contains(out.String(), "-pizza 1+2;\n\\ No newline at end of file\n+pizza 1 + 2;\n") {
+		t.Errorf("wrong -d output: %q (%v)", out.String(), err)
+	}
+
+	out.Reset()
+	if err := fmtCommand([]string{"-w", messy}, nil, &out, &errOut); err != nil || out.Len() != 0 {
+		t.Errorf("wrong -w output: %q (%v)", out.String(), err)
+	}
+	if data, _ := os.ReadFile(messy); string(data) != "pizza 1 + 2;\n" {
+		t.Errorf("-w wrote %q", data)
+	}
+
+	out.Reset()
+	if err := fmtCommand(nil, strings.NewReader("pizza  2;"), &out, &errOut); err != nil || out.String() != "pizza 2;\n" {
+		t.Errorf("wrong output for stdin: %q (%v)", out.String(), err)
+	}
+	if err := fmtCommand([]string{"-w"}, strings.NewReader(""), &out, &errOut); err == nil {
+		t.Errorf("expected an error for -w with stdin")
+	}
+
+	// A program the parser did not fully accept is left as it is.
+	broken := filepath.Join(dir, "broken.goofy")
+	source := "cheese x = 99999999999999999999;\n"
+	if err := os.WriteFile(broken, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	errOut.Reset()
+	if code := runGoofy([]string{"fmt", "-w", broken}, &out, &errOut); code == 0 || !strings.Contains(errOut.String(), "does not fit") {
+		t.Errorf("expected fmt -w to fail. got=%d, %q", code, errOut.String())
+	}
+	if data, _ := os.ReadFile(broken); string(data) != source {
+		t.Errorf("fmt -w changed a program it could not parse: %q", data)
+	}
+}
+
+func TestFormatRefusesIncompletePrograms(t *testing.T) {
+	if formatted, err := Format("pizza 010;"); err != nil || formatted != "pizza 10;\n" {
+		t.Errorf("wrong formatting of a literal with a leading zero: %q (%v)", formatted, err)
+	}
+
+	// Hand-built programs can have holes the parser would have reported.
+	program := &Program{Statements: []Statement{
+		&LetStatement{Token: Token{Type: TOKEN_CHEESE, Literal: "cheese", Line: 1, Column: 1}, Name: &Identifier{Token: Token{Type: TOKEN_IDENT, Literal: "x", Line: 1, Column: 8}, Value: "x"}},
+	}}
+	if node, ok := incompleteNode(program); !ok || node != program.Statements[0] {
+		t.Errorf("expected the cheese without a value to be incomplete. got=%v, %t", node, ok)
+	}
+	program.Statements[0].(*LetStatement).Value = &IntegralLiteral{Token: Token{Type: TOKEN_INT, Literal: "1"}, Value: 1}
+	if node, ok := incompleteNode(program); ok {
+		t.Errorf("expected the program to be complete. got=%v", node)
+	}
+}
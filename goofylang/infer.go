@@ -0,0 +1,820 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Type inference gives every cheese binding the most general type its uses allow, in the
+// style of Hindley and Milner: each value starts with an unknown type variable, every
+// operator, call and takeout requires two types to be equal, and solving those equations
+// one by one (unification) fills the variables in. A binding whose type still has free
+// variables once its value is inferred is polymorphic, so a burrito like
+// burrito(x) { takeout x; } can be called with a nuggets in one place and a string in
+// another.
+//
+// A few things have to be bent to fit a dynamically typed language:
+//   - apple, the comparisons, len and the rest work on more than one type. Their operands
+//     get a type variable restricted to the types they accept, such as 'a: nuggets | string.
+//   - An index works on arrays and hashes alike. Once the indexed value is known to be one
+//     of them, the key and element are tied to it; if that is never known, a nuggets index
+//     makes it an array and anything else a hash.
+//   - A variable that is assigned to, or declared more than once in the same scope, keeps
+//     one type everywhere, since burritos that captured it see every value it gets.
+//   - Inside a tacos case naming a single type, a variable used as the subject has that type.
+//
+// This is stricter than running the program: an array holding nuggets and strings, for
+// example, is an error here but fine at runtime.
+
+// infType is a type during inference. It is either a variable, when name is "", or a
+// constructor such as nuggets, an array of some type or a burrito.
+type infType struct {
+	name     string     // "nuggets", "boolean", "string", "nothing", "array", "hash", "burrito" or "".
+	args     []*infType // The element of an array, the key and value of a hash, or the parameters and result of a burrito.
+	instance *infType   // For a variable, the type it was unified with, if any.
+	id       int        // For a variable, a number unique in the inference.
+	class    []string   // For a variable, the constructors it may stand for, or nil for any.
+	origin   Span       // Where the type, or the restriction of a variable, comes from.
+}
+
+// The restrictions placed on operands that accept several types.
+var (
+	addableTypes     = []string{"nuggets", "string"}
+	orderedTypes     = []string{"nuggets", "string"}
+	equatableTypes   = []string{"nuggets", "boolean", "string"}
+	hashableTypes    = []string{"nuggets", "boolean", "string"}
+	convertibleTypes = []string{"nuggets", "boolean", "string"}
+	sizedTypes       = []string{"string", "array", "hash"}
+	indexableTypes   = []string{"array", "hash"}
+)
+
+// prune follows the instances of variables that have been unified with something.
+func prune(t *infType) *infType {
+	for t.name == "" && t.instance != nil {
+		t = t.instance
+	}
+	return t
+}
+
+// occurs reports whether the variable v appears in t.
+func occurs(v, t *infType) bool {
+	t = prune(t)
+	if t == v {
+		return true
+	}
+	for _, arg := range t.args {
+		if occurs(v, arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// freeTypeVars adds the variables in t that are not unified with anything to vars.
+func freeTypeVars(t *infType, vars map[*infType]bool) {
+	t = prune(t)
+	if t.name == "" {
+		vars[t] = true
+	}
+	for _, arg := range t.args {
+		freeTypeVars(arg, vars)
+	}
+}
+
+// typeScheme is the type of a binding: a type whose variables in vars stand for a fresh
+// type at each use of the binding.
+type typeScheme struct {
+	vars []*infType
+	t    *infType
+}
+
+// typeNamer gives type variables the names 'a, 'b, ... in the order they are shown.
+type typeNamer struct {
+	names map[*infType]string
+	order []*infType
+}
+
+func newTypeNamer() *typeNamer {
+	return &typeNamer{names: map[*infType]string{}}
+}
+
+// name returns the name of the variable v.
+func (n *typeNamer) name(v *infType) string {
+	if name, ok := n.names[v]; ok {
+		return name
+	}
+	i := len(n.order)
+	name := "'" + string(rune('a'+i%26))
+	if i >= 26 {
+		name += fmt.Sprint(i / 26)
+	}
+	n.names[v] = name
+	n.order = append(n.order, v)
+	return name
+}
+
+// show formats t, naming its variables with n.
+func (n *typeNamer) show(t *infType) string {
+	t = prune(t)
+	switch t.name {
+	case "":
+		return n.name(t)
+	case "array":
+		return "[" + n.show(t.args[0]) + "]"
+	case "hash":
+		return "{" + n.show(t.args[0]) + ": " + n.show(t.args[1]) + "}"
+	case "burrito":
+		last := len(t.args) - 1
+		params := make([]string, last)
+		for i, param := range t.args[:last] {
+			params[i] = n.show(param)
+		}
+		return "burrito(" + strings.Join(params, ", ") + ") -> " + n.show(t.args[last])
+	default:
+		return t.name
+	}
+}
+
+// constraints formats the restrictions of every variable named so far, as in
+// " where 'a: nuggets | string", or returns "" if none is restricted.
+func (n *typeNamer) constraints() string {
+	var parts []string
+	for _, v := range n.order {
+		if v.class != nil {
+			parts = append(parts, n.names[v]+": "+strings.Join(v.class, " | "))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " where " + strings.Join(parts, ", ")
+}
+
+// describe formats t for an error message, showing what a restricted variable may be.
+func (n *typeNamer) describe(t *infType) string {
+	t = prune(t)
+	if t.name == "" && t.class != nil {
+		return strings.Join(t.class, " | ")
+	}
+	return n.show(t)
+}
+
+// TypeBinding is the type inferred for one cheese binding.
+type TypeBinding struct {
+	Name   *Identifier
+	scheme *typeScheme
+	decl   Span // The cheese statement that made the binding.
+}
+
+// Type formats the principal type of the binding, as in "burrito('a) -> 'a".
+func (b TypeBinding) Type() string {
+	n := newTypeNamer()
+	t := n.show(b.scheme.t)
+	return t + n.constraints()
+}
+
+// String formats the binding as "line:column: name: type".
+func (b TypeBinding) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", b.Name.Token.Line, b.Name.Token.Column, b.Name.Value, b.Type())
+}
+
+// inferScope holds the bindings declared in one scope.
+type inferScope struct {
+	vars  map[string]*typeScheme
+	outer *inferScope
+}
+
+func (s *inferScope) lookup(name string) (*typeScheme, bool) {
+	for scope := s; scope != nil; scope = scope.outer {
+		if scheme, ok := scope.vars[name]; ok {
+			return scheme, true
+		}
+	}
+	return nil, false
+}
+
+// indexConstraint is an index whose indexed value was not yet known to be an array or a
+// hash when it was inferred.
+type indexConstraint struct {
+	left, index, result *infType
+	node                *IndexExpression
+}
+
+// Inferrer infers the types of a program without running it.
+type Inferrer struct {
+	scope       *inferScope
+	nextVar     int
+	diagnostics []Diagnostic
+	bindings    []TypeBinding
+	pending     []indexConstraint
+	result      *infType // The result type of the innermost burrito, or nil at the top level.
+	assigned    map[string]bool
+	redeclared  map[*LetStatement]bool
+}
+
+// NewInferrer creates an Inferrer.
+func NewInferrer() *Inferrer {
+	return &Inferrer{}
+}
+
+// Infer infers the types of a whole program and returns the unification errors found,
+// which Errors also returns formatted.
+func (in *Inferrer) Infer(program *Program) []Diagnostic {
+	in.assigned = map[string]bool{}
+	in.redeclared = map[*LetStatement]bool{}
+	walkAST(program, func(node Node) {
+		switch node := node.(type) {
+		case *AssignStatement:
+			in.assigned[node.Name.Value] = true
+		case *Program:
+			in.findRedeclarations(node.Statements)
+		case *BlockStatement:
+			in.findRedeclarations(node.Statements)
+		}
+	})
+
+	in.scope = &inferScope{vars: map[string]*typeScheme{}}
+	in.inferStatements(program.Statements)
+	in.solvePending(func(*infType) bool { return true })
+	return in.diagnostics
+}
+
+// findRedeclarations marks the cheese statements of stmts that declare a name declared
+// by another one of them too.
+func (in *Inferrer) findRedeclarations(stmts []Statement) {
+	lets := map[string][]*LetStatement{}
+	for _, stmt := range stmts {
+		if let, ok := stmt.(*LetStatement); ok {
+			lets[let.Name.Value] = append(lets[let.Name.Value], let)
+		}
+	}
+	for _, same := range lets {
+		if len(same) > 1 {
+			for _, let := range same {
+				in.redeclared[let] = true
+			}
+		}
+	}
+}
+
+// Errors returns every error found, formatted like the ones Checker.Errors returns.
+func (in *Inferrer) Errors() []string {
+	errs := make([]string, len(in.diagnostics))
+	for i, d := range in.diagnostics {
+		errs[i] = d.String()
+	}
+	return errs
+}
+
+// Bindings returns the type of every cheese binding in the program, in source order.
+func (in *Inferrer) Bindings() []TypeBinding {
+	bindings := append([]TypeBinding(nil), in.bindings...)
+	sort.SliceStable(bindings, func(i, j int) bool {
+		a, b := bindings[i].Name.Token, bindings[j].Name.Token
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return bindings
+}
+
+// Failed reports whether an error was found inside the cheese statement that made b, such
+// as in the body of the burrito it binds. The type of such a binding is only a guess.
+func (in *Inferrer) Failed(b TypeBinding) bool {
+	for _, d := range in.diagnostics {
+		start := d.Span
+		if start.Line == 0 {
+			continue
+		}
+		afterStart := start.Line > b.decl.Line || start.Line == b.decl.Line && start.Column >= b.decl.Column
+		beforeEnd := start.Line < b.decl.EndLine || start.Line == b.decl.EndLine && start.Column < b.decl.EndColumn
+		if afterStart && beforeEnd {
+			return true
+		}
+	}
+	return false
+}
+
+// fresh returns a new type variable that may stand for the constructors in class, or
+// for any type if class is nil.
+func (in *Inferrer) fresh(class []string, origin Node) *infType {
+	in.nextVar++
+	v := &infType{id: in.nextVar, class: class}
+	if origin != nil {
+		v.origin = spanOf(origin)
+	}
+	return v
+}
+
+// con returns a constructed type that node requires.
+func con(name string, origin Node, args ...*infType) *infType {
+	t := &infType{name: name, args: args}
+	if origin != nil {
+		t.origin = spanOf(origin)
+	}
+	return t
+}
+
+// unify makes a and b equal, reporting an error about node if they cannot be.
+func (in *Inferrer) unify(a, b *infType, node Node) bool {
+	x, y, ok := unifyTypes(a, b)
+	if ok {
+		return true
+	}
+
+	n := newTypeNamer()
+	d := Diagnostic{Span: spanOf(node)}
+	if x == y || (prune(x).name == "" && occurs(prune(x), y)) || (prune(y).name == "" && occurs(prune(y), x)) {
+		d.Message = fmt.Sprintf("infinite type: %s = %s", n.show(x), n.show(y))
+	} else {
+		d.Message = fmt.Sprintf("cannot unify %s with %s", n.describe(x), n.describe(y))
+	}
+	for _, t := range []*infType{prune(x), prune(y)} {
+		if t.origin.Line == 0 {
+			continue
+		}
+		message := n.describe(t) + " comes from here"
+		if t.name == "" {
+			message = n.describe(t) + " is required here"
+		}
+		d.Related = append(d.Related, Diagnostic{Span: t.origin, Message: message})
+	}
+	in.diagnostics = append(in.diagnostics, d)
+	return false
+}
+
+// unifyTypes makes a and b equal. If they cannot be, it returns the two parts of them
+// that conflict.
+func unifyTypes(a, b *infType) (*infType, *infType, bool) {
+	a, b = prune(a), prune(b)
+	switch {
+	case a == b:
+		return nil, nil, true
+	case a.name == "" && b.name == "":
+		class := a.class
+		if class == nil {
+			class = b.class
+		} else if b.class != nil {
+			class = intersectClasses(a.class, b.class)
+			if len(class) == 0 {
+				return a, b, false
+			}
+		}
+		if b.class == nil {
+			b.origin = a.origin
+		}
+		b.class = class
+		a.instance = b
+		return nil, nil, true
+	case a.name == "":
+		return bindVar(a, b)
+	case b.name == "":
+		y, x, ok := bindVar(b, a)
+		return x, y, ok
+	case a.name != b.name || len(a.args) != len(b.args):
+		return a, b, false
+	}
+
+	for i := range a.args {
+		if x, y, ok := unifyTypes(a.args[i], b.args[i]); !ok {
+			if a.name == "burrito" {
+				// A burrito with the wrong parameters is easier to read as a whole.
+				return a, b, false
+			}
+			return x, y, ok
+		}
+	}
+	return nil, nil, true
+}
+
+// bindVar unifies the variable v with the constructed type t.
+func bindVar(v, t *infType) (*infType, *infType, bool) {
+	if occurs(v, t) {
+		return v, t, false
+	}
+	if v.class != nil && !containsString(v.class, t.name) {
+		return v, t, false
+	}
+	v.instance = t
+	return nil, nil, true
+}
+
+// intersectClasses returns the constructors in both a and b.
+func intersectClasses(a, b []string) []string {
+	both := []string{}
+	for _, name := range a {
+		if containsString(b, name) {
+			both = append(both, name)
+		}
+	}
+	return both
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// instantiate returns the type of a use of a binding with the scheme s.
+func (in *Inferrer) instantiate(s *typeScheme) *infType {
+	if len(s.vars) == 0 {
+		return s.t
+	}
+	fresh := map[*infType]*infType{}
+	for _, v := range s.vars {
+		fresh[v] = in.fresh(v.class, nil)
+		fresh[v].origin = v.origin
+	}
+	return copyType(s.t, fresh)
+}
+
+// copyType returns t with every variable in fresh replaced.
+func copyType(t *infType, fresh map[*infType]*infType) *infType {
+	t = prune(t)
+	if t.name == "" {
+		if v, ok := fresh[t]; ok {
+			return v
+		}
+		return t
+	}
+	if len(t.args) == 0 {
+		return t
+	}
+	args := make([]*infType, len(t.args))
+	for i, arg := range t.args {
+		args[i] = copyType(arg, fresh)
+	}
+	return &infType{name: t.name, args: args, origin: t.origin}
+}
+
+// scopeTypeVars returns the variables free in the type of any visible binding.
+func (in *Inferrer) scopeTypeVars() map[*infType]bool {
+	vars := map[*infType]bool{}
+	for scope := in.scope; scope != nil; scope = scope.outer {
+		for _, s := range scope.vars {
+			free := map[*infType]bool{}
+			freeTypeVars(s.t, free)
+			for _, v := range s.vars {
+				delete(free, v)
+			}
+			for v := range free {
+				vars[v] = true
+			}
+		}
+	}
+	return vars
+}
+
+// generalize returns the scheme of a binding whose value has type t: every variable of t
+// that no visible binding shares stands for any type.
+func (in *Inferrer) generalize(t *infType) *typeScheme {
+	bound := in.scopeTypeVars()
+	generalizable := func(v *infType) bool {
+		free := map[*infType]bool{}
+		freeTypeVars(t, free)
+		return free[v] && !bound[v]
+	}
+	// An index into a value that is about to become polymorphic has to be settled now.
+	in.solvePending(generalizable)
+
+	free := map[*infType]bool{}
+	freeTypeVars(t, free)
+	s := &typeScheme{t: t}
+	for v := range free {
+		if !bound[v] {
+			s.vars = append(s.vars, v)
+		}
+	}
+	sort.Slice(s.vars, func(i, j int) bool { return s.vars[i].id < s.vars[j].id })
+	return s
+}
+
+// solvePending ties the key and element of every pending index to the indexed value once
+// it is known to be an array or a hash. An index into a value that is not known yet but
+// for which settle returns true is decided by the type of its key.
+func (in *Inferrer) solvePending(settle func(*infType) bool) {
+	for progress := true; progress; {
+		progress = false
+		remaining := in.pending[:0]
+		for _, c := range in.pending {
+			left := prune(c.left)
+			switch {
+			case left.name == "array":
+				in.unify(c.index, con("nuggets", c.node.Index), c.node)
+				in.unify(left.args[0], c.result, c.node)
+			case left.name == "hash":
+				in.unify(left, con("hash", c.node, c.index, c.result), c.node)
+			case left.name == "" && settle(left):
+				if prune(c.index).name == "nuggets" {
+					in.unify(left, con("array", c.node, c.result), c.node)
+				} else {
+					in.unify(left, con("hash", c.node, c.index, c.result), c.node)
+				}
+			default:
+				remaining = append(remaining, c)
+				continue
+			}
+			progress = true
+		}
+		in.pending = remaining
+	}
+}
+
+// builtinScheme returns the scheme of a builtin.
+func (in *Inferrer) builtinScheme(name string) (*typeScheme, bool) {
+	switch name {
+	case "len":
+		a := in.fresh(sizedTypes, nil)
+		return &typeScheme{vars: []*infType{a}, t: con("burrito", nil, a, con("nuggets", nil))}, true
+	case "push":
+		a := in.fresh(nil, nil)
+		return &typeScheme{vars: []*infType{a}, t: con("burrito", nil, con("array", nil, a), a, con("array", nil, a))}, true
+	case "keys":
+		k, v := in.fresh(hashableTypes, nil), in.fresh(nil, nil)
+		return &typeScheme{vars: []*infType{k, v}, t: con("burrito", nil, con("hash", nil, k, v), con("array", nil, k))}, true
+	default:
+		return nil, false
+	}
+}
+
+func (in *Inferrer) enter() {
+	in.scope = &inferScope{vars: map[string]*typeScheme{}, outer: in.scope}
+}
+
+func (in *Inferrer) leave() {
+	in.scope = in.scope.outer
+}
+
+func (in *Inferrer) inferStatements(stmts []Statement) {
+	for _, stmt := range stmts {
+		in.inferStatement(stmt)
+	}
+}
+
+func (in *Inferrer) inferBlock(block *BlockStatement) {
+	if block == nil {
+		return
+	}
+	in.enter()
+	in.inferStatements(block.Statements)
+	in.leave()
+}
+
+func (in *Inferrer) inferStatement(stmt Statement) {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		in.inferLet(stmt)
+	case *AssignStatement:
+		value := in.inferExpression(stmt.Value)
+		s, ok := in.scope.lookup(stmt.Name.Value)
+		if !ok {
+			return
+		}
+		current := in.instantiate(s)
+		if operator, ok := stmt.Operator(); ok {
+			value = in.inferInfix(operator, current, value, stmt)
+		}
+		in.unify(current, value, stmt)
+	case *ExpressionStatement:
+		in.inferExpression(stmt.Expression)
+	case *PrintStatement:
+		in.inferExpression(stmt.Value)
+	case *BlockStatement:
+		in.inferBlock(stmt)
+	case *IfStatement:
+		in.inferExpression(stmt.Condition)
+		in.inferBlock(stmt.Consequence)
+		if stmt.Alternative != nil {
+			in.inferStatement(stmt.Alternative)
+		}
+	case *WhileStatement:
+		in.inferExpression(stmt.Condition)
+		in.inferBlock(stmt.Body)
+	case *ForStatement:
+		in.unify(in.inferExpression(stmt.Start), con("nuggets", stmt), stmt.Start)
+		in.unify(in.inferExpression(stmt.End), con("nuggets", stmt), stmt.End)
+		in.enter()
+		in.scope.vars[stmt.Variable.Value] = &typeScheme{t: con("nuggets", stmt.Variable)}
+		in.inferBlock(stmt.Body)
+		in.leave()
+	case *ReturnStatement:
+		value := con("nothing", stmt)
+		if stmt.Value != nil {
+			value = in.inferExpression(stmt.Value)
+		}
+		if in.result != nil {
+			in.unify(in.result, value, stmt)
+		}
+	case *TypeSwitchStatement:
+		in.inferTypeSwitch(stmt)
+	}
+}
+
+// inferLet infers the type of a cheese statement and binds its name.
+func (in *Inferrer) inferLet(stmt *LetStatement) {
+	name := stmt.Name.Value
+	mutable := in.assigned[name] || in.redeclared[stmt]
+
+	// A variable declared again in the same scope is the same variable.
+	if existing, ok := in.scope.vars[name]; ok && mutable {
+		in.unify(existing.t, in.inferExpression(stmt.Value), stmt)
+		in.annotate(stmt, existing.t)
+		in.bindings = append(in.bindings, TypeBinding{Name: stmt.Name, scheme: existing, decl: spanOf(stmt)})
+		return
+	}
+
+	var value *infType
+	if _, ok := stmt.Value.(*FunctionLiteral); ok {
+		// The burrito is called after the binding exists, so it can call itself.
+		self := in.fresh(nil, nil)
+		in.scope.vars[name] = &typeScheme{t: self}
+		value = in.inferExpression(stmt.Value)
+		in.unify(self, value, stmt)
+	} else {
+		value = in.inferExpression(stmt.Value)
+	}
+	in.annotate(stmt, value)
+
+	s := &typeScheme{t: value}
+	if !mutable {
+		delete(in.scope.vars, name)
+		s = in.generalize(value)
+	}
+	in.scope.vars[name] = s
+	in.bindings = append(in.bindings, TypeBinding{Name: stmt.Name, scheme: s, decl: spanOf(stmt)})
+}
+
+// annotate requires the value of a typed cheese statement to have its declared type.
+func (in *Inferrer) annotate(stmt *LetStatement, value *infType) {
+	if stmt.Annotation == nil {
+		return
+	}
+	declared := &infType{name: typeNames[INTEGER_OBJ], origin: tokenSpan(*stmt.Annotation)}
+	in.unify(value, declared, stmt.Value)
+}
+
+// narrowedType returns the type of values a tacos case naming name matches, if there is
+// one. Functions of any arity have no type in common.
+func (in *Inferrer) narrowedType(name string, node Node) (*infType, bool) {
+	switch name {
+	case "nuggets", "boolean", "string", "nothing":
+		return con(name, node), true
+	case "array":
+		return con(name, node, in.fresh(nil, nil)), true
+	case "hash":
+		return con(name, node, in.fresh(hashableTypes, nil), in.fresh(nil, nil)), true
+	default:
+		return nil, false
+	}
+}
+
+// inferTypeSwitch infers a tacos statement. A variable used as the subject, and never
+// assigned to, has the type each case names inside it.
+func (in *Inferrer) inferTypeSwitch(stmt *TypeSwitchStatement) {
+	in.inferExpression(stmt.Subject)
+	subject, _ := stmt.Subject.(*Identifier)
+
+	for _, c := range stmt.Cases {
+		in.enter()
+		if subject != nil && !in.assigned[subject.Value] && len(c.Types) == 1 {
+			if t, ok := in.narrowedType(c.Types[0], c.Body); ok {
+				in.scope.vars[subject.Value] = &typeScheme{t: t}
+			}
+		}
+		in.inferBlock(c.Body)
+		in.leave()
+	}
+	in.inferBlock(stmt.Default)
+}
+
+// inferExpression infers the type of an expression.
+func (in *Inferrer) inferExpression(exp Expression) *infType {
+	switch exp := exp.(type) {
+	case *IntegralLiteral, *InputExpression:
+		return con("nuggets", exp)
+	case *StringLiteral:
+		return con("string", exp)
+	case *BooleanLiteral:
+		return con("boolean", exp)
+	case *Identifier:
+		if s, ok := in.scope.lookup(exp.Value); ok {
+			return in.instantiate(s)
+		}
+		if s, ok := in.builtinScheme(exp.Value); ok {
+			return in.instantiate(s)
+		}
+		// Undefined names fail at runtime, which is not for inference to report.
+		return in.fresh(nil, nil)
+	case *PrefixExpression:
+		right := in.inferExpression(exp.Right)
+		if exp.Token.Type == TOKEN_BANG {
+			in.unify(right, con("boolean", exp), exp)
+			return con("boolean", exp)
+		}
+		in.unify(right, con("nuggets", exp), exp)
+		return con("nuggets", exp)
+	case *InfixExpression:
+		left := in.inferExpression(exp.Left)
+		right := in.inferExpression(exp.Right)
+		return in.inferInfix(exp.Token.Type, left, right, exp)
+	case *TypeofExpression:
+		in.inferExpression(exp.Right)
+		return con("string", exp)
+	case *NuggetsExpression:
+		in.unify(in.inferExpression(exp.Value), in.fresh(convertibleTypes, exp), exp)
+		return con("nuggets", exp)
+	case *ArrayLiteral:
+		element := in.fresh(nil, nil)
+		for _, el := range exp.Elements {
+			in.unify(element, in.inferExpression(el), el)
+		}
+		return con("array", exp, element)
+	case *HashLiteral:
+		key, value := in.fresh(hashableTypes, exp), in.fresh(nil, nil)
+		for i := range exp.Keys {
+			in.unify(key, in.inferExpression(exp.Keys[i]), exp.Keys[i])
+			in.unify(value, in.inferExpression(exp.Values[i]), exp.Values[i])
+		}
+		return con("hash", exp, key, value)
+	case *IndexExpression:
+		left := in.inferExpression(exp.Left)
+		index := in.inferExpression(exp.Index)
+		in.unify(left, in.fresh(indexableTypes, exp), exp)
+		result := in.fresh(nil, nil)
+		in.pending = append(in.pending, indexConstraint{left: left, index: index, result: result, node: exp})
+		in.solvePending(func(*infType) bool { return false })
+		return result
+	case *FunctionLiteral:
+		return in.inferFunction(exp)
+	case *CallExpression:
+		callee := in.inferExpression(exp.Function)
+		args := make([]*infType, len(exp.Arguments)+1)
+		for i, arg := range exp.Arguments {
+			args[i] = in.inferExpression(arg)
+		}
+		result := in.fresh(nil, nil)
+		args[len(exp.Arguments)] = result
+		in.unify(callee, con("burrito", exp, args...), exp)
+		return result
+	default:
+		return in.fresh(nil, nil)
+	}
+}
+
+// inferInfix returns the type an infix operator produces from operands of types left
+// and right, reporting errors about node.
+func (in *Inferrer) inferInfix(operator TokenType, left, right *infType, node Node) *infType {
+	switch operator {
+	case TOKEN_APPLE:
+		operand := in.fresh(addableTypes, node)
+		if in.unify(left, operand, node) {
+			in.unify(right, operand, node)
+		}
+		return operand
+	case TOKEN_LT, TOKEN_GT, TOKEN_LT_EQ, TOKEN_GT_EQ:
+		operand := in.fresh(orderedTypes, node)
+		if in.unify(left, operand, node) {
+			in.unify(right, operand, node)
+		}
+		return con("boolean", node)
+	case TOKEN_EQ, TOKEN_NOT_EQ:
+		operand := in.fresh(equatableTypes, node)
+		if in.unify(left, operand, node) {
+			in.unify(right, operand, node)
+		}
+		return con("boolean", node)
+	default:
+		if in.unify(left, con("nuggets", node), node) {
+			in.unify(right, con("nuggets", node), node)
+		}
+		return con("nuggets", node)
+	}
+}
+
+// inferFunction infers the type of a burrito. Its parameters have one type throughout
+// its body, and a call returns the type every takeout agrees on, which is nothing if
+// control can fall off the end of the body.
+func (in *Inferrer) inferFunction(fn *FunctionLiteral) *infType {
+	outerResult := in.result
+	defer func() { in.result = outerResult }()
+
+	in.enter()
+	defer in.leave()
+
+	types := make([]*infType, len(fn.Parameters)+1)
+	for i, param := range fn.Parameters {
+		types[i] = in.fresh(nil, nil)
+		in.scope.vars[param.Value] = &typeScheme{t: types[i]}
+	}
+	in.result = in.fresh(nil, nil)
+	types[len(fn.Parameters)] = in.result
+
+	in.inferStatements(fn.Body.Statements)
+	if completesNormally(fn.Body.Statements) {
+		in.unify(in.result, con("nothing", fn.Body), fn.Body)
+	}
+	return con("burrito", fn, types...)
+}
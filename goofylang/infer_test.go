@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func inferProgram(t *testing.T, input string) *Inferrer {
+	t.Helper()
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parser errors for %q: %v", input, errs)
+	}
+	in := NewInferrer()
+	in.Infer(program)
+	return in
+}
+
+func TestInferTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // The type of each cheese binding, in source order.
+	}{
+		{`cheese a = 1; cheese b = "s"; cheese c = a < 2; cheese d = icaco;`, []string{"nuggets", "string", "boolean", "nuggets"}},
+		{"cheese id = burrito(x) { takeout x; }; cheese n = id(1); cheese s = id(\"a\");", []string{"burrito('a) -> 'a", "nuggets", "string"}},
+		{"cheese add = burrito(a, b) { takeout a + b; };", []string{"burrito('a, 'a) -> 'a where 'a: nuggets | string"}},
+		{"cheese sub = burrito(a, b) { takeout a - b; };", []string{"burrito(nuggets, nuggets) -> nuggets"}},
+		{"cheese eq = burrito(a, b) { takeout a == b; };", []string{"burrito('a, 'a) -> boolean where 'a: nuggets | boolean | string"}},
+		{"cheese fact = burrito(n) { waffles n < 2 { takeout 1; } takeout n * fact(n - 1); };", []string{"burrito(nuggets) -> nuggets"}},
+		{"cheese p = burrito(x) { pizza x; };", []string{"burrito('a) -> nothing"}},
+		{"cheese f = burrito(b) { waffles b { takeout 1; } fries { takeout 2; } };", []string{"burrito('a) -> nuggets"}},
+		{"cheese size = burrito(c) { takeout len(c); };", []string{"burrito('a) -> nuggets where 'a: string | array | hash"}},
+		{"cheese first = burrito(a) { takeout a[0]; };", []string{"burrito(['a]) -> 'a"}},
+		{"cheese get = burrito(h, k) { takeout h[k]; };", []string{"burrito({'a: 'b}, 'a) -> 'b"}},
+		{"cheese get = burrito(h) { takeout h[\"k\"] + 1; };", []string{"burrito({string: nuggets}) -> nuggets"}},
+		{`cheese h = {"a": [1]}; cheese k = keys(h); cheese more = push(h["a"], 2);`, []string{"{string: [nuggets]}", "[string]", "[nuggets]"}},
+		{"cheese compose = burrito(f, g) { takeout burrito(x) { takeout f(g(x)); }; };", []string{"burrito(burrito('a) -> 'b, burrito('c) -> 'a) -> burrito('c) -> 'b"}},
+		{"cheese n = nuggets(\"4\"); cheese t = tacos n;", []string{"nuggets", "string"}},
+		{"cheese show = burrito(v) { tacos v { nuggets { takeout v + 1; } string { takeout len(v); } fries { takeout 0; } } };", []string{"burrito('a) -> nuggets"}},
+		// Bindings that are assigned to keep a single type, so they are not generalized.
+		{"cheese f = burrito(x) { takeout x; }; f = burrito(y) { takeout y + 1; };", []string{"burrito(nuggets) -> nuggets"}},
+		{"cheese x = icaco; cheese g = burrito() { x = x + 1; };", []string{"nuggets", "burrito() -> nothing"}},
+		// Inner bindings are listed too.
+		{"cheese f = burrito(a) { cheese b = a + 1; takeout b; };", []string{"burrito(nuggets) -> nuggets", "nuggets"}},
+	}
+
+	for _, tt := range tests {
+		in := inferProgram(t, tt.input)
+		if errs := in.Errors(); len(errs) > 0 {
+			t.Errorf("unexpected errors for %q: %v", tt.input, errs)
+			continue
+		}
+		var got []string
+		for _, b := range in.Bindings() {
+			got = append(got, b.Type())
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("wrong types for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestInferErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"cheese x = 1;\nx = \"a\";", "2:1-2:8: cannot unify nuggets with string (1:12-1:13: nuggets comes from here; 2:5-2:8: string comes from here)"},
+		{"cheese f = burrito(a) { takeout a + 1; };\nf(cake);", "2:1-2:8: cannot unify burrito(nuggets) -> nuggets with burrito(boolean) -> 'a (1:12-1:41: burrito(nuggets) -> nuggets comes from here; 2:1-2:8: burrito(boolean) -> 'a comes from here)"},
+		{"pizza [1, \"a\"];", "1:11-1:14: cannot unify nuggets with string (1:8-1:9: nuggets comes from here; 1:11-1:14: string comes from here)"},
+		{"pizza cake apple cake;", "1:7-1:22: cannot unify boolean with nuggets | string (1:7-1:11: boolean comes from here; 1:7-1:22: nuggets | string is required here)"},
+		{"pizza 5[0];", "1:7-1:11: cannot unify nuggets with array | hash (1:7-1:8: nuggets comes from here; 1:7-1:11: array | hash is required here)"},
+		{"cheese nuggets x = cake;", "1:20-1:24: cannot unify boolean with nuggets (1:20-1:24: boolean comes from here; 1:8-1:15: nuggets comes from here)"},
+		{"cheese f = burrito(b) { waffles b { takeout 1; } };", "1:23-1:51: cannot unify nuggets with nothing (1:45-1:46: nuggets comes from here; 1:23-1:51: nothing comes from here)"},
+		{"cheese f = burrito(x) { takeout x(x); };", "1:33-1:37: infinite type: 'a = burrito('a) -> 'b (1:33-1:37: burrito('a) -> 'b comes from here)"},
+		{"cheese x = 1; cheese x = \"a\";", "1:15-1:29: cannot unify nuggets with string (1:12-1:13: nuggets comes from here; 1:26-1:29: string comes from here)"},
+	}
+
+	for _, tt := range tests {
+		errs := inferProgram(t, tt.input).Errors()
+		if len(errs) != 1 || errs[0] != tt.expected {
+			t.Errorf("wrong errors for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestTypesCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "types.goofy")
+	if err := os.WriteFile(path, []byte("cheese id = burrito(x) { takeout x; };\ncheese n = id(2);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := typesCommand([]string{path}, &out); err != nil {
+		t.Fatalf("types failed: %s", err)
+	}
+	expected := "1:8: id: burrito('a) -> 'a\n2:8: n: nuggets\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+
+	if err := os.WriteFile(path, []byte("cheese n = 1;\nn = cake;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := typesCommand([]string{path}, &out)
+	if err == nil || !strings.HasPrefix(err.Error(), path+": 2:1-2:9: cannot unify nuggets with boolean") {
+		t.Errorf("wrong error for a program that does not type: %v", err)
+	}
+
+	// A burrito that may fall off its end after taking out a value is an error of its
+	// own; the other bindings still get their types.
+	source := "cheese n = 1;\ncheese f = burrito(b) {\n    waffles b { takeout 1; }\n};\ncheese s = \"a\";"
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	err = typesCommand([]string{path}, &out)
+	if err == nil || !strings.Contains(err.Error(), "cannot unify nuggets with nothing") {
+		t.Errorf("expected the error of f. got=%v", err)
+	}
+	if expected := "1:8: n: nuggets\n5:8: s: string\n"; out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
@@ -49,4 +49,454 @@ func TestLexer(t *testing.T) {
             t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
         }
     }
-}
\ No newline at end of file
+}
+func TestLexerAssignmentOperators(t *testing.T) {
+    input := `x = 1; x += 2; x apple= 3; x -= 4; x salmon= 5; x apple 6; x *= 2; x pie= 3; x %= 4;`
+
+    tests := []struct {
+        expectedType    TokenType
+        expectedLiteral string
+    }{
+        {TOKEN_IDENT, "x"},
+        {TOKEN_ENCHILADA, "="},
+        {TOKEN_INT, "1"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_APPLE_ENCHILADA, "+="},
+        {TOKEN_INT, "2"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_APPLE_ENCHILADA, "apple="},
+        {TOKEN_INT, "3"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_SALMON_ENCHILADA, "-="},
+        {TOKEN_INT, "4"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_SALMON_ENCHILADA, "salmon="},
+        {TOKEN_INT, "5"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_APPLE, "apple"},
+        {TOKEN_INT, "6"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_PANCAKES_ENCHILADA, "*="},
+        {TOKEN_INT, "2"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_PIE_ENCHILADA, "pie="},
+        {TOKEN_INT, "3"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_LEFTOVERS_ENCHILADA, "%="},
+        {TOKEN_INT, "4"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_EOF, ""},
+    }
+
+    l := NewLexer(input)
+
+    for i, tt := range tests {
+        tok := l.NextToken()
+
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+        }
+
+        if tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+        }
+    }
+}
+
+func TestLexerProductOperators(t *testing.T) {
+    input := `a * b / c % d pancakes e pie f leftovers g`
+
+    tests := []struct {
+        expectedType    TokenType
+        expectedLiteral string
+    }{
+        {TOKEN_IDENT, "a"},
+        {TOKEN_PANCAKES, "*"},
+        {TOKEN_IDENT, "b"},
+        {TOKEN_PIE, "/"},
+        {TOKEN_IDENT, "c"},
+        {TOKEN_LEFTOVERS, "%"},
+        {TOKEN_IDENT, "d"},
+        {TOKEN_PANCAKES, "pancakes"},
+        {TOKEN_IDENT, "e"},
+        {TOKEN_PIE, "pie"},
+        {TOKEN_IDENT, "f"},
+        {TOKEN_LEFTOVERS, "leftovers"},
+        {TOKEN_IDENT, "g"},
+        {TOKEN_EOF, ""},
+    }
+
+    l := NewLexer(input)
+
+    for i, tt := range tests {
+        tok := l.NextToken()
+
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+        }
+
+        if tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+        }
+    }
+}
+
+func TestLexerDelimiters(t *testing.T) {
+    input := `(x + y) - z; { [1, 2] } {"k": 3}`
+
+    tests := []struct {
+        expectedType    TokenType
+        expectedLiteral string
+    }{
+        {TOKEN_LPAREN, "("},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_APPLE, "+"},
+        {TOKEN_IDENT, "y"},
+        {TOKEN_RPAREN, ")"},
+        {TOKEN_SALMON, "-"},
+        {TOKEN_IDENT, "z"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_LBRACE, "{"},
+        {TOKEN_LBRACKET, "["},
+        {TOKEN_INT, "1"},
+        {TOKEN_COMMA, ","},
+        {TOKEN_INT, "2"},
+        {TOKEN_RBRACKET, "]"},
+        {TOKEN_RBRACE, "}"},
+        {TOKEN_LBRACE, "{"},
+        {TOKEN_STRING, "k"},
+        {TOKEN_COLON, ":"},
+        {TOKEN_INT, "3"},
+        {TOKEN_RBRACE, "}"},
+        {TOKEN_EOF, ""},
+    }
+
+    l := NewLexer(input)
+
+    for i, tt := range tests {
+        tok := l.NextToken()
+
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+        }
+
+        if tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+        }
+    }
+}
+
+func TestLexerComparisons(t *testing.T) {
+    input := `x == y != z < 1 > 2 <= 3 >= 4; !cake; broccoli;`
+
+    tests := []struct {
+        expectedType    TokenType
+        expectedLiteral string
+    }{
+        {TOKEN_IDENT, "x"},
+        {TOKEN_EQ, "=="},
+        {TOKEN_IDENT, "y"},
+        {TOKEN_NOT_EQ, "!="},
+        {TOKEN_IDENT, "z"},
+        {TOKEN_LT, "<"},
+        {TOKEN_INT, "1"},
+        {TOKEN_GT, ">"},
+        {TOKEN_INT, "2"},
+        {TOKEN_LT_EQ, "<="},
+        {TOKEN_INT, "3"},
+        {TOKEN_GT_EQ, ">="},
+        {TOKEN_INT, "4"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_BANG, "!"},
+        {TOKEN_CAKE, "cake"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_BROCCOLI, "broccoli"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_EOF, ""},
+    }
+
+    l := NewLexer(input)
+
+    for i, tt := range tests {
+        tok := l.NextToken()
+
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+        }
+
+        if tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+        }
+    }
+}
+
+func TestLexerConditionals(t *testing.T) {
+    input := `waffles x { pizza 1; } fries { pizza 2; }`
+
+    tests := []struct {
+        expectedType    TokenType
+        expectedLiteral string
+    }{
+        {TOKEN_WAFFLES, "waffles"},
+        {TOKEN_IDENT, "x"},
+        {TOKEN_LBRACE, "{"},
+        {TOKEN_PIZZA, "pizza"},
+        {TOKEN_INT, "1"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_RBRACE, "}"},
+        {TOKEN_FRIES, "fries"},
+        {TOKEN_LBRACE, "{"},
+        {TOKEN_PIZZA, "pizza"},
+        {TOKEN_INT, "2"},
+        {TOKEN_SEMICOLON, ";"},
+        {TOKEN_RBRACE, "}"},
+        {TOKEN_EOF, ""},
+    }
+
+    l := NewLexer(input)
+
+    for i, tt := range tests {
+        tok := l.NextToken()
+
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+        }
+
+        if tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+        }
+    }
+}
+
+func TestLexerLoops(t *testing.T) {
+    input := `noodles donuts dessert seconds`
+
+    tests := []struct {
+        expectedType    TokenType
+        expectedLiteral string
+    }{
+        {TOKEN_NOODLES, "noodles"},
+        {TOKEN_DONUTS, "donuts"},
+        {TOKEN_DESSERT, "dessert"},
+        {TOKEN_SECONDS, "seconds"},
+        {TOKEN_EOF, ""},
+    }
+
+    l := NewLexer(input)
+
+    for i, tt := range tests {
+        tok := l.NextToken()
+
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+        }
+
+        if tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+        }
+    }
+}
+
+func TestLexerFunctions(t *testing.T) {
+    input := "cheese add = burrito(a, b) {\n    takeout a + b;\n};\nadd(1, 2);"
+
+    tests := []struct {
+        expectedType    TokenType
+        expectedLiteral string
+        expectedLine    int
+        expectedColumn  int
+    }{
+        {TOKEN_CHEESE, "cheese", 1, 1},
+        {TOKEN_IDENT, "add", 1, 8},
+        {TOKEN_ENCHILADA, "=", 1, 12},
+        {TOKEN_BURRITO, "burrito", 1, 14},
+        {TOKEN_LPAREN, "(", 1, 21},
+        {TOKEN_IDENT, "a", 1, 22},
+        {TOKEN_COMMA, ",", 1, 23},
+        {TOKEN_IDENT, "b", 1, 25},
+        {TOKEN_RPAREN, ")", 1, 26},
+        {TOKEN_LBRACE, "{", 1, 28},
+        {TOKEN_TAKEOUT, "takeout", 2, 5},
+        {TOKEN_IDENT, "a", 2, 13},
+        {TOKEN_APPLE, "+", 2, 15},
+        {TOKEN_IDENT, "b", 2, 17},
+        {TOKEN_SEMICOLON, ";", 2, 18},
+        {TOKEN_RBRACE, "}", 3, 1},
+        {TOKEN_SEMICOLON, ";", 3, 2},
+        {TOKEN_IDENT, "add", 4, 1},
+        {TOKEN_LPAREN, "(", 4, 4},
+        {TOKEN_INT, "1", 4, 5},
+        {TOKEN_COMMA, ",", 4, 6},
+        {TOKEN_INT, "2", 4, 8},
+        {TOKEN_RPAREN, ")", 4, 9},
+        {TOKEN_SEMICOLON, ";", 4, 10},
+        {TOKEN_EOF, "", 4, 11},
+    }
+
+    l := NewLexer(input)
+
+    for i, tt := range tests {
+        tok := l.NextToken()
+
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+        }
+
+        if tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+        }
+
+        if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+            t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+        }
+    }
+}
+
+func TestLexerStrings(t *testing.T) {
+    input := `"hello" "a \"quoted\" word\n" "tab\tback\\slash" "" "oops\q" "open`
+
+    tests := []struct {
+        expectedType    TokenType
+        expectedLiteral string
+    }{
+        {TOKEN_STRING, "hello"},
+        {TOKEN_STRING, "a \"quoted\" word\n"},
+        {TOKEN_STRING, "tab\tback\\slash"},
+        {TOKEN_STRING, ""},
+        {TOKEN_ILLEGAL, `"oops\q"`},
+        {TOKEN_ILLEGAL, `"open`},
+        {TOKEN_EOF, ""},
+    }
+
+    l := NewLexer(input)
+
+    for i, tt := range tests {
+        tok := l.NextToken()
+
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+        }
+
+        if tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+        }
+    }
+}
+
+func TestLexerEndPositions(t *testing.T) {
+    input := "cheese s = \"a\\n\";\n  x += 10"
+
+    tests := []struct {
+        expectedType      TokenType
+        expectedColumn    int
+        expectedEndColumn int
+        expectedLine      int
+    }{
+        {TOKEN_CHEESE, 1, 7, 1},
+        {TOKEN_IDENT, 8, 9, 1},
+        {TOKEN_ENCHILADA, 10, 11, 1},
+        {TOKEN_STRING, 12, 17, 1},
+        {TOKEN_SEMICOLON, 17, 18, 1},
+        {TOKEN_IDENT, 3, 4, 2},
+        {TOKEN_APPLE_ENCHILADA, 5, 7, 2},
+        {TOKEN_INT, 8, 10, 2},
+        {TOKEN_EOF, 10, 10, 2},
+    }
+
+    l := NewLexer(input)
+    for i, tt := range tests {
+        tok := l.NextToken()
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+        }
+        if tok.Line != tt.expectedLine || tok.EndLine != tt.expectedLine {
+            t.Errorf("tests[%d] - lines wrong. expected=%d, got=%d-%d", i, tt.expectedLine, tok.Line, tok.EndLine)
+        }
+        if tok.Column != tt.expectedColumn || tok.EndColumn != tt.expectedEndColumn {
+            t.Errorf("tests[%d] - columns wrong. expected=%d-%d, got=%d-%d", i, tt.expectedColumn, tt.expectedEndColumn, tok.Column, tok.EndColumn)
+        }
+    }
+}
+
+func TestLexerComments(t *testing.T) {
+    input := "// intro\ncheese x = 1; // trailing\npizza 4 / 2;"
+
+    l := NewLexer(input)
+    var types []TokenType
+    for tok := l.NextToken(); tok.Type != TOKEN_EOF; tok = l.NextToken() {
+        types = append(types, tok.Type)
+    }
+    expected := []TokenType{
+        TOKEN_CHEESE, TOKEN_IDENT, TOKEN_ENCHILADA, TOKEN_INT, TOKEN_SEMICOLON,
+        TOKEN_PIZZA, TOKEN_INT, TOKEN_PIE, TOKEN_INT, TOKEN_SEMICOLON,
+    }
+    if len(types) != len(expected) {
+        t.Fatalf("wrong tokens. expected=%v, got=%v", expected, types)
+    }
+    for i := range expected {
+        if types[i] != expected[i] {
+            t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, expected[i], types[i])
+        }
+    }
+
+    comments := l.Comments()
+    if len(comments) != 2 {
+        t.Fatalf("wrong number of comments. expected=2, got=%d", len(comments))
+    }
+    if comments[0].Literal != "// intro" || comments[0].Line != 1 || comments[0].Column != 1 || comments[0].EndColumn != 9 {
+        t.Errorf("wrong first comment: %+v", comments[0])
+    }
+    if comments[1].Literal != "// trailing" || comments[1].Line != 2 || comments[1].Column != 15 {
+        t.Errorf("wrong second comment: %+v", comments[1])
+    }
+}
+
+func TestLexerBlockComments(t *testing.T) {
+    input := "pizza /* one */ 1;\n/* two\n   lines */ pizza 2 /* 3 */ / 4;\n/*/ no */ /**/"
+
+    l := NewLexer(input)
+    var types []TokenType
+    for tok := l.NextToken(); tok.Type != TOKEN_EOF; tok = l.NextToken() {
+        types = append(types, tok.Type)
+    }
+    expected := []TokenType{
+        TOKEN_PIZZA, TOKEN_INT, TOKEN_SEMICOLON,
+        TOKEN_PIZZA, TOKEN_INT, TOKEN_PIE, TOKEN_INT, TOKEN_SEMICOLON,
+    }
+    if len(types) != len(expected) {
+        t.Fatalf("wrong tokens. expected=%v, got=%v", expected, types)
+    }
+
+    comments := l.Comments()
+    literals := []string{"/* one */", "/* two\n   lines */", "/* 3 */", "/*/ no */", "/**/"}
+    if len(comments) != len(literals) {
+        t.Fatalf("wrong number of comments. expected=%d, got=%d", len(literals), len(comments))
+    }
+    for i, literal := range literals {
+        if comments[i].Literal != literal {
+            t.Errorf("comments[%d] - literal wrong. expected=%q, got=%q", i, literal, comments[i].Literal)
+        }
+    }
+    if c := comments[1]; c.Line != 2 || c.Column != 1 || c.EndLine != 3 || c.EndColumn != 12 {
+        t.Errorf("wrong position for a comment over two lines: %+v", c)
+    }
+
+    l = NewLexer("pizza 1; /* open\n")
+    for tok := l.NextToken(); tok.Type != TOKEN_EOF; tok = l.NextToken() {
+        if tok.Type == TOKEN_ILLEGAL {
+            if tok.Literal != "/* open\n" || tok.Line != 1 || tok.Column != 10 || tok.EndLine != 2 {
+                t.Errorf("wrong token for an unterminated comment: %+v", tok)
+            }
+            return
+        }
+    }
+    t.Errorf("expected an ILLEGAL token for an unterminated comment")
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The linter looks for code that runs but is probably not what its author meant, such as
+// a cheese binding that is never read or a statement after a takeout. Each kind of
+// problem is found by one rule, which walks the parsed program and reports findings.
+// Rules can be turned off for a whole program with a config file, and for part of a file
+// with comments:
+//
+//	// goofylint:disable [rule ...]            off from here on
+//	// goofylint:enable [rule ...]             back on from here on
+//	// goofylint:disable-line [rule ...]       off for the line of the comment
+//	// goofylint:disable-next-line [rule ...]  off for the line after the comment
+//
+// A directive without rule names applies to every rule.
+
+// LintFinding is one problem a rule found.
+type LintFinding struct {
+	Rule    string
+	Span    Span
+	Message string
+}
+
+// String formats the finding as "line:column: message (rule)".
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", f.Span.Line, f.Span.Column, f.Message, f.Rule)
+}
+
+// LintRule is one check the linter can run.
+type LintRule struct {
+	Name        string
+	Description string
+	Check       func(pass *LintPass)
+}
+
+// LintPass is what a rule sees of the program it checks.
+type LintPass struct {
+	Program  *Program
+	Resolver *Resolver // Has already resolved Program.
+	rule     string
+	findings []LintFinding
+}
+
+// Report records a finding of the running rule.
+func (p *LintPass) Report(span Span, format string, a ...interface{}) {
+	p.findings = append(p.findings, LintFinding{Rule: p.rule, Span: span, Message: fmt.Sprintf(format, a...)})
+}
+
+// lintRules are the rules every new Linter runs.
+var lintRules = []LintRule{
+	{"unused-cheese", "a cheese binding is never read", lintUnusedCheese},
+	{"shadowing", "a binding hides one of an enclosing scope or a builtin", lintShadowing},
+	{"self-assignment", "a variable is assigned its own value", lintSelfAssignment},
+	{"mixed-spelling", "an operator is spelled both as a word and as a symbol in one file", lintMixedSpelling},
+	{"unreachable", "a statement follows one that never completes", lintUnreachable},
+}
+
+// directiveRule is the rule findings about malformed directives are reported under. It
+// cannot be disabled.
+const directiveRule = "goofylint"
+
+// LintConfig is the contents of a config file, such as
+//
+//	{"rules": {"shadowing": false}}
+//
+// Rules it does not mention keep their default.
+type LintConfig struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// LoadLintConfig reads a config file.
+func LoadLintConfig(path string) (LintConfig, error) {
+	var config LintConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %s", path, err)
+	}
+	return config, nil
+}
+
+// Linter runs a set of rules over programs.
+type Linter struct {
+	rules    []LintRule
+	disabled map[string]bool
+}
+
+// NewLinter creates a Linter with every rule enabled.
+func NewLinter() *Linter {
+	return &Linter{rules: append([]LintRule(nil), lintRules...), disabled: map[string]bool{}}
+}
+
+// AddRule adds a rule, replacing any rule with the same name.
+func (l *Linter) AddRule(rule LintRule) {
+	for i := range l.rules {
+		if l.rules[i].Name == rule.Name {
+			l.rules[i] = rule
+			return
+		}
+	}
+	l.rules = append(l.rules, rule)
+}
+
+// Rules returns the rules the linter knows, enabled or not.
+func (l *Linter) Rules() []LintRule {
+	return append([]LintRule(nil), l.rules...)
+}
+
+// SetEnabled turns a rule on or off.
+func (l *Linter) SetEnabled(name string, enabled bool) error {
+	if !l.known(name) {
+		return fmt.Errorf("unknown lint rule: %s", name)
+	}
+	l.disabled[name] = !enabled
+	return nil
+}
+
+// Configure applies a config file's settings.
+func (l *Linter) Configure(config LintConfig) error {
+	names := make([]string, 0, len(config.Rules))
+	for name := range config.Rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := l.SetEnabled(name, config.Rules[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Linter) known(name string) bool {
+	for _, rule := range l.rules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint runs every enabled rule over program, whose source had the given comments, and
+// returns the findings the comments do not suppress, in source order.
+func (l *Linter) Lint(program *Program, comments []Token) []LintFinding {
+	resolver := NewResolver(nil)
+	resolver.Resolve(program)
+
+	var findings []LintFinding
+	directives := l.directives(comments, &findings)
+	for _, rule := range l.rules {
+		if l.disabled[rule.Name] {
+			continue
+		}
+		pass := &LintPass{Program: program, Resolver: resolver, rule: rule.Name}
+		rule.Check(pass)
+		for _, f := range pass.findings {
+			if !suppressed(directives, f) {
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i].Span, findings[j].Span
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return findings
+}
+
+// lintDirective is one goofylint comment.
+type lintDirective struct {
+	kind  string
+	line  int
+	rules map[string]bool // nil for every rule
+}
+
+// applies reports whether the directive is about rule.
+func (d lintDirective) applies(rule string) bool {
+	return d.rules == nil || d.rules[rule]
+}
+
+// directives parses the goofylint comments, reporting the malformed ones as findings.
+func (l *Linter) directives(comments []Token, findings *[]LintFinding) []lintDirective {
+	var directives []lintDirective
+	for _, c := range comments {
+		text := CommentText(c)
+		if !strings.HasPrefix(text, "goofylint:") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(text, "goofylint:"))
+		report := func(format string, a ...interface{}) {
+			*findings = append(*findings, LintFinding{Rule: directiveRule, Span: tokenSpan(c), Message: fmt.Sprintf(format, a...)})
+		}
+		if len(fields) == 0 {
+			report("missing goofylint directive")
+			continue
+		}
+
+		d := lintDirective{kind: fields[0], line: c.Line}
+		switch d.kind {
+		case "disable", "enable", "disable-line", "disable-next-line":
+		default:
+			report("unknown goofylint directive: %s", d.kind)
+			continue
+		}
+		for _, name := range fields[1:] {
+			if !l.known(name) {
+				report("unknown lint rule: %s", name)
+				continue
+			}
+			if d.rules == nil {
+				d.rules = map[string]bool{}
+			}
+			d.rules[name] = true
+		}
+		if len(fields) > 1 && d.rules == nil {
+			// Every rule named was unknown, which must not turn into "every rule".
+			continue
+		}
+		directives = append(directives, d)
+	}
+	return directives
+}
+
+// suppressed reports whether the directives turn off the rule of f where it was found.
+func suppressed(directives []lintDirective, f LintFinding) bool {
+	off := false
+	for _, d := range directives {
+		if !d.applies(f.Rule) {
+			continue
+		}
+		switch d.kind {
+		case "disable", "enable":
+			if d.line <= f.Span.Line {
+				off = d.kind == "disable"
+			}
+		case "disable-line":
+			if d.line == f.Span.Line {
+				return true
+			}
+		case "disable-next-line":
+			if d.line+1 == f.Span.Line {
+				return true
+			}
+		}
+	}
+	return off
+}
+
+// lintUnusedCheese reports cheese bindings that are never read. Assigning to a binding
+// does not read it, but a compound assignment such as apple= does. Names starting with
+// an underscore are never reported.
+func lintUnusedCheese(pass *LintPass) {
+	targets := map[*Identifier]bool{}
+	walkAST(pass.Program, func(node Node) {
+		if assign, ok := node.(*AssignStatement); ok && assign.Token.Type == TOKEN_ENCHILADA {
+			targets[assign.Name] = true
+		}
+	})
+
+	read := map[*Identifier]bool{}
+	var lets []*LetStatement
+	walkAST(pass.Program, func(node Node) {
+		switch node := node.(type) {
+		case *LetStatement:
+			lets = append(lets, node)
+		case *Identifier:
+			if def, ok := pass.Resolver.Definition(node); ok && def != node && !targets[node] {
+				read[def] = true
+			}
+		}
+	})
+
+	for _, let := range lets {
+		if !read[let.Name] && !strings.HasPrefix(let.Name.Value, "_") {
+			pass.Report(spanOf(let.Name), "cheese %s is never used", let.Name.Value)
+		}
+	}
+}
+
+// lintShadowing reports cheese bindings, parameters and donuts variables that hide a
+// binding of an enclosing scope or a builtin.
+func lintShadowing(pass *LintPass) {
+	check := func(decl *Identifier) {
+		outer, ok := pass.Resolver.Shadowed(decl)
+		switch {
+		case !ok:
+		case outer.Token.Line != 0:
+			pass.Report(spanOf(decl), "%s shadows the declaration at %d:%d", decl.Value, outer.Token.Line, outer.Token.Column)
+		case builtins[decl.Value] != nil:
+			pass.Report(spanOf(decl), "%s shadows the builtin %s", decl.Value, decl.Value)
+		}
+	}
+	walkAST(pass.Program, func(node Node) {
+		switch node := node.(type) {
+		case *LetStatement:
+			check(node.Name)
+		case *ForStatement:
+			check(node.Variable)
+		case *FunctionLiteral:
+			for _, param := range node.Parameters {
+				check(param)
+			}
+		}
+	})
+}
+
+// lintSelfAssignment reports x = x, and cheese x = x when x is already declared in the
+// same scope, since neither changes anything.
+func lintSelfAssignment(pass *LintPass) {
+	walkAST(pass.Program, func(node Node) {
+		switch node := node.(type) {
+		case *AssignStatement:
+			if value, ok := node.Value.(*Identifier); ok && node.Token.Type == TOKEN_ENCHILADA && value.Value == node.Name.Value {
+				pass.Report(spanOf(node), "%s is assigned to itself", node.Name.Value)
+			}
+		case *LetStatement:
+			if value, ok := node.Value.(*Identifier); ok && value.Value == node.Name.Value && value.Resolved && value.Depth == 0 {
+				pass.Report(spanOf(node), "%s is assigned to itself", node.Name.Value)
+			}
+		}
+	})
+}
+
+// operatorWords maps every operator token that can be written as a word or as a symbol
+// to the word.
+var operatorWords = map[TokenType]string{
+	TOKEN_APPLE:               "apple",
+	TOKEN_APPLE_ENCHILADA:     "apple",
+	TOKEN_SALMON:              "salmon",
+	TOKEN_SALMON_ENCHILADA:    "salmon",
+	TOKEN_PANCAKES:            "pancakes",
+	TOKEN_PANCAKES_ENCHILADA:  "pancakes",
+	TOKEN_PIE:                 "pie",
+	TOKEN_PIE_ENCHILADA:       "pie",
+	TOKEN_LEFTOVERS:           "leftovers",
+	TOKEN_LEFTOVERS_ENCHILADA: "leftovers",
+}
+
+// lintMixedSpelling reports operators spelled differently from the first use of the
+// same operator in the file, such as a + after an apple. The compound assignments count
+// as uses of their operator.
+func lintMixedSpelling(pass *LintPass) {
+	// The walk meets an infix operator before the ones in its left operand, so the
+	// operators are put back in source order first.
+	var operators []Token
+	walkAST(pass.Program, func(node Node) {
+		switch node := node.(type) {
+		case *InfixExpression:
+			operators = append(operators, node.Token)
+		case *PrefixExpression:
+			operators = append(operators, node.Token)
+		case *AssignStatement:
+			operators = append(operators, node.Token)
+		}
+	})
+	sort.SliceStable(operators, func(i, j int) bool {
+		a, b := operators[i], operators[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	first := map[string]Token{}
+	for _, tok := range operators {
+		word, ok := operatorWords[tok.Type]
+		if !ok {
+			continue
+		}
+		prev, seen := first[word]
+		if !seen {
+			first[word] = tok
+		} else if isLetter(prev.Literal[0]) != isLetter(tok.Literal[0]) {
+			pass.Report(tokenSpan(tok), "%s is spelled %s at %d:%d", tok.Literal, prev.Literal, prev.Line, prev.Column)
+		}
+	}
+}
+
+// lintUnreachable reports the first statement after one that never completes, such as a
+// takeout or a waffles whose branches all take out.
+func lintUnreachable(pass *LintPass) {
+	check := func(stmts []Statement) {
+		for i, stmt := range stmts[:max(len(stmts)-1, 0)] {
+			if !statementCompletes(stmt) {
+				pass.Report(spanOf(stmts[i+1]), "unreachable code after %s", stmt.TokenLiteral())
+				return
+			}
+		}
+	}
+	walkAST(pass.Program, func(node Node) {
+		switch node := node.(type) {
+		case *Program:
+			check(node.Statements)
+		case *BlockStatement:
+			check(node.Statements)
+		}
+	})
+}
+
+// The SARIF types are the part of the Static Analysis Results Interchange Format that
+// code scanning tools read.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// writeSARIF writes the findings of each file as one SARIF log.
+func writeSARIF(w io.Writer, rules []LintRule, files []string, findings map[string][]LintFinding) error {
+	driver := sarifDriver{Name: "goofylint", Rules: []sarifRule{}}
+	for _, rule := range rules {
+		driver.Rules = append(driver.Rules, sarifRule{ID: rule.Name, ShortDescription: sarifMessage{rule.Description}})
+	}
+	driver.Rules = append(driver.Rules, sarifRule{ID: directiveRule, ShortDescription: sarifMessage{"a goofylint comment is malformed"}})
+	run := sarifRun{Tool: sarifTool{driver}, Results: []sarifResult{}}
+	for _, file := range files {
+		for _, f := range findings[file] {
+			run.Results = append(run.Results, sarifResult{
+				RuleID:  f.Rule,
+				Level:   "warning",
+				Message: sarifMessage{f.Message},
+				Locations: []sarifLocation{{sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{file},
+					Region:           sarifRegion{f.Span.Line, f.Span.Column, f.Span.EndLine, f.Span.EndColumn},
+				}}},
+			})
+		}
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lintProgram(t *testing.T, linter *Linter, input string) []string {
+	t.Helper()
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("parser errors for %q: %v", input, errs)
+	}
+	var findings []string
+	for _, f := range linter.Lint(program, p.Comments()) {
+		findings = append(findings, f.String())
+	}
+	return findings
+}
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"cheese x = 1;", []string{"1:8: cheese x is never used (unused-cheese)"}},
+		{"cheese x = 1; x = 2;", []string{"1:8: cheese x is never used (unused-cheese)"}},
+		{"cheese x = 1; x += 2;", nil},
+		{"cheese _x = 1;", nil},
+		{"cheese f = burrito() { takeout g(); }; cheese g = burrito() { takeout 1; }; pizza f();", nil},
+		{"cheese x = 1; cheese x = x + 1;", []string{"1:22: cheese x is never used (unused-cheese)"}},
+		{"cheese x = 1; waffles cake { cheese x = 2; pizza x; } pizza x;", []string{"1:37: x shadows the declaration at 1:8 (shadowing)"}},
+		{"cheese x = 1; cheese f = burrito(x) { takeout x; }; pizza f(x);", []string{"1:34: x shadows the declaration at 1:8 (shadowing)"}},
+		{"donuts len = 0, 3 { pizza len; }", []string{"1:8: len shadows the builtin len (shadowing)"}},
+		{"cheese x = 1; cheese x = 2; pizza x;", []string{"1:8: cheese x is never used (unused-cheese)"}},
+		{"cheese x = 1; x = x; pizza x;", []string{"1:15: x is assigned to itself (self-assignment)"}},
+		{"cheese x = 1; cheese x = x; pizza x;", []string{"1:15: x is assigned to itself (self-assignment)"}},
+		{"cheese x = 1; waffles cake { cheese x = x; pizza x; }", []string{"1:37: x shadows the declaration at 1:8 (shadowing)"}},
+		{"pizza 1 apple 2 + 3;", []string{"1:17: + is spelled apple at 1:9 (mixed-spelling)"}},
+		{"cheese x = 1; x += 1; pizza x apple -x salmon 1;", []string{"1:31: apple is spelled += at 1:17 (mixed-spelling)", "1:40: salmon is spelled - at 1:37 (mixed-spelling)"}},
+		{"pizza 1 + 2 * 3 pie 4;", nil},
+		{"cheese f = burrito() { takeout 1; pizza 2; pizza 3; };\npizza f();", []string{"1:35: unreachable code after takeout (unreachable)"}},
+		{"noodles cake { waffles cake { dessert; } fries { seconds; } pizza 1; }", []string{"1:61: unreachable code after waffles (unreachable)"}},
+	}
+
+	for _, tt := range tests {
+		findings := lintProgram(t, NewLinter(), tt.input)
+		if !reflect.DeepEqual(findings, tt.expected) {
+			t.Errorf("wrong findings for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, findings)
+		}
+	}
+}
+
+func TestLintDirectives(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"cheese x = 1; // goofylint:disable-line", nil},
+		{"cheese x = 1; // goofylint:disable-line shadowing", []string{"1:8: cheese x is never used (unused-cheese)"}},
+		{"// goofylint:disable-next-line unused-cheese\ncheese x = 1;\ncheese y = 2;", []string{"3:8: cheese y is never used (unused-cheese)"}},
+		{"cheese a = 1;\n// goofylint:disable unused-cheese\ncheese b = 2;\n// goofylint:enable\ncheese c = 3;", []string{"1:8: cheese a is never used (unused-cheese)", "5:8: cheese c is never used (unused-cheese)"}},
+		{"// goofylint:disable nope\npizza 1;", []string{"1:1: unknown lint rule: nope (goofylint)"}},
+		{"pizza 1; // goofylint:silence", []string{"1:10: unknown goofylint directive: silence (goofylint)"}},
+	}
+
+	for _, tt := range tests {
+		findings := lintProgram(t, NewLinter(), tt.input)
+		if !reflect.DeepEqual(findings, tt.expected) {
+			t.Errorf("wrong findings for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, findings)
+		}
+	}
+}
+
+func TestLinterConfiguration(t *testing.T) {
+	linter := NewLinter()
+	if err := linter.Configure(LintConfig{Rules: map[string]bool{"unused-cheese": false}}); err != nil {
+		t.Fatal(err)
+	}
+	if findings := lintProgram(t, linter, "cheese y = 1; cheese x = 1; x = x;"); !reflect.DeepEqual(findings, []string{"1:29: x is assigned to itself (self-assignment)"}) {
+		t.Errorf("wrong findings with unused-cheese disabled: %q", findings)
+	}
+	if err := linter.Configure(LintConfig{Rules: map[string]bool{"nope": true}}); err == nil || err.Error() != "unknown lint rule: nope" {
+		t.Errorf("wrong error for an unknown rule: %v", err)
+	}
+
+	linter.AddRule(LintRule{Name: "no-pizza", Check: func(pass *LintPass) {
+		walkAST(pass.Program, func(node Node) {
+			if stmt, ok := node.(*PrintStatement); ok {
+				pass.Report(spanOf(stmt), "pizza")
+			}
+		})
+	}})
+	if findings := lintProgram(t, linter, "pizza 1; // goofylint:disable-line unused-cheese\n// goofylint:disable-next-line no-pizza\npizza 2;"); !reflect.DeepEqual(findings, []string{"1:1: pizza (no-pizza)"}) {
+		t.Errorf("wrong findings for an added rule: %q", findings)
+	}
+}
+
+func TestLintCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lint.goofy")
+	if err := os.WriteFile(path, []byte("cheese x = 1;\ncheese y = 2;\npizza y;\ny = y;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	err := lintCommand([]string{path}, &out, &errOut)
+	if err == nil || err.Error() != "2 lint findings" {
+		t.Errorf("wrong error: %v", err)
+	}
+	expected := path + ":1:8: cheese x is never used (unused-cheese)\n" + path + ":4:1: y is assigned to itself (self-assignment)\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+
+	// A goofylint.json next to the file is used by default.
+	if err := os.WriteFile(filepath.Join(dir, lintConfigFile), []byte(`{"rules": {"unused-cheese": false}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := lintCommand([]string{"-format", "json", path}, &out, &errOut); err == nil {
+		t.Errorf("expected an error for a file with findings")
+	}
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("output is not JSON: %s\n%s", err, out.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || log.Runs[0].Tool.Driver.Name != "goofylint" {
+		t.Fatalf("wrong SARIF log: %+v", log)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 || results[0].RuleID != "self-assignment" || results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI != path {
+		t.Fatalf("wrong results: %+v", results)
+	}
+	if region := results[0].Locations[0].PhysicalLocation.Region; region != (sarifRegion{4, 1, 4, 6}) {
+		t.Errorf("wrong region: %+v", region)
+	}
+
+	// -config overrides it.
+	config := filepath.Join(dir, "all.json")
+	if err := os.WriteFile(config, []byte(`{"rules": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	lintCommand([]string{"-config", config, path}, &out, &errOut)
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Errorf("wrong output with -config: %q", out.String())
+	}
+
+	if err := os.WriteFile(path, []byte("cheese x = 1;\npizza x;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := lintCommand([]string{path}, &out, &errOut); err != nil || out.Len() != 0 {
+		t.Errorf("clean file reported %v: %q", err, out.String())
+	}
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The language server speaks the Language Server Protocol over a pair of streams,
+// normally stdin and stdout, so editors can show goofylang errors as they are typed,
+// explain what a food means on hover, jump to the cheese binding a name refers to, list
+// the bindings of a file, complete keywords and format the file.
+//
+// Documents are synchronized in full on every change. Positions in the protocol count
+// lines and characters from 0 and Spans count them from 1. The protocol also counts the
+// characters of a line in UTF-16 code units while Spans count bytes, which differ once a
+// string literal holds text outside ASCII, so positions are converted on their line.
+
+// readMessage reads one message framed with a Content-Length header, as both the
+// language server and the debug adapter protocols frame them.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("bad Content-Length header: %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeMessage writes v as JSON framed with a Content-Length header.
+func writeMessage(w io.Writer, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// rpcMessage is a JSON-RPC request, response or notification as it is read.
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse answers a request with either a result or an error.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcNotification is a message the server sends without expecting an answer.
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes the server uses.
+const (
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// The protocol types the server reads and writes, with only the fields it uses.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+}
+
+type lspHover struct {
+	Contents lspMarkup `json:"contents"`
+	Range    lspRange  `json:"range"`
+}
+
+type lspMarkup struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type lspSymbol struct {
+	Name           string      `json:"name"`
+	Detail         string      `json:"detail,omitempty"`
+	Kind           int         `json:"kind"`
+	Range          lspRange    `json:"range"`
+	SelectionRange lspRange    `json:"selectionRange"`
+	Children       []lspSymbol `json:"children,omitempty"`
+}
+
+type lspCompletion struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+// Protocol enumerations.
+const (
+	lspSeverityError        = 1
+	lspSymbolFunction       = 12
+	lspSymbolVariable       = 13
+	lspCompletionFunction   = 3
+	lspCompletionKeyword    = 14
+	lspTextDocumentSyncFull = 1
+)
+
+// tokenMeanings says what each kind of token means, for hovers.
+var tokenMeanings = map[TokenType]string{
+	TOKEN_IDENT:               "a name",
+	TOKEN_INT:                 "an integer",
+	TOKEN_STRING:              "a string",
+	TOKEN_PIZZA:               "print a value",
+	TOKEN_CHEESE:              "declare a variable",
+	TOKEN_TACOS:               "the type name of a value, or a switch on it",
+	TOKEN_NUGGETS:             "the integer type, or conversion to an integer",
+	TOKEN_ENCHILADA:           "assignment (`=`)",
+	TOKEN_APPLE:               "addition (`+`)",
+	TOKEN_SALMON:              "subtraction or negation (`-`)",
+	TOKEN_PANCAKES:            "multiplication (`*`)",
+	TOKEN_PIE:                 "division (`/`)",
+	TOKEN_LEFTOVERS:           "remainder (`%`)",
+	TOKEN_APPLE_ENCHILADA:     "add and assign (`+=`)",
+	TOKEN_SALMON_ENCHILADA:    "subtract and assign (`-=`)",
+	TOKEN_PANCAKES_ENCHILADA:  "multiply and assign (`*=`)",
+	TOKEN_PIE_ENCHILADA:       "divide and assign (`/=`)",
+	TOKEN_LEFTOVERS_ENCHILADA: "take the remainder and assign (`%=`)",
+	TOKEN_ICACO:               "read an integer from input",
+	TOKEN_EQ:                  "equal to",
+	TOKEN_NOT_EQ:              "not equal to",
+	TOKEN_LT:                  "less than",
+	TOKEN_GT:                  "greater than",
+	TOKEN_LT_EQ:               "less than or equal to",
+	TOKEN_GT_EQ:               "greater than or equal to",
+	TOKEN_BANG:                "not",
+	TOKEN_CAKE:                "true",
+	TOKEN_BROCCOLI:            "false",
+	TOKEN_WAFFLES:             "if",
+	TOKEN_FRIES:               "else, or the default case of tacos",
+	TOKEN_NOODLES:             "a while loop",
+	TOKEN_DONUTS:              "a counted for loop",
+	TOKEN_DESSERT:             "break out of the loop",
+	TOKEN_SECONDS:             "continue with the next iteration",
+	TOKEN_BURRITO:             "a function",
+	TOKEN_TAKEOUT:             "return from the burrito",
+}
+
+// LSPServer is a language server for goofylang.
+type LSPServer struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]string // The text of every open document, by URI.
+	shutdown bool
+}
+
+// NewLSPServer creates a server that reads from in and writes to out.
+func NewLSPServer(in io.Reader, out io.Writer) *LSPServer {
+	return &LSPServer{in: bufio.NewReader(in), out: out, docs: map[string]string{}}
+}
+
+// Serve handles messages until the client sends exit or closes the input.
+func (s *LSPServer) Serve() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return fmt.Errorf("bad message: %s", err)
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			// Notifications get no answer, not even an error.
+			continue
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: msg.ID, Error: rerr}
+		if rerr == nil {
+			if resp.Result, err = json.Marshal(result); err != nil {
+				return err
+			}
+		}
+		if err := writeMessage(s.out, resp); err != nil {
+			return err
+		}
+	}
+}
+
+// notify sends a notification to the client.
+func (s *LSPServer) notify(method string, params interface{}) error {
+	return writeMessage(s.out, rpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle runs one request or notification and returns its result.
+func (s *LSPServer) handle(msg rpcMessage) (interface{}, *rpcError) {
+	if s.shutdown && msg.Method != "exit" {
+		return nil, &rpcError{rpcInvalidRequest, "the server is shutting down"}
+	}
+
+	var params struct {
+		lspPositionParams
+		ContentChanges []lspTextDocument `json:"contentChanges"`
+	}
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+	uri := params.TextDocument.URI
+
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":           lspTextDocumentSyncFull,
+				"hoverProvider":              true,
+				"definitionProvider":         true,
+				"documentSymbolProvider":     true,
+				"completionProvider":         map[string]interface{}{},
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "goofy"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = params.TextDocument.Text
+		s.publishDiagnostics(uri)
+		return nil, nil
+	case "textDocument/didChange":
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[uri] = params.ContentChanges[n-1].Text
+		}
+		s.publishDiagnostics(uri)
+		return nil, nil
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": []lspDiagnostic{}})
+		return nil, nil
+	case "textDocument/hover":
+		return s.hover(uri, params.Position), nil
+	case "textDocument/definition":
+		return s.definition(uri, params.Position), nil
+	case "textDocument/documentSymbol":
+		return s.symbols(uri), nil
+	case "textDocument/completion":
+		return lspKeywordCompletions(), nil
+	case "textDocument/formatting":
+		return s.format(uri), nil
+	default:
+		return nil, &rpcError{rpcMethodNotFound, "method not supported: " + msg.Method}
+	}
+}
+
+// lspDocument is an open document, parsed.
+type lspDocument struct {
+	text    string
+	lines   []string // The lines of text, to convert positions on them.
+	program *Program
+	parser  *Parser
+}
+
+// document parses the open document at uri.
+func (s *LSPServer) document(uri string) lspDocument {
+	text := s.docs[uri]
+	p := NewParser(NewLexer(text))
+	return lspDocument{text: text, lines: strings.Split(text, "\n"), program: p.ParseProgram(), parser: p}
+}
+
+// character returns the protocol character of a 1-based byte column on a 1-based line.
+func (d lspDocument) character(line, column int) int {
+	if line < 1 || line > len(d.lines) {
+		return max(column-1, 0)
+	}
+	text := d.lines[line-1]
+	n := min(max(column-1, 0), len(text))
+	return lspUTF16Len(text[:n]) + max(column-1-n, 0)
+}
+
+// column returns the 1-based line and byte column of a protocol position.
+func (d lspDocument) column(pos lspPosition) (int, int) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return pos.Line + 1, pos.Character + 1
+	}
+	units := 0
+	for i, r := range d.lines[pos.Line] {
+		if units >= pos.Character {
+			return pos.Line + 1, i + 1
+		}
+		units += lspRuneUnits(r)
+	}
+	return pos.Line + 1, len(d.lines[pos.Line]) + 1 + max(pos.Character-units, 0)
+}
+
+// spanRange converts a span to a protocol range.
+func (d lspDocument) spanRange(s Span) lspRange {
+	return lspRange{
+		Start: lspPosition{Line: max(s.Line-1, 0), Character: d.character(s.Line, s.Column)},
+		End:   lspPosition{Line: max(s.EndLine-1, 0), Character: d.character(s.EndLine, s.EndColumn)},
+	}
+}
+
+// lspUTF16Len returns how many UTF-16 code units text takes. A byte that is not valid
+// UTF-8 counts as one.
+func lspUTF16Len(text string) int {
+	n := 0
+	for _, r := range text {
+		n += lspRuneUnits(r)
+	}
+	return n
+}
+
+// lspRuneUnits returns how many UTF-16 code units r takes: two for a rune outside the
+// Basic Multilingual Plane, which needs a surrogate pair, and one for any other.
+func lspRuneUnits(r rune) int {
+	if r > 0xffff {
+		return 2
+	}
+	return 1
+}
+
+// publishDiagnostics reports the errors of a document: the parser's, or, for a document
+// that parses, the ones goofy check would report.
+func (s *LSPServer) publishDiagnostics(uri string) {
+	doc := s.document(uri)
+	found := doc.parser.Diagnostics()
+	if len(found) == 0 {
+		found = append(found, NewResolver(nil).Resolve(doc.program)...)
+		found = append(found, NewChecker().Check(doc.program)...)
+	}
+
+	diagnostics := []lspDiagnostic{}
+	for _, d := range found {
+		message := d.Message
+		for _, rel := range d.Related {
+			message += "\n" + rel.Span.String() + ": " + rel.Message
+		}
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    doc.spanRange(d.Span),
+			Severity: lspSeverityError,
+			Source:   "goofy",
+			Message:  message,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diagnostics})
+}
+
+// lspContains reports whether the 1-based line and column fall within span, counting
+// the position just past its end.
+func lspContains(span Span, line, column int) bool {
+	if line < span.Line || line > span.EndLine {
+		return false
+	}
+	if line == span.Line && column < span.Column {
+		return false
+	}
+	return line != span.EndLine || column <= span.EndColumn
+}
+
+// tokenAt returns the token at a 1-based line and byte column, preferring one that starts
+// there over one that ends there.
+func tokenAt(text string, line, column int) (Token, bool) {
+	var touching *Token
+	l := NewLexer(text)
+	for tok := l.NextToken(); tok.Type != TOKEN_EOF; tok = l.NextToken() {
+		if tok.Line != line || column < tok.Column || column > tok.EndColumn {
+			continue
+		}
+		if column < tok.EndColumn {
+			return tok, true
+		}
+		t := tok
+		touching = &t
+	}
+	if touching != nil {
+		return *touching, true
+	}
+	return Token{}, false
+}
+
+// identifierAt returns the identifier of the program at a 1-based line and byte column.
+func identifierAt(program *Program, line, column int) *Identifier {
+	var found *Identifier
+	walkAST(program, func(node Node) {
+		if ident, ok := node.(*Identifier); ok && found == nil && lspContains(spanOf(ident), line, column) {
+			found = ident
+		}
+	})
+	return found
+}
+
+// hover explains the token at a position: its kind, what the food means and, for a
+// name, where it was declared, its type and its documentation.
+func (s *LSPServer) hover(uri string, pos lspPosition) interface{} {
+	doc := s.document(uri)
+	line, column := doc.column(pos)
+	tok, ok := tokenAt(doc.text, line, column)
+	if !ok {
+		return nil
+	}
+
+	text := fmt.Sprintf("`%s` (%s): %s", tok.Literal, tok.Type, tokenMeanings[tok.Type])
+	if tok.Type == TOKEN_IDENT {
+		if _, ok := builtins[tok.Literal]; ok {
+			text += "\n\nbuiltin burrito"
+		}
+		if ident := identifierAt(doc.program, line, column); ident != nil && len(doc.parser.Errors()) == 0 {
+			text += s.describeBinding(doc, ident)
+		}
+	}
+	return lspHover{Contents: lspMarkup{Kind: "markdown", Value: text}, Range: doc.spanRange(tokenSpan(tok))}
+}
+
+// describeBinding tells where the binding an identifier refers to was declared, its type
+// if it can be inferred and its documentation.
+func (s *LSPServer) describeBinding(doc lspDocument, ident *Identifier) string {
+	r := NewResolver(nil)
+	r.Resolve(doc.program)
+	def, ok := r.Definition(ident)
+	if !ok {
+		return ""
+	}
+
+	text := fmt.Sprintf("\n\ndeclared at %d:%d", def.Token.Line, def.Token.Column)
+	in := NewInferrer()
+	in.Infer(doc.program)
+	for _, b := range in.Bindings() {
+		if b.Name == def && !in.Failed(b) {
+			text += fmt.Sprintf("\n\n```\n%s: %s\n```", def.Value, b.Type())
+		}
+	}
+
+	var declaration Node = def
+	walkAST(doc.program, func(node Node) {
+		if let, ok := node.(*LetStatement); ok && let.Name == def {
+			declaration = let
+		}
+	})
+	if comment := DocComment(declaration, doc.parser.Comments()); comment != "" {
+		text += "\n\n" + comment
+	}
+	return text
+}
+
+// definition returns the declaration of the name at a position.
+func (s *LSPServer) definition(uri string, pos lspPosition) interface{} {
+	doc := s.document(uri)
+	line, column := doc.column(pos)
+	ident := identifierAt(doc.program, line, column)
+	if ident == nil {
+		return nil
+	}
+	r := NewResolver(nil)
+	r.Resolve(doc.program)
+	def, ok := r.Definition(ident)
+	if !ok {
+		return nil
+	}
+	return lspLocation{URI: uri, Range: doc.spanRange(spanOf(def))}
+}
+
+// symbols lists the cheese bindings of a document. The bindings made inside a burrito are
+// the children of the binding holding it.
+func (s *LSPServer) symbols(uri string) []lspSymbol {
+	doc := s.document(uri)
+	return lspSymbols(doc, doc.program.Statements)
+}
+
+func lspSymbols(doc lspDocument, stmts []Statement) []lspSymbol {
+	symbols := []lspSymbol{}
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *LetStatement:
+			symbol := lspSymbol{
+				Name:           stmt.Name.Value,
+				Detail:         "cheese",
+				Kind:           lspSymbolVariable,
+				Range:          doc.spanRange(spanOf(stmt)),
+				SelectionRange: doc.spanRange(spanOf(stmt.Name)),
+			}
+			if stmt.Annotation != nil {
+				symbol.Detail = "cheese nuggets"
+			}
+			if fn, ok := stmt.Value.(*FunctionLiteral); ok {
+				params := make([]string, len(fn.Parameters))
+				for i, param := range fn.Parameters {
+					params[i] = param.Value
+				}
+				symbol.Detail = "burrito(" + strings.Join(params, ", ") + ")"
+				symbol.Kind = lspSymbolFunction
+				symbol.Children = lspSymbols(doc, fn.Body.Statements)
+			}
+			symbols = append(symbols, symbol)
+		default:
+			// Bindings in nested blocks belong to the enclosing burrito or file.
+			symbols = append(symbols, lspSymbols(doc, nestedStatements(stmt))...)
+		}
+	}
+	return symbols
+}
+
+// nestedStatements returns the statements of the blocks directly inside stmt.
+func nestedStatements(stmt Statement) []Statement {
+	var stmts []Statement
+	switch stmt := stmt.(type) {
+	case *BlockStatement:
+		stmts = stmt.Statements
+	case *IfStatement:
+		stmts = append(stmts, stmt.Consequence.Statements...)
+		if stmt.Alternative != nil {
+			stmts = append(stmts, stmt.Alternative)
+		}
+	case *WhileStatement:
+		stmts = stmt.Body.Statements
+	case *ForStatement:
+		stmts = stmt.Body.Statements
+	case *TypeSwitchStatement:
+		for _, c := range stmt.Cases {
+			stmts = append(stmts, c.Body.Statements...)
+		}
+		if stmt.Default != nil {
+			stmts = append(stmts, stmt.Default.Statements...)
+		}
+	}
+	return stmts
+}
+
+// lspKeywordCompletions offers every keyword and builtin, in alphabetical order.
+func lspKeywordCompletions() []lspCompletion {
+	var items []lspCompletion
+	for word, tok := range keywords {
+		items = append(items, lspCompletion{Label: word, Kind: lspCompletionKeyword, Detail: tokenMeanings[tok]})
+	}
+	for name := range builtins {
+		items = append(items, lspCompletion{Label: name, Kind: lspCompletionFunction, Detail: "builtin burrito"})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+// format returns the edit that formats a whole document, none if it is already
+// formatted, or null if it does not parse.
+func (s *LSPServer) format(uri string) interface{} {
+	text := s.docs[uri]
+	formatted, err := Format(text)
+	if err != nil {
+		return nil
+	}
+	edits := []lspTextEdit{}
+	if formatted != text {
+		lines := strings.Split(text, "\n")
+		end := lspPosition{Line: len(lines) - 1, Character: lspUTF16Len(lines[len(lines)-1])}
+		edits = append(edits, lspTextEdit{Range: lspRange{End: end}, NewText: formatted})
+	}
+	return edits
+}
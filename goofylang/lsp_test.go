@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClient drives an LSPServer in process, the way an editor would over stdio.
+type fakeClient struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan map[string]json.RawMessage
+	done     chan error
+	nextID   int
+}
+
+func newFakeClient(t *testing.T) *fakeClient {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	c := &fakeClient{t: t, in: clientOut, messages: make(chan map[string]json.RawMessage, 100), done: make(chan error, 1)}
+
+	go func() {
+		c.done <- NewLSPServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			body, err := readMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg map[string]json.RawMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("server sent bad JSON %q: %s", body, err)
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *fakeClient) send(msg map[string]interface{}) {
+	msg["jsonrpc"] = "2.0"
+	if err := writeMessage(c.in, msg); err != nil {
+		c.t.Fatalf("could not send %v: %s", msg, err)
+	}
+}
+
+// next returns the next message from the server.
+func (c *fakeClient) next() map[string]json.RawMessage {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("the server closed its output")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+	}
+	return nil
+}
+
+// call sends a request and decodes the result of its response into result.
+func (c *fakeClient) call(method string, params interface{}, result interface{}) *rpcError {
+	c.nextID++
+	c.send(map[string]interface{}{"id": c.nextID, "method": method, "params": params})
+	for {
+		msg := c.next()
+		if _, ok := msg["id"]; !ok {
+			continue // A notification sent before the response.
+		}
+		if raw, ok := msg["error"]; ok {
+			var rerr rpcError
+			json.Unmarshal(raw, &rerr)
+			return &rerr
+		}
+		if err := json.Unmarshal(msg["result"], result); err != nil {
+			c.t.Fatalf("%s: bad result %s: %s", method, msg["result"], err)
+		}
+		return nil
+	}
+}
+
+func (c *fakeClient) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"method": method, "params": params})
+}
+
+// diagnostics waits for the diagnostics published for uri.
+func (c *fakeClient) diagnostics(uri string) []lspDiagnostic {
+	for {
+		msg := c.next()
+		var method string
+		json.Unmarshal(msg["method"], &method)
+		if method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params struct {
+			URI         string          `json:"uri"`
+			Diagnostics []lspDiagnostic `json:"diagnostics"`
+		}
+		json.Unmarshal(msg["params"], &params)
+		if params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+}
+
+func (c *fakeClient) open(uri, text string) []lspDiagnostic {
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "goofy", "version": 1, "text": text},
+	})
+	return c.diagnostics(uri)
+}
+
+func (c *fakeClient) close() {
+	var result interface{}
+	if err := c.call("shutdown", nil, &result); err != nil {
+		c.t.Fatalf("shutdown failed: %v", err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("Serve returned an error: %s", err)
+	}
+}
+
+func position(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": character},
+	}
+}
+
+func TestLSPInitialize(t *testing.T) {
+	c := newFakeClient(t)
+	var result struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	if err := c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	for _, capability := range []string{"hoverProvider", "definitionProvider", "documentSymbolProvider", "completionProvider", "documentFormattingProvider"} {
+		if _, ok := result.Capabilities[capability]; !ok {
+			t.Errorf("capability %s is missing", capability)
+		}
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	var ignored interface{}
+	if err := c.call("workspace/symbol", map[string]interface{}{}, &ignored); err == nil || err.Code != rpcMethodNotFound {
+		t.Errorf("expected method not found. got=%v", err)
+	}
+	c.close()
+}
+
+func TestLSPDiagnostics(t *testing.T) {
+	c := newFakeClient(t)
+	uri := "file:///broken.goofy"
+
+	diagnostics := c.open(uri, "cheese x = 1;\ncheese = 2;\n")
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected diagnostics for a parse error")
+	}
+	if d := diagnostics[0]; d.Range.Start.Line != 1 || d.Severity != lspSeverityError || d.Source != "goofy" {
+		t.Errorf("wrong diagnostic: %+v", d)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": "pizza y;\n"}},
+	})
+	diagnostics = c.diagnostics(uri)
+	if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "y") {
+		t.Fatalf("expected one undefined name diagnostic. got=%+v", diagnostics)
+	}
+	if r := diagnostics[0].Range; r.Start != (lspPosition{0, 6}) || r.End != (lspPosition{0, 7}) {
+		t.Errorf("wrong range: %+v", r)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 3},
+		"contentChanges": []map[string]string{{"text": "pizza 1;\n"}},
+	})
+	if diagnostics := c.diagnostics(uri); len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics. got=%+v", diagnostics)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 4},
+		"contentChanges": []map[string]string{{"text": "cheese x = 99999999999999999999;\n"}},
+	})
+	diagnostics = c.diagnostics(uri)
+	if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "does not fit") {
+		t.Fatalf("expected a diagnostic for an integer that overflows. got=%+v", diagnostics)
+	}
+	if r := diagnostics[0].Range; r.Start != (lspPosition{0, 11}) || r.End != (lspPosition{0, 31}) {
+		t.Errorf("wrong range: %+v", r)
+	}
+
+	c.notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]string{"uri": uri}})
+	if diagnostics := c.diagnostics(uri); len(diagnostics) != 0 {
+		t.Errorf("expected closing to clear diagnostics. got=%+v", diagnostics)
+	}
+	c.close()
+}
+
+const lspSource = `// count says how many.
+cheese count = 3;
+cheese twice = burrito(n) {
+    cheese doubled = n pancakes 2;
+    takeout doubled;
+};
+waffles (count > 1) {
+    cheese inner = twice(count);
+}
+pizza count apple 1;
+`
+
+func TestLSPHover(t *testing.T) {
+	c := newFakeClient(t)
+	uri := "file:///hover.goofy"
+	c.open(uri, lspSource)
+
+	tests := []struct {
+		line, character int
+		expected        []string
+	}{
+		{9, 12, []string{"`apple`", "addition (`+`)"}},
+		{9, 0, []string{"`pizza`", "print a value"}},
+		{9, 8, []string{"`count`", "declared at 2:8", "count: nuggets", "count says how many."}},
+		{3, 21, []string{"`n`", "declared at 3:24"}},
+		{7, 20, []string{"`twice`", "twice: burrito(nuggets) -> nuggets"}},
+	}
+	for _, tt := range tests {
+		var hover *lspHover
+		if err := c.call("textDocument/hover", position(uri, tt.line, tt.character), &hover); err != nil {
+			t.Fatalf("hover failed: %v", err)
+		}
+		if hover == nil {
+			t.Errorf("%d:%d: expected a hover", tt.line, tt.character)
+			continue
+		}
+		for _, want := range tt.expected {
+			if !strings.Contains(hover.Contents.Value, want) {
+				t.Errorf("%d:%d: hover %q does not contain %q", tt.line, tt.character, hover.Contents.Value, want)
+			}
+		}
+	}
+
+	var hover *lspHover
+	if err := c.call("textDocument/hover", position(uri, 4, 0), &hover); err != nil || hover != nil {
+		t.Errorf("expected no hover on whitespace. got=%+v, %v", hover, err)
+	}
+	c.close()
+}
+
+func TestLSPDefinition(t *testing.T) {
+	c := newFakeClient(t)
+	uri := "file:///definition.goofy"
+	c.open(uri, lspSource)
+
+	tests := []struct {
+		line, character int
+		expected        lspPosition
+	}{
+		{9, 8, lspPosition{1, 7}},
+		{4, 14, lspPosition{3, 11}},
+		{3, 21, lspPosition{2, 23}},
+		{7, 26, lspPosition{1, 7}},
+	}
+	for _, tt := range tests {
+		var location *lspLocation
+		if err := c.call("textDocument/definition", position(uri, tt.line, tt.character), &location); err != nil {
+			t.Fatalf("definition failed: %v", err)
+		}
+		if location == nil || location.URI != uri || location.Range.Start != tt.expected {
+			t.Errorf("%d:%d: expected a definition at %+v. got=%+v", tt.line, tt.character, tt.expected, location)
+		}
+	}
+
+	var location *lspLocation
+	if err := c.call("textDocument/definition", position(uri, 9, 0), &location); err != nil || location != nil {
+		t.Errorf("expected no definition for a keyword. got=%+v, %v", location, err)
+	}
+	c.close()
+}
+
+func TestLSPPositionsCountUTF16(t *testing.T) {
+	// é takes two bytes and one UTF-16 unit, 😀 four bytes and two units.
+	c := newFakeClient(t)
+	uri := "file:///utf16.goofy"
+	diagnostics := c.open(uri, "cheese s = \"é😀\"; cheese t = s; s();")
+
+	call := lspRange{Start: lspPosition{0, 32}, End: lspPosition{0, 35}}
+	if len(diagnostics) != 1 || diagnostics[0].Range != call {
+		t.Errorf("expected one diagnostic at %+v. got=%+v", call, diagnostics)
+	}
+
+	var hover *lspHover
+	if err := c.call("textDocument/hover", position(uri, 0, 29), &hover); err != nil {
+		t.Fatalf("hover failed: %v", err)
+	}
+	use := lspRange{Start: lspPosition{0, 29}, End: lspPosition{0, 30}}
+	if hover == nil || hover.Range != use || !strings.Contains(hover.Contents.Value, "declared at 1:8") {
+		t.Errorf("wrong hover for s at %+v. got=%+v", use, hover)
+	}
+
+	var location *lspLocation
+	if err := c.call("textDocument/definition", position(uri, 0, 29), &location); err != nil {
+		t.Fatalf("definition failed: %v", err)
+	}
+	if location == nil || location.Range.Start != (lspPosition{0, 7}) {
+		t.Errorf("expected a definition at 0:7. got=%+v", location)
+	}
+	c.close()
+}
+
+func TestLSPDocumentSymbols(t *testing.T) {
+	c := newFakeClient(t)
+	uri := "file:///symbols.goofy"
+	c.open(uri, lspSource)
+
+	var symbols []lspSymbol
+	if err := c.call("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": uri}}, &symbols); err != nil {
+		t.Fatalf("documentSymbol failed: %v", err)
+	}
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, " ") != "count twice inner" {
+		t.Fatalf("wrong symbols: %v", names)
+	}
+	twice := symbols[1]
+	if twice.Kind != lspSymbolFunction || twice.Detail != "burrito(n)" || len(twice.Children) != 1 || twice.Children[0].Name != "doubled" {
+		t.Errorf("wrong burrito symbol: %+v", twice)
+	}
+	if twice.Range.Start.Line != 2 || twice.Range.End.Line != 5 || twice.SelectionRange.Start != (lspPosition{2, 7}) {
+		t.Errorf("wrong burrito ranges: %+v", twice)
+	}
+	if symbols[0].Kind != lspSymbolVariable {
+		t.Errorf("expected count to be a variable. got=%d", symbols[0].Kind)
+	}
+	c.close()
+}
+
+func TestLSPCompletion(t *testing.T) {
+	c := newFakeClient(t)
+	var items []lspCompletion
+	if err := c.call("textDocument/completion", position("file:///empty.goofy", 0, 0), &items); err != nil {
+		t.Fatalf("completion failed: %v", err)
+	}
+	labels := map[string]int{}
+	for _, item := range items {
+		labels[item.Label] = item.Kind
+	}
+	for word := range keywords {
+		if labels[word] != lspCompletionKeyword {
+			t.Errorf("keyword %s is not offered", word)
+		}
+	}
+	if labels["len"] != lspCompletionFunction {
+		t.Errorf("builtin len is not offered")
+	}
+	c.close()
+}
+
+func TestLSPFormatting(t *testing.T) {
+	c := newFakeClient(t)
+	uri := "file:///format.goofy"
+	c.open(uri, "cheese   x=1;\npizza x;")
+
+	formatting := map[string]interface{}{"textDocument": map[string]string{"uri": uri}, "options": map[string]interface{}{"tabSize": 4}}
+	var edits []lspTextEdit
+	if err := c.call("textDocument/formatting", formatting, &edits); err != nil {
+		t.Fatalf("formatting failed: %v", err)
+	}
+	if len(edits) != 1 || edits[0].NewText != "cheese x = 1;\npizza x;\n" {
+		t.Fatalf("wrong edits: %+v", edits)
+	}
+	if r := edits[0].Range; r.Start != (lspPosition{0, 0}) || r.End != (lspPosition{1, 8}) {
+		t.Errorf("the edit does not cover the document: %+v", r)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri},
+		"contentChanges": []map[string]string{{"text": "cheese = ;"}},
+	})
+	c.diagnostics(uri)
+	edits = nil
+	if err := c.call("textDocument/formatting", formatting, &edits); err != nil || edits != nil {
+		t.Errorf("expected no edits for a document that does not parse. got=%+v, %v", edits, err)
+	}
+	c.close()
+}
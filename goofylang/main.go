@@ -10,6 +10,12 @@ type TokenType int
 
 // Pizza for printing, Cheese for var declaration, Tacos for identifying, Nuggets for number
 // Enchilada for equal, Apple for add, Salmon for subtract, Icaco for inputs
+// Pancakes for multiply (they stack), Pie for divide (it gets sliced), Leftovers for remainder
+// Cake for true (everyone wants it), Broccoli for false (nobody does)
+// Waffles for if (waffling over a choice), Fries for else (the side you get instead)
+// Noodles for while (they go on and on), Donuts for counted loops (round, like a loop)
+// Dessert for break (the meal is over), Seconds for continue (back for another round)
+// Burrito for functions (everything wrapped up to go), Takeout for return (what you leave with)
 
 const (
 	TOKEN_IDENT TokenType = iota
@@ -25,6 +31,41 @@ const (
 	TOKEN_APPLE
 	TOKEN_SALMON
 	TOKEN_ICACO
+	TOKEN_APPLE_ENCHILADA     // += or apple=
+	TOKEN_SALMON_ENCHILADA    // -= or salmon=
+	TOKEN_LPAREN              // (
+	TOKEN_RPAREN              // )
+	TOKEN_LBRACE              // {
+	TOKEN_RBRACE              // }
+	TOKEN_LBRACKET            // [
+	TOKEN_RBRACKET            // ]
+	TOKEN_COMMA               // ,
+	TOKEN_PANCAKES            // * or pancakes
+	TOKEN_PIE                 // / or pie
+	TOKEN_LEFTOVERS           // % or leftovers
+	TOKEN_PANCAKES_ENCHILADA  // *= or pancakes=
+	TOKEN_PIE_ENCHILADA       // /= or pie=
+	TOKEN_LEFTOVERS_ENCHILADA // %= or leftovers=
+	TOKEN_EQ                  // ==
+	TOKEN_NOT_EQ              // !=
+	TOKEN_LT                  // <
+	TOKEN_GT                  // >
+	TOKEN_LT_EQ               // <=
+	TOKEN_GT_EQ               // >=
+	TOKEN_BANG                // !
+	TOKEN_CAKE                // cake (true)
+	TOKEN_BROCCOLI            // broccoli (false)
+	TOKEN_WAFFLES             // waffles (if)
+	TOKEN_FRIES               // fries (else)
+	TOKEN_NOODLES             // noodles (while)
+	TOKEN_DONUTS              // donuts (counted for)
+	TOKEN_DESSERT             // dessert (break)
+	TOKEN_SECONDS             // seconds (continue)
+	TOKEN_BURRITO             // burrito (function)
+	TOKEN_TAKEOUT             // takeout (return)
+	TOKEN_STRING              // "double quoted", with the escapes already decoded in the literal
+	TOKEN_COLON               // : between the key and value of a hash entry
+	TOKEN_COMMENT             // // to the end of the line, or /* to */; kept aside by the lexer, never seen by the parser
 )
 
 const (
@@ -36,27 +77,42 @@ const (
     PRODUCT     // *
     PREFIX      // -X or !X
     CALL        // myFunction(X)
+    INDEX       // array[X]
 )
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type      TokenType
+	Literal   string
+	Line      int // 1-based line of the first character, or 0 for tokens not read from source
+	Column    int // 1-based column of the first character, counted in bytes
+	EndLine   int // Line and column just past the last character; only comments span lines
+	EndColumn int
 }
 
 type Lexer struct {
 	input        string
-	position     int  // current position in input
-	readPosition int  // current reading position in input
-	ch           byte // current char under examination
+	position     int     // current position in input
+	readPosition int     // current reading position in input
+	ch           byte    // current char under examination
+	line         int     // line of ch
+	column       int     // column of ch
+	comments     []Token // comments skipped so far
 }
 
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	// Move the line and column past the character we are leaving behind
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
 	// Check if we reached the end of the input
 	if l.readPosition >= len(l.input) {
 		// ASCII code for "NUL" character, signifies end of input
@@ -76,21 +132,54 @@ func (l *Lexer) NextToken() Token {
 	var tok Token
 
 	// Skip any whitespace characters to reach the start of the next token.
-	l.skipWhitespace()
+	if illegal, ok := l.skipWhitespace(); ok {
+		return illegal
+	}
+	line, column, start := l.line, l.column, l.position
 
 	// Switch statement to handle different characters.
 	switch l.ch {
 	case '=':
-		// If the current character is '=', create an ENCHILADA token.
-		tok = newToken(TOKEN_ENCHILADA, l.ch)
+		// If the current character is '=', create an ENCHILADA token, or an EQ token for "==".
+		tok = l.readComparison(TOKEN_ENCHILADA, TOKEN_EQ)
+	case '!':
+		tok = l.readComparison(TOKEN_BANG, TOKEN_NOT_EQ)
+	case '<':
+		tok = l.readComparison(TOKEN_LT, TOKEN_LT_EQ)
+	case '>':
+		tok = l.readComparison(TOKEN_GT, TOKEN_GT_EQ)
 	case '+':
-		// If it's '+', create an APPLE token.
-		tok = newToken(TOKEN_APPLE, l.ch)
+		// If it's '+', create an APPLE token, or an APPLE_ENCHILADA token for "+=".
+		tok = l.readOperator(TOKEN_APPLE)
 	case '-':
-		// If it's '-', create a SALMON token.
-		tok = newToken(TOKEN_SALMON, l.ch)
+		// If it's '-', create a SALMON token, or a SALMON_ENCHILADA token for "-=".
+		tok = l.readOperator(TOKEN_SALMON)
+	case '*':
+		tok = l.readOperator(TOKEN_PANCAKES)
+	case '/':
+		tok = l.readOperator(TOKEN_PIE)
+	case '%':
+		tok = l.readOperator(TOKEN_LEFTOVERS)
     case ';':
         tok = newToken(TOKEN_SEMICOLON, l.ch)
+	case '(':
+		tok = newToken(TOKEN_LPAREN, l.ch)
+	case ')':
+		tok = newToken(TOKEN_RPAREN, l.ch)
+	case '{':
+		tok = newToken(TOKEN_LBRACE, l.ch)
+	case '}':
+		tok = newToken(TOKEN_RBRACE, l.ch)
+	case '[':
+		tok = newToken(TOKEN_LBRACKET, l.ch)
+	case ']':
+		tok = newToken(TOKEN_RBRACKET, l.ch)
+	case ',':
+		tok = newToken(TOKEN_COMMA, l.ch)
+	case ':':
+		tok = newToken(TOKEN_COLON, l.ch)
+	case '"':
+		tok = l.readString()
 	case 0:
 		// If it's the end of the input (0), create an EOF (End Of File) token.
 		tok.Literal = ""
@@ -101,11 +190,21 @@ func (l *Lexer) NextToken() Token {
 			// If it's a letter, read the full identifier and check if it's a keyword.
 			tok.Literal = l.readIdentifier()
 			tok.Type = LookupIdent(tok.Literal)
+			// "apple=", "salmon=" and friends are the spelled out forms of "+=", "-=" and so on.
+			if compound, ok := compoundAssignments[tok.Type]; ok && l.ch == '=' {
+				l.readChar()
+				tok.Literal += "="
+				tok.Type = compound
+			}
+			tok.Line, tok.Column = line, column
+			tok.EndLine, tok.EndColumn = line, column+l.position-start
 			return tok
 		} else if isDigit(l.ch) {
 			// If it's a digit, read the full number.
 			tok.Literal = l.readNumber()
 			tok.Type = TOKEN_INT
+			tok.Line, tok.Column = line, column
+			tok.EndLine, tok.EndColumn = line, column+l.position-start
 			return tok
 		} else {
 			// If it's an unknown character, create an ILLEGAL token.
@@ -114,15 +213,174 @@ func (l *Lexer) NextToken() Token {
 	}
 
 	// Read the next character for the next call to NextToken.
+	end := l.position
 	l.readChar()
+	tok.Line, tok.Column = line, column
+	tok.EndLine, tok.EndColumn = line, column+end-start
+	if tok.Type != TOKEN_EOF {
+		tok.EndColumn++
+	}
 	return tok
 }
 
-// skipWhitespace advances the lexer's position past any whitespace.
-func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+// readOperator creates a token for a single character arithmetic operator, or for its
+// compound assignment form when the operator is immediately followed by '='.
+func (l *Lexer) readOperator(operator TokenType) Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return Token{Type: compoundAssignments[operator], Literal: string(ch) + "="}
+	}
+	return newToken(operator, l.ch)
+}
+
+// readComparison looks one character ahead: if the current character is followed by '='
+// both are consumed as a withEquals token, otherwise the character alone becomes a single token.
+func (l *Lexer) readComparison(single, withEquals TokenType) Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return Token{Type: withEquals, Literal: string(ch) + "="}
+	}
+	return newToken(single, l.ch)
+}
+
+// readString reads a double quoted string literal, leaving the lexer on its closing quote.
+// The token's literal is the decoded contents. A string that is not closed before the end
+// of its line, or that uses an unknown escape, becomes an ILLEGAL token holding the source.
+func (l *Lexer) readString() Token {
+	start := l.position
+	var out strings.Builder
+	bad := false
+
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			if bad {
+				return Token{Type: TOKEN_ILLEGAL, Literal: l.input[start : l.position+1]}
+			}
+			return Token{Type: TOKEN_STRING, Literal: out.String()}
+		case 0, '\n':
+			return Token{Type: TOKEN_ILLEGAL, Literal: l.input[start:l.position]}
+		case '\\':
+			if decoded, ok := stringEscapes[l.peekChar()]; ok {
+				l.readChar()
+				out.WriteByte(decoded)
+			} else {
+				// Keep going to the closing quote so lexing resumes after the string.
+				bad = true
+			}
+		default:
+			out.WriteByte(l.ch)
+		}
+	}
+}
+
+// stringEscapes maps the character after a backslash in a string literal to the byte it stands for.
+var stringEscapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+}
+
+// quoteString writes s as a string literal that reads back as s.
+func quoteString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// compoundAssignments maps each arithmetic operator to the token of its compound assignment form.
+var compoundAssignments = map[TokenType]TokenType{
+	TOKEN_APPLE:     TOKEN_APPLE_ENCHILADA,
+	TOKEN_SALMON:    TOKEN_SALMON_ENCHILADA,
+	TOKEN_PANCAKES:  TOKEN_PANCAKES_ENCHILADA,
+	TOKEN_PIE:       TOKEN_PIE_ENCHILADA,
+	TOKEN_LEFTOVERS: TOKEN_LEFTOVERS_ENCHILADA,
+}
+
+// peekChar returns the next character without consuming the current one.
+func (l *Lexer) peekChar() byte {
+	if l.readPosition >= len(l.input) {
+		return 0
+	}
+	return l.input[l.readPosition]
+}
+
+// skipWhitespace advances the lexer's position past any whitespace and comments. A block
+// comment that is never closed runs to the end of the input, and is returned as an
+// ILLEGAL token for NextToken to return.
+func (l *Lexer) skipWhitespace() (Token, bool) {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*'):
+			if comment := l.readComment(); comment.Type == TOKEN_ILLEGAL {
+				return comment, true
+			}
+		default:
+			return Token{}, false
+		}
+	}
+}
+
+// readComment reads a comment: a line comment runs from "//" to the end of the line, and
+// a block comment from "/*" to the next "*/", possibly over several lines. Comments are
+// not tokens the parser sees, but the lexer keeps them as trivia for tools such as the
+// formatter and the linter. A block comment that reaches the end of the input without
+// a "*/" is not kept, and is returned as an ILLEGAL token instead.
+func (l *Lexer) readComment() Token {
+	line, column, start := l.line, l.column, l.position
+	tok := Token{Type: TOKEN_COMMENT, Line: line, Column: column}
+	if l.peekChar() == '*' {
 		l.readChar()
+		l.readChar()
+		for !(l.ch == '*' && l.peekChar() == '/') && l.ch != 0 {
+			l.readChar()
+		}
+		if l.ch == 0 {
+			tok.Type = TOKEN_ILLEGAL
+		} else {
+			l.readChar()
+			l.readChar()
+		}
+	} else {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
 	}
+	tok.Literal = l.input[start:l.position]
+	tok.EndLine, tok.EndColumn = l.line, l.column
+	if tok.Type == TOKEN_COMMENT {
+		l.comments = append(l.comments, tok)
+	}
+	return tok
+}
+
+// Comments returns the comments read so far, in the order they appear.
+func (l *Lexer) Comments() []Token {
+	return l.comments
 }
 
 // newToken creates a new Token of the given TokenType and literal character.
@@ -160,20 +418,33 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// keywords maps every keyword to its token type.
+var keywords = map[string]TokenType{
+	"pizza":     TOKEN_PIZZA,
+	"cheese":    TOKEN_CHEESE,
+	"apple":     TOKEN_APPLE,
+	"enchilada": TOKEN_ENCHILADA,
+	"icaco":     TOKEN_ICACO,
+	"nuggets":   TOKEN_NUGGETS,
+	"salmon":    TOKEN_SALMON,
+	"tacos":     TOKEN_TACOS,
+	"pancakes":  TOKEN_PANCAKES,
+	"pie":       TOKEN_PIE,
+	"leftovers": TOKEN_LEFTOVERS,
+	"cake":      TOKEN_CAKE,
+	"broccoli":  TOKEN_BROCCOLI,
+	"waffles":   TOKEN_WAFFLES,
+	"fries":     TOKEN_FRIES,
+	"noodles":   TOKEN_NOODLES,
+	"donuts":    TOKEN_DONUTS,
+	"dessert":   TOKEN_DESSERT,
+	"seconds":   TOKEN_SECONDS,
+	"burrito":   TOKEN_BURRITO,
+	"takeout":   TOKEN_TAKEOUT,
+}
+
 // LookupIdent checks if an identifier is a keyword or just a regular identifier.
 func LookupIdent(ident string) TokenType {
-	keywords := map[string]TokenType{
-		// Mapping of keyword strings to their respective token types.
-		"pizza":     TOKEN_PIZZA,
-		"cheese":    TOKEN_CHEESE,
-		"apple":     TOKEN_APPLE,
-		"enchilada": TOKEN_ENCHILADA,
-		"icaco":     TOKEN_ICACO,
-		"nuggets":   TOKEN_NUGGETS,
-		"salmon":    TOKEN_SALMON,
-		"tacos":     TOKEN_TACOS,
-	}
-
 	if tok, ok := keywords[ident]; ok {
 		// If the identifier is a keyword, return the corresponding token type.
 		return tok
@@ -226,11 +497,12 @@ type Expression interface {
     expressionNode() // A marker method to differentiate other nodes from expression nodes.
 }
 
-// LetStatement represents a variable declaration (e.g., "cheese x = 5;").
+// LetStatement represents a variable declaration (e.g., "cheese x = 5;" or "cheese nuggets x = 5;").
 type LetStatement struct {
-    Token Token     // The first token of the statement (TOKEN_CHEESE in this case).
-    Name  *Identifier // The variable name being declared.
-    Value Expression // The expression assigned to the variable.
+    Token      Token       // The first token of the statement (TOKEN_CHEESE in this case).
+    Annotation *Token      // The TOKEN_NUGGETS of a typed declaration, or nil.
+    Name       *Identifier // The variable name being declared.
+    Value      Expression  // The expression assigned to the variable.
 }
 
 func (ls *LetStatement) statementNode() {}
@@ -239,10 +511,21 @@ func (ls *LetStatement) TokenLiteral() string {
     return ls.Token.Literal
 }
 
+// AnnotatedType returns the type a typed declaration requires the variable to hold.
+func (ls *LetStatement) AnnotatedType() (ObjectType, bool) {
+    if ls.Annotation == nil {
+        return "", false
+    }
+    return INTEGER_OBJ, true
+}
+
 // Identifier represents a variable name in the AST.
 type Identifier struct {
-    Token Token  // The token (TOKEN_IDENT) associated with the identifier.
-    Value string // The name of the identifier.
+    Token    Token  // The token (TOKEN_IDENT) associated with the identifier.
+    Value    string // The name of the identifier.
+    Resolved bool   // Whether the resolver found the binding Depth and Slot point to.
+    Depth    int    // How many scopes out from where the identifier appears its binding is.
+    Slot     int    // The slot of the binding in that scope, see Environment.
 }
 
 func (i *Identifier) expressionNode() {}
@@ -255,6 +538,9 @@ func (i *Identifier) TokenLiteral() string {
 func (ls *LetStatement) String() string {
     var out strings.Builder
     out.WriteString(ls.TokenLiteral() + " ")
+    if ls.Annotation != nil {
+        out.WriteString(ls.Annotation.Literal + " ")
+    }
     out.WriteString(ls.Name.String())
     out.WriteString(" = ")
 
@@ -277,6 +563,9 @@ type Parser struct {
     curToken  Token
     peekToken Token
     errors    []string // A slice of errors encountered during parsing.
+    spans     []Span   // The span of the token each error is about.
+    loops     int      // How many loops enclose the current token, so dessert and seconds can be checked.
+    functions int      // How many burritos enclose the current token, so takeout can be checked.
 }
 
 // NewParser creates a new Parser instance using a Lexer.
@@ -320,9 +609,34 @@ func (p *Parser) parseStatement() Statement {
     switch p.curToken.Type {
     case TOKEN_CHEESE:
         return p.parseLetStatement()
+    case TOKEN_PIZZA:
+        return p.parsePrintStatement()
+    case TOKEN_IDENT:
+        if p.peekTokenIs(TOKEN_ENCHILADA) || isCompoundAssignment(p.peekToken.Type) {
+            return p.parseAssignStatement()
+        }
+        return p.parseExpressionStatement()
+    case TOKEN_WAFFLES:
+        return p.parseIfStatement()
+    case TOKEN_LBRACE:
+        // A brace opening a statement is always a block; a hash there needs parentheses.
+        return p.parseBlockStatement()
+    case TOKEN_NOODLES:
+        return p.parseWhileStatement()
+    case TOKEN_DONUTS:
+        return p.parseForStatement()
+    case TOKEN_DESSERT, TOKEN_SECONDS:
+        return p.parseLoopControlStatement()
+    case TOKEN_TAKEOUT:
+        return p.parseReturnStatement()
+    case TOKEN_TACOS:
+        return p.parseTacosStatement()
+    case TOKEN_SEMICOLON:
+        // An empty statement.
+        return nil
     // Add more cases for other types of statements.
     default:
-        return nil
+        return p.parseExpressionStatement()
     }
 }
 
@@ -330,6 +644,12 @@ func (p *Parser) parseStatement() Statement {
 func (p *Parser) parseLetStatement() *LetStatement {
     stmt := &LetStatement{Token: p.curToken}
 
+    if p.peekTokenIs(TOKEN_NUGGETS) {
+        p.nextToken()
+        annotation := p.curToken
+        stmt.Annotation = &annotation
+    }
+
     if !p.expectPeek(TOKEN_IDENT) {
         return nil
     }
@@ -345,13 +665,274 @@ func (p *Parser) parseLetStatement() *LetStatement {
     stmt.Value = p.parseExpression(LOWEST)
 
     // Skipping to the end of the statement (semicolon).
-    for !p.curTokenIs(TOKEN_SEMICOLON) {
+    for !p.curTokenIs(TOKEN_SEMICOLON) && !p.curTokenIs(TOKEN_EOF) {
+        p.nextToken()
+    }
+
+    return stmt
+}
+
+// parseAssignStatement parses an assignment to an existing variable (e.g., "x = 5;" or "x apple= 1;").
+func (p *Parser) parseAssignStatement() *AssignStatement {
+    stmt := &AssignStatement{Name: &Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+
+    p.nextToken()
+    stmt.Token = p.curToken
+
+    p.nextToken()
+    stmt.Value = p.parseExpression(LOWEST)
+
+    for !p.curTokenIs(TOKEN_SEMICOLON) && !p.curTokenIs(TOKEN_EOF) {
+        p.nextToken()
+    }
+
+    return stmt
+}
+
+// parseExpressionStatement parses an expression used on its own as a statement (e.g., "x + 1;").
+func (p *Parser) parseExpressionStatement() *ExpressionStatement {
+    stmt := &ExpressionStatement{Token: p.curToken}
+
+    stmt.Expression = p.parseExpression(LOWEST)
+
+    for !p.curTokenIs(TOKEN_SEMICOLON) && !p.curTokenIs(TOKEN_EOF) {
+        p.nextToken()
+    }
+
+    return stmt
+}
+
+// parsePrintStatement parses a print statement (e.g., "pizza x;").
+func (p *Parser) parsePrintStatement() *PrintStatement {
+    stmt := &PrintStatement{Token: p.curToken}
+
+    p.nextToken()
+    stmt.Value = p.parseExpression(LOWEST)
+
+    for !p.curTokenIs(TOKEN_SEMICOLON) && !p.curTokenIs(TOKEN_EOF) {
+        p.nextToken()
+    }
+
+    return stmt
+}
+
+// parseIfStatement parses a conditional (e.g., "waffles x > 1 { pizza x; } fries { pizza 0; }").
+// "fries waffles" chains another conditional onto the else branch.
+func (p *Parser) parseIfStatement() *IfStatement {
+    stmt := &IfStatement{Token: p.curToken}
+
+    p.nextToken()
+    stmt.Condition = p.parseExpression(LOWEST)
+
+    if !p.expectPeek(TOKEN_LBRACE) {
+        return nil
+    }
+    stmt.Consequence = p.parseBlockStatement()
+
+    if !p.peekTokenIs(TOKEN_FRIES) {
+        return stmt
+    }
+    p.nextToken()
+
+    if p.peekTokenIs(TOKEN_WAFFLES) {
+        p.nextToken()
+        nested := p.parseIfStatement()
+        if nested == nil {
+            return nil
+        }
+        stmt.Alternative = nested
+        return stmt
+    }
+
+    if !p.expectPeek(TOKEN_LBRACE) {
+        return nil
+    }
+    stmt.Alternative = p.parseBlockStatement()
+
+    return stmt
+}
+
+// parseWhileStatement parses a loop that runs while its condition holds (e.g., "noodles x > 0 { x -= 1; }").
+func (p *Parser) parseWhileStatement() *WhileStatement {
+    stmt := &WhileStatement{Token: p.curToken}
+
+    p.nextToken()
+    stmt.Condition = p.parseExpression(LOWEST)
+
+    if !p.expectPeek(TOKEN_LBRACE) {
+        return nil
+    }
+    stmt.Body = p.parseLoopBody()
+
+    return stmt
+}
+
+// parseForStatement parses a counted loop (e.g., "donuts i = 0, 10 { pizza i; }").
+func (p *Parser) parseForStatement() *ForStatement {
+    stmt := &ForStatement{Token: p.curToken}
+
+    if !p.expectPeek(TOKEN_IDENT) {
+        return nil
+    }
+    stmt.Variable = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+    if !p.expectPeek(TOKEN_ENCHILADA) {
+        return nil
+    }
+    p.nextToken()
+    stmt.Start = p.parseExpression(LOWEST)
+
+    if !p.expectPeek(TOKEN_COMMA) {
+        return nil
+    }
+    p.nextToken()
+    stmt.End = p.parseExpression(LOWEST)
+
+    if !p.expectPeek(TOKEN_LBRACE) {
+        return nil
+    }
+    stmt.Body = p.parseLoopBody()
+
+    return stmt
+}
+
+// parseLoopBody parses the block of a loop, where dessert and seconds are allowed.
+func (p *Parser) parseLoopBody() *BlockStatement {
+    p.loops++
+    defer func() { p.loops-- }()
+    return p.parseBlockStatement()
+}
+
+// parseLoopControlStatement parses dessert or seconds, with or without a semicolon.
+func (p *Parser) parseLoopControlStatement() *LoopControlStatement {
+    stmt := &LoopControlStatement{Token: p.curToken}
+
+    if p.loops == 0 {
+        msg := fmt.Sprintf("%s outside of a loop", p.curToken.Literal)
+        p.errorAt(p.curToken, msg)
+    }
+    if p.peekTokenIs(TOKEN_SEMICOLON) {
+        p.nextToken()
+    }
+
+    return stmt
+}
+
+// parseReturnStatement parses takeout, with or without a value (e.g., "takeout x + 1;").
+func (p *Parser) parseReturnStatement() *ReturnStatement {
+    stmt := &ReturnStatement{Token: p.curToken}
+
+    if p.functions == 0 {
+        msg := fmt.Sprintf("%s outside of a burrito", p.curToken.Literal)
+        p.errorAt(p.curToken, msg)
+    }
+    if p.peekTokenIs(TOKEN_SEMICOLON) || p.peekTokenIs(TOKEN_RBRACE) {
+        if p.peekTokenIs(TOKEN_SEMICOLON) {
+            p.nextToken()
+        }
+        return stmt
+    }
+
+    p.nextToken()
+    stmt.Value = p.parseExpression(LOWEST)
+
+    if p.peekTokenIs(TOKEN_SEMICOLON) {
+        p.nextToken()
+    }
+
+    return stmt
+}
+
+// parseTacosStatement parses a statement starting with tacos. Followed by a brace it is a
+// type switch (e.g., "tacos x { nuggets { pizza x + 1; } string, array { pizza len(x); } fries { } }");
+// otherwise it is an ordinary expression statement such as "tacos x == "nuggets";".
+func (p *Parser) parseTacosStatement() Statement {
+    tok := p.curToken
+    exp := p.parseExpression(LOWEST)
+
+    typeof, ok := exp.(*TypeofExpression)
+    if !ok || !p.peekTokenIs(TOKEN_LBRACE) {
+        for !p.curTokenIs(TOKEN_SEMICOLON) && !p.curTokenIs(TOKEN_EOF) {
+            p.nextToken()
+        }
+        return &ExpressionStatement{Token: tok, Expression: exp}
+    }
+
+    stmt := &TypeSwitchStatement{Token: tok, Subject: typeof.Right}
+    p.nextToken()
+    seen := map[string]bool{}
+
+    for !p.peekTokenIs(TOKEN_RBRACE) {
         p.nextToken()
+
+        if p.curTokenIs(TOKEN_FRIES) {
+            if stmt.Default != nil {
+                p.errorAt(p.curToken, "tacos has more than one fries case")
+            }
+            if !p.expectPeek(TOKEN_LBRACE) {
+                return nil
+            }
+            stmt.Default = p.parseBlockStatement()
+            continue
+        }
+
+        typeCase := &TypeCase{Token: p.curToken}
+        for {
+            if !p.curTokenIs(TOKEN_IDENT) && !p.curTokenIs(TOKEN_NUGGETS) {
+                msg := fmt.Sprintf("expected a type name in tacos, got %s instead", p.curToken.Type.String())
+                p.errorAt(p.curToken, msg)
+                return nil
+            }
+            name := p.curToken.Literal
+            if !isTypeName(name) {
+                p.errorAt(p.curToken, fmt.Sprintf("unknown type name in tacos: %s", name))
+            } else if seen[name] {
+                p.errorAt(p.curToken, fmt.Sprintf("duplicate tacos case: %s", name))
+            }
+            seen[name] = true
+            typeCase.Types = append(typeCase.Types, name)
+
+            if !p.peekTokenIs(TOKEN_COMMA) {
+                break
+            }
+            p.nextToken()
+            p.nextToken()
+        }
+
+        if !p.expectPeek(TOKEN_LBRACE) {
+            return nil
+        }
+        typeCase.Body = p.parseBlockStatement()
+        stmt.Cases = append(stmt.Cases, typeCase)
     }
+    p.nextToken()
+    stmt.Rbrace = p.curToken
 
     return stmt
 }
 
+// parseBlockStatement parses the statements between a LBRACE and its RBRACE.
+func (p *Parser) parseBlockStatement() *BlockStatement {
+    block := &BlockStatement{Token: p.curToken, Statements: []Statement{}}
+
+    p.nextToken()
+
+    for !p.curTokenIs(TOKEN_RBRACE) {
+        if p.curTokenIs(TOKEN_EOF) {
+            p.errorAt(p.curToken, "expected } to close the block, got TOKEN_EOF instead")
+            return block
+        }
+        stmt := p.parseStatement()
+        if stmt != nil {
+            block.Statements = append(block.Statements, stmt)
+        }
+        p.nextToken()
+    }
+    block.Rbrace = p.curToken
+
+    return block
+}
+
 // curTokenIs checks if the current token is of a given type.
 func (p *Parser) curTokenIs(t TokenType) bool {
     return p.curToken.Type == t
@@ -365,7 +946,7 @@ func (p *Parser) peekTokenIs(t TokenType) bool {
 // peekError appends an error message when the next token is not of the expected type.
 func (p *Parser) peekError(t TokenType) {
     msg := fmt.Sprintf("expected next token to be %s, got %s instead", t.String(), p.peekToken.Type.String())
-    p.errors = append(p.errors, msg)
+    p.errorAt(p.peekToken, msg)
 }
 
 // expectPeek checks if the next token is of the expected type and advances the tokens if true.
@@ -379,11 +960,64 @@ func (p *Parser) expectPeek(t TokenType) bool {
     }
 }
 
+// errorAt records an error about the token tok.
+func (p *Parser) errorAt(tok Token, msg string) {
+    p.errors = append(p.errors, msg)
+    p.spans = append(p.spans, tokenSpan(tok))
+}
+
 // Errors returns the list of parsing errors encountered.
 func (p *Parser) Errors() []string {
     return p.errors
 }
 
+// Diagnostics returns the same errors as Errors, each with the span of the token it is about.
+func (p *Parser) Diagnostics() []Diagnostic {
+    diagnostics := make([]Diagnostic, len(p.errors))
+    for i, msg := range p.errors {
+        diagnostics[i] = Diagnostic{Span: p.spans[i], Message: msg}
+    }
+    return diagnostics
+}
+
+// Comments returns the comments in the source parsed so far.
+func (p *Parser) Comments() []Token {
+    return p.lexer.Comments()
+}
+
+// precedences maps infix operator tokens to their binding power.
+var precedences = map[TokenType]int{
+    TOKEN_EQ:        EQUALS,
+    TOKEN_NOT_EQ:    EQUALS,
+    TOKEN_LT:        LESSGREATER,
+    TOKEN_GT:        LESSGREATER,
+    TOKEN_LT_EQ:     LESSGREATER,
+    TOKEN_GT_EQ:     LESSGREATER,
+    TOKEN_APPLE:     SUM,
+    TOKEN_SALMON:    SUM,
+    TOKEN_PANCAKES:  PRODUCT,
+    TOKEN_PIE:       PRODUCT,
+    TOKEN_LEFTOVERS: PRODUCT,
+    TOKEN_LPAREN:    CALL,
+    TOKEN_LBRACKET:  INDEX,
+}
+
+// peekPrecedence returns the precedence of the next token, or LOWEST if it is not an operator.
+func (p *Parser) peekPrecedence() int {
+    if prec, ok := precedences[p.peekToken.Type]; ok {
+        return prec
+    }
+    return LOWEST
+}
+
+// curPrecedence returns the precedence of the current token, or LOWEST if it is not an operator.
+func (p *Parser) curPrecedence() int {
+    if prec, ok := precedences[p.curToken.Type]; ok {
+        return prec
+    }
+    return LOWEST
+}
+
 // parseExpression handles the parsing of expressions, with precedence taken into account.
 func (p *Parser) parseExpression(precedence int) Expression {
     var leftExp Expression
@@ -392,57 +1026,982 @@ func (p *Parser) parseExpression(precedence int) Expression {
     switch p.curToken.Type {
     case TOKEN_INT:
         leftExp = p.parseIntegerLiteral()
+    case TOKEN_STRING:
+        leftExp = p.parseStringLiteral()
     case TOKEN_IDENT:
         leftExp = p.parseIdentifier()
+    case TOKEN_ICACO:
+        leftExp = p.parseInputExpression()
+    case TOKEN_SALMON, TOKEN_BANG:
+        leftExp = p.parsePrefixExpression()
+    case TOKEN_TACOS:
+        leftExp = p.parseTypeofExpression()
+    case TOKEN_NUGGETS:
+        leftExp = p.parseNuggetsExpression()
+    case TOKEN_CAKE, TOKEN_BROCCOLI:
+        leftExp = p.parseBooleanLiteral()
+    case TOKEN_LPAREN:
+        leftExp = p.parseGroupedExpression()
+    case TOKEN_BURRITO:
+        leftExp = p.parseFunctionLiteral()
+    case TOKEN_LBRACKET:
+        leftExp = p.parseArrayLiteral()
+    case TOKEN_LBRACE:
+        leftExp = p.parseHashLiteral()
+    case TOKEN_ILLEGAL:
+        if strings.HasPrefix(p.curToken.Literal, "\"") {
+            // The lexer gives up on a string at its end of line or at an escape it does not know.
+            msg := fmt.Sprintf("unterminated string or unknown escape in %s", p.curToken.Literal)
+            p.errorAt(p.curToken, msg)
+            return nil
+        }
+        if strings.HasPrefix(p.curToken.Literal, "/*") {
+            p.errorAt(p.curToken, "comment not terminated")
+            return nil
+        }
+        fallthrough
     default:
+        msg := fmt.Sprintf("no expression can start with %s (%q)", p.curToken.Type.String(), p.curToken.Literal)
+        p.errorAt(p.curToken, msg)
         return nil
     }
 
+    // Keep folding infix operators into the left side while they bind tighter than the caller.
+    for !p.peekTokenIs(TOKEN_SEMICOLON) && precedence < p.peekPrecedence() {
+        switch p.peekToken.Type {
+        case TOKEN_APPLE, TOKEN_SALMON, TOKEN_PANCAKES, TOKEN_PIE, TOKEN_LEFTOVERS,
+            TOKEN_EQ, TOKEN_NOT_EQ, TOKEN_LT, TOKEN_GT, TOKEN_LT_EQ, TOKEN_GT_EQ:
+            p.nextToken()
+            leftExp = p.parseInfixExpression(leftExp)
+        case TOKEN_LPAREN:
+            p.nextToken()
+            leftExp = p.parseCallExpression(leftExp)
+        case TOKEN_LBRACKET:
+            p.nextToken()
+            leftExp = p.parseIndexExpression(leftExp)
+        default:
+            return leftExp
+        }
+    }
+
     return leftExp
 }
 
-// parseIntegerLiteral handles parsing of integer literals.
-func (p *Parser) parseIntegerLiteral() Expression {
-    lit := &IntegralLiteral{Token: p.curToken}
+// parsePrefixExpression handles parsing of prefix expressions (e.g., "-x" or "salmon x").
+func (p *Parser) parsePrefixExpression() Expression {
+    expression := &PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
 
-    value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
-    if err != nil {
-        fmt.Println("parseIntegerLiteral: Invalid integer")
+    p.nextToken()
+    expression.Right = p.parseExpression(PREFIX)
+
+    return expression
+}
+
+// parseTypeofExpression handles parsing of tacos, which binds as tightly as the other
+// prefix operators (e.g., "tacos x == "nuggets"" compares the type name).
+func (p *Parser) parseTypeofExpression() Expression {
+    expression := &TypeofExpression{Token: p.curToken}
+
+    p.nextToken()
+    expression.Right = p.parseExpression(PREFIX)
+
+    return expression
+}
+
+// parseNuggetsExpression handles parsing of a conversion to an integer (e.g., "nuggets(icaco)").
+func (p *Parser) parseNuggetsExpression() Expression {
+    exp := &NuggetsExpression{Token: p.curToken}
+
+    if !p.expectPeek(TOKEN_LPAREN) {
         return nil
     }
+    p.nextToken()
+    exp.Value = p.parseExpression(LOWEST)
 
-    lit.Value = value
-    return lit
+    if !p.expectPeek(TOKEN_RPAREN) {
+        return nil
+    }
+    exp.Rparen = p.curToken
+
+    return exp
 }
 
-// parseIdentifier handles parsing of identifiers.
-func (p *Parser) parseIdentifier() Expression {
-    return &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+// parseInfixExpression handles parsing of infix expressions (e.g., "x + y" or "x apple y").
+func (p *Parser) parseInfixExpression(left Expression) Expression {
+    expression := &InfixExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: left}
+
+    precedence := p.curPrecedence()
+    p.nextToken()
+    expression.Right = p.parseExpression(precedence)
+
+    return expression
 }
 
-// IntegralLiteral represents an integer literal in the AST.
-type IntegralLiteral struct {
-    Token Token
-    Value int64
+// parseBooleanLiteral handles parsing of the boolean literals cake and broccoli.
+func (p *Parser) parseBooleanLiteral() Expression {
+    return &BooleanLiteral{Token: p.curToken, Value: p.curTokenIs(TOKEN_CAKE)}
 }
 
-func (il *IntegralLiteral) expressionNode() {}
+// parseGroupedExpression handles parsing of a parenthesised expression (e.g., "(x + y)").
+// The parentheses only steer precedence, so the inner expression is returned directly.
+func (p *Parser) parseGroupedExpression() Expression {
+    p.nextToken()
 
-func (il *IntegralLiteral) TokenLiteral() string {
-    return il.Token.Literal
-}
+    exp := p.parseExpression(LOWEST)
 
-func (il *IntegralLiteral) String() string {
-    return il.Token.Literal
+    if !p.expectPeek(TOKEN_RPAREN) {
+        return nil
+    }
+
+    return exp
 }
 
-// String method for TokenType returns a string representation of the TokenType.
-func (t TokenType) String() string {
-    switch t {
+// parseFunctionLiteral handles parsing of a function literal (e.g., "burrito(x, y) { takeout x + y; }").
+// Loops outside the burrito do not reach into its body, so dessert and seconds there are errors.
+func (p *Parser) parseFunctionLiteral() Expression {
+    lit := &FunctionLiteral{Token: p.curToken}
+
+    if !p.expectPeek(TOKEN_LPAREN) {
+        return nil
+    }
+    lit.Parameters = p.parseFunctionParameters()
+    if lit.Parameters == nil {
+        return nil
+    }
+
+    if !p.expectPeek(TOKEN_LBRACE) {
+        return nil
+    }
+
+    loops := p.loops
+    p.loops = 0
+    p.functions++
+    lit.Body = p.parseBlockStatement()
+    p.functions--
+    p.loops = loops
+
+    return lit
+}
+
+// parseFunctionParameters parses the comma separated parameter names of a function literal
+// up to and including the closing RPAREN. It returns nil after reporting an error.
+func (p *Parser) parseFunctionParameters() []*Identifier {
+    identifiers := []*Identifier{}
+
+    if p.peekTokenIs(TOKEN_RPAREN) {
+        p.nextToken()
+        return identifiers
+    }
+
+    for {
+        if !p.expectPeek(TOKEN_IDENT) {
+            return nil
+        }
+        identifiers = append(identifiers, &Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+        if !p.peekTokenIs(TOKEN_COMMA) {
+            break
+        }
+        p.nextToken()
+    }
+
+    if !p.expectPeek(TOKEN_RPAREN) {
+        return nil
+    }
+
+    return identifiers
+}
+
+// parseCallExpression handles parsing of a call (e.g., "add(1, 2)"). The current token is
+// the LPAREN after the expression being called.
+func (p *Parser) parseCallExpression(function Expression) Expression {
+    exp := &CallExpression{Token: p.curToken, Function: function}
+
+    exp.Arguments = p.parseCallArguments()
+    if exp.Arguments == nil {
+        return nil
+    }
+    exp.Rparen = p.curToken
+
+    return exp
+}
+
+// parseCallArguments parses the comma separated arguments of a call up to and including
+// the closing RPAREN. It returns nil after reporting an error.
+func (p *Parser) parseCallArguments() []Expression {
+    return p.parseExpressionList(TOKEN_RPAREN)
+}
+
+// parseExpressionList parses comma separated expressions up to and including the end
+// token. It returns nil after reporting an error.
+func (p *Parser) parseExpressionList(end TokenType) []Expression {
+    list := []Expression{}
+
+    if p.peekTokenIs(end) {
+        p.nextToken()
+        return list
+    }
+
+    p.nextToken()
+    list = append(list, p.parseExpression(LOWEST))
+
+    for p.peekTokenIs(TOKEN_COMMA) {
+        p.nextToken()
+        p.nextToken()
+        list = append(list, p.parseExpression(LOWEST))
+    }
+
+    if !p.expectPeek(end) {
+        return nil
+    }
+
+    return list
+}
+
+// parseArrayLiteral handles parsing of an array literal (e.g., "[1, 2, 3]").
+func (p *Parser) parseArrayLiteral() Expression {
+    array := &ArrayLiteral{Token: p.curToken}
+
+    array.Elements = p.parseExpressionList(TOKEN_RBRACKET)
+    if array.Elements == nil {
+        return nil
+    }
+    array.Rbracket = p.curToken
+
+    return array
+}
+
+// parseIndexExpression handles parsing of an index (e.g., "a[0]" or "h["k"]"). The current
+// token is the LBRACKET after the expression being indexed.
+func (p *Parser) parseIndexExpression(left Expression) Expression {
+    exp := &IndexExpression{Token: p.curToken, Left: left}
+
+    p.nextToken()
+    exp.Index = p.parseExpression(LOWEST)
+
+    if !p.expectPeek(TOKEN_RBRACKET) {
+        return nil
+    }
+    exp.Rbracket = p.curToken
+
+    return exp
+}
+
+// parseHashLiteral handles parsing of a hash literal (e.g., "{"a": 1, 2: cake}") up to
+// and including its closing RBRACE.
+func (p *Parser) parseHashLiteral() Expression {
+    hash := &HashLiteral{Token: p.curToken}
+
+    for !p.peekTokenIs(TOKEN_RBRACE) {
+        p.nextToken()
+        key := p.parseExpression(LOWEST)
+
+        if !p.expectPeek(TOKEN_COLON) {
+            return nil
+        }
+        p.nextToken()
+        value := p.parseExpression(LOWEST)
+
+        hash.Keys = append(hash.Keys, key)
+        hash.Values = append(hash.Values, value)
+
+        if !p.peekTokenIs(TOKEN_RBRACE) && !p.expectPeek(TOKEN_COMMA) {
+            return nil
+        }
+    }
+    p.nextToken()
+    hash.Rbrace = p.curToken
+
+    return hash
+}
+
+// parseInputExpression handles parsing of the icaco input expression.
+func (p *Parser) parseInputExpression() Expression {
+    return &InputExpression{Token: p.curToken}
+}
+
+// parseIntegerLiteral handles parsing of integer literals.
+func (p *Parser) parseIntegerLiteral() Expression {
+    lit := &IntegralLiteral{Token: p.curToken}
+
+    value, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
+    if err != nil {
+        p.errorAt(p.curToken, fmt.Sprintf("integer literal %s does not fit in nuggets", p.curToken.Literal))
+        return nil
+    }
+
+    lit.Value = value
+    return lit
+}
+
+// parseStringLiteral handles parsing of string literals.
+func (p *Parser) parseStringLiteral() Expression {
+    return &StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// parseIdentifier handles parsing of identifiers.
+func (p *Parser) parseIdentifier() Expression {
+    return &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// IntegralLiteral represents an integer literal in the AST.
+type IntegralLiteral struct {
+    Token Token
+    Value int64
+}
+
+func (il *IntegralLiteral) expressionNode() {}
+
+func (il *IntegralLiteral) TokenLiteral() string {
+    return il.Token.Literal
+}
+
+func (il *IntegralLiteral) String() string {
+    return il.Token.Literal
+}
+
+// StringLiteral represents a double quoted string in the AST (e.g., "\"hello\\n\"").
+type StringLiteral struct {
+    Token Token
+    Value string // The contents with every escape decoded.
+}
+
+func (sl *StringLiteral) expressionNode() {}
+
+func (sl *StringLiteral) TokenLiteral() string {
+    return sl.Token.Literal
+}
+
+func (sl *StringLiteral) String() string {
+    return quoteString(sl.Value)
+}
+
+// AssignStatement represents an update of an existing variable (e.g., "x = 5;" or "x apple= 1;").
+type AssignStatement struct {
+    Token Token       // The assignment token: TOKEN_ENCHILADA, TOKEN_APPLE_ENCHILADA or TOKEN_SALMON_ENCHILADA.
+    Name  *Identifier // The variable being updated.
+    Value Expression  // The new value, or the right operand of a compound assignment.
+}
+
+func (as *AssignStatement) statementNode() {}
+
+func (as *AssignStatement) TokenLiteral() string {
+    return as.Token.Literal
+}
+
+func (as *AssignStatement) String() string {
+    var out strings.Builder
+    out.WriteString(as.Name.String())
+    out.WriteString(" " + as.TokenLiteral() + " ")
+
+    if as.Value != nil {
+        out.WriteString(as.Value.String())
+    }
+
+    out.WriteString(";")
+    return out.String()
+}
+
+// Operator returns the arithmetic token applied by a compound assignment, or false for a plain one.
+func (as *AssignStatement) Operator() (TokenType, bool) {
+    for operator, compound := range compoundAssignments {
+        if as.Token.Type == compound {
+            return operator, true
+        }
+    }
+    return as.Token.Type, false
+}
+
+// isCompoundAssignment reports whether t is one of the "+=" style assignment tokens.
+func isCompoundAssignment(t TokenType) bool {
+    for _, compound := range compoundAssignments {
+        if t == compound {
+            return true
+        }
+    }
+    return false
+}
+
+// ExpressionStatement represents an expression used as a statement (e.g., "x + 1;").
+type ExpressionStatement struct {
+    Token      Token // The first token of the expression.
+    Expression Expression
+}
+
+func (es *ExpressionStatement) statementNode() {}
+
+func (es *ExpressionStatement) TokenLiteral() string {
+    return es.Token.Literal
+}
+
+func (es *ExpressionStatement) String() string {
+    if es.Expression != nil {
+        return es.Expression.String() + ";"
+    }
+    return ";"
+}
+
+// BooleanLiteral represents cake (true) or broccoli (false) in the AST.
+type BooleanLiteral struct {
+    Token Token
+    Value bool
+}
+
+func (bl *BooleanLiteral) expressionNode() {}
+
+func (bl *BooleanLiteral) TokenLiteral() string {
+    return bl.Token.Literal
+}
+
+func (bl *BooleanLiteral) String() string {
+    return bl.Token.Literal
+}
+
+// BlockStatement represents statements grouped in braces (e.g., "{ cheese y = x; pizza y; }").
+// A block opens a new scope, so cheese bindings made inside it end with it.
+type BlockStatement struct {
+    Token      Token // The TOKEN_LBRACE token.
+    Statements []Statement
+    Rbrace     Token // The closing TOKEN_RBRACE.
+}
+
+func (bs *BlockStatement) statementNode() {}
+
+func (bs *BlockStatement) TokenLiteral() string {
+    return bs.Token.Literal
+}
+
+func (bs *BlockStatement) String() string {
+    var out strings.Builder
+    out.WriteString("{")
+    for _, s := range bs.Statements {
+        out.WriteString(s.String())
+    }
+    out.WriteString("}")
+    return out.String()
+}
+
+// IfStatement represents a conditional (e.g., "waffles x > 1 { pizza x; } fries { pizza 0; }").
+type IfStatement struct {
+    Token       Token // The TOKEN_WAFFLES token.
+    Condition   Expression
+    Consequence *BlockStatement
+    Alternative Statement // The fries *BlockStatement, a chained *IfStatement, or nil.
+}
+
+func (is *IfStatement) statementNode() {}
+
+func (is *IfStatement) TokenLiteral() string {
+    return is.Token.Literal
+}
+
+func (is *IfStatement) String() string {
+    var out strings.Builder
+    out.WriteString(is.TokenLiteral() + " ")
+    if is.Condition != nil {
+        out.WriteString(is.Condition.String())
+    }
+    out.WriteString(" " + is.Consequence.String())
+    if is.Alternative != nil {
+        out.WriteString(" fries " + is.Alternative.String())
+    }
+    return out.String()
+}
+
+// WhileStatement represents a loop that runs while its condition holds (e.g., "noodles x > 0 { x -= 1; }").
+type WhileStatement struct {
+    Token     Token // The TOKEN_NOODLES token.
+    Condition Expression
+    Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+func (ws *WhileStatement) TokenLiteral() string {
+    return ws.Token.Literal
+}
+
+func (ws *WhileStatement) String() string {
+    var out strings.Builder
+    out.WriteString(ws.TokenLiteral() + " ")
+    if ws.Condition != nil {
+        out.WriteString(ws.Condition.String())
+    }
+    out.WriteString(" " + ws.Body.String())
+    return out.String()
+}
+
+// ForStatement represents a counted loop (e.g., "donuts i = 0, 10 { pizza i; }"). The
+// variable takes every integer from Start up to, but not including, End. Both bounds
+// are evaluated once, before the first iteration.
+type ForStatement struct {
+    Token    Token       // The TOKEN_DONUTS token.
+    Variable *Identifier // Declared in a scope of its own around the body.
+    Start    Expression
+    End      Expression
+    Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+
+func (fs *ForStatement) TokenLiteral() string {
+    return fs.Token.Literal
+}
+
+func (fs *ForStatement) String() string {
+    var out strings.Builder
+    out.WriteString(fs.TokenLiteral() + " " + fs.Variable.String() + " = ")
+    if fs.Start != nil {
+        out.WriteString(fs.Start.String())
+    }
+    out.WriteString(", ")
+    if fs.End != nil {
+        out.WriteString(fs.End.String())
+    }
+    out.WriteString(" " + fs.Body.String())
+    return out.String()
+}
+
+// LoopControlStatement represents dessert (break) or seconds (continue).
+type LoopControlStatement struct {
+    Token Token // The TOKEN_DESSERT or TOKEN_SECONDS token.
+}
+
+func (lc *LoopControlStatement) statementNode() {}
+
+func (lc *LoopControlStatement) TokenLiteral() string {
+    return lc.Token.Literal
+}
+
+func (lc *LoopControlStatement) String() string {
+    return lc.TokenLiteral() + ";"
+}
+
+// IsBreak reports whether the statement leaves the loop rather than starting its next iteration.
+func (lc *LoopControlStatement) IsBreak() bool {
+    return lc.Token.Type == TOKEN_DESSERT
+}
+
+// ReturnStatement represents takeout, which ends the call of the enclosing burrito (e.g., "takeout x;").
+type ReturnStatement struct {
+    Token Token      // The TOKEN_TAKEOUT token.
+    Value Expression // The value handed back to the caller, or nil for a bare takeout.
+}
+
+func (rs *ReturnStatement) statementNode() {}
+
+func (rs *ReturnStatement) TokenLiteral() string {
+    return rs.Token.Literal
+}
+
+func (rs *ReturnStatement) String() string {
+    if rs.Value == nil {
+        return rs.TokenLiteral() + ";"
+    }
+    return rs.TokenLiteral() + " " + rs.Value.String() + ";"
+}
+
+// FunctionLiteral represents a function value (e.g., "burrito(x, y) { takeout x + y; }").
+type FunctionLiteral struct {
+    Token      Token // The TOKEN_BURRITO token.
+    Parameters []*Identifier
+    Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode() {}
+
+func (fl *FunctionLiteral) TokenLiteral() string {
+    return fl.Token.Literal
+}
+
+func (fl *FunctionLiteral) String() string {
+    params := make([]string, len(fl.Parameters))
+    for i, param := range fl.Parameters {
+        params[i] = param.String()
+    }
+    return fl.TokenLiteral() + "(" + strings.Join(params, ", ") + ") " + fl.Body.String()
+}
+
+// CallExpression represents calling a function (e.g., "add(1, 2)").
+type CallExpression struct {
+    Token     Token      // The TOKEN_LPAREN token, which is where the call happens.
+    Function  Expression // An identifier, a function literal or any expression producing a function.
+    Arguments []Expression
+    Rparen    Token      // The closing TOKEN_RPAREN.
+}
+
+func (ce *CallExpression) expressionNode() {}
+
+func (ce *CallExpression) TokenLiteral() string {
+    return ce.Token.Literal
+}
+
+func (ce *CallExpression) String() string {
+    args := make([]string, len(ce.Arguments))
+    for i, arg := range ce.Arguments {
+        if arg != nil {
+            args[i] = arg.String()
+        }
+    }
+    return ce.Function.String() + "(" + strings.Join(args, ", ") + ")"
+}
+
+// ArrayLiteral represents a list of values (e.g., "[1, 2, 3]").
+type ArrayLiteral struct {
+    Token    Token // The TOKEN_LBRACKET token.
+    Elements []Expression
+    Rbracket Token // The closing TOKEN_RBRACKET.
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+
+func (al *ArrayLiteral) TokenLiteral() string {
+    return al.Token.Literal
+}
+
+func (al *ArrayLiteral) String() string {
+    elements := make([]string, len(al.Elements))
+    for i, el := range al.Elements {
+        if el != nil {
+            elements[i] = el.String()
+        }
+    }
+    return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// IndexExpression represents looking up an element of an array or a hash (e.g., "a[0]").
+type IndexExpression struct {
+    Token    Token // The TOKEN_LBRACKET token, which is where the lookup happens.
+    Left     Expression
+    Index    Expression
+    Rbracket Token // The closing TOKEN_RBRACKET.
+}
+
+func (ie *IndexExpression) expressionNode() {}
+
+func (ie *IndexExpression) TokenLiteral() string {
+    return ie.Token.Literal
+}
+
+func (ie *IndexExpression) String() string {
+    var out strings.Builder
+    out.WriteString("(")
+    if ie.Left != nil {
+        out.WriteString(ie.Left.String())
+    }
+    out.WriteString("[")
+    if ie.Index != nil {
+        out.WriteString(ie.Index.String())
+    }
+    out.WriteString("])")
+    return out.String()
+}
+
+// HashLiteral represents a map from keys to values (e.g., "{"a": 1, "b": 2}"). Keys and
+// Values line up and keep the order they were written in.
+type HashLiteral struct {
+    Token  Token // The TOKEN_LBRACE token.
+    Keys   []Expression
+    Values []Expression
+    Rbrace Token // The closing TOKEN_RBRACE.
+}
+
+func (hl *HashLiteral) expressionNode() {}
+
+func (hl *HashLiteral) TokenLiteral() string {
+    return hl.Token.Literal
+}
+
+func (hl *HashLiteral) String() string {
+    pairs := make([]string, len(hl.Keys))
+    for i := range hl.Keys {
+        var key, value string
+        if hl.Keys[i] != nil {
+            key = hl.Keys[i].String()
+        }
+        if hl.Values[i] != nil {
+            value = hl.Values[i].String()
+        }
+        pairs[i] = key + ": " + value
+    }
+    return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// PrintStatement represents a print statement (e.g., "pizza x;").
+type PrintStatement struct {
+    Token Token      // The TOKEN_PIZZA token.
+    Value Expression // The expression to print.
+}
+
+func (ps *PrintStatement) statementNode() {}
+
+func (ps *PrintStatement) TokenLiteral() string {
+    return ps.Token.Literal
+}
+
+func (ps *PrintStatement) String() string {
+    var out strings.Builder
+    out.WriteString(ps.TokenLiteral() + " ")
+
+    if ps.Value != nil {
+        out.WriteString(ps.Value.String())
+    }
+
+    out.WriteString(";")
+    return out.String()
+}
+
+// InputExpression represents reading an integer from input (e.g., "icaco").
+type InputExpression struct {
+    Token Token // The TOKEN_ICACO token.
+}
+
+func (ie *InputExpression) expressionNode() {}
+
+func (ie *InputExpression) TokenLiteral() string {
+    return ie.Token.Literal
+}
+
+func (ie *InputExpression) String() string {
+    return ie.Token.Literal
+}
+
+// PrefixExpression represents an operator applied to a single operand (e.g., "-x").
+type PrefixExpression struct {
+    Token    Token  // The prefix token, e.g. TOKEN_SALMON.
+    Operator string // The operator as written in the source.
+    Right    Expression
+}
+
+func (pe *PrefixExpression) expressionNode() {}
+
+func (pe *PrefixExpression) TokenLiteral() string {
+    return pe.Token.Literal
+}
+
+func (pe *PrefixExpression) String() string {
+    var out strings.Builder
+    out.WriteString("(")
+    out.WriteString(pe.Operator)
+    if pe.Right != nil {
+        out.WriteString(pe.Right.String())
+    }
+    out.WriteString(")")
+    return out.String()
+}
+
+// TypeofExpression represents asking for the type name of a value (e.g., "tacos x").
+type TypeofExpression struct {
+    Token Token // The TOKEN_TACOS token.
+    Right Expression
+}
+
+func (te *TypeofExpression) expressionNode() {}
+
+func (te *TypeofExpression) TokenLiteral() string {
+    return te.Token.Literal
+}
+
+func (te *TypeofExpression) String() string {
+    var out strings.Builder
+    out.WriteString("(" + te.TokenLiteral() + " ")
+    if te.Right != nil {
+        out.WriteString(te.Right.String())
+    }
+    out.WriteString(")")
+    return out.String()
+}
+
+// NuggetsExpression represents converting a value to an integer (e.g., "nuggets("42")").
+type NuggetsExpression struct {
+    Token  Token // The TOKEN_NUGGETS token.
+    Value  Expression
+    Rparen Token // The closing TOKEN_RPAREN.
+}
+
+func (ne *NuggetsExpression) expressionNode() {}
+
+func (ne *NuggetsExpression) TokenLiteral() string {
+    return ne.Token.Literal
+}
+
+func (ne *NuggetsExpression) String() string {
+    var out strings.Builder
+    out.WriteString(ne.TokenLiteral() + "(")
+    if ne.Value != nil {
+        out.WriteString(ne.Value.String())
+    }
+    out.WriteString(")")
+    return out.String()
+}
+
+// TypeSwitchStatement runs the first case naming the type of its subject, or the fries
+// case if none does (e.g., "tacos x { nuggets { pizza x; } fries { pizza 0; } }").
+type TypeSwitchStatement struct {
+    Token   Token // The TOKEN_TACOS token.
+    Subject Expression
+    Cases   []*TypeCase
+    Default *BlockStatement // The fries case, or nil.
+    Rbrace  Token           // The closing TOKEN_RBRACE.
+}
+
+// TypeCase is one case of a type switch: the type names it matches and what it runs.
+type TypeCase struct {
+    Token Token    // The token of the first type name.
+    Types []string // Type names as tacos reports them, e.g. "nuggets".
+    Body  *BlockStatement
+}
+
+func (ts *TypeSwitchStatement) statementNode() {}
+
+func (ts *TypeSwitchStatement) TokenLiteral() string {
+    return ts.Token.Literal
+}
+
+func (ts *TypeSwitchStatement) String() string {
+    var out strings.Builder
+    out.WriteString(ts.TokenLiteral() + " ")
+    if ts.Subject != nil {
+        out.WriteString(ts.Subject.String())
+    }
+    out.WriteString(" {")
+    for _, c := range ts.Cases {
+        out.WriteString(strings.Join(c.Types, ", ") + " " + c.Body.String())
+    }
+    if ts.Default != nil {
+        out.WriteString("fries " + ts.Default.String())
+    }
+    out.WriteString("}")
+    return out.String()
+}
+
+// InfixExpression represents a binary operation (e.g., "x + y" or "x apple y").
+type InfixExpression struct {
+    Token    Token  // The operator token, e.g. TOKEN_APPLE.
+    Left     Expression
+    Operator string // The operator as written in the source.
+    Right    Expression
+}
+
+func (ie *InfixExpression) expressionNode() {}
+
+func (ie *InfixExpression) TokenLiteral() string {
+    return ie.Token.Literal
+}
+
+func (ie *InfixExpression) String() string {
+    var out strings.Builder
+    out.WriteString("(")
+    if ie.Left != nil {
+        out.WriteString(ie.Left.String())
+    }
+    out.WriteString(" " + ie.Operator + " ")
+    if ie.Right != nil {
+        out.WriteString(ie.Right.String())
+    }
+    out.WriteString(")")
+    return out.String()
+}
+
+// String method for TokenType returns a string representation of the TokenType.
+func (t TokenType) String() string {
+    switch t {
     case TOKEN_IDENT:
         return "TOKEN_IDENT"
     case TOKEN_EOF:
         return "TOKEN_EOF"
+    case TOKEN_INT:
+        return "TOKEN_INT"
+    case TOKEN_ILLEGAL:
+        return "TOKEN_ILLEGAL"
+    case TOKEN_SEMICOLON:
+        return "TOKEN_SEMICOLON"
+    case TOKEN_PIZZA:
+        return "TOKEN_PIZZA"
+    case TOKEN_CHEESE:
+        return "TOKEN_CHEESE"
+    case TOKEN_TACOS:
+        return "TOKEN_TACOS"
+    case TOKEN_NUGGETS:
+        return "TOKEN_NUGGETS"
+    case TOKEN_ENCHILADA:
+        return "TOKEN_ENCHILADA"
+    case TOKEN_APPLE:
+        return "TOKEN_APPLE"
+    case TOKEN_SALMON:
+        return "TOKEN_SALMON"
+    case TOKEN_ICACO:
+        return "TOKEN_ICACO"
+    case TOKEN_APPLE_ENCHILADA:
+        return "TOKEN_APPLE_ENCHILADA"
+    case TOKEN_SALMON_ENCHILADA:
+        return "TOKEN_SALMON_ENCHILADA"
+    case TOKEN_LPAREN:
+        return "TOKEN_LPAREN"
+    case TOKEN_RPAREN:
+        return "TOKEN_RPAREN"
+    case TOKEN_LBRACE:
+        return "TOKEN_LBRACE"
+    case TOKEN_RBRACE:
+        return "TOKEN_RBRACE"
+    case TOKEN_LBRACKET:
+        return "TOKEN_LBRACKET"
+    case TOKEN_RBRACKET:
+        return "TOKEN_RBRACKET"
+    case TOKEN_COMMA:
+        return "TOKEN_COMMA"
+    case TOKEN_PANCAKES:
+        return "TOKEN_PANCAKES"
+    case TOKEN_PIE:
+        return "TOKEN_PIE"
+    case TOKEN_LEFTOVERS:
+        return "TOKEN_LEFTOVERS"
+    case TOKEN_PANCAKES_ENCHILADA:
+        return "TOKEN_PANCAKES_ENCHILADA"
+    case TOKEN_PIE_ENCHILADA:
+        return "TOKEN_PIE_ENCHILADA"
+    case TOKEN_LEFTOVERS_ENCHILADA:
+        return "TOKEN_LEFTOVERS_ENCHILADA"
+    case TOKEN_EQ:
+        return "TOKEN_EQ"
+    case TOKEN_NOT_EQ:
+        return "TOKEN_NOT_EQ"
+    case TOKEN_LT:
+        return "TOKEN_LT"
+    case TOKEN_GT:
+        return "TOKEN_GT"
+    case TOKEN_LT_EQ:
+        return "TOKEN_LT_EQ"
+    case TOKEN_GT_EQ:
+        return "TOKEN_GT_EQ"
+    case TOKEN_BANG:
+        return "TOKEN_BANG"
+    case TOKEN_CAKE:
+        return "TOKEN_CAKE"
+    case TOKEN_BROCCOLI:
+        return "TOKEN_BROCCOLI"
+    case TOKEN_WAFFLES:
+        return "TOKEN_WAFFLES"
+    case TOKEN_FRIES:
+        return "TOKEN_FRIES"
+    case TOKEN_NOODLES:
+        return "TOKEN_NOODLES"
+    case TOKEN_DONUTS:
+        return "TOKEN_DONUTS"
+    case TOKEN_DESSERT:
+        return "TOKEN_DESSERT"
+    case TOKEN_SECONDS:
+        return "TOKEN_SECONDS"
+    case TOKEN_BURRITO:
+        return "TOKEN_BURRITO"
+    case TOKEN_TAKEOUT:
+        return "TOKEN_TAKEOUT"
+    case TOKEN_STRING:
+        return "TOKEN_STRING"
+    case TOKEN_COLON:
+        return "TOKEN_COLON"
+    case TOKEN_COMMENT:
+        return "TOKEN_COMMENT"
     // ... add cases for other token types ...
     default:
         return fmt.Sprintf("Unknown TokenType (%d)", int(t))
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ObjectType names the kind of a runtime value.
+type ObjectType string
+
+const (
+	INTEGER_OBJ  = "INTEGER"
+	BOOLEAN_OBJ  = "BOOLEAN"
+	STRING_OBJ   = "STRING"
+	ERROR_OBJ    = "ERROR"
+	FUNCTION_OBJ = "FUNCTION"
+	BUILTIN_OBJ  = "BUILTIN"
+	ARRAY_OBJ    = "ARRAY"
+	HASH_OBJ     = "HASH"
+	NULL_OBJ     = "NULL"
+
+	LOOP_SIGNAL_OBJ  = "LOOP_SIGNAL"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+)
+
+// typeNames gives the name tacos reports for each type of value. Builtins and burritos
+// are both just functions to a script.
+var typeNames = map[ObjectType]string{
+	INTEGER_OBJ:  "nuggets",
+	BOOLEAN_OBJ:  "boolean",
+	STRING_OBJ:   "string",
+	FUNCTION_OBJ: "function",
+	BUILTIN_OBJ:  "function",
+	ARRAY_OBJ:    "array",
+	HASH_OBJ:     "hash",
+	NULL_OBJ:     "nothing",
+}
+
+// typeName returns the name tacos reports for obj.
+func typeName(obj Object) string {
+	return typeNameOf(obj.Type())
+}
+
+// typeNameOf returns the name tacos reports for values of type typ. Runtime errors name
+// types the same way, so a program only ever sees one name for each type.
+func typeNameOf(typ ObjectType) string {
+	if name, ok := typeNames[typ]; ok {
+		return name
+	}
+	return strings.ToLower(string(typ))
+}
+
+// isTypeName reports whether tacos can ever report name.
+func isTypeName(name string) bool {
+	for _, known := range typeNames {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Object is the interface every runtime value produced by the evaluator implements.
+type Object interface {
+	Type() ObjectType // Returns the kind of the value.
+	Inspect() string  // Returns the value the way pizza prints it.
+}
+
+// Integer is the runtime representation of a number.
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return strconv.FormatInt(i.Value, 10) }
+
+// String is the runtime representation of text. Pizza prints it as is, without quotes.
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+// Boolean is the runtime representation of cake and broccoli. There are only ever the
+// two values TRUE and FALSE, so booleans can be compared by pointer.
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string {
+	if b.Value {
+		return "cake"
+	}
+	return "broccoli"
+}
+
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
+// nativeBoolToBooleanObject returns the shared Boolean for a Go bool.
+func nativeBoolToBooleanObject(value bool) *Boolean {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
+// LoopSignal carries dessert or seconds up through the blocks of a loop body until it
+// reaches the loop, which then stops or starts its next iteration.
+type LoopSignal struct {
+	Break bool // True for dessert, false for seconds.
+}
+
+func (ls *LoopSignal) Type() ObjectType { return LOOP_SIGNAL_OBJ }
+func (ls *LoopSignal) Inspect() string {
+	if ls.Break {
+		return "dessert"
+	}
+	return "seconds"
+}
+
+var (
+	BREAK    = &LoopSignal{Break: true}
+	CONTINUE = &LoopSignal{Break: false}
+)
+
+// Closure is a burrito together with the Environment it was created in, so the body can
+// keep using the variables around it after that scope has finished running.
+type Closure struct {
+	Parameters []*Identifier
+	Body       *BlockStatement
+	Env        *Environment
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	params := make([]string, len(c.Parameters))
+	for i, param := range c.Parameters {
+		params[i] = param.String()
+	}
+	return "burrito(" + strings.Join(params, ", ") + ") " + c.Body.String()
+}
+
+// BuiltinFunction is the Go implementation of a builtin. It returns an *Error for bad
+// arguments, which the call site then positions.
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin is a function provided by the interpreter rather than written in goofylang.
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin " + b.Name }
+
+// Array is an ordered list of values. Arrays are never changed in place; push builds a
+// new one, so every variable holding an array keeps seeing the same elements.
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	elements := make([]string, len(a.Elements))
+	for i, el := range a.Elements {
+		elements[i] = inspectElement(el)
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashKey identifies a hash entry. Two values produce the same HashKey exactly when they
+// are ==, so strings are keyed by their text rather than a hash of it, which two
+// different strings could share.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64 // The integer or boolean.
+	Text  string // The string.
+}
+
+// Hashable is implemented by the values that can be used as hash keys.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Text: s.Value}
+}
+
+// HashPair is one entry of a hash: the original key, kept for printing and keys, and its value.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps integer, string and boolean keys to values. Order lists the keys in the
+// order they were first added, so printing a hash and keys are deterministic.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Order []HashKey
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	pairs := make([]string, len(h.Order))
+	for i, key := range h.Order {
+		pair := h.Pairs[key]
+		pairs[i] = inspectElement(pair.Key) + ": " + inspectElement(pair.Value)
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// inspectElement prints a value inside an array or hash. Strings are quoted there, so
+// ["a, b"] and ["a", "b"] do not look the same.
+func inspectElement(obj Object) string {
+	if s, ok := obj.(*String); ok {
+		return quoteString(s.Value)
+	}
+	return obj.Inspect()
+}
+
+// Null is what a call evaluates to when the burrito finishes without taking out a value.
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "nothing" }
+
+var NULL = &Null{}
+
+// ReturnValue carries the value of a takeout up through the blocks of a function body
+// until it reaches the call, which unwraps it.
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Error carries a runtime error up through the evaluator until it reaches the caller.
+type Error struct {
+	Message string
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
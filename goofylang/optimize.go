@@ -0,0 +1,779 @@
+package main
+
+import (
+	"strconv"
+)
+
+// Optimization levels understood by Optimize.
+const (
+	OptNone = 0 // Leave the program untouched.
+	OptFold = 1 // Fold operators applied to literals.
+	OptFull = 2 // Also propagate constants through cheese bindings and drop dead stores.
+)
+
+// Optimize returns an optimized copy of program; the original is never modified.
+// The passes only rewrite things they fully understand. Any statement or expression
+// they do not recognise is kept as is and treated as if it could read every variable,
+// so observable pizza and icaco behaviour, including runtime errors, never changes.
+func Optimize(program *Program, level int) *Program {
+	if level <= OptNone {
+		return program
+	}
+
+	// consts stays nil below OptFull, which turns constant propagation off.
+	var consts map[string]Object
+	if level >= OptFull {
+		consts = map[string]Object{}
+	}
+
+	out := &Program{Statements: optimizeStatements(program.Statements, consts)}
+
+	if level >= OptFull {
+		out.Statements = eliminateDeadStores(out.Statements)
+	}
+
+	return out
+}
+
+// optimizeStatements folds the expressions of a list of statements, updating consts with
+// what is known about every variable after each one. consts may be nil.
+func optimizeStatements(stmts []Statement, consts map[string]Object) []Statement {
+	out := make([]Statement, 0, len(stmts))
+
+	for _, stmt := range stmts {
+		// A call can assign to any variable its burrito can see, even halfway through an
+		// expression, so nothing known before it can be trusted.
+		if hasCall(stmt) {
+			clearConstants(consts)
+		}
+
+		switch stmt := stmt.(type) {
+		case *LetStatement:
+			value := foldExpression(stmt.Value, consts)
+			out = append(out, &LetStatement{Token: stmt.Token, Annotation: stmt.Annotation, Name: stmt.Name, Value: value})
+
+			if consts != nil {
+				if obj, ok := literalValue(value); ok {
+					consts[stmt.Name.Value] = obj
+				} else {
+					delete(consts, stmt.Name.Value)
+				}
+			}
+		case *AssignStatement:
+			value := foldExpression(stmt.Value, consts)
+			assign := &AssignStatement{Token: stmt.Token, Name: stmt.Name, Value: value}
+
+			if consts != nil {
+				if result, ok := updateConstant(consts, stmt, value); ok {
+					// The result is known, so a compound assignment becomes a plain one.
+					tok := stmt.Token
+					tok.Type = TOKEN_ENCHILADA
+					tok.Literal = "="
+					assign = &AssignStatement{Token: tok, Name: stmt.Name, Value: newLiteral(value, result)}
+				}
+			}
+			out = append(out, assign)
+		case *PrintStatement:
+			value := foldExpression(stmt.Value, consts)
+			out = append(out, &PrintStatement{Token: stmt.Token, Value: value})
+		case *ExpressionStatement:
+			value := foldExpression(stmt.Expression, consts)
+			out = append(out, &ExpressionStatement{Token: stmt.Token, Expression: value})
+		case *BlockStatement:
+			out = append(out, optimizeBlock(stmt, consts))
+		case *IfStatement:
+			out = append(out, optimizeIfStatement(stmt, consts)...)
+		case *WhileStatement:
+			out = append(out, optimizeWhileStatement(stmt, consts)...)
+		case *ForStatement:
+			out = append(out, optimizeForStatement(stmt, consts))
+		case *TypeSwitchStatement:
+			out = append(out, optimizeTypeSwitchStatement(stmt, consts)...)
+		case *LoopControlStatement:
+			out = append(out, stmt)
+		default:
+			// We cannot tell what an unknown statement binds, so forget everything we knew.
+			out = append(out, stmt)
+			clearConstants(consts)
+		}
+	}
+
+	return out
+}
+
+// optimizeBlock optimizes the statements of a block. Inside the block everything known
+// beforehand still holds; afterwards every name the block touches is forgotten, since a
+// cheese in the block may shadow it and an assignment may or may not have run.
+func optimizeBlock(block *BlockStatement, consts map[string]Object) *BlockStatement {
+	var inner map[string]Object
+	if consts != nil {
+		inner = make(map[string]Object, len(consts))
+		for name, value := range consts {
+			inner[name] = value
+		}
+	}
+
+	out := &BlockStatement{Token: block.Token, Statements: optimizeStatements(block.Statements, inner), Rbrace: block.Rbrace}
+
+	if consts != nil {
+		names := map[string]bool{}
+		if !collectStores(block, names) {
+			clearConstants(consts)
+		}
+		for name := range names {
+			delete(consts, name)
+		}
+	}
+
+	return out
+}
+
+// optimizeIfStatement folds the condition and both branches of a conditional. When the
+// condition is known, only the branch that runs is kept, still as a block of its own.
+func optimizeIfStatement(stmt *IfStatement, consts map[string]Object) []Statement {
+	condition := foldExpression(stmt.Condition, consts)
+
+	if value, ok := literalValue(condition); ok {
+		switch {
+		case isTruthy(value):
+			return []Statement{optimizeBlock(stmt.Consequence, consts)}
+		case stmt.Alternative != nil:
+			return optimizeStatements([]Statement{stmt.Alternative}, consts)
+		default:
+			return nil
+		}
+	}
+
+	// Each branch starts from what was known before the conditional.
+	var before map[string]Object
+	if consts != nil {
+		before = make(map[string]Object, len(consts))
+		for name, value := range consts {
+			before[name] = value
+		}
+	}
+
+	out := &IfStatement{Token: stmt.Token, Condition: condition}
+	out.Consequence = optimizeBlock(stmt.Consequence, consts)
+	if stmt.Alternative != nil {
+		// A chained conditional whose condition is known folds to a block or to nothing.
+		if alternative := optimizeStatements([]Statement{stmt.Alternative}, before); len(alternative) > 0 {
+			out.Alternative = alternative[0]
+		}
+		// Branches only ever forget names, so what both still know is still true.
+		for name := range consts {
+			if _, ok := before[name]; !ok {
+				delete(consts, name)
+			}
+		}
+	}
+
+	return []Statement{out}
+}
+
+// optimizeTypeSwitchStatement folds the subject and every case of a tacos statement. When
+// the subject is a literal its type is known, so only the case that runs is kept.
+func optimizeTypeSwitchStatement(stmt *TypeSwitchStatement, consts map[string]Object) []Statement {
+	subject := foldExpression(stmt.Subject, consts)
+
+	if value, ok := literalValue(subject); ok {
+		name := typeName(value)
+		for _, c := range stmt.Cases {
+			for _, t := range c.Types {
+				if t == name {
+					return []Statement{optimizeBlock(c.Body, consts)}
+				}
+			}
+		}
+		if stmt.Default != nil {
+			return []Statement{optimizeBlock(stmt.Default, consts)}
+		}
+		return nil
+	}
+
+	// Each case starts from what was known before the statement, and afterwards only
+	// what no case forgot is still known.
+	var before map[string]Object
+	if consts != nil {
+		before = make(map[string]Object, len(consts))
+		for name, value := range consts {
+			before[name] = value
+		}
+	}
+	optimizeCase := func(body *BlockStatement) *BlockStatement {
+		var branch map[string]Object
+		if before != nil {
+			branch = make(map[string]Object, len(before))
+			for name, value := range before {
+				branch[name] = value
+			}
+		}
+		out := optimizeBlock(body, branch)
+		for name := range consts {
+			if _, ok := branch[name]; !ok {
+				delete(consts, name)
+			}
+		}
+		return out
+	}
+
+	out := &TypeSwitchStatement{Token: stmt.Token, Subject: subject, Rbrace: stmt.Rbrace}
+	for _, c := range stmt.Cases {
+		out.Cases = append(out.Cases, &TypeCase{Token: c.Token, Types: c.Types, Body: optimizeCase(c.Body)})
+	}
+	if stmt.Default != nil {
+		out.Default = optimizeCase(stmt.Default)
+	}
+
+	return []Statement{out}
+}
+
+// forgetStores removes from consts every name the body of a loop may change, since the
+// body can run any number of times. What is left holds at the start of every iteration.
+func forgetStores(body *BlockStatement, consts map[string]Object) {
+	if consts == nil {
+		return
+	}
+	names := map[string]bool{}
+	if !collectStores(body, names) {
+		clearConstants(consts)
+	}
+	for name := range names {
+		delete(consts, name)
+	}
+}
+
+// optimizeWhileStatement folds the condition and body of a noodles loop. A loop whose
+// condition is known to be falsy never runs and is dropped.
+func optimizeWhileStatement(stmt *WhileStatement, consts map[string]Object) []Statement {
+	forgetStores(stmt.Body, consts)
+
+	condition := foldExpression(stmt.Condition, consts)
+	if value, ok := literalValue(condition); ok && !isTruthy(value) {
+		return nil
+	}
+
+	return []Statement{&WhileStatement{Token: stmt.Token, Condition: condition, Body: optimizeBlock(stmt.Body, consts)}}
+}
+
+// optimizeForStatement folds the bounds and body of a donuts loop.
+func optimizeForStatement(stmt *ForStatement, consts map[string]Object) *ForStatement {
+	out := &ForStatement{Token: stmt.Token, Variable: stmt.Variable}
+	out.Start = foldExpression(stmt.Start, consts)
+	out.End = foldExpression(stmt.End, consts)
+
+	forgetStores(stmt.Body, consts)
+
+	// The loop variable shadows any outer binding of the same name inside the body.
+	var inner map[string]Object
+	if consts != nil {
+		inner = make(map[string]Object, len(consts))
+		for name, value := range consts {
+			inner[name] = value
+		}
+		delete(inner, stmt.Variable.Value)
+	}
+	out.Body = optimizeBlock(stmt.Body, inner)
+
+	return out
+}
+
+// collectStores adds every name a statement declares or assigns, at any depth, to names.
+// It returns false if the statement contains something it does not understand.
+func collectStores(stmt Statement, names map[string]bool) bool {
+	if hasCall(stmt) {
+		return false
+	}
+
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		names[stmt.Name.Value] = true
+	case *AssignStatement:
+		names[stmt.Name.Value] = true
+	case *PrintStatement, *ExpressionStatement, *LoopControlStatement:
+	case *WhileStatement:
+		return collectStores(stmt.Body, names)
+	case *ForStatement:
+		return collectStores(stmt.Body, names)
+	case *BlockStatement:
+		for _, inner := range stmt.Statements {
+			if !collectStores(inner, names) {
+				return false
+			}
+		}
+	case *IfStatement:
+		if !collectStores(stmt.Consequence, names) {
+			return false
+		}
+		if stmt.Alternative != nil {
+			return collectStores(stmt.Alternative, names)
+		}
+	case *TypeSwitchStatement:
+		for _, c := range stmt.Cases {
+			if !collectStores(c.Body, names) {
+				return false
+			}
+		}
+		if stmt.Default != nil {
+			return collectStores(stmt.Default, names)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// hasCall reports whether evaluating the expressions of stmt itself, not counting nested
+// blocks, may call a function.
+func hasCall(stmt Statement) bool {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		return expressionHasCall(stmt.Value)
+	case *AssignStatement:
+		return expressionHasCall(stmt.Value)
+	case *PrintStatement:
+		return expressionHasCall(stmt.Value)
+	case *ExpressionStatement:
+		return expressionHasCall(stmt.Expression)
+	case *IfStatement:
+		return expressionHasCall(stmt.Condition)
+	case *WhileStatement:
+		return expressionHasCall(stmt.Condition)
+	case *ForStatement:
+		return expressionHasCall(stmt.Start) || expressionHasCall(stmt.End)
+	case *TypeSwitchStatement:
+		return expressionHasCall(stmt.Subject)
+	default:
+		return false
+	}
+}
+
+// expressionHasCall reports whether exp contains a call. The body of a burrito only runs
+// when it is called, so a function literal alone does not count.
+func expressionHasCall(exp Expression) bool {
+	switch exp := exp.(type) {
+	case *CallExpression:
+		return true
+	case *PrefixExpression:
+		return expressionHasCall(exp.Right)
+	case *TypeofExpression:
+		return expressionHasCall(exp.Right)
+	case *NuggetsExpression:
+		return expressionHasCall(exp.Value)
+	case *InfixExpression:
+		return expressionHasCall(exp.Left) || expressionHasCall(exp.Right)
+	case *IndexExpression:
+		return expressionHasCall(exp.Left) || expressionHasCall(exp.Index)
+	case *ArrayLiteral:
+		return anyHasCall(exp.Elements)
+	case *HashLiteral:
+		return anyHasCall(exp.Keys) || anyHasCall(exp.Values)
+	default:
+		return false
+	}
+}
+
+// anyHasCall reports whether any of exps contains a call.
+func anyHasCall(exps []Expression) bool {
+	for _, exp := range exps {
+		if expressionHasCall(exp) {
+			return true
+		}
+	}
+	return false
+}
+
+// clearConstants forgets everything known about every variable.
+func clearConstants(consts map[string]Object) {
+	for name := range consts {
+		delete(consts, name)
+	}
+}
+
+// updateConstant records the value an assignment leaves in its variable and returns it,
+// or forgets the variable and returns false if the value is not known.
+func updateConstant(consts map[string]Object, stmt *AssignStatement, value Expression) (Object, bool) {
+	name := stmt.Name.Value
+	result, ok := literalValue(value)
+	if !ok {
+		delete(consts, name)
+		return nil, false
+	}
+
+	if operator, compound := stmt.Operator(); compound {
+		current, known := consts[name]
+		if !known {
+			delete(consts, name)
+			return nil, false
+		}
+		tok := stmt.Token
+		tok.Type = operator
+		result = evalInfixExpression(tok, current, result)
+		if isError(result) {
+			delete(consts, name)
+			return nil, false
+		}
+	}
+
+	consts[name] = result
+	return result, true
+}
+
+// foldExpression returns exp with every constant subexpression replaced by its value.
+// Identifiers found in consts are substituted first; consts may be nil.
+// Operations that would fail at runtime, such as an overflowing apple, are left alone
+// so the error still happens when the program runs.
+func foldExpression(exp Expression, consts map[string]Object) Expression {
+	switch exp := exp.(type) {
+	case *Identifier:
+		if value, ok := consts[exp.Value]; ok {
+			return newLiteral(exp, value)
+		}
+		return exp
+	case *CallExpression:
+		// The function and its arguments are folded without substituting variables,
+		// since optimizeStatements cannot follow what the call changes.
+		call := &CallExpression{Token: exp.Token, Function: foldExpression(exp.Function, nil), Rparen: exp.Rparen}
+		for _, arg := range exp.Arguments {
+			call.Arguments = append(call.Arguments, foldExpression(arg, nil))
+		}
+		return call
+	case *PrefixExpression:
+		right := foldExpression(exp.Right, consts)
+		if obj, ok := literalValue(right); ok {
+			if lit := newLiteral(exp, evalPrefixExpression(exp.Token, obj)); lit != nil {
+				return lit
+			}
+		}
+		return &PrefixExpression{Token: exp.Token, Operator: exp.Operator, Right: right}
+	case *TypeofExpression:
+		right := foldExpression(exp.Right, consts)
+		if obj, ok := literalValue(right); ok {
+			return newLiteral(exp, &String{Value: typeName(obj)})
+		}
+		return &TypeofExpression{Token: exp.Token, Right: right}
+	case *NuggetsExpression:
+		value := foldExpression(exp.Value, consts)
+		if obj, ok := literalValue(value); ok {
+			if lit := newLiteral(exp, convertToNuggets(exp.Token, obj)); lit != nil {
+				return lit
+			}
+		}
+		return &NuggetsExpression{Token: exp.Token, Value: value, Rparen: exp.Rparen}
+	case *InfixExpression:
+		left := foldExpression(exp.Left, consts)
+		right := foldExpression(exp.Right, consts)
+		l, lok := literalValue(left)
+		r, rok := literalValue(right)
+		if lok && rok {
+			if lit := newLiteral(exp, evalInfixExpression(exp.Token, l, r)); lit != nil {
+				return lit
+			}
+		}
+		return &InfixExpression{Token: exp.Token, Left: left, Operator: exp.Operator, Right: right}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: exp.Token, Elements: foldExpressions(exp.Elements, consts), Rbracket: exp.Rbracket}
+	case *HashLiteral:
+		return &HashLiteral{Token: exp.Token, Keys: foldExpressions(exp.Keys, consts), Values: foldExpressions(exp.Values, consts), Rbrace: exp.Rbrace}
+	case *IndexExpression:
+		// Indexing can fail at runtime, so only the operands are folded.
+		return &IndexExpression{Token: exp.Token, Left: foldExpression(exp.Left, consts), Index: foldExpression(exp.Index, consts), Rbracket: exp.Rbracket}
+	default:
+		return exp
+	}
+}
+
+// foldExpressions folds every expression of a list.
+func foldExpressions(exps []Expression, consts map[string]Object) []Expression {
+	out := make([]Expression, len(exps))
+	for i, exp := range exps {
+		out[i] = foldExpression(exp, consts)
+	}
+	return out
+}
+
+// literalValue returns the runtime value of a literal expression.
+func literalValue(exp Expression) (Object, bool) {
+	switch exp := exp.(type) {
+	case *IntegralLiteral:
+		return &Integer{Value: exp.Value}, true
+	case *StringLiteral:
+		return &String{Value: exp.Value}, true
+	case *BooleanLiteral:
+		return nativeBoolToBooleanObject(exp.Value), true
+	default:
+		return nil, false
+	}
+}
+
+// newLiteral builds the literal expression for a folded value, keeping the position of
+// the node it replaces. It returns nil for values that have no literal form, such as
+// runtime errors, which must be left for the program to raise.
+func newLiteral(at Node, value Object) Expression {
+	tok := nodeToken(at)
+
+	switch value := value.(type) {
+	case *Integer:
+		tok.Type = TOKEN_INT
+		tok.Literal = strconv.FormatInt(value.Value, 10)
+		return &IntegralLiteral{Token: tok, Value: value.Value}
+	case *Boolean:
+		tok.Type = TOKEN_BROCCOLI
+		if value.Value {
+			tok.Type = TOKEN_CAKE
+		}
+		tok.Literal = value.Inspect()
+		return &BooleanLiteral{Token: tok, Value: value.Value}
+	case *String:
+		tok.Type = TOKEN_STRING
+		tok.Literal = value.Value
+		return &StringLiteral{Token: tok, Value: value.Value}
+	default:
+		return nil
+	}
+}
+
+// nodeToken returns the token a node was built from.
+func nodeToken(node Node) Token {
+	switch node := node.(type) {
+	case *Identifier:
+		return node.Token
+	case *IntegralLiteral:
+		return node.Token
+	case *BooleanLiteral:
+		return node.Token
+	case *StringLiteral:
+		return node.Token
+	case *PrefixExpression:
+		return node.Token
+	case *TypeofExpression:
+		return node.Token
+	case *NuggetsExpression:
+		return node.Token
+	case *InfixExpression:
+		return node.Token
+	default:
+		return Token{}
+	}
+}
+
+// eliminateDeadStores removes cheese bindings and assignments whose value is never read
+// afterwards. Only stores that cannot have an effect of their own are removed: the value
+// must be a literal or an identifier that is already bound, and an assignment must target
+// a bound name, so no icaco read and no runtime error disappears with it. Compound
+// assignments can overflow, so they are always kept, and so is any store into a nuggets
+// variable of something that is not an integer literal, since its type is checked.
+func eliminateDeadStores(stmts []Statement) []Statement {
+	// Work out which names are bound before each statement runs, and which of them were
+	// declared with a type.
+	bound := make([]map[string]bool, len(stmts))
+	typed := make([]map[string]bool, len(stmts))
+	declared := map[string]bool{}
+	annotated := map[string]bool{}
+	for i, stmt := range stmts {
+		bound[i] = copyNameSet(declared)
+		typed[i] = copyNameSet(annotated)
+		if let, ok := stmt.(*LetStatement); ok {
+			declared[let.Name.Value] = true
+			_, annotated[let.Name.Value] = let.AnnotatedType()
+		}
+	}
+
+	// Walk backwards tracking which names are still going to be read, and which names a
+	// later assignment needs to have been declared.
+	live := map[string]bool{}
+	needDecl := map[string]bool{}
+	everything := false // Set once an unknown statement might read any variable.
+	keep := make([]bool, len(stmts))
+
+	for i := len(stmts) - 1; i >= 0; i-- {
+		keep[i] = true
+
+		switch stmt := stmts[i].(type) {
+		case *LetStatement:
+			_, typedLet := stmt.AnnotatedType()
+			checked := typedLet && !isIntegerLiteral(stmt.Value)
+			if !everything && !checked && !live[stmt.Name.Value] && !needDecl[stmt.Name.Value] && isPureExpression(stmt.Value, bound[i]) {
+				keep[i] = false
+				continue
+			}
+			delete(live, stmt.Name.Value)
+			delete(needDecl, stmt.Name.Value)
+			if !collectUses(stmt.Value, live) {
+				everything = true
+			}
+		case *AssignStatement:
+			name := stmt.Name.Value
+			_, compound := stmt.Operator()
+			checked := typed[i][name] && !isIntegerLiteral(stmt.Value)
+			if !everything && !compound && !checked && !live[name] && bound[i][name] && isPureExpression(stmt.Value, bound[i]) {
+				keep[i] = false
+				continue
+			}
+			needDecl[name] = true
+			if !compound {
+				delete(live, name)
+			}
+			if !collectUses(stmt.Value, live) {
+				everything = true
+			}
+			if compound {
+				live[name] = true
+			}
+		case *PrintStatement:
+			if !collectUses(stmt.Value, live) {
+				everything = true
+			}
+		case *ExpressionStatement:
+			// Always kept: its value may be the result of the program.
+			if !collectUses(stmt.Expression, live) {
+				everything = true
+			}
+		case *BlockStatement, *IfStatement, *WhileStatement, *ForStatement, *TypeSwitchStatement:
+			// Always kept, and nothing is removed inside: the stores in a block may or may
+			// not run, so they never make an earlier store dead.
+			if !collectStatementUses(stmt, live, needDecl) {
+				everything = true
+			}
+		default:
+			everything = true
+		}
+	}
+
+	out := make([]Statement, 0, len(stmts))
+	for i, stmt := range stmts {
+		if keep[i] {
+			out = append(out, stmt)
+		}
+	}
+	return out
+}
+
+// isPureExpression reports whether evaluating exp can neither fail nor consume input.
+func isPureExpression(exp Expression, bound map[string]bool) bool {
+	switch exp := exp.(type) {
+	case *IntegralLiteral, *BooleanLiteral, *StringLiteral:
+		return true
+	case *Identifier:
+		return bound[exp.Value]
+	default:
+		return false
+	}
+}
+
+// isIntegerLiteral reports whether exp is an integer literal, which any nuggets variable
+// can hold.
+func isIntegerLiteral(exp Expression) bool {
+	_, ok := exp.(*IntegralLiteral)
+	return ok
+}
+
+// collectUses adds every identifier read by exp to live. It returns false if exp
+// contains something it does not understand.
+func collectUses(exp Expression, live map[string]bool) bool {
+	switch exp := exp.(type) {
+	case *IntegralLiteral, *BooleanLiteral, *StringLiteral, *InputExpression:
+		return true
+	case *Identifier:
+		live[exp.Value] = true
+		return true
+	case *PrefixExpression:
+		return collectUses(exp.Right, live)
+	case *TypeofExpression:
+		return collectUses(exp.Right, live)
+	case *NuggetsExpression:
+		return collectUses(exp.Value, live)
+	case *InfixExpression:
+		left := collectUses(exp.Left, live)
+		right := collectUses(exp.Right, live)
+		return left && right
+	case *IndexExpression:
+		left := collectUses(exp.Left, live)
+		index := collectUses(exp.Index, live)
+		return left && index
+	case *ArrayLiteral:
+		return collectAllUses(exp.Elements, live)
+	case *HashLiteral:
+		keys := collectAllUses(exp.Keys, live)
+		values := collectAllUses(exp.Values, live)
+		return keys && values
+	default:
+		return false
+	}
+}
+
+// collectAllUses adds every identifier read by any of exps to live.
+func collectAllUses(exps []Expression, live map[string]bool) bool {
+	ok := true
+	for _, exp := range exps {
+		if !collectUses(exp, live) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// collectStatementUses adds every identifier read anywhere in stmt to live, and every
+// name it assigns to needDecl. It returns false if stmt contains something it does not
+// understand.
+func collectStatementUses(stmt Statement, live, needDecl map[string]bool) bool {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		return collectUses(stmt.Value, live)
+	case *AssignStatement:
+		needDecl[stmt.Name.Value] = true
+		if _, compound := stmt.Operator(); compound {
+			live[stmt.Name.Value] = true
+		}
+		return collectUses(stmt.Value, live)
+	case *PrintStatement:
+		return collectUses(stmt.Value, live)
+	case *ExpressionStatement:
+		return collectUses(stmt.Expression, live)
+	case *BlockStatement:
+		for _, inner := range stmt.Statements {
+			if !collectStatementUses(inner, live, needDecl) {
+				return false
+			}
+		}
+		return true
+	case *IfStatement:
+		if !collectUses(stmt.Condition, live) || !collectStatementUses(stmt.Consequence, live, needDecl) {
+			return false
+		}
+		if stmt.Alternative != nil {
+			return collectStatementUses(stmt.Alternative, live, needDecl)
+		}
+		return true
+	case *WhileStatement:
+		return collectUses(stmt.Condition, live) && collectStatementUses(stmt.Body, live, needDecl)
+	case *ForStatement:
+		return collectUses(stmt.Start, live) && collectUses(stmt.End, live) && collectStatementUses(stmt.Body, live, needDecl)
+	case *TypeSwitchStatement:
+		if !collectUses(stmt.Subject, live) {
+			return false
+		}
+		for _, c := range stmt.Cases {
+			if !collectStatementUses(c.Body, live, needDecl) {
+				return false
+			}
+		}
+		if stmt.Default != nil {
+			return collectStatementUses(stmt.Default, live, needDecl)
+		}
+		return true
+	case *LoopControlStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// copyNameSet returns a shallow copy of a set of names.
+func copyNameSet(names map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(names))
+	for name := range names {
+		out[name] = true
+	}
+	return out
+}
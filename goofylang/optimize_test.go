@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		level    int
+		expected string
+	}{
+		{"cheese x = 2 apple 3;", OptNone, "cheese x = (2 apple 3);"},
+		{"cheese x = 2 apple 3;", OptFold, "cheese x = 5;"},
+		{"cheese x = 10 - 2 - salmon 1; pizza x;", OptFold, "cheese x = 9;pizza x;"},
+		{"cheese x = 2; pizza x + 1;", OptFold, "cheese x = 2;pizza (x + 1);"},
+		{"cheese x = 2; pizza x + 1;", OptFull, "pizza 3;"},
+		{"cheese x = 1; cheese x = 2; cheese y = x; pizza y;", OptFull, "pizza 2;"},
+		{"cheese x = icaco; cheese y = x; pizza x;", OptFull, "cheese x = icaco;pizza x;"},
+		{"cheese x = icaco; cheese x = 1;", OptFull, "cheese x = icaco;"},
+		{"cheese x = 1; x += 2; x salmon= 1; pizza x;", OptFull, "pizza 2;"},
+		{"cheese x = icaco; x = 3; x = 4; pizza x;", OptFull, "cheese x = icaco;pizza 4;"},
+		{"x = 3;", OptFull, "x = 3;"},
+		{"pizza 2 + 3 * 4;", OptFold, "pizza 14;"},
+		{"pizza 1 / 0;", OptFull, "pizza (1 / 0);"},
+		// Overflow and undefined identifiers must still fail at runtime.
+		{"cheese x = icaco; cheese y = x + 1; pizza x;", OptFull, "cheese x = icaco;cheese y = (x + 1);pizza x;"},
+		{"cheese x = 9223372036854775807 + 1;", OptFull, "cheese x = (9223372036854775807 + 1);"},
+		{"cheese x = nope;", OptFull, "cheese x = nope;"},
+		{"pizza 1 + 2 < 4;", OptFold, "pizza cake;"},
+		{"pizza !(3 == 3) != broccoli;", OptFold, "pizza broccoli;"},
+		{"cheese b = 2 >= 3; cheese c = !b; pizza c;", OptFull, "pizza cake;"},
+		{"cheese b = cake; b == broccoli;", OptFull, "broccoli;"},
+		// Type errors are left for the program to raise.
+		{"pizza 1 + cake;", OptFull, "pizza (1 + cake);"},
+		{"pizza -broccoli;", OptFull, "pizza (-broccoli);"},
+		{`cheese s = "a" apple "b"; pizza s + "\n" == "ab\n";`, OptFull, "pizza cake;"},
+		{`pizza "a" - "b";`, OptFull, `pizza ("a" - "b");`},
+		{"waffles 1 < 2 { pizza 1 + 1; } fries { pizza 3; }", OptFold, "{pizza 2;}"},
+		{"waffles 0 { pizza 1; }", OptFold, ""},
+		{"waffles broccoli { pizza 1; } fries waffles icaco { pizza 2; }", OptFold, "waffles icaco {pizza 2;}"},
+		{"cheese x = 5; waffles icaco { x = 1; } pizza x;", OptFull, "cheese x = 5;waffles icaco {x = 1;}pizza x;"},
+		{"cheese x = 5; cheese y = 2; waffles icaco { pizza x; } fries { cheese y = 3; } pizza x + y;", OptFull, "cheese y = 2;waffles icaco {pizza 5;} fries {cheese y = 3;}pizza (5 + y);"},
+		{"noodles 2 < 1 { pizza 1; }", OptFold, ""},
+		{"cheese x = 5; noodles x { x = x - 1; } pizza x;", OptFull, "cheese x = 5;noodles x {x = (x - 1);}pizza x;"},
+		{"cheese n = 3; donuts i = 0, n { pizza i + n; }", OptFull, "donuts i = 0, 3 {pizza (i + 3);}"},
+		{"cheese i = 7; donuts i = 0, 2 { pizza i; } pizza i;", OptFull, "cheese i = 7;donuts i = 0, 2 {pizza i;}pizza 7;"},
+		{"cheese x = 1; noodles cake { x = 2; dessert; } pizza x;", OptFull, "cheese x = 1;noodles cake {x = 2;dessert;}pizza x;"},
+		// A call may change any variable its burrito can see.
+		{"cheese x = 1; cheese f = burrito() { x = 2; }; f(); pizza x;", OptFull, "cheese x = 1;cheese f = burrito() {x = 2;};f();pizza x;"},
+		{"cheese x = 1; cheese f = burrito() { x = 2; takeout 0; }; pizza f() + x;", OptFull, "cheese x = 1;cheese f = burrito() {x = 2;takeout 0;};pizza (f() + x);"},
+		{"cheese x = 1; pizza x + 1; { f(2 + 3); } pizza x;", OptFull, "cheese x = 1;pizza 2;{f(5);}pizza x;"},
+		{"cheese x = 2; cheese a = [x, x * 3]; pizza a[x - 2];", OptFull, "cheese a = [2, 6];pizza (a[0]);"},
+		{`cheese k = "a"; pizza {k: 1 + 1}[k];`, OptFull, `pizza ({"a": 2}["a"]);`},
+		{"cheese x = 1; cheese a = [f(), x]; pizza x;", OptFull, "cheese x = 1;cheese a = [f(), x];pizza x;"},
+		{"cheese x = 1; pizza tacos x;", OptFull, `pizza "nuggets";`},
+		{"cheese x = 1; tacos x { nuggets { pizza x + 1; } fries { pizza 0; } }", OptFull, "{pizza 2;}"},
+		{"tacos cake { nuggets { pizza 1; } }", OptFold, ""},
+		{"cheese x = 1; cheese y = 2; tacos icaco { nuggets { x = 5; } fries { pizza y; } } pizza x + y;", OptFull, "cheese x = 1;tacos icaco {nuggets {x = 5;}fries {pizza 2;}}pizza (x + 2);"},
+		{`pizza nuggets("4") * 2;`, OptFold, "pizza 8;"},
+		{`pizza nuggets("four");`, OptFull, `pizza nuggets("four");`},
+		// Stores into a nuggets variable are type checked, so they stay unless they are integers.
+		{`cheese nuggets x = "a"; cheese nuggets y = 2;`, OptFull, `cheese nuggets x = "a";`},
+		{"cheese nuggets x = 1; cheese y = cake; x = y; x = 3;", OptFull, "cheese nuggets x = 1;x = cake;"},
+		{"cheese x = 1; tacos f() { nuggets { } } pizza x;", OptFull, "cheese x = 1;tacos f() {nuggets {}}pizza x;"},
+	}
+
+	for _, tt := range tests {
+		l := NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		before := program.String()
+		optimized := Optimize(program, tt.level)
+		if optimized.String() != tt.expected {
+			t.Errorf("%q at -O%d: expected=%q, got=%q", tt.input, tt.level, tt.expected, optimized.String())
+		}
+		if program.String() != before {
+			t.Errorf("%q at -O%d: the input program was modified", tt.input, tt.level)
+		}
+	}
+}
+
+// TestOptimizeDifferential runs random programs with and without optimization and checks
+// that they print the same thing and fail with the same error.
+func TestOptimizeDifferential(t *testing.T) {
+	rng := rand.New(rand.NewSource(28))
+	stdin := "3 -7 9223372036854775807 12 0 -1 5 8 100 42"
+
+	for i := 0; i < 500; i++ {
+		input := randomProgram(rng)
+
+		l := NewLexer(input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		wantOut, wantResult := runProgram(program, stdin)
+		for _, level := range []int{OptFold, OptFull} {
+			gotOut, gotResult := runProgram(Optimize(program, level), stdin)
+			if gotOut != wantOut || describeResult(gotResult) != describeResult(wantResult) {
+				t.Fatalf("-O%d changed behaviour of %q\nexpected output=%q result=%s\ngot output=%q result=%s",
+					level, input, wantOut, describeResult(wantResult), gotOut, describeResult(gotResult))
+			}
+		}
+	}
+}
+
+func runProgram(program *Program, stdin string) (string, Object) {
+	var out bytes.Buffer
+	result := NewEvaluator(strings.NewReader(stdin), &out).Eval(program, NewEnvironment())
+	return out.String(), result
+}
+
+func describeResult(obj Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
+
+// randomProgram builds a short goofylang program over a handful of names.
+func randomProgram(rng *rand.Rand) string {
+	names := []string{"a", "b", "c", "d"}
+	var out strings.Builder
+
+	var expr func(depth int) string
+	expr = func(depth int) string {
+		switch n := rng.Intn(12); {
+		case depth > 2 || n < 3:
+			return fmt.Sprint(rng.Intn(20))
+		case n < 6:
+			return names[rng.Intn(len(names))]
+		case n < 7:
+			return "icaco"
+		case n < 8:
+			return "salmon " + expr(depth+1)
+		case n < 9:
+			return "!" + expr(depth+1)
+		case n < 10:
+			return []string{"cake", "broccoli"}[rng.Intn(2)]
+		default:
+			ops := []string{"+", "-", "apple", "salmon", "*", "/", "%", "pancakes", "pie", "leftovers", "==", "!=", "<", ">", "<=", ">="}
+			op := ops[rng.Intn(len(ops))]
+			if rng.Intn(2) == 0 {
+				return "(" + expr(depth+1) + " " + op + " " + expr(depth+1) + ")"
+			}
+			return expr(depth+1) + " " + op + " " + expr(depth+1)
+		}
+	}
+
+	var block func(depth, n int, loop bool)
+	block = func(depth, n int, loop bool) {
+		indent := strings.Repeat("    ", depth)
+		for ; n > 0; n-- {
+			name := names[rng.Intn(len(names))]
+			switch k := rng.Intn(8); {
+			case k < 2:
+				fmt.Fprintf(&out, "%spizza %s;\n", indent, expr(0))
+			case k == 2:
+				fmt.Fprintf(&out, "%s%s = %s;\n", indent, name, expr(0))
+			case k == 5:
+				fmt.Fprintf(&out, "%s%s;\n", indent, expr(0))
+			case k == 3:
+				op := []string{"+=", "-=", "apple=", "salmon=", "*=", "pie=", "%="}[rng.Intn(7)]
+				fmt.Fprintf(&out, "%s%s %s %s;\n", indent, name, op, expr(0))
+			case k == 7 && depth < 2:
+				fmt.Fprintf(&out, "%swaffles %s {\n", indent, expr(0))
+				block(depth+1, rng.Intn(3), loop)
+				if rng.Intn(2) == 0 {
+					fmt.Fprintf(&out, "%s} fries {\n", indent)
+					block(depth+1, rng.Intn(3), loop)
+				}
+				fmt.Fprintf(&out, "%s}\n", indent)
+			case k == 6 && depth < 2 && rng.Intn(2) == 0:
+				// Literal bounds keep every loop short.
+				fmt.Fprintf(&out, "%sdonuts %s = %d, %d {\n", indent, name, rng.Intn(3)-1, rng.Intn(4))
+				block(depth+1, rng.Intn(4), true)
+				fmt.Fprintf(&out, "%s}\n", indent)
+			case k == 4 && loop && rng.Intn(2) == 0:
+				fmt.Fprintf(&out, "%s%s;\n", indent, []string{"dessert", "seconds"}[rng.Intn(2)])
+			default:
+				fmt.Fprintf(&out, "%scheese %s = %s;\n", indent, name, expr(0))
+			}
+		}
+	}
+	block(0, rng.Intn(8)+1, false)
+
+	return out.String()
+}
@@ -35,4 +35,347 @@ func checkParserErrors(t *testing.T, p *Parser) {
         t.Errorf("parser error: %q", msg)
     }
     t.FailNow()
-}
\ No newline at end of file
+}
+
+func TestOperatorPrecedenceParsing(t *testing.T) {
+    tests := []struct {
+        input    string
+        expected string
+    }{
+        {"cheese a = x + y;", "cheese a = (x + y);"},
+        {"cheese a = x apple y salmon z;", "cheese a = ((x apple y) salmon z);"},
+        {"cheese a = -x + 5;", "cheese a = ((-x) + 5);"},
+        {"cheese a = icaco - 1;", "cheese a = (icaco - 1);"},
+        {"pizza x + 1;", "pizza (x + 1);"},
+        {"(x + y) - z;", "((x + y) - z);"},
+        {"x - (y - z);", "(x - (y - z));"},
+        {"-(x apple y);", "(-(x apple y));"},
+        {"((1));", "1;"},
+        {"x + y * z;", "(x + (y * z));"},
+        {"x pancakes y apple z;", "((x pancakes y) apple z);"},
+        {"a / b % c * d;", "(((a / b) % c) * d);"},
+        {"(a - b) pie -c;", "((a - b) pie (-c));"},
+        {"a + b < c == d > e;", "(((a + b) < c) == (d > e));"},
+        {"a <= b != c >= d;", "((a <= b) != (c >= d));"},
+        {"!cake == broccoli;", "((!cake) == broccoli);"},
+        {"cheese t = -a * b >= 3;", "cheese t = (((-a) * b) >= 3);"},
+        {"a * [1, 2, 3, 4][b * c] * d;", "((a * ([1, 2, 3, 4][(b * c)])) * d);"},
+        {"add(a * b[2], b[1], 2 * [1, 2][1]);", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])));"},
+        {"-a[0];", "(-(a[0]));"},
+        {"f(x)[0][1];", "((f(x)[0])[1]);"},
+    }
+
+    for _, tt := range tests {
+        l := NewLexer(tt.input)
+        p := NewParser(l)
+        program := p.ParseProgram()
+        checkParserErrors(t, p)
+
+        if program.String() != tt.expected {
+            t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+        }
+    }
+}
+
+func TestExpressionStatements(t *testing.T) {
+    input := `x + 1; 5; icaco;;`
+
+    l := NewLexer(input)
+    p := NewParser(l)
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    expected := []string{"(x + 1);", "5;", "icaco;"}
+    if len(program.Statements) != len(expected) {
+        t.Fatalf("program.Statements does not contain %d statements. got=%d", len(expected), len(program.Statements))
+    }
+    for i, want := range expected {
+        stmt, ok := program.Statements[i].(*ExpressionStatement)
+        if !ok {
+            t.Fatalf("program.Statements[%d] is not *ExpressionStatement. got=%T", i, program.Statements[i])
+        }
+        if stmt.String() != want {
+            t.Errorf("program.Statements[%d] expected=%q, got=%q", i, want, stmt.String())
+        }
+    }
+}
+
+func TestParserReportsBadStatements(t *testing.T) {
+    tests := []string{"= 5;", "(1 + 2;", "cheese x = (;", "waffles x { pizza x;", "waffles x pizza x;", "waffles x {} fries pizza x;", "dessert;", "waffles x { seconds; }", "donuts i = 0 { }", "donuts = 0, 1 { }", "noodles x pizza x;", "takeout 1;", "burrito(x { }", "burrito(1) { }", "f(1, 2;", "noodles cake { burrito() { dessert; }; }", "pizza \"open;", "pizza \"bad\\q\";", "pizza [1, 2;", "pizza a[1;", "pizza {1 2};", "pizza {1: 2 3: 4};", "pizza {1: 2;", "cheese nuggets = 5;", "cheese nuggets nuggets x = 5;", "pizza nuggets 5;", "pizza nuggets(1;", "cheese x = 99999999999999999999;"}
+
+    for _, input := range tests {
+        l := NewLexer(input)
+        p := NewParser(l)
+        p.ParseProgram()
+
+        if len(p.Errors()) == 0 {
+            t.Errorf("expected a parser error for %q", input)
+        }
+    }
+}
+
+func TestAssignStatements(t *testing.T) {
+    tests := []struct {
+        input    string
+        name     string
+        operator TokenType
+        expected string
+    }{
+        {"x = x apple 1;", "x", TOKEN_ENCHILADA, "x = (x apple 1);"},
+        {"y += 2;", "y", TOKEN_APPLE_ENCHILADA, "y += 2;"},
+        {"z salmon= icaco;", "z", TOKEN_SALMON_ENCHILADA, "z salmon= icaco;"},
+    }
+
+    for _, tt := range tests {
+        l := NewLexer(tt.input)
+        p := NewParser(l)
+        program := p.ParseProgram()
+        checkParserErrors(t, p)
+
+        if len(program.Statements) != 1 {
+            t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+        }
+
+        stmt, ok := program.Statements[0].(*AssignStatement)
+        if !ok {
+            t.Fatalf("program.Statements[0] is not *AssignStatement. got=%T", program.Statements[0])
+        }
+        if stmt.Name.Value != tt.name {
+            t.Errorf("stmt.Name.Value not %q. got=%q", tt.name, stmt.Name.Value)
+        }
+        if stmt.Token.Type != tt.operator {
+            t.Errorf("stmt.Token.Type not %s. got=%s", tt.operator.String(), stmt.Token.Type.String())
+        }
+        if program.String() != tt.expected {
+            t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+        }
+    }
+}
+
+func TestIfStatements(t *testing.T) {
+    tests := []struct {
+        input    string
+        expected string
+    }{
+        {"waffles x < y { pizza x; }", "waffles (x < y) {pizza x;}"},
+        {"waffles x { pizza 1; } fries { pizza 2; } pizza 3;", "waffles x {pizza 1;} fries {pizza 2;}pizza 3;"},
+        {"waffles a { } fries waffles b { b; } fries { cheese c = 1; }", "waffles a {} fries waffles b {b;} fries {cheese c = 1;}"},
+        {"waffles (cake) { waffles broccoli { x = 1; } }", "waffles cake {waffles broccoli {x = 1;}}"},
+        {"{ cheese x = 1; { pizza x; } }", "{cheese x = 1;{pizza x;}}"},
+    }
+
+    for _, tt := range tests {
+        l := NewLexer(tt.input)
+        p := NewParser(l)
+        program := p.ParseProgram()
+        checkParserErrors(t, p)
+
+        if program.String() != tt.expected {
+            t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+        }
+    }
+
+    l := NewLexer("waffles x > 1 { pizza x; } fries waffles x { pizza 0; }")
+    p := NewParser(l)
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    stmt, ok := program.Statements[0].(*IfStatement)
+    if !ok {
+        t.Fatalf("program.Statements[0] is not *IfStatement. got=%T", program.Statements[0])
+    }
+    if _, ok := stmt.Alternative.(*IfStatement); !ok {
+        t.Errorf("a chained fries waffles should be an *IfStatement. got=%T", stmt.Alternative)
+    }
+}
+
+func TestLoopStatements(t *testing.T) {
+    tests := []struct {
+        input    string
+        expected string
+    }{
+        {"noodles x < 10 { x += 1; }", "noodles (x < 10) {x += 1;}"},
+        {"donuts i = 0, n + 1 { pizza i; }", "donuts i = 0, (n + 1) {pizza i;}"},
+        {"noodles cake { waffles x { dessert; } fries { seconds } }", "noodles cake {waffles x {dessert;} fries {seconds;}}"},
+        {"donuts i = 0, 3 { noodles i { dessert; } seconds; }", "donuts i = 0, 3 {noodles i {dessert;}seconds;}"},
+    }
+
+    for _, tt := range tests {
+        l := NewLexer(tt.input)
+        p := NewParser(l)
+        program := p.ParseProgram()
+        checkParserErrors(t, p)
+
+        if program.String() != tt.expected {
+            t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+        }
+    }
+}
+
+func TestFunctionsAndCalls(t *testing.T) {
+    tests := []struct {
+        input    string
+        expected string
+    }{
+        {"cheese add = burrito(a, b) { takeout a + b; };", "cheese add = burrito(a, b) {takeout (a + b);};"},
+        {"burrito() { takeout; };", "burrito() {takeout;};"},
+        {"burrito(x) { takeout x }(1);", "burrito(x) {takeout x;}(1);"},
+        {"a + add(b * c, 2) + d;", "((a + add((b * c), 2)) + d);"},
+        {"-f(1) * g();", "((-f(1)) * g());"},
+        {"counter()();", "counter()();"},
+        {"pizza f(g(1), burrito() { });", "pizza f(g(1), burrito() {});"},
+        {"donuts i = 0, 3 { cheese f = burrito() { noodles cake { dessert; } takeout i; }; }", "donuts i = 0, 3 {cheese f = burrito() {noodles cake {dessert;}takeout i;};}"},
+        {`pizza "a\"b" apple "\tc\n";`, `pizza ("a\"b" apple "\tc\n");`},
+    }
+
+    for _, tt := range tests {
+        l := NewLexer(tt.input)
+        p := NewParser(l)
+        program := p.ParseProgram()
+        checkParserErrors(t, p)
+
+        if program.String() != tt.expected {
+            t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+        }
+    }
+
+    l := NewLexer("add(1, 2 + 3);")
+    p := NewParser(l)
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    stmt := program.Statements[0].(*ExpressionStatement)
+    call, ok := stmt.Expression.(*CallExpression)
+    if !ok {
+        t.Fatalf("stmt.Expression is not *CallExpression. got=%T", stmt.Expression)
+    }
+    if call.Function.String() != "add" || len(call.Arguments) != 2 {
+        t.Errorf("wrong call. got function=%q with %d arguments", call.Function.String(), len(call.Arguments))
+    }
+    if call.Token.Line != 1 || call.Token.Column != 4 {
+        t.Errorf("call should be positioned at its (. got=%d:%d", call.Token.Line, call.Token.Column)
+    }
+}
+
+func TestArraysAndHashes(t *testing.T) {
+    tests := []struct {
+        input    string
+        expected string
+    }{
+        {"cheese a = [];", "cheese a = [];"},
+        {"cheese a = [1, 2 * 2, \"x\" apple \"y\"];", "cheese a = [1, (2 * 2), (\"x\" apple \"y\")];"},
+        {"cheese h = {};", "cheese h = {};"},
+        {"cheese h = {\"one\": 1, 2: 1 + 1, cake: [3]};", "cheese h = {\"one\": 1, 2: (1 + 1), cake: [3]};"},
+        {"pizza {\"k\": 1}[\"k\"];", "pizza ({\"k\": 1}[\"k\"]);"},
+        {"{ pizza 1; }", "{pizza 1;}"},
+        {"({1: 2});", "{1: 2};"},
+    }
+
+    for _, tt := range tests {
+        l := NewLexer(tt.input)
+        p := NewParser(l)
+        program := p.ParseProgram()
+        checkParserErrors(t, p)
+
+        if program.String() != tt.expected {
+            t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+        }
+    }
+
+    l := NewLexer("pizza {\"a\": 1, \"b\": 2};")
+    p := NewParser(l)
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    hash, ok := program.Statements[0].(*PrintStatement).Value.(*HashLiteral)
+    if !ok {
+        t.Fatalf("value is not *HashLiteral. got=%T", program.Statements[0].(*PrintStatement).Value)
+    }
+    if len(hash.Keys) != 2 || len(hash.Values) != 2 || hash.Keys[1].String() != "\"b\"" {
+        t.Errorf("wrong hash entries. got=%s", hash.String())
+    }
+}
+
+func TestTacos(t *testing.T) {
+    tests := []struct {
+        input    string
+        expected string
+    }{
+        {"pizza tacos x;", "pizza (tacos x);"},
+        {"tacos x == \"nuggets\";", "((tacos x) == \"nuggets\");"},
+        {"pizza tacos a[0] apple \"!\";", "pizza ((tacos (a[0])) apple \"!\");"},
+        {"pizza tacos -x;", "pizza (tacos (-x));"},
+        {"tacos x { nuggets { pizza x; } string, array { pizza len(x); } fries { pizza 0; } }", "tacos x {nuggets {pizza x;}string, array {pizza len(x);}fries {pizza 0;}}"},
+        {"tacos f(1) { }", "tacos f(1) {}"},
+        {"noodles cake { tacos x { nothing { dessert; } } }", "noodles cake {tacos x {nothing {dessert;}}}"},
+    }
+
+    for _, tt := range tests {
+        l := NewLexer(tt.input)
+        p := NewParser(l)
+        program := p.ParseProgram()
+        checkParserErrors(t, p)
+
+        if program.String() != tt.expected {
+            t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+        }
+    }
+
+    bad := []struct {
+        input    string
+        expected string
+    }{
+        {"tacos x { pasta { } }", "unknown type name in tacos: pasta"},
+        {"tacos x { nuggets { } string, nuggets { } }", "duplicate tacos case: nuggets"},
+        {"tacos x { fries { } fries { } }", "tacos has more than one fries case"},
+        {"tacos x { 1 { } }", "expected a type name in tacos, got TOKEN_INT instead"},
+        {"tacos x { nuggets pizza x; }", "expected next token to be TOKEN_LBRACE, got TOKEN_PIZZA instead"},
+        {"tacos x { dessert; }", "expected a type name in tacos, got TOKEN_DESSERT instead"},
+    }
+
+    for _, tt := range bad {
+        l := NewLexer(tt.input)
+        p := NewParser(l)
+        p.ParseProgram()
+
+        if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+            t.Errorf("%q: expected error %q, got=%q", tt.input, tt.expected, p.Errors())
+        }
+    }
+}
+
+func TestNuggets(t *testing.T) {
+    tests := []struct {
+        input    string
+        expected string
+    }{
+        {"cheese nuggets x = 5;", "cheese nuggets x = 5;"},
+        {"cheese nuggets n = nuggets(\"42\") + 1;", "cheese nuggets n = (nuggets(\"42\") + 1);"},
+        {"pizza -nuggets(cake) * 2;", "pizza ((-nuggets(cake)) * 2);"},
+        {"pizza nuggets(a[0])[1];", "pizza (nuggets((a[0]))[1]);"},
+    }
+
+    for _, tt := range tests {
+        l := NewLexer(tt.input)
+        p := NewParser(l)
+        program := p.ParseProgram()
+        checkParserErrors(t, p)
+
+        if program.String() != tt.expected {
+            t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+        }
+    }
+
+    l := NewLexer("cheese nuggets x = 1;")
+    p := NewParser(l)
+    program := p.ParseProgram()
+    checkParserErrors(t, p)
+
+    let := program.Statements[0].(*LetStatement)
+    if typ, ok := let.AnnotatedType(); !ok || typ != INTEGER_OBJ {
+        t.Errorf("expected a nuggets annotation. got=%q, %t", typ, ok)
+    }
+    if let.Annotation.Line != 1 || let.Annotation.Column != 8 {
+        t.Errorf("annotation should be positioned at nuggets. got=%d:%d", let.Annotation.Line, let.Annotation.Column)
+    }
+}
@@ -0,0 +1,286 @@
+package main
+
+import "fmt"
+
+// The resolver runs after parsing and binds every identifier to the declaration it refers
+// to. It mirrors the scopes the evaluator creates, one for every block, one for the
+// variable of each donuts loop and one for the parameters and body of each call, and
+// numbers the bindings of each scope in the order they are first declared, the way
+// Environment does. Each identifier is then annotated with how many scopes out its
+// binding is and its slot there, so the evaluator can find it without a name lookup.
+//
+// A name must be declared before it is used, except inside a burrito: the burrito runs
+// when it is called, so it may use a name its enclosing scopes declare after it, as two
+// burritos calling each other do. Calling it before the name is declared is then an
+// error at runtime. Names that are not declared anywhere are errors, and
+// so is declaring a name twice in one scope, whether with cheese or as a parameter. The
+// bindings of the environment a program runs in can be declared again, as the REPL does
+// when a line redefines a name.
+
+// resolveScope is one scope as the resolver sees it.
+type resolveScope struct {
+	slots    map[string]int         // The slot every name declared anywhere in the scope gets.
+	declared map[string]*Identifier // The latest declaration of each name declared so far.
+	planned  map[string]*Identifier // The first declaration of each name declared anywhere in the scope.
+	outer    *resolveScope
+	function bool // Whether this is the scope of a burrito's parameters and body.
+}
+
+func newResolveScope(outer *resolveScope, function bool) *resolveScope {
+	return &resolveScope{
+		slots:    map[string]int{},
+		declared: map[string]*Identifier{},
+		planned:  map[string]*Identifier{},
+		outer:    outer,
+		function: function,
+	}
+}
+
+// plan gives a slot to every name the statements declare directly in the scope.
+func (s *resolveScope) plan(stmts []Statement) {
+	for _, stmt := range stmts {
+		if let, ok := stmt.(*LetStatement); ok {
+			s.add(let.Name.Value)
+			if _, ok := s.planned[let.Name.Value]; !ok {
+				s.planned[let.Name.Value] = let.Name
+			}
+		}
+	}
+}
+
+// add gives name the next slot, unless it already has one.
+func (s *resolveScope) add(name string) int {
+	if slot, ok := s.slots[name]; ok {
+		return slot
+	}
+	s.slots[name] = len(s.slots)
+	return s.slots[name]
+}
+
+// Resolver binds the identifiers of a program to their declarations.
+type Resolver struct {
+	scope       *resolveScope
+	diagnostics []Diagnostic
+	definitions map[*Identifier]*Identifier // The declaration each resolved identifier refers to.
+	shadows     map[*Identifier]*Identifier // The declaration each declaration hides in an enclosing scope.
+}
+
+// NewResolver creates a Resolver for a program that will run in env, whose bindings the
+// program can use. A nil env stands for a new, empty environment.
+func NewResolver(env *Environment) *Resolver {
+	top := newResolveScope(nil, false)
+	if env != nil {
+		for _, name := range env.Names() {
+			top.add(name)
+			// The binding was not declared in this program, so it has no declaration.
+			top.declared[name] = &Identifier{Value: name}
+		}
+	}
+	return &Resolver{
+		scope:       top,
+		definitions: map[*Identifier]*Identifier{},
+		shadows:     map[*Identifier]*Identifier{},
+	}
+}
+
+// Definition returns the declaration an identifier was resolved to: the name of a
+// cheese statement, a parameter or the variable of a donuts loop. A declaration is its
+// own definition.
+func (r *Resolver) Definition(ident *Identifier) (*Identifier, bool) {
+	def, ok := r.definitions[ident]
+	return def, ok && def.Token.Line != 0
+}
+
+// Shadowed returns the declaration in an enclosing scope that decl hides, if there is one.
+// A builtin, or a binding of the environment the program runs in, has no declaration in
+// the program, so it is returned as an identifier without a position.
+func (r *Resolver) Shadowed(decl *Identifier) (*Identifier, bool) {
+	outer, ok := r.shadows[decl]
+	return outer, ok
+}
+
+// Resolve annotates every identifier of the program and returns the errors found, which
+// Errors also returns formatted. Identifiers that cannot be resolved are left for the
+// evaluator to look up by name.
+func (r *Resolver) Resolve(program *Program) []Diagnostic {
+	r.scope.plan(program.Statements)
+	r.resolveStatements(program.Statements)
+	return r.diagnostics
+}
+
+// Errors returns every error found, formatted like the ones Checker.Errors returns.
+func (r *Resolver) Errors() []string {
+	errs := make([]string, len(r.diagnostics))
+	for i, d := range r.diagnostics {
+		errs[i] = d.String()
+	}
+	return errs
+}
+
+func (r *Resolver) errorAt(node Node, format string, a ...interface{}) {
+	r.diagnostics = append(r.diagnostics, Diagnostic{Span: spanOf(node), Message: fmt.Sprintf(format, a...)})
+}
+
+// enter opens a scope nested in the current one.
+func (r *Resolver) enter(function bool) {
+	r.scope = newResolveScope(r.scope, function)
+}
+
+// leave closes the scope opened by the matching enter.
+func (r *Resolver) leave() {
+	r.scope = r.scope.outer
+}
+
+// declare binds ident in the current scope, reporting an error if the program already
+// declared it there. kind says what ident is, for the error.
+func (r *Resolver) declare(ident *Identifier, kind string) {
+	if prev, redeclared := r.scope.declared[ident.Value]; redeclared {
+		if prev.Token.Line != 0 {
+			r.errorAt(ident, "duplicate %s: %s", kind, ident.Value)
+		}
+	} else {
+		if outer, _, _, ok := r.find(ident.Value, r.scope.outer, r.scope.function); ok {
+			r.shadows[ident] = outer
+		} else if _, ok := builtins[ident.Value]; ok {
+			r.shadows[ident] = &Identifier{Value: ident.Value}
+		}
+	}
+	ident.Resolved, ident.Depth, ident.Slot = true, 0, r.scope.add(ident.Value)
+	r.scope.declared[ident.Value] = ident
+	r.definitions[ident] = ident
+}
+
+// lookup finds the binding ident refers to and annotates it, reporting whether there is
+// one.
+func (r *Resolver) lookup(ident *Identifier) bool {
+	def, depth, slot, ok := r.find(ident.Value, r.scope, false)
+	if !ok {
+		ident.Resolved = false
+		return false
+	}
+	ident.Resolved, ident.Depth, ident.Slot = true, depth, slot
+	r.definitions[ident] = def
+	return true
+}
+
+// find looks name up from the scope from outwards and returns the declaration it refers
+// to, how many scopes out from it that is, and its slot. crossed tells whether the lookup
+// starts inside a burrito that from encloses.
+func (r *Resolver) find(name string, from *resolveScope, crossed bool) (*Identifier, int, int, bool) {
+	depth := 0
+	for s := from; s != nil; s = s.outer {
+		if def, ok := s.declared[name]; ok {
+			return def, depth, s.slots[name], true
+		}
+		if def, ok := s.planned[name]; ok && crossed {
+			return def, depth, s.slots[name], true
+		}
+		crossed = crossed || s.function
+		depth++
+	}
+	return nil, 0, 0, false
+}
+
+func (r *Resolver) resolveStatements(stmts []Statement) {
+	for _, stmt := range stmts {
+		r.resolveStatement(stmt)
+	}
+}
+
+func (r *Resolver) resolveBlock(block *BlockStatement) {
+	if block == nil {
+		return
+	}
+	r.enter(false)
+	r.scope.plan(block.Statements)
+	r.resolveStatements(block.Statements)
+	r.leave()
+}
+
+func (r *Resolver) resolveStatement(stmt Statement) {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		r.resolveExpression(stmt.Value)
+		r.declare(stmt.Name, "declaration")
+	case *AssignStatement:
+		r.resolveExpression(stmt.Value)
+		if !r.lookup(stmt.Name) {
+			r.errorAt(stmt.Name, "cannot assign to undeclared identifier: %s", stmt.Name.Value)
+		}
+	case *ExpressionStatement:
+		r.resolveExpression(stmt.Expression)
+	case *PrintStatement:
+		r.resolveExpression(stmt.Value)
+	case *ReturnStatement:
+		r.resolveExpression(stmt.Value)
+	case *BlockStatement:
+		r.resolveBlock(stmt)
+	case *IfStatement:
+		r.resolveExpression(stmt.Condition)
+		r.resolveBlock(stmt.Consequence)
+		if stmt.Alternative != nil {
+			r.resolveStatement(stmt.Alternative)
+		}
+	case *WhileStatement:
+		r.resolveExpression(stmt.Condition)
+		r.resolveBlock(stmt.Body)
+	case *ForStatement:
+		r.resolveExpression(stmt.Start)
+		r.resolveExpression(stmt.End)
+		r.enter(false)
+		r.declare(stmt.Variable, "declaration")
+		r.resolveBlock(stmt.Body)
+		r.leave()
+	case *TypeSwitchStatement:
+		r.resolveExpression(stmt.Subject)
+		for _, c := range stmt.Cases {
+			r.resolveBlock(c.Body)
+		}
+		r.resolveBlock(stmt.Default)
+	}
+}
+
+func (r *Resolver) resolveExpression(exp Expression) {
+	switch exp := exp.(type) {
+	case *Identifier:
+		if !r.lookup(exp) {
+			if _, ok := builtins[exp.Value]; !ok {
+				r.errorAt(exp, "identifier not found: %s", exp.Value)
+			}
+		}
+	case *PrefixExpression:
+		r.resolveExpression(exp.Right)
+	case *InfixExpression:
+		r.resolveExpression(exp.Left)
+		r.resolveExpression(exp.Right)
+	case *TypeofExpression:
+		r.resolveExpression(exp.Right)
+	case *NuggetsExpression:
+		r.resolveExpression(exp.Value)
+	case *ArrayLiteral:
+		for _, el := range exp.Elements {
+			r.resolveExpression(el)
+		}
+	case *HashLiteral:
+		for i := range exp.Keys {
+			r.resolveExpression(exp.Keys[i])
+			r.resolveExpression(exp.Values[i])
+		}
+	case *IndexExpression:
+		r.resolveExpression(exp.Left)
+		r.resolveExpression(exp.Index)
+	case *CallExpression:
+		r.resolveExpression(exp.Function)
+		for _, arg := range exp.Arguments {
+			r.resolveExpression(arg)
+		}
+	case *FunctionLiteral:
+		r.enter(true)
+		for _, param := range exp.Parameters {
+			r.declare(param, "parameter")
+		}
+		r.scope.plan(exp.Body.Statements)
+		r.resolveStatements(exp.Body.Statements)
+		r.leave()
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// resolvedIdentifiers resolves input and lists every identifier as "name@depth:slot", or
+// "name@?" if it was left unresolved, in source order.
+func resolvedIdentifiers(t *testing.T, input string, env *Environment) ([]string, []string) {
+	t.Helper()
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	r := NewResolver(env)
+	r.Resolve(program)
+
+	var idents []string
+	walkAST(program, func(node Node) {
+		if ident, ok := node.(*Identifier); ok {
+			if ident.Resolved {
+				idents = append(idents, fmt.Sprintf("%s@%d:%d", ident.Value, ident.Depth, ident.Slot))
+			} else {
+				idents = append(idents, ident.Value+"@?")
+			}
+		}
+	})
+	return idents, r.Errors()
+}
+
+func TestResolver(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"cheese a = 1; cheese b = a; a = b;", "a@0:0 b@0:1 a@0:0 a@0:0 b@0:1"},
+		{"cheese x = 1; { pizza x; cheese x = 2; pizza x; }", "x@0:0 x@1:0 x@0:0 x@0:0"},
+		{"cheese x = 1; waffles x { cheese y = x; } fries { cheese z = 1; cheese y = z; }", "x@0:0 x@0:0 y@0:0 x@1:0 z@0:0 y@0:1 z@0:0"},
+		{"cheese n = 3; donuts i = 0, n { cheese d = i * 2; pizza d; }", "n@0:0 i@0:0 n@0:0 d@0:0 i@1:0 d@0:0"},
+		{"cheese add = burrito(a, b) { cheese s = a + b; takeout s; };", "add@0:0 a@0:0 b@0:1 s@0:2 a@0:0 b@0:1 s@0:2"},
+		// A burrito may use names declared after it, as in mutual recursion.
+		{"cheese even = burrito(n) { takeout odd(n - 1); }; cheese odd = burrito(n) { takeout even(n); };", "even@0:0 n@0:0 odd@1:1 n@0:0 odd@0:1 n@0:0 even@1:0 n@0:0"},
+		{"cheese f = burrito() { takeout burrito() { takeout x; }; }; cheese x = 1;", "f@0:0 x@2:1 x@0:1"},
+		{"cheese x = 1; x += 2; pizza len;", "x@0:0 x@0:0 len@?"},
+		{"tacos 1 { nuggets { cheese t = 1; } fries { cheese u = 2; } }", "t@0:0 u@0:0"},
+	}
+
+	for _, tt := range tests {
+		idents, errs := resolvedIdentifiers(t, tt.input, nil)
+		if len(errs) > 0 {
+			t.Errorf("unexpected errors for %q: %v", tt.input, errs)
+		}
+		if got := strings.Join(idents, " "); got != tt.expected {
+			t.Errorf("wrong resolution of %q.\nexpected=%s\ngot=     %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"pizza x; cheese x = 1;", []string{"1:7-1:8: identifier not found: x"}},
+		{"nope = 1;", []string{"1:1-1:5: cannot assign to undeclared identifier: nope"}},
+		{"cheese f = burrito(a, b, a) { takeout a; };", []string{"1:26-1:27: duplicate parameter: a"}},
+		{"cheese f = burrito(a) { cheese a = 2; takeout a; };", []string{"1:32-1:33: duplicate declaration: a"}},
+		{"cheese x = 1;\ncheese y = 2;\ncheese x = 3;", []string{"3:8-3:9: duplicate declaration: x"}},
+		{"cheese x = 1; { cheese x = 2; cheese x = 3; }", []string{"1:38-1:39: duplicate declaration: x"}},
+		{"{ cheese y = 1; } pizza y;", []string{"1:25-1:26: identifier not found: y"}},
+		{"noodles icaco { pizza z; cheese z = 1; }", []string{"1:23-1:24: identifier not found: z"}},
+		{"cheese x = x + 1;\ncheese f = burrito() { takeout g(); };", []string{"1:12-1:13: identifier not found: x", "2:32-2:33: identifier not found: g"}},
+	}
+
+	for _, tt := range tests {
+		_, errs := resolvedIdentifiers(t, tt.input, nil)
+		if !reflect.DeepEqual(errs, tt.expected) {
+			t.Errorf("wrong errors for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestResolverUsesEnvironment(t *testing.T) {
+	env := NewEnvironment()
+	env.Declare("a", &Integer{Value: 1})
+	env.Declare("b", &Integer{Value: 2})
+
+	// The REPL declares the names of the environment again without it being an error.
+	idents, errs := resolvedIdentifiers(t, "cheese c = b; cheese a = c;", env)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got, expected := strings.Join(idents, " "), "c@0:2 b@0:1 a@0:0 c@0:2"; got != expected {
+		t.Errorf("wrong resolution.\nexpected=%s\ngot=     %s", expected, got)
+	}
+}
+
+func TestEvalResolvedUsesOnlySlots(t *testing.T) {
+	// g was resolved to the x its block declares, so calling it before that x is declared
+	// is an error instead of a search for some other x by name.
+	input := "cheese x = 1; { cheese g = burrito() { takeout x; }; pizza g(); cheese x = 2; pizza g(); }"
+	p := NewParser(NewLexer(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	NewResolver(nil).Resolve(program)
+
+	var out bytes.Buffer
+	result := NewEvaluator(strings.NewReader(""), &out).Eval(program, NewEnvironment())
+	errObj, ok := result.(*Error)
+	if !ok {
+		t.Fatalf("expected an error, got %s (output %q)", inspect(result), out.String())
+	}
+	if expected := "1:48: x is used before it is declared"; errObj.Message != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
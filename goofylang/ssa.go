@@ -0,0 +1,1051 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The SSA intermediate representation sits between the AST and the code generators.
+// A Function is a list of basic blocks; every Value is defined exactly once and names
+// the values it uses directly, and a phi at the top of a block picks one value per
+// predecessor. BuildSSA lowers a parsed Program into this form and VerifySSA checks the
+// invariants every pass is allowed to rely on.
+
+// IRType is the static type of an SSA value.
+type IRType string
+
+const (
+	IRInt  IRType = "i64"  // A 64-bit signed integer.
+	IRBool IRType = "bool" // Cake or broccoli.
+	IRVoid IRType = "void" // Values that only exist for their effect, such as pizza.
+)
+
+// IROp is the operation a Value performs.
+type IROp string
+
+const (
+	OpConst IROp = "const" // Aux holds the integer, or 1 for cake and 0 for broccoli.
+	OpAdd   IROp = "add"   // Args[0] + Args[1], failing on overflow.
+	OpSub   IROp = "sub"   // Args[0] - Args[1], failing on overflow.
+	OpMul   IROp = "mul"   // Args[0] * Args[1], failing on overflow.
+	OpDiv   IROp = "div"   // Args[0] / Args[1] truncated, failing on zero and overflow.
+	OpMod   IROp = "mod"   // Remainder of Args[0] / Args[1], failing on zero.
+	OpNeg   IROp = "neg"   // -Args[0], failing on overflow.
+	OpEq    IROp = "eq"    // Args[0] == Args[1], for two integers or two booleans.
+	OpNe    IROp = "ne"    // Args[0] != Args[1], for two integers or two booleans.
+	OpLt    IROp = "lt"    // Args[0] < Args[1].
+	OpGt    IROp = "gt"    // Args[0] > Args[1].
+	OpLe    IROp = "le"    // Args[0] <= Args[1].
+	OpGe    IROp = "ge"    // Args[0] >= Args[1].
+	OpNot   IROp = "not"   // !Args[0].
+	OpInt   IROp = "int"   // Args[0], a boolean, as the integer 1 for cake or 0 for broccoli.
+	OpInput IROp = "icaco" // Reads an integer from input.
+	OpPrint IROp = "pizza" // Prints Args[0].
+	OpPhi   IROp = "phi"   // Args[i] is the value flowing in from Block.Preds[i].
+)
+
+// BlockKind says how control leaves a block.
+type BlockKind string
+
+const (
+	BlockPlain BlockKind = "jump" // Falls through to its single successor.
+	BlockIf    BlockKind = "if"   // Goes to Succs[0] if Control is cake, else Succs[1].
+	BlockExit  BlockKind = "exit" // Ends the program.
+)
+
+// Value is a single SSA instruction and the value it defines.
+type Value struct {
+	ID    int
+	Op    IROp
+	Type  IRType
+	Args  []*Value
+	Aux   int64  // Constant payload for OpConst.
+	Block *Block // The block the value lives in.
+}
+
+// Block is a basic block: phis first, then straight-line values, then a terminator.
+type Block struct {
+	ID      int
+	Kind    BlockKind
+	Values  []*Value
+	Control *Value // The condition tested by a BlockIf.
+	Preds   []*Block
+	Succs   []*Block
+}
+
+// Function is a lowered goofylang program.
+type Function struct {
+	Name   string
+	Blocks []*Block // Blocks[0] is the entry block.
+}
+
+// Entry returns the block execution starts in.
+func (f *Function) Entry() *Block {
+	return f.Blocks[0]
+}
+
+// String prints the function in the textual dump format used for debugging.
+func (f *Function) String() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "func %s\n", f.Name)
+
+	for _, b := range f.Blocks {
+		fmt.Fprintf(&out, "b%d:", b.ID)
+		if len(b.Preds) > 0 {
+			out.WriteString(" <-")
+			for _, p := range b.Preds {
+				fmt.Fprintf(&out, " b%d", p.ID)
+			}
+		}
+		out.WriteString("\n")
+
+		for _, v := range b.Values {
+			fmt.Fprintf(&out, "  %s\n", v.LongString())
+		}
+
+		switch b.Kind {
+		case BlockPlain:
+			fmt.Fprintf(&out, "  jump b%d\n", b.Succs[0].ID)
+		case BlockIf:
+			fmt.Fprintf(&out, "  if v%d b%d b%d\n", b.Control.ID, b.Succs[0].ID, b.Succs[1].ID)
+		default:
+			fmt.Fprintf(&out, "  %s\n", b.Kind)
+		}
+	}
+
+	return out.String()
+}
+
+// String returns the short name of a value, e.g. "v3".
+func (v *Value) String() string {
+	return fmt.Sprintf("v%d", v.ID)
+}
+
+// LongString returns the full definition of a value, e.g. "v3 = add i64 v1 v2".
+func (v *Value) LongString() string {
+	var out strings.Builder
+	if v.Type != IRVoid {
+		fmt.Fprintf(&out, "v%d = ", v.ID)
+	}
+	out.WriteString(string(v.Op))
+	if v.Type != IRVoid {
+		out.WriteString(" " + string(v.Type))
+	}
+
+	switch v.Op {
+	case OpConst:
+		fmt.Fprintf(&out, " %d", v.Aux)
+	case OpPhi:
+		for i, arg := range v.Args {
+			fmt.Fprintf(&out, " [b%d: %s]", v.Block.Preds[i].ID, arg)
+		}
+	default:
+		for _, arg := range v.Args {
+			out.WriteString(" " + arg.String())
+		}
+	}
+
+	return out.String()
+}
+
+// ssaBuilder lowers the AST using the on-the-fly SSA construction of Braun et al.:
+// each block remembers the current value of every variable it defines, and reads that
+// miss walk backwards through the predecessors, placing phis where paths join.
+type ssaBuilder struct {
+	fn         *Function
+	cur        *Block
+	defs       map[*Block]map[string]*Value
+	incomplete map[*Block]map[string]*Value // Phis placed in blocks that were not sealed yet.
+	sealed     map[*Block]bool              // Blocks whose predecessors are all known.
+	scope      *symbolTable                 // Maps source names to variables, which are keyed by storage name.
+	types      map[string]IRType            // The type last assigned to each variable.
+	loops      []*ssaLoop                   // The loops enclosing the current point, innermost last.
+	nextID     int
+	err        error
+}
+
+// BuildSSA lowers a parsed program into a single SSA function named "main".
+func BuildSSA(program *Program) (*Function, error) {
+	b := &ssaBuilder{
+		fn:         &Function{Name: "main"},
+		defs:       map[*Block]map[string]*Value{},
+		incomplete: map[*Block]map[string]*Value{},
+		sealed:     map[*Block]bool{},
+		scope:      newSymbolTable(),
+		types:      map[string]IRType{},
+	}
+
+	b.cur = b.newBlock()
+	b.sealBlock(b.cur)
+
+	for _, stmt := range program.Statements {
+		b.lowerStatement(stmt)
+		if b.err != nil {
+			return nil, b.err
+		}
+	}
+	b.cur.Kind = BlockExit
+
+	return b.fn, nil
+}
+
+// fail records the first lowering error; later ones are usually consequences of it.
+func (b *ssaBuilder) fail(format string, args ...interface{}) {
+	if b.err == nil {
+		b.err = fmt.Errorf("ssa: "+format, args...)
+	}
+}
+
+// newBlock appends an empty block to the function.
+func (b *ssaBuilder) newBlock() *Block {
+	block := &Block{ID: len(b.fn.Blocks)}
+	b.fn.Blocks = append(b.fn.Blocks, block)
+	return block
+}
+
+// addEdge connects two blocks in the control flow graph.
+func (b *ssaBuilder) addEdge(from, to *Block) {
+	from.Succs = append(from.Succs, to)
+	to.Preds = append(to.Preds, from)
+}
+
+// newValue appends a value to the current block.
+func (b *ssaBuilder) newValue(op IROp, typ IRType, args ...*Value) *Value {
+	b.nextID++
+	v := &Value{ID: b.nextID, Op: op, Type: typ, Args: args, Block: b.cur}
+	b.cur.Values = append(b.cur.Values, v)
+	return v
+}
+
+// newPhi places an empty phi of the given type at the top of block. An empty type is
+// filled in by addPhiOperands once the operands are known.
+func (b *ssaBuilder) newPhi(block *Block, typ IRType) *Value {
+	b.nextID++
+	v := &Value{ID: b.nextID, Op: OpPhi, Type: typ, Block: block}
+	block.Values = append([]*Value{v}, block.Values...)
+	return v
+}
+
+// ssaMangle names the variable of a cheese binding made inside a block.
+func ssaMangle(name string, n int) string {
+	return fmt.Sprintf("%s.%d", name, n)
+}
+
+// lowerStatement emits the values for one statement into the current block.
+func (b *ssaBuilder) lowerStatement(stmt Statement) {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		if value := b.lowerExpression(stmt.Value); value != nil {
+			sym, err := b.scope.declareLet(stmt, value.Type.objectType(), ssaMangle)
+			if err != nil {
+				b.fail("%s", err)
+				return
+			}
+			b.assignVariable(sym.storage, value)
+		}
+	case *AssignStatement:
+		sym, ok := b.scope.lookup(stmt.Name.Value)
+		if !ok {
+			b.fail("cannot assign to undeclared identifier: %s", stmt.Name.Value)
+			return
+		}
+		value := b.lowerExpression(stmt.Value)
+		if value == nil {
+			return
+		}
+		if operator, ok := stmt.Operator(); ok {
+			current := b.readVariable(sym.storage, b.cur)
+			if current == nil {
+				return
+			}
+			value = b.lowerOperator(operator, current, value)
+		}
+		if value != nil {
+			if err := sym.check(value.Type.objectType()); value.Type != "" && err != nil {
+				b.fail("%s", err)
+				return
+			}
+			b.assignVariable(sym.storage, value)
+		}
+	case *PrintStatement:
+		if value := b.lowerExpression(stmt.Value); value != nil {
+			b.newValue(OpPrint, IRVoid, value)
+		}
+	case *ExpressionStatement:
+		b.lowerExpression(stmt.Expression)
+	case *BlockStatement:
+		b.lowerBlock(stmt)
+	case *IfStatement:
+		b.lowerIfStatement(stmt)
+	case *WhileStatement:
+		b.lowerWhileStatement(stmt)
+	case *ForStatement:
+		b.lowerForStatement(stmt)
+	case *LoopControlStatement:
+		if len(b.loops) == 0 {
+			b.fail("%s outside of a loop", stmt.Token.Literal)
+			return
+		}
+		loop := b.loops[len(b.loops)-1]
+		if stmt.IsBreak() {
+			b.jump(loop.exit)
+		} else {
+			b.jump(loop.next())
+		}
+	case *TypeSwitchStatement:
+		b.fail("tacos is not supported")
+	default:
+		b.fail("unsupported statement %T", stmt)
+	}
+}
+
+// lowerBlock emits the statements of a block in a scope of their own. Statements after
+// a dessert or seconds can never run, so they are not lowered at all.
+func (b *ssaBuilder) lowerBlock(block *BlockStatement) {
+	outer := b.scope
+	b.scope = outer.enclose()
+	defer func() { b.scope = outer }()
+
+	for _, stmt := range block.Statements {
+		if b.cur == nil {
+			return
+		}
+		b.lowerStatement(stmt)
+		if b.err != nil {
+			return
+		}
+	}
+}
+
+// jump ends the current block with a jump to target. Nothing can follow it, so the
+// current block is left nil until something starts a new one.
+func (b *ssaBuilder) jump(target *Block) {
+	b.cur.Kind = BlockPlain
+	b.addEdge(b.cur, target)
+	b.cur = nil
+}
+
+// lowerIfStatement ends the current block with a branch on the condition and continues
+// in a new block that both arms jump to when they are done.
+func (b *ssaBuilder) lowerIfStatement(stmt *IfStatement) {
+	condition := b.lowerExpression(stmt.Condition)
+	if condition == nil {
+		return
+	}
+	condition = b.truthValue(condition)
+
+	entry := b.cur
+	entry.Kind = BlockIf
+	entry.Control = condition
+
+	then := b.newBlock()
+	b.addEdge(entry, then)
+	b.sealBlock(then)
+	b.cur = then
+	b.lowerBlock(stmt.Consequence)
+	var ends []*Block
+	if b.cur != nil {
+		ends = append(ends, b.cur)
+	}
+
+	if stmt.Alternative != nil {
+		alternative := b.newBlock()
+		b.addEdge(entry, alternative)
+		b.sealBlock(alternative)
+		b.cur = alternative
+		b.lowerStatement(stmt.Alternative)
+		if b.cur != nil {
+			ends = append(ends, b.cur)
+		}
+	} else {
+		ends = append(ends, entry)
+	}
+
+	// When both arms leave the loop around them there is nothing to join.
+	if len(ends) == 0 {
+		b.cur = nil
+		return
+	}
+	join := b.newBlock()
+	for _, end := range ends {
+		if end != entry {
+			end.Kind = BlockPlain
+		}
+		b.addEdge(end, join)
+	}
+	b.sealBlock(join)
+	b.cur = join
+}
+
+// ssaLoop is a loop being lowered.
+type ssaLoop struct {
+	b    *ssaBuilder
+	exit *Block // Where dessert goes.
+	cont *Block // Where seconds goes, made on first use.
+}
+
+// next returns the block seconds jumps to.
+func (l *ssaLoop) next() *Block {
+	if l.cont == nil {
+		l.cont = l.b.newBlock()
+	}
+	return l.cont
+}
+
+// lowerLoop lowers a loop whose header tests a condition: it emits the header with test,
+// the body starting with enter, and the exit block, and continues in the exit block.
+// For a while loop seconds goes straight back to the header; otherwise it goes to a latch
+// block where step runs before the header. The header is sealed only once every back
+// edge is known, so the variables the body changes get phis there.
+func (b *ssaBuilder) lowerLoop(body *BlockStatement, test func() *Value, enter, step func()) {
+	header := b.newBlock()
+	b.jump(header)
+	b.cur = header
+	condition := test()
+	if condition == nil {
+		return
+	}
+	header.Kind = BlockIf
+	header.Control = condition
+
+	bodyBlock := b.newBlock()
+	b.addEdge(header, bodyBlock)
+	b.sealBlock(bodyBlock)
+	exit := b.newBlock()
+	b.addEdge(header, exit)
+
+	loop := &ssaLoop{b: b, exit: exit}
+	if step == nil {
+		loop.cont = header
+	}
+
+	outer := b.scope
+	b.scope = outer.enclose()
+	b.cur = bodyBlock
+	enter()
+	b.loops = append(b.loops, loop)
+	b.lowerBlock(body)
+	b.loops = b.loops[:len(b.loops)-1]
+	b.scope = outer
+	if b.err != nil {
+		return
+	}
+
+	if b.cur != nil {
+		b.jump(loop.next())
+	}
+	if step != nil && loop.cont != nil {
+		b.cur = loop.cont
+		b.sealBlock(loop.cont)
+		step()
+		b.jump(header)
+	}
+
+	b.sealBlock(header)
+	b.sealBlock(exit)
+	b.cur = exit
+}
+
+// lowerWhileStatement lowers a noodles loop.
+func (b *ssaBuilder) lowerWhileStatement(stmt *WhileStatement) {
+	b.lowerLoop(stmt.Body, func() *Value {
+		condition := b.lowerExpression(stmt.Condition)
+		if condition == nil {
+			return nil
+		}
+		return b.truthValue(condition)
+	}, func() {}, nil)
+}
+
+// lowerForStatement lowers a donuts loop. The counter is a hidden variable named after
+// the header block; the loop variable gets a copy of it at the start of every iteration,
+// so assigning to the loop variable does not change how often the loop runs.
+func (b *ssaBuilder) lowerForStatement(stmt *ForStatement) {
+	start := b.lowerExpression(stmt.Start)
+	if start == nil {
+		return
+	}
+	if start.Type != IRInt {
+		b.fail("donuts expected an integer start, got %s", typeNameOf(start.Type.objectType()))
+		return
+	}
+	end := b.lowerExpression(stmt.End)
+	if end == nil {
+		return
+	}
+	if end.Type != IRInt {
+		b.fail("donuts expected an integer end, got %s", typeNameOf(end.Type.objectType()))
+		return
+	}
+
+	counter := fmt.Sprintf("donuts.%d", len(b.fn.Blocks))
+	b.assignVariable(counter, start)
+
+	b.lowerLoop(stmt.Body, func() *Value {
+		return b.newValue(OpLt, IRBool, b.readVariable(counter, b.cur), end)
+	}, func() {
+		sym := b.scope.declare(stmt.Variable.Value, INTEGER_OBJ, ssaMangle)
+		b.assignVariable(sym.storage, b.readVariable(counter, b.cur))
+	}, func() {
+		one := b.newValue(OpConst, IRInt)
+		one.Aux = 1
+		b.assignVariable(counter, b.newValue(OpAdd, IRInt, b.readVariable(counter, b.cur), one))
+	})
+}
+
+// truthValue turns a condition into the bool a branch tests: integers are true unless
+// they are 0.
+func (b *ssaBuilder) truthValue(v *Value) *Value {
+	if v.Type == IRBool {
+		return v
+	}
+	zero := b.newValue(OpConst, IRInt)
+	return b.newValue(OpNe, IRBool, v, zero)
+}
+
+// assignVariable makes value the current definition of a variable in the current block.
+func (b *ssaBuilder) assignVariable(name string, value *Value) {
+	b.types[name] = value.Type
+	b.writeVariable(name, b.cur, value)
+}
+
+// lowerExpression emits the values computing exp and returns the result.
+func (b *ssaBuilder) lowerExpression(exp Expression) *Value {
+	switch exp := exp.(type) {
+	case *IntegralLiteral:
+		v := b.newValue(OpConst, IRInt)
+		v.Aux = exp.Value
+		return v
+	case *BooleanLiteral:
+		v := b.newValue(OpConst, IRBool)
+		if exp.Value {
+			v.Aux = 1
+		}
+		return v
+	case *Identifier:
+		sym, ok := b.scope.lookup(exp.Value)
+		if !ok {
+			b.fail("identifier not found: %s", exp.Value)
+			return nil
+		}
+		v := b.readVariable(sym.storage, b.cur)
+		if v == nil {
+			b.fail("identifier not found: %s", exp.Value)
+		}
+		return v
+	case *InputExpression:
+		return b.newValue(OpInput, IRInt)
+	case *PrefixExpression:
+		right := b.lowerExpression(exp.Right)
+		if right == nil {
+			return nil
+		}
+		if right.Type != "" {
+			if _, err := prefixResultType(exp.Token.Type, right.Type.objectType()); err != nil {
+				b.fail("%s", err)
+				return nil
+			}
+		}
+		if exp.Token.Type == TOKEN_BANG {
+			return b.newValue(OpNot, IRBool, right)
+		}
+		return b.newValue(OpNeg, IRInt, right)
+	case *InfixExpression:
+		left := b.lowerExpression(exp.Left)
+		right := b.lowerExpression(exp.Right)
+		if left == nil || right == nil {
+			return nil
+		}
+		return b.lowerOperator(exp.Token.Type, left, right)
+	case *FunctionLiteral, *CallExpression:
+		b.fail("burritos are not supported")
+		return nil
+	case *StringLiteral:
+		b.fail("strings are not supported")
+		return nil
+	case *ArrayLiteral, *HashLiteral, *IndexExpression:
+		b.fail("arrays and hashes are not supported")
+		return nil
+	case *TypeofExpression:
+		b.fail("tacos is not supported")
+		return nil
+	case *NuggetsExpression:
+		value := b.lowerExpression(exp.Value)
+		if value == nil || value.Type != IRBool {
+			return value
+		}
+		return b.newValue(OpInt, IRInt, value)
+	case nil:
+		b.fail("missing expression")
+		return nil
+	default:
+		b.fail("unsupported expression %T", exp)
+		return nil
+	}
+}
+
+// ssaComparisons maps each comparison operator to the op implementing it.
+var ssaComparisons = map[TokenType]IROp{
+	TOKEN_EQ:     OpEq,
+	TOKEN_NOT_EQ: OpNe,
+	TOKEN_LT:     OpLt,
+	TOKEN_GT:     OpGt,
+	TOKEN_LT_EQ:  OpLe,
+	TOKEN_GT_EQ:  OpGe,
+}
+
+// lowerOperator emits the value applying an infix operator to two operands. Operands
+// whose type is still unknown, such as phis in unsealed blocks, are left to VerifySSA.
+func (b *ssaBuilder) lowerOperator(operator TokenType, left, right *Value) *Value {
+	if left.Type != "" && right.Type != "" {
+		if _, err := infixResultType(operator, left.Type.objectType(), right.Type.objectType()); err != nil {
+			b.fail("%s", err)
+			return nil
+		}
+	}
+
+	switch operator {
+	case TOKEN_APPLE:
+		return b.newValue(OpAdd, IRInt, left, right)
+	case TOKEN_SALMON:
+		return b.newValue(OpSub, IRInt, left, right)
+	case TOKEN_PANCAKES:
+		return b.newValue(OpMul, IRInt, left, right)
+	case TOKEN_PIE:
+		return b.newValue(OpDiv, IRInt, left, right)
+	case TOKEN_LEFTOVERS:
+		return b.newValue(OpMod, IRInt, left, right)
+	}
+	if op, ok := ssaComparisons[operator]; ok {
+		return b.newValue(op, IRBool, left, right)
+	}
+	b.fail("unsupported operator %s", operator.String())
+	return nil
+}
+
+// objectType returns the runtime type of the values an IR type describes, which is
+// what error messages are phrased in.
+func (t IRType) objectType() ObjectType {
+	switch t {
+	case IRInt:
+		return INTEGER_OBJ
+	case IRBool:
+		return BOOLEAN_OBJ
+	default:
+		return ObjectType(t)
+	}
+}
+
+// writeVariable records value as the current definition of name in block.
+func (b *ssaBuilder) writeVariable(name string, block *Block, value *Value) {
+	if b.defs[block] == nil {
+		b.defs[block] = map[string]*Value{}
+	}
+	b.defs[block][name] = value
+}
+
+// readVariable returns the value of name at the end of block, or nil if it is undefined.
+func (b *ssaBuilder) readVariable(name string, block *Block) *Value {
+	if v, ok := b.defs[block][name]; ok {
+		return v
+	}
+	return b.readVariableRecursive(name, block)
+}
+
+func (b *ssaBuilder) readVariableRecursive(name string, block *Block) *Value {
+	var v *Value
+
+	switch {
+	case !b.sealed[block]:
+		// More predecessors may still appear, so leave a phi to complete later. Its type
+		// is the one the variable had when the block was entered.
+		v = b.newPhi(block, b.types[name])
+		if b.incomplete[block] == nil {
+			b.incomplete[block] = map[string]*Value{}
+		}
+		b.incomplete[block][name] = v
+	case len(block.Preds) == 0:
+		return nil
+	case len(block.Preds) == 1:
+		v = b.readVariable(name, block.Preds[0])
+		if v == nil {
+			return nil
+		}
+	default:
+		// Write the phi before reading the operands so loops find it instead of recursing forever.
+		v = b.newPhi(block, "")
+		b.writeVariable(name, block, v)
+		v = b.addPhiOperands(name, v)
+		if v == nil {
+			return nil
+		}
+	}
+
+	b.writeVariable(name, block, v)
+	return v
+}
+
+// addPhiOperands fills in a phi from every predecessor and then simplifies it.
+func (b *ssaBuilder) addPhiOperands(name string, phi *Value) *Value {
+	for _, pred := range phi.Block.Preds {
+		arg := b.readVariable(name, pred)
+		if arg == nil {
+			return nil
+		}
+		phi.Args = append(phi.Args, arg)
+		if phi.Type == "" {
+			phi.Type = arg.Type
+		}
+		if arg.Type != phi.Type {
+			source, _, _ := strings.Cut(name, ".")
+			b.fail("%s holds %s on one path and %s on another", source, phi.Type.objectType(), arg.Type.objectType())
+			return nil
+		}
+	}
+	return b.tryRemoveTrivialPhi(phi)
+}
+
+// tryRemoveTrivialPhi replaces a phi that only ever sees one value (apart from itself)
+// with that value.
+func (b *ssaBuilder) tryRemoveTrivialPhi(phi *Value) *Value {
+	var same *Value
+	for _, arg := range phi.Args {
+		if arg == same || arg == phi {
+			continue
+		}
+		if same != nil {
+			return phi
+		}
+		same = arg
+	}
+	if same == nil {
+		return phi
+	}
+
+	// Remove the phi from its block and reroute every use of it.
+	values := phi.Block.Values[:0]
+	for _, v := range phi.Block.Values {
+		if v != phi {
+			values = append(values, v)
+		}
+	}
+	phi.Block.Values = values
+
+	var users []*Value
+	for _, block := range b.fn.Blocks {
+		for _, v := range block.Values {
+			for i, arg := range v.Args {
+				if arg == phi {
+					v.Args[i] = same
+					if v.Op == OpPhi && v != phi {
+						users = append(users, v)
+					}
+				}
+			}
+		}
+		if block.Control == phi {
+			block.Control = same
+		}
+		for name, v := range b.defs[block] {
+			if v == phi {
+				b.defs[block][name] = same
+			}
+		}
+		for name, v := range b.incomplete[block] {
+			if v == phi {
+				b.incomplete[block][name] = same
+			}
+		}
+	}
+
+	// Phis that used this one may have become trivial in turn.
+	for _, user := range users {
+		b.tryRemoveTrivialPhi(user)
+	}
+	return same
+}
+
+// sealBlock marks block as having all of its predecessors and completes its pending phis.
+func (b *ssaBuilder) sealBlock(block *Block) {
+	for name, phi := range b.incomplete[block] {
+		if phi.Op == OpPhi && phi.Block == block && len(phi.Args) == 0 {
+			if b.addPhiOperands(name, phi) == nil {
+				b.fail("identifier not found: %s", name)
+			}
+		}
+	}
+	delete(b.incomplete, block)
+	b.sealed[block] = true
+}
+
+// VerifySSA checks the structural invariants of a function: the control flow graph is
+// consistent, every block is reachable, phis match their predecessors, operand types
+// agree, and every use is dominated by its definition.
+func VerifySSA(f *Function) error {
+	if len(f.Blocks) == 0 {
+		return fmt.Errorf("ssa: %s has no blocks", f.Name)
+	}
+
+	defined := map[*Value]bool{}
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if defined[v] {
+				return fmt.Errorf("ssa: %s is defined twice", v)
+			}
+			defined[v] = true
+		}
+	}
+
+	for _, b := range f.Blocks {
+		if err := verifyEdges(b); err != nil {
+			return err
+		}
+	}
+
+	idom, order := computeDominators(f)
+	for _, b := range f.Blocks {
+		if _, ok := order[b]; !ok {
+			return fmt.Errorf("ssa: b%d is unreachable", b.ID)
+		}
+	}
+
+	for _, b := range f.Blocks {
+		position := map[*Value]int{}
+		seenNonPhi := false
+		for i, v := range b.Values {
+			position[v] = i
+			if v.Block != b {
+				return fmt.Errorf("ssa: %s is listed in b%d but claims b%d", v, b.ID, v.Block.ID)
+			}
+			if v.Op == OpPhi {
+				if seenNonPhi {
+					return fmt.Errorf("ssa: phi %s is not at the top of b%d", v, b.ID)
+				}
+				if len(v.Args) != len(b.Preds) {
+					return fmt.Errorf("ssa: phi %s has %d operands but b%d has %d predecessors", v, len(v.Args), b.ID, len(b.Preds))
+				}
+			} else {
+				seenNonPhi = true
+			}
+			if err := verifyTypes(v); err != nil {
+				return err
+			}
+		}
+
+		for i, v := range b.Values {
+			for j, arg := range v.Args {
+				if !defined[arg] {
+					return fmt.Errorf("ssa: %s uses %s, which is not defined in the function", v, arg)
+				}
+				if v.Op == OpPhi {
+					// A phi operand only has to be available at the end of its predecessor.
+					if !dominates(idom, arg.Block, b.Preds[j]) {
+						return fmt.Errorf("ssa: %s uses %s, which does not dominate b%d", v, arg, b.Preds[j].ID)
+					}
+					continue
+				}
+				if arg.Block == b {
+					if position[arg] >= i {
+						return fmt.Errorf("ssa: %s uses %s before it is defined", v, arg)
+					}
+				} else if !dominates(idom, arg.Block, b) {
+					return fmt.Errorf("ssa: %s uses %s, which does not dominate b%d", v, arg, b.ID)
+				}
+			}
+		}
+
+		if b.Control != nil {
+			if !defined[b.Control] {
+				return fmt.Errorf("ssa: b%d branches on %s, which is not defined in the function", b.ID, b.Control)
+			}
+			if !dominates(idom, b.Control.Block, b) {
+				return fmt.Errorf("ssa: b%d branches on %s, which does not dominate it", b.ID, b.Control)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyEdges checks that a block's terminator matches its successors and that every
+// edge is recorded on both ends.
+func verifyEdges(b *Block) error {
+	want := map[BlockKind]int{BlockPlain: 1, BlockIf: 2, BlockExit: 0}
+	n, ok := want[b.Kind]
+	if !ok {
+		return fmt.Errorf("ssa: b%d has unknown kind %q", b.ID, b.Kind)
+	}
+	if len(b.Succs) != n {
+		return fmt.Errorf("ssa: b%d is a %s block with %d successors", b.ID, b.Kind, len(b.Succs))
+	}
+	if (b.Kind == BlockIf) != (b.Control != nil) {
+		return fmt.Errorf("ssa: b%d has a control value but is a %s block", b.ID, b.Kind)
+	}
+	if b.Control != nil && b.Control.Type != IRBool {
+		return fmt.Errorf("ssa: b%d branches on %s of type %s", b.ID, b.Control, b.Control.Type)
+	}
+
+	for _, s := range b.Succs {
+		if countBlock(s.Preds, b) != countBlock(b.Succs, s) {
+			return fmt.Errorf("ssa: edge b%d -> b%d is missing from b%d's predecessors", b.ID, s.ID, s.ID)
+		}
+	}
+	for _, p := range b.Preds {
+		if countBlock(p.Succs, b) != countBlock(b.Preds, p) {
+			return fmt.Errorf("ssa: edge b%d -> b%d is missing from b%d's successors", p.ID, b.ID, p.ID)
+		}
+	}
+	return nil
+}
+
+// verifyTypes checks that a value's operands have the types its op expects.
+func verifyTypes(v *Value) error {
+	var want []IRType
+	result := IRInt
+
+	switch v.Op {
+	case OpConst:
+		if v.Type == IRBool {
+			result = IRBool
+		}
+	case OpInput:
+	case OpNeg:
+		want = []IRType{IRInt}
+	case OpAdd, OpSub, OpMul, OpDiv, OpMod:
+		want = []IRType{IRInt, IRInt}
+	case OpEq, OpNe:
+		// Both operands have to agree, whichever type they are.
+		if len(v.Args) == 2 && v.Args[0].Type == IRBool {
+			want = []IRType{IRBool, IRBool}
+		} else {
+			want = []IRType{IRInt, IRInt}
+		}
+		result = IRBool
+	case OpLt, OpGt, OpLe, OpGe:
+		want = []IRType{IRInt, IRInt}
+		result = IRBool
+	case OpNot:
+		want = []IRType{IRBool}
+		result = IRBool
+	case OpInt:
+		want = []IRType{IRBool}
+	case OpPrint:
+		if len(v.Args) == 1 && v.Args[0].Type == IRBool {
+			want = []IRType{IRBool}
+		} else {
+			want = []IRType{IRInt}
+		}
+		result = IRVoid
+	case OpPhi:
+		for _, arg := range v.Args {
+			if arg.Type != v.Type {
+				return fmt.Errorf("ssa: phi %s of type %s has operand %s of type %s", v, v.Type, arg, arg.Type)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("ssa: %s has unknown op %q", v, v.Op)
+	}
+
+	if v.Type != result {
+		return fmt.Errorf("ssa: %s %s should have type %s, not %s", v.Op, v, result, v.Type)
+	}
+	if len(v.Args) != len(want) {
+		return fmt.Errorf("ssa: %s %s takes %d operands, got %d", v.Op, v, len(want), len(v.Args))
+	}
+	for i, arg := range v.Args {
+		if arg.Type != want[i] {
+			return fmt.Errorf("ssa: operand %d of %s has type %s, expected %s", i, v, arg.Type, want[i])
+		}
+	}
+	return nil
+}
+
+// computeDominators returns the immediate dominator of every reachable block together
+// with its reverse postorder number, using the iterative algorithm of Cooper, Harvey
+// and Kennedy.
+func computeDominators(f *Function) (map[*Block]*Block, map[*Block]int) {
+	var postorder []*Block
+	visited := map[*Block]bool{}
+	var walk func(b *Block)
+	walk = func(b *Block) {
+		visited[b] = true
+		for _, s := range b.Succs {
+			if !visited[s] {
+				walk(s)
+			}
+		}
+		postorder = append(postorder, b)
+	}
+	walk(f.Entry())
+
+	order := map[*Block]int{}
+	rpo := make([]*Block, len(postorder))
+	for i, b := range postorder {
+		rpo[len(postorder)-1-i] = b
+	}
+	for i, b := range rpo {
+		order[b] = i
+	}
+
+	idom := map[*Block]*Block{f.Entry(): f.Entry()}
+	intersect := func(a, b *Block) *Block {
+		for a != b {
+			for order[a] > order[b] {
+				a = idom[a]
+			}
+			for order[b] > order[a] {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, b := range rpo[1:] {
+			var newIdom *Block
+			for _, p := range b.Preds {
+				if _, ok := idom[p]; !ok {
+					continue
+				}
+				if newIdom == nil {
+					newIdom = p
+				} else {
+					newIdom = intersect(p, newIdom)
+				}
+			}
+			if idom[b] != newIdom {
+				idom[b] = newIdom
+				changed = true
+			}
+		}
+	}
+
+	return idom, order
+}
+
+// dominates reports whether every path from the entry to b passes through a.
+func dominates(idom map[*Block]*Block, a, b *Block) bool {
+	for {
+		if a == b {
+			return true
+		}
+		next, ok := idom[b]
+		if !ok || next == b {
+			return false
+		}
+		b = next
+	}
+}
+
+// countBlock returns how many times b appears in blocks.
+func countBlock(blocks []*Block, b *Block) int {
+	n := 0
+	for _, x := range blocks {
+		if x == b {
+			n++
+		}
+	}
+	return n
+}
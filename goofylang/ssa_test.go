@@ -0,0 +1,442 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestBuildSSA(t *testing.T) {
+	input := `
+    cheese x = 7;
+    cheese y = icaco;
+    cheese x = x apple y;
+    cheese z = x;
+    z -= y;
+    pizza salmon z - 1;
+    `
+
+	expected := `func main
+b0:
+  v1 = const i64 7
+  v2 = icaco i64
+  v3 = add i64 v1 v2
+  v4 = sub i64 v3 v2
+  v5 = neg i64 v4
+  v6 = const i64 1
+  v7 = sub i64 v5 v6
+  pizza v7
+  exit
+`
+
+	fn := buildSSAInput(t, input)
+	if fn.String() != expected {
+		t.Errorf("wrong dump.\nexpected:\n%s\ngot:\n%s", expected, fn.String())
+	}
+	if err := VerifySSA(fn); err != nil {
+		t.Errorf("VerifySSA failed: %s", err)
+	}
+}
+
+func TestBuildSSABooleans(t *testing.T) {
+	input := `
+    cheese small = icaco < 10;
+    pizza !small == cake;
+    `
+
+	expected := `func main
+b0:
+  v1 = icaco i64
+  v2 = const i64 10
+  v3 = lt bool v1 v2
+  v4 = not bool v3
+  v5 = const bool 1
+  v6 = eq bool v4 v5
+  pizza v6
+  exit
+`
+
+	fn := buildSSAInput(t, input)
+	if fn.String() != expected {
+		t.Errorf("wrong dump.\nexpected:\n%s\ngot:\n%s", expected, fn.String())
+	}
+	if err := VerifySSA(fn); err != nil {
+		t.Errorf("VerifySSA failed: %s", err)
+	}
+
+	l := NewLexer("cheese b = cake; b *= 2;")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err := BuildSSA(program)
+	if err == nil || err.Error() != "ssa: type mismatch: boolean * nuggets" {
+		t.Errorf("expected a type mismatch error. got=%v", err)
+	}
+
+	fn = buildSSAInput(t, "cheese nuggets n = nuggets(icaco > 0); pizza n;")
+	expected = `func main
+b0:
+  v1 = icaco i64
+  v2 = const i64 0
+  v3 = gt bool v1 v2
+  v4 = int i64 v3
+  pizza v4
+  exit
+`
+	if fn.String() != expected {
+		t.Errorf("wrong dump.\nexpected:\n%s\ngot:\n%s", expected, fn.String())
+	}
+	if err := VerifySSA(fn); err != nil {
+		t.Errorf("VerifySSA failed: %s", err)
+	}
+
+	l = NewLexer("cheese nuggets n = 1; n = cake;")
+	p = NewParser(l)
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err = BuildSSA(program)
+	if err == nil || err.Error() != "ssa: nuggets n cannot hold boolean" {
+		t.Errorf("expected a nuggets error. got=%v", err)
+	}
+}
+
+func TestBuildSSAIfStatements(t *testing.T) {
+	input := `
+    cheese x = icaco;
+    cheese y = 1;
+    waffles x {
+        cheese y = 5;
+        x = y;
+    } fries {
+        y = 2;
+    }
+    pizza x + y;
+    `
+
+	expected := `func main
+b0:
+  v1 = icaco i64
+  v2 = const i64 1
+  v3 = const i64 0
+  v4 = ne bool v1 v3
+  if v4 b1 b2
+b1: <- b0
+  v5 = const i64 5
+  jump b3
+b2: <- b0
+  v6 = const i64 2
+  jump b3
+b3: <- b1 b2
+  v8 = phi i64 [b1: v2] [b2: v6]
+  v7 = phi i64 [b1: v5] [b2: v1]
+  v9 = add i64 v7 v8
+  pizza v9
+  exit
+`
+
+	fn := buildSSAInput(t, input)
+	if fn.String() != expected {
+		t.Errorf("wrong dump.\nexpected:\n%s\ngot:\n%s", expected, fn.String())
+	}
+	if err := VerifySSA(fn); err != nil {
+		t.Errorf("VerifySSA failed: %s", err)
+	}
+
+	l := NewLexer("cheese x = 1; waffles icaco { x = cake; } pizza x;")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err := BuildSSA(program)
+	if err == nil || err.Error() != "ssa: x holds BOOLEAN on one path and INTEGER on another" {
+		t.Errorf("expected a type error for x. got=%v", err)
+	}
+}
+
+func TestBuildSSAUndefinedIdentifier(t *testing.T) {
+	l := NewLexer("cheese x = y;")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err := BuildSSA(program)
+	if err == nil || !strings.Contains(err.Error(), "identifier not found: y") {
+		t.Fatalf("expected an undefined identifier error. got=%v", err)
+	}
+
+	l = NewLexer("pizza burrito(x) { takeout x; }(1);")
+	p = NewParser(l)
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err = BuildSSA(program)
+	if err == nil || err.Error() != "ssa: burritos are not supported" {
+		t.Errorf("expected burritos to be rejected. got=%v", err)
+	}
+}
+
+func TestBuildSSALoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"cheese n = icaco; cheese s = 0; noodles n > 0 { s apple= n; n salmon= 1; } pizza s;",
+			`func main
+b0:
+  v1 = icaco i64
+  v2 = const i64 0
+  jump b1
+b1: <- b0 b2
+  v6 = phi i64 [b0: v2] [b2: v7]
+  v3 = phi i64 [b0: v1] [b2: v9]
+  v4 = const i64 0
+  v5 = gt bool v3 v4
+  if v5 b2 b3
+b2: <- b1
+  v7 = add i64 v6 v3
+  v8 = const i64 1
+  v9 = sub i64 v3 v8
+  jump b1
+b3: <- b1
+  pizza v6
+  exit
+`,
+		},
+		{
+			"donuts i = 0, 3 { waffles i { dessert; pizza 1; } fries { seconds; } }",
+			`func main
+b0:
+  v1 = const i64 0
+  v2 = const i64 3
+  jump b1
+b1: <- b0 b6
+  v3 = phi i64 [b0: v1] [b6: v8]
+  v4 = lt bool v3 v2
+  if v4 b2 b3
+b2: <- b1
+  v5 = const i64 0
+  v6 = ne bool v3 v5
+  if v6 b4 b5
+b3: <- b1 b4
+  exit
+b4: <- b2
+  jump b3
+b5: <- b2
+  jump b6
+b6: <- b5
+  v7 = const i64 1
+  v8 = add i64 v3 v7
+  jump b1
+`,
+		},
+	}
+
+	for i, tt := range tests {
+		fn := buildSSAInput(t, tt.input)
+		if fn.String() != tt.expected {
+			t.Errorf("tests[%d] - wrong dump.\nexpected:\n%s\ngot:\n%s", i, tt.expected, fn.String())
+		}
+		if err := VerifySSA(fn); err != nil {
+			t.Errorf("tests[%d] - VerifySSA failed: %s", i, err)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"cheese x = 1; noodles icaco { x = cake; } pizza x;", "ssa: x holds INTEGER on one path and BOOLEAN on another"},
+		{"donuts i = cake, 3 { }", "ssa: donuts expected an integer start, got boolean"},
+		{"donuts i = 0, 3 > 1 { }", "ssa: donuts expected an integer end, got boolean"},
+	}
+	for i, tt := range errors {
+		l := NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		_, err := BuildSSA(program)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("errors[%d] - expected %q. got=%v", i, tt.expected, err)
+		}
+	}
+}
+
+func TestBuildSSARandomProgramsVerify(t *testing.T) {
+	rng := rand.New(rand.NewSource(29))
+
+	for i := 0; i < 300; i++ {
+		input := randomProgram(rng)
+
+		l := NewLexer(input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		fn, err := BuildSSA(program)
+		if err != nil {
+			// Random programs may read names before binding them.
+			continue
+		}
+		if err := VerifySSA(fn); err != nil {
+			t.Fatalf("VerifySSA failed for %q: %s\n%s", input, err, fn)
+		}
+	}
+}
+
+// diamond builds b0 -> {b1, b2} -> b3 with a phi in b3 merging one value from each arm.
+func diamond() (*Function, map[string]*Value) {
+	fn := &Function{Name: "diamond"}
+	blocks := make([]*Block, 4)
+	for i := range blocks {
+		blocks[i] = &Block{ID: i, Kind: BlockPlain}
+	}
+	fn.Blocks = blocks
+	edge := func(from, to int) {
+		blocks[from].Succs = append(blocks[from].Succs, blocks[to])
+		blocks[to].Preds = append(blocks[to].Preds, blocks[from])
+	}
+	edge(0, 1)
+	edge(0, 2)
+	edge(1, 3)
+	edge(2, 3)
+
+	cond := &Value{ID: 1, Op: OpConst, Type: IRBool, Aux: 1, Block: blocks[0]}
+	one := &Value{ID: 2, Op: OpConst, Type: IRInt, Aux: 1, Block: blocks[1]}
+	two := &Value{ID: 3, Op: OpConst, Type: IRInt, Aux: 2, Block: blocks[2]}
+	phi := &Value{ID: 4, Op: OpPhi, Type: IRInt, Args: []*Value{one, two}, Block: blocks[3]}
+	print := &Value{ID: 5, Op: OpPrint, Type: IRVoid, Args: []*Value{phi}, Block: blocks[3]}
+
+	blocks[0].Values = []*Value{cond}
+	blocks[0].Kind = BlockIf
+	blocks[0].Control = cond
+	blocks[1].Values = []*Value{one}
+	blocks[2].Values = []*Value{two}
+	blocks[3].Values = []*Value{phi, print}
+	blocks[3].Kind = BlockExit
+
+	return fn, map[string]*Value{"cond": cond, "one": one, "two": two, "phi": phi, "print": print}
+}
+
+func TestVerifySSA(t *testing.T) {
+	fn, _ := diamond()
+	if err := VerifySSA(fn); err != nil {
+		t.Fatalf("VerifySSA rejected a valid diamond: %s", err)
+	}
+
+	expected := `func diamond
+b0:
+  v1 = const bool 1
+  if v1 b1 b2
+b1: <- b0
+  v2 = const i64 1
+  jump b3
+b2: <- b0
+  v3 = const i64 2
+  jump b3
+b3: <- b1 b2
+  v4 = phi i64 [b1: v2] [b2: v3]
+  pizza v4
+  exit
+`
+	if fn.String() != expected {
+		t.Errorf("wrong dump.\nexpected:\n%s\ngot:\n%s", expected, fn.String())
+	}
+}
+
+func TestVerifySSAErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		breakIt  func(fn *Function, v map[string]*Value)
+		expected string
+	}{
+		{
+			"use not dominated by its definition",
+			func(fn *Function, v map[string]*Value) { v["print"].Args[0] = v["one"] },
+			"does not dominate b3",
+		},
+		{
+			"use before definition",
+			func(fn *Function, v map[string]*Value) {
+				b := fn.Blocks[3]
+				b.Values = []*Value{v["phi"], v["print"]}
+				extra := &Value{ID: 6, Op: OpNot, Type: IRBool, Args: []*Value{v["cond"]}, Block: b}
+				v["print"].Args[0] = extra
+				b.Values = append(b.Values, extra)
+			},
+			"before it is defined",
+		},
+		{
+			"phi arity",
+			func(fn *Function, v map[string]*Value) { v["phi"].Args = v["phi"].Args[:1] },
+			"has 1 operands but b3 has 2 predecessors",
+		},
+		{
+			"phi after ordinary values",
+			func(fn *Function, v map[string]*Value) {
+				fn.Blocks[3].Values = []*Value{v["print"], v["phi"]}
+			},
+			"not at the top",
+		},
+		{
+			"operand type",
+			func(fn *Function, v map[string]*Value) {
+				v["print"].Args[0] = &Value{ID: 7, Op: OpPrint, Type: IRVoid, Args: []*Value{v["phi"]}, Block: fn.Blocks[3]}
+				fn.Blocks[3].Values = append([]*Value{v["phi"], v["print"].Args[0]}, v["print"])
+			},
+			"has type void",
+		},
+		{
+			"branch on an integer",
+			func(fn *Function, v map[string]*Value) {
+				v["cond"].Type = IRInt
+			},
+			"branches on v1 of type i64",
+		},
+		{
+			"one sided edge",
+			func(fn *Function, v map[string]*Value) {
+				fn.Blocks[3].Preds = fn.Blocks[3].Preds[:1]
+				v["phi"].Args = v["phi"].Args[:1]
+			},
+			"missing from b3's predecessors",
+		},
+		{
+			"unreachable block",
+			func(fn *Function, v map[string]*Value) {
+				fn.Blocks = append(fn.Blocks, &Block{ID: 4, Kind: BlockExit})
+			},
+			"b4 is unreachable",
+		},
+	}
+
+	for _, tt := range tests {
+		fn, values := diamond()
+		tt.breakIt(fn, values)
+
+		err := VerifySSA(fn)
+		if err == nil {
+			t.Errorf("%s: VerifySSA accepted a broken function", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q, got=%q", tt.name, tt.expected, err.Error())
+		}
+	}
+}
+
+func buildSSAInput(t *testing.T, input string) *Function {
+	l := NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fn, err := BuildSSA(program)
+	if err != nil {
+		t.Fatalf("BuildSSA returned an error: %s", err)
+	}
+	return fn
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The compilers cannot keep an Environment around at runtime, so they resolve every
+// variable while compiling instead. A symbolTable mirrors the Environment chain the
+// evaluator would build: each block gets its own table, and each cheese binding gets a
+// storage location that no other binding in the program shares. The table also follows
+// the type of the value a variable holds, which can change as the program runs.
+
+// symbol is a variable as the compilers see it.
+type symbol struct {
+	name    string     // The name used in the source.
+	storage string     // The name of the backend's storage for it, unique in the program.
+	typ     ObjectType // The type of the value it holds at the current point of the program.
+	fixed   ObjectType // The type it was declared with, as in cheese nuggets x, or "".
+}
+
+// check returns an error if a variable declared with a type would be given a value of
+// another type. The message matches the one the evaluator raises at runtime.
+func (sym *symbol) check(typ ObjectType) error {
+	if sym.fixed != "" && typ != sym.fixed {
+		return fmt.Errorf("%s %s cannot hold %s", typeNames[sym.fixed], sym.name, typeNameOf(typ))
+	}
+	return nil
+}
+
+// symbolTable holds the variables declared in one scope.
+type symbolTable struct {
+	symbols map[string]*symbol
+	outer   *symbolTable
+	count   *int // Shared by every table of a program, to number inner bindings.
+}
+
+// newSymbolTable creates the table for the top level of a program.
+func newSymbolTable() *symbolTable {
+	return &symbolTable{symbols: map[string]*symbol{}, count: new(int)}
+}
+
+// enclose creates the table for a block nested in s.
+func (s *symbolTable) enclose() *symbolTable {
+	return &symbolTable{symbols: map[string]*symbol{}, outer: s, count: s.count}
+}
+
+// lookup finds the innermost binding of name.
+func (s *symbolTable) lookup(name string) (*symbol, bool) {
+	for t := s; t != nil; t = t.outer {
+		if sym, ok := t.symbols[name]; ok {
+			return sym, true
+		}
+	}
+	return nil, false
+}
+
+// declare binds name in this scope and returns its symbol. Like Environment.Declare,
+// declaring a name again in the same scope rebinds the existing variable. Top level
+// variables are stored under their own name; inner ones get a numbered storage name
+// built by mangle so they never clash with the variables they shadow.
+func (s *symbolTable) declare(name string, typ ObjectType, mangle func(name string, n int) string) *symbol {
+	if sym, ok := s.symbols[name]; ok {
+		sym.typ = typ
+		sym.fixed = ""
+		return sym
+	}
+
+	sym := &symbol{name: name, storage: name, typ: typ}
+	if s.outer != nil {
+		*s.count++
+		sym.storage = mangle(name, *s.count)
+	}
+	s.symbols[name] = sym
+	return sym
+}
+
+// declareLet declares the variable of a cheese statement whose value has type typ,
+// checking and recording its annotation if it has one.
+func (s *symbolTable) declareLet(stmt *LetStatement, typ ObjectType, mangle func(name string, n int) string) (*symbol, error) {
+	sym := s.declare(stmt.Name.Value, typ, mangle)
+	if fixed, ok := stmt.AnnotatedType(); ok {
+		sym.fixed = fixed
+		if err := sym.check(typ); err != nil {
+			return nil, err
+		}
+	}
+	return sym, nil
+}
+
+// typeSnapshot records the type every variable visible from s holds at some point.
+type typeSnapshot map[*symbol]ObjectType
+
+// snapshot returns the types every visible variable currently holds.
+func (s *symbolTable) snapshot() typeSnapshot {
+	snap := typeSnapshot{}
+	for t := s; t != nil; t = t.outer {
+		for _, sym := range t.symbols {
+			if _, shadowed := snap[sym]; !shadowed {
+				snap[sym] = sym.typ
+			}
+		}
+	}
+	return snap
+}
+
+// restore puts the types recorded in snap back.
+func (snap typeSnapshot) restore() {
+	for sym, typ := range snap {
+		sym.typ = typ
+	}
+}
+
+// mergeTypes checks that the variables in before agree on their type at the end of two
+// paths through the program, such as the two branches of a waffles, and returns an
+// error naming the first variable (by name) that does not.
+func mergeTypes(a, b typeSnapshot) error {
+	var conflicts []*symbol
+	for sym, typ := range a {
+		if other, ok := b[sym]; ok && other != typ {
+			conflicts = append(conflicts, sym)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].name < conflicts[j].name })
+	sym := conflicts[0]
+	return fmt.Errorf("%s holds %s on one path and %s on another", sym.name, a[sym], b[sym])
+}
+
+// loopTypes follows the types of variables through the body of a loop. The compiled
+// body runs the same code in every iteration, so wherever control leaves it, at its end
+// or at a dessert or seconds, every variable has to hold the type it held before.
+type loopTypes struct {
+	before typeSnapshot
+	exits  []typeSnapshot
+}
+
+// newLoopTypes starts following the types visible from s at the start of a loop.
+func newLoopTypes(s *symbolTable) *loopTypes {
+	return &loopTypes{before: s.snapshot()}
+}
+
+// exit records the types visible from s where control leaves the body.
+func (l *loopTypes) exit(s *symbolTable) {
+	l.exits = append(l.exits, s.snapshot())
+}
+
+// check returns an error for the first exit at which a variable changed its type.
+func (l *loopTypes) check() error {
+	for _, exit := range l.exits {
+		if err := mergeTypes(l.before, exit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
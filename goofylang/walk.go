@@ -0,0 +1,211 @@
+package main
+
+import "reflect"
+
+// walkAST calls visit for node and then for every node inside it, depth first, in the
+// order they appear in the source. Missing children, such as the value of a bare takeout,
+// are skipped.
+func walkAST(node Node, visit func(Node)) {
+	if node == nil || isNilNode(node) {
+		return
+	}
+	visit(node)
+
+	switch node := node.(type) {
+	case *Program:
+		for _, stmt := range node.Statements {
+			walkAST(stmt, visit)
+		}
+	case *LetStatement:
+		walkAST(node.Name, visit)
+		walkAST(node.Value, visit)
+	case *AssignStatement:
+		walkAST(node.Name, visit)
+		walkAST(node.Value, visit)
+	case *ExpressionStatement:
+		walkAST(node.Expression, visit)
+	case *PrintStatement:
+		walkAST(node.Value, visit)
+	case *ReturnStatement:
+		walkAST(node.Value, visit)
+	case *BlockStatement:
+		for _, stmt := range node.Statements {
+			walkAST(stmt, visit)
+		}
+	case *IfStatement:
+		walkAST(node.Condition, visit)
+		walkAST(node.Consequence, visit)
+		walkAST(node.Alternative, visit)
+	case *WhileStatement:
+		walkAST(node.Condition, visit)
+		walkAST(node.Body, visit)
+	case *ForStatement:
+		walkAST(node.Variable, visit)
+		walkAST(node.Start, visit)
+		walkAST(node.End, visit)
+		walkAST(node.Body, visit)
+	case *TypeSwitchStatement:
+		walkAST(node.Subject, visit)
+		for _, c := range node.Cases {
+			walkAST(c.Body, visit)
+		}
+		walkAST(node.Default, visit)
+	case *PrefixExpression:
+		walkAST(node.Right, visit)
+	case *InfixExpression:
+		walkAST(node.Left, visit)
+		walkAST(node.Right, visit)
+	case *TypeofExpression:
+		walkAST(node.Right, visit)
+	case *NuggetsExpression:
+		walkAST(node.Value, visit)
+	case *FunctionLiteral:
+		for _, param := range node.Parameters {
+			walkAST(param, visit)
+		}
+		walkAST(node.Body, visit)
+	case *CallExpression:
+		walkAST(node.Function, visit)
+		for _, arg := range node.Arguments {
+			walkAST(arg, visit)
+		}
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			walkAST(el, visit)
+		}
+	case *HashLiteral:
+		for i := range node.Keys {
+			walkAST(node.Keys[i], visit)
+			walkAST(node.Values[i], visit)
+		}
+	case *IndexExpression:
+		walkAST(node.Left, visit)
+		walkAST(node.Index, visit)
+	}
+}
+
+// isNilNode reports whether node is a typed nil, such as the *BlockStatement of an
+// IfStatement without fries stored in its Statement field.
+func isNilNode(node Node) bool {
+	switch node := node.(type) {
+	case *BlockStatement:
+		return node == nil
+	case *Identifier:
+		return node == nil
+	default:
+		return false
+	}
+}
+
+// incompleteNode returns the first node inside node that lacks a child every node of its
+// kind has, such as a cheese without a value. The parser leaves such holes where it
+// reported an error, so a program without them is one the parser fully accepted.
+func incompleteNode(node Node) (Node, bool) {
+	var found Node
+	walkAST(node, func(n Node) {
+		if found == nil && !hasRequiredChildren(n) {
+			found = n
+		}
+	})
+	return found, found != nil
+}
+
+// hasRequiredChildren reports whether none of the children node must have is missing.
+func hasRequiredChildren(node Node) bool {
+	var required []Node
+	switch node := node.(type) {
+	case *LetStatement:
+		required = []Node{node.Name, node.Value}
+	case *AssignStatement:
+		required = []Node{node.Name, node.Value}
+	case *ExpressionStatement:
+		required = []Node{node.Expression}
+	case *PrintStatement:
+		required = []Node{node.Value}
+	case *IfStatement:
+		required = []Node{node.Condition, node.Consequence}
+	case *WhileStatement:
+		required = []Node{node.Condition, node.Body}
+	case *ForStatement:
+		required = []Node{node.Variable, node.Start, node.End, node.Body}
+	case *TypeSwitchStatement:
+		required = []Node{node.Subject}
+		for _, c := range node.Cases {
+			required = append(required, c.Body)
+		}
+	case *PrefixExpression:
+		required = []Node{node.Right}
+	case *InfixExpression:
+		required = []Node{node.Left, node.Right}
+	case *TypeofExpression:
+		required = []Node{node.Right}
+	case *NuggetsExpression:
+		required = []Node{node.Value}
+	case *FunctionLiteral:
+		required = []Node{node.Body}
+		for _, param := range node.Parameters {
+			required = append(required, param)
+		}
+	case *CallExpression:
+		required = []Node{node.Function}
+		for _, arg := range node.Arguments {
+			required = append(required, arg)
+		}
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			required = append(required, el)
+		}
+	case *HashLiteral:
+		for i := range node.Keys {
+			required = append(required, node.Keys[i], node.Values[i])
+		}
+	case *IndexExpression:
+		required = []Node{node.Left, node.Index}
+	}
+
+	for _, child := range required {
+		if child == nil || reflect.ValueOf(child).IsNil() {
+			return false
+		}
+	}
+	return true
+}
+
+// completesNormally reports whether control can reach the end of a list of statements,
+// rather than always leaving it with a takeout, dessert or seconds. Loops are assumed to
+// end, whatever their condition.
+func completesNormally(stmts []Statement) bool {
+	for _, stmt := range stmts {
+		if !statementCompletes(stmt) {
+			return false
+		}
+	}
+	return true
+}
+
+// statementCompletes reports whether control can continue after stmt.
+func statementCompletes(stmt Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ReturnStatement, *LoopControlStatement:
+		return false
+	case *BlockStatement:
+		return completesNormally(stmt.Statements)
+	case *IfStatement:
+		if stmt.Alternative == nil {
+			return true
+		}
+		return completesNormally(stmt.Consequence.Statements) || statementCompletes(stmt.Alternative)
+	case *TypeSwitchStatement:
+		if stmt.Default == nil || completesNormally(stmt.Default.Statements) {
+			return true
+		}
+		for _, c := range stmt.Cases {
+			if completesNormally(c.Body.Statements) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
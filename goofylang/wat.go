@@ -0,0 +1,535 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The WebAssembly backend turns a parsed Program into a WebAssembly text (WAT) module.
+// Every goofylang value is an i64, with cake stored as 1 and broccoli as 0. Variables
+// declared with cheese become mutable globals, with the ones declared inside blocks
+// numbered so that they never clash with the variables they shadow. Pizza calls the imported host function
+// "goofy.pizza" (or "goofy.pizza_bool" for booleans, imported only when needed) and
+// icaco calls the imported "goofy.icaco" to read an integer. The program body runs from
+// the exported "main" function. Loops are a wasm loop inside a block, so dessert and
+// seconds become branches to labels.
+//
+// Like the interpreter and the C backend, arithmetic does not wrap on overflow: apple,
+// salmon and pancakes call checking helpers that trap with unreachable, and i64.div_s
+// traps by itself on division by zero and on MinInt64 / -1. Only the helpers a program
+// uses are emitted.
+
+// watCompiler holds the state needed while emitting a single module.
+type watCompiler struct {
+	scope     *symbolTable    // The variables visible at the current point.
+	globals   map[string]bool // The storage name of every variable in the program.
+	printBool bool            // Whether $pizza_bool has to be imported.
+	checked   map[string]bool // The checked arithmetic helpers the program calls.
+	depth     int             // How many structured instructions the next instruction is nested in.
+	loops     []*watLoop      // The loops enclosing the current point, innermost last.
+	labels    int             // Number of loops compiled so far, to name their labels.
+	body      strings.Builder // Instructions emitted for $main.
+}
+
+// CompileWAT translates a goofylang program into a WebAssembly text module.
+func CompileWAT(program *Program) (string, error) {
+	c := &watCompiler{scope: newSymbolTable(), globals: map[string]bool{}, checked: map[string]bool{}}
+
+	for _, stmt := range program.Statements {
+		if err := c.compileStatement(stmt); err != nil {
+			return "", err
+		}
+	}
+
+	return c.module(), nil
+}
+
+// module assembles the imports, globals and the main function into the final text.
+func (c *watCompiler) module() string {
+	var out strings.Builder
+
+	out.WriteString("(module\n")
+	out.WriteString("  (import \"goofy\" \"pizza\" (func $pizza (param i64)))\n")
+	out.WriteString("  (import \"goofy\" \"icaco\" (func $icaco (result i64)))\n")
+	if c.printBool {
+		out.WriteString("  (import \"goofy\" \"pizza_bool\" (func $pizza_bool (param i64)))\n")
+	}
+
+	// Sort the globals so the output is stable between runs.
+	names := make([]string, 0, len(c.globals))
+	for name := range c.globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&out, "  (global $%s (mut i64) (i64.const 0))\n", name)
+	}
+
+	for _, name := range []string{"add", "sub", "mul"} {
+		if c.checked[name] {
+			out.WriteString(watCheckedHelpers[name])
+		}
+	}
+
+	out.WriteString("  (func $main (export \"main\")\n")
+	out.WriteString(c.body.String())
+	out.WriteString("  )\n")
+	out.WriteString(")\n")
+
+	return out.String()
+}
+
+// emit writes a single instruction into the body of $main.
+func (c *watCompiler) emit(format string, args ...interface{}) {
+	c.body.WriteString(strings.Repeat("  ", c.depth+2))
+	fmt.Fprintf(&c.body, format, args...)
+	c.body.WriteString("\n")
+}
+
+// emitChecked calls the checked arithmetic helper for an operation, such as "add".
+func (c *watCompiler) emitChecked(name string) {
+	c.checked[name] = true
+	c.emit("call $goofy_%s", name)
+}
+
+// watCheckedHelpers are the functions apple, salmon and pancakes compile to. Each traps if
+// the exact result does not fit in an i64: a sum or difference overflowed when its sign
+// differs from the signs the operands force on it, and a product when dividing it by one
+// operand does not give back the other.
+var watCheckedHelpers = map[string]string{
+	"add": `  (func $goofy_add (param $a i64) (param $b i64) (result i64)
+    (local $r i64)
+    local.get $a
+    local.get $b
+    i64.add
+    local.set $r
+    local.get $a
+    local.get $r
+    i64.xor
+    local.get $b
+    local.get $r
+    i64.xor
+    i64.and
+    i64.const 0
+    i64.lt_s
+    if
+      unreachable
+    end
+    local.get $r
+  )
+`,
+	"sub": `  (func $goofy_sub (param $a i64) (param $b i64) (result i64)
+    (local $r i64)
+    local.get $a
+    local.get $b
+    i64.sub
+    local.set $r
+    local.get $a
+    local.get $b
+    i64.xor
+    local.get $a
+    local.get $r
+    i64.xor
+    i64.and
+    i64.const 0
+    i64.lt_s
+    if
+      unreachable
+    end
+    local.get $r
+  )
+`,
+	"mul": `  (func $goofy_mul (param $a i64) (param $b i64) (result i64)
+    (local $r i64)
+    local.get $a
+    local.get $b
+    i64.mul
+    local.set $r
+    local.get $a
+    i64.const -1
+    i64.eq
+    if
+      local.get $b
+      i64.const -9223372036854775808
+      i64.eq
+      if
+        unreachable
+      end
+    else
+      local.get $a
+      i64.eqz
+      i32.eqz
+      if
+        local.get $r
+        local.get $a
+        i64.div_s
+        local.get $b
+        i64.ne
+        if
+          unreachable
+        end
+      end
+    end
+    local.get $r
+  )
+`,
+}
+
+// watMangle names the global of a variable declared inside a block. Identifiers never
+// contain '.', so the result cannot clash with a top level variable.
+func watMangle(name string, n int) string {
+	return fmt.Sprintf("%s.%d", name, n)
+}
+
+// compileStatement emits the instructions for one statement.
+func (c *watCompiler) compileStatement(stmt Statement) error {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		typ, err := c.compileExpression(stmt.Value)
+		if err != nil {
+			return err
+		}
+		sym, err := c.scope.declareLet(stmt, typ, watMangle)
+		if err != nil {
+			return fmt.Errorf("wat: %s", err)
+		}
+		c.globals[sym.storage] = true
+		c.emit("global.set $%s", sym.storage)
+	case *AssignStatement:
+		sym, ok := c.scope.lookup(stmt.Name.Value)
+		if !ok {
+			return fmt.Errorf("wat: cannot assign to undeclared identifier: %s", stmt.Name.Value)
+		}
+		operator, compound := stmt.Operator()
+		if compound {
+			c.emit("global.get $%s", sym.storage)
+		}
+		typ, err := c.compileExpression(stmt.Value)
+		if err != nil {
+			return err
+		}
+		if compound {
+			if typ, err = c.emitOperator(operator, sym.typ, typ); err != nil {
+				return err
+			}
+		}
+		if err := sym.check(typ); err != nil {
+			return fmt.Errorf("wat: %s", err)
+		}
+		sym.typ = typ
+		c.emit("global.set $%s", sym.storage)
+	case *PrintStatement:
+		typ, err := c.compileExpression(stmt.Value)
+		if err != nil {
+			return err
+		}
+		if typ == BOOLEAN_OBJ {
+			c.printBool = true
+			c.emit("call $pizza_bool")
+		} else {
+			c.emit("call $pizza")
+		}
+	case *ExpressionStatement:
+		if _, err := c.compileExpression(stmt.Expression); err != nil {
+			return err
+		}
+		c.emit("drop")
+	case *BlockStatement:
+		return c.compileBlock(stmt)
+	case *IfStatement:
+		return c.compileIfStatement(stmt)
+	case *WhileStatement:
+		return c.compileWhileStatement(stmt)
+	case *ForStatement:
+		return c.compileForStatement(stmt)
+	case *LoopControlStatement:
+		if len(c.loops) == 0 {
+			return fmt.Errorf("wat: %s outside of a loop", stmt.Token.Literal)
+		}
+		loop := c.loops[len(c.loops)-1]
+		loop.types.exit(c.scope)
+		if stmt.IsBreak() {
+			c.emit("br $break_%d", loop.label)
+		} else {
+			c.emit("br $continue_%d", loop.label)
+		}
+	case *TypeSwitchStatement:
+		return fmt.Errorf("wat: tacos is not supported")
+	default:
+		return fmt.Errorf("wat: unsupported statement %T", stmt)
+	}
+	return nil
+}
+
+// compileBlock emits the statements of a block in a scope of their own.
+func (c *watCompiler) compileBlock(block *BlockStatement) error {
+	outer := c.scope
+	c.scope = outer.enclose()
+	defer func() { c.scope = outer }()
+
+	for _, stmt := range block.Statements {
+		if err := c.compileStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileIfStatement emits a wasm if instruction. The condition is an i64 and if wants
+// an i32, so it is tested against zero first, which is also what makes 0 falsy.
+func (c *watCompiler) compileIfStatement(stmt *IfStatement) error {
+	if _, err := c.compileExpression(stmt.Condition); err != nil {
+		return err
+	}
+	c.emit("i64.eqz")
+	c.emit("i32.eqz")
+	c.emit("if")
+
+	before := c.scope.snapshot()
+	c.depth++
+	if err := c.compileBlock(stmt.Consequence); err != nil {
+		return err
+	}
+	afterThen := c.scope.snapshot()
+	before.restore()
+
+	if stmt.Alternative != nil {
+		c.depth--
+		c.emit("else")
+		c.depth++
+		if err := c.compileStatement(stmt.Alternative); err != nil {
+			return err
+		}
+	}
+	c.depth--
+	c.emit("end")
+
+	if err := mergeTypes(afterThen, c.scope.snapshot()); err != nil {
+		return fmt.Errorf("wat: %s", err)
+	}
+	return nil
+}
+
+// watLoop is a loop being compiled.
+type watLoop struct {
+	label int // The number in the names of its labels.
+	types *loopTypes
+}
+
+// compileLoop emits a loop around body. Every iteration first runs test, which has to
+// branch to $break_N to leave the loop, then the body inside block $continue_N, then
+// step. A global has one type for the whole program, so the body must leave every
+// variable holding the type it held before the loop.
+func (c *watCompiler) compileLoop(body *BlockStatement, test, enter, step func(label int) error) error {
+	c.labels++
+	loop := &watLoop{label: c.labels, types: newLoopTypes(c.scope)}
+
+	c.emit("block $break_%d", loop.label)
+	c.depth++
+	c.emit("loop $loop_%d", loop.label)
+	c.depth++
+	if err := test(loop.label); err != nil {
+		return err
+	}
+
+	outer := c.scope
+	c.scope = outer.enclose()
+	if err := enter(loop.label); err != nil {
+		return err
+	}
+	c.emit("block $continue_%d", loop.label)
+	c.depth++
+	c.loops = append(c.loops, loop)
+	err := c.compileBlock(body)
+	c.loops = c.loops[:len(c.loops)-1]
+	if err != nil {
+		return err
+	}
+	loop.types.exit(c.scope)
+	c.scope = outer
+	c.depth--
+	c.emit("end")
+
+	if err := step(loop.label); err != nil {
+		return err
+	}
+	c.emit("br $loop_%d", loop.label)
+	c.depth--
+	c.emit("end")
+	c.depth--
+	c.emit("end")
+
+	if err := loop.types.check(); err != nil {
+		return fmt.Errorf("wat: %s", err)
+	}
+	return nil
+}
+
+// compileWhileStatement emits a noodles loop, which leaves as soon as its condition is falsy.
+func (c *watCompiler) compileWhileStatement(stmt *WhileStatement) error {
+	none := func(int) error { return nil }
+	return c.compileLoop(stmt.Body, func(label int) error {
+		if _, err := c.compileExpression(stmt.Condition); err != nil {
+			return err
+		}
+		c.emit("i64.eqz")
+		c.emit("br_if $break_%d", label)
+		return nil
+	}, none, none)
+}
+
+// compileForStatement emits a donuts loop. The bounds are evaluated once into hidden
+// globals named after the loop, and the counter is copied into the loop variable at the
+// start of every iteration so assigning to it does not change how often the loop runs.
+func (c *watCompiler) compileForStatement(stmt *ForStatement) error {
+	counter := fmt.Sprintf("donuts.%d", c.labels+1)
+	end := counter + ".end"
+	for _, bound := range []struct {
+		exp    Expression
+		global string
+		name   string
+	}{{stmt.Start, counter, "start"}, {stmt.End, end, "end"}} {
+		typ, err := c.compileExpression(bound.exp)
+		if err != nil {
+			return err
+		}
+		if typ != INTEGER_OBJ {
+			return fmt.Errorf("wat: donuts expected an integer %s, got %s", bound.name, typeNameOf(typ))
+		}
+		c.globals[bound.global] = true
+		c.emit("global.set $%s", bound.global)
+	}
+
+	return c.compileLoop(stmt.Body, func(label int) error {
+		c.emit("global.get $%s", counter)
+		c.emit("global.get $%s", end)
+		c.emit("i64.ge_s")
+		c.emit("br_if $break_%d", label)
+		return nil
+	}, func(int) error {
+		sym := c.scope.declare(stmt.Variable.Value, INTEGER_OBJ, watMangle)
+		c.globals[sym.storage] = true
+		c.emit("global.get $%s", counter)
+		c.emit("global.set $%s", sym.storage)
+		return nil
+	}, func(int) error {
+		c.emit("global.get $%s", counter)
+		c.emit("i64.const 1")
+		c.emit("i64.add")
+		c.emit("global.set $%s", counter)
+		return nil
+	})
+}
+
+// compileExpression emits instructions that leave the value of the expression on the
+// stack and returns the type of that value.
+func (c *watCompiler) compileExpression(exp Expression) (ObjectType, error) {
+	switch exp := exp.(type) {
+	case *IntegralLiteral:
+		c.emit("i64.const %d", exp.Value)
+		return INTEGER_OBJ, nil
+	case *BooleanLiteral:
+		if exp.Value {
+			c.emit("i64.const 1")
+		} else {
+			c.emit("i64.const 0")
+		}
+		return BOOLEAN_OBJ, nil
+	case *Identifier:
+		sym, ok := c.scope.lookup(exp.Value)
+		if !ok {
+			return "", fmt.Errorf("wat: identifier not found: %s", exp.Value)
+		}
+		c.emit("global.get $%s", sym.storage)
+		return sym.typ, nil
+	case *InputExpression:
+		c.emit("call $icaco")
+		return INTEGER_OBJ, nil
+	case *PrefixExpression:
+		if exp.Token.Type == TOKEN_SALMON {
+			c.emit("i64.const 0")
+		}
+		right, err := c.compileExpression(exp.Right)
+		if err != nil {
+			return "", err
+		}
+		typ, err := prefixResultType(exp.Token.Type, right)
+		if err != nil {
+			return "", fmt.Errorf("wat: %s", err)
+		}
+		if exp.Token.Type == TOKEN_SALMON {
+			c.emitChecked("sub")
+		} else {
+			c.emit("i64.eqz")
+			c.emit("i64.extend_i32_u")
+		}
+		return typ, nil
+	case *InfixExpression:
+		left, err := c.compileExpression(exp.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := c.compileExpression(exp.Right)
+		if err != nil {
+			return "", err
+		}
+		return c.emitOperator(exp.Token.Type, left, right)
+	case *FunctionLiteral, *CallExpression:
+		return "", fmt.Errorf("wat: burritos are not supported")
+	case *StringLiteral:
+		return "", fmt.Errorf("wat: strings are not supported")
+	case *ArrayLiteral, *HashLiteral, *IndexExpression:
+		return "", fmt.Errorf("wat: arrays and hashes are not supported")
+	case *TypeofExpression:
+		return "", fmt.Errorf("wat: tacos is not supported")
+	case *NuggetsExpression:
+		// Booleans are already the i64 values 1 and 0, so only the type changes.
+		if _, err := c.compileExpression(exp.Value); err != nil {
+			return "", err
+		}
+		return INTEGER_OBJ, nil
+	case nil:
+		return "", fmt.Errorf("wat: missing expression")
+	default:
+		return "", fmt.Errorf("wat: unsupported expression %T", exp)
+	}
+}
+
+// watComparisons maps each comparison operator to the wasm instruction implementing it.
+var watComparisons = map[TokenType]string{
+	TOKEN_EQ:     "i64.eq",
+	TOKEN_NOT_EQ: "i64.ne",
+	TOKEN_LT:     "i64.lt_s",
+	TOKEN_GT:     "i64.gt_s",
+	TOKEN_LT_EQ:  "i64.le_s",
+	TOKEN_GT_EQ:  "i64.ge_s",
+}
+
+// emitOperator emits the instructions for an infix operator whose operands are on the
+// stack and returns the type of its result.
+func (c *watCompiler) emitOperator(operator TokenType, left, right ObjectType) (ObjectType, error) {
+	typ, err := infixResultType(operator, left, right)
+	if err != nil {
+		return "", fmt.Errorf("wat: %s", err)
+	}
+
+	switch operator {
+	case TOKEN_APPLE:
+		c.emitChecked("add")
+	case TOKEN_SALMON:
+		c.emitChecked("sub")
+	case TOKEN_PANCAKES:
+		c.emitChecked("mul")
+	case TOKEN_PIE:
+		// Like the interpreter, wasm traps on division by zero and on MinInt64 / -1.
+		c.emit("i64.div_s")
+	case TOKEN_LEFTOVERS:
+		c.emit("i64.rem_s")
+	default:
+		// Comparisons produce an i32 that has to be widened back to an i64.
+		c.emit(watComparisons[operator])
+		c.emit("i64.extend_i32_u")
+	}
+	return typ, nil
+}
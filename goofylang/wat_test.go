@@ -0,0 +1,746 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompileWAT(t *testing.T) {
+	input := `
+    cheese x = 7;
+    cheese y = icaco;
+    cheese z = x apple y - 2;
+    z += 1 * 3 / 2 % 5;
+    z = z - x;
+    pizza salmon z;
+    `
+
+	wat := compileWATInput(t, input)
+
+	if err := checkWAT(wat); err != nil {
+		t.Fatalf("generated module is not well formed: %s\n%s", err, wat)
+	}
+
+	expected := []string{
+		`(import "goofy" "pizza" (func $pizza (param i64)))`,
+		`(import "goofy" "icaco" (func $icaco (result i64)))`,
+		"(global $x (mut i64) (i64.const 0))",
+		"(global $z (mut i64) (i64.const 0))",
+		"call $icaco",
+		"global.set $y",
+		"call $goofy_add",
+		"call $goofy_sub",
+		"call $goofy_mul",
+		"call $pizza",
+	}
+	for _, want := range expected {
+		if !strings.Contains(wat, want) {
+			t.Errorf("module does not contain %q\n%s", want, wat)
+		}
+	}
+}
+
+func TestCompileWATUndefinedIdentifier(t *testing.T) {
+	l := NewLexer("cheese x = y;")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err := CompileWAT(program)
+	if err == nil {
+		t.Fatalf("expected an error for an undefined identifier")
+	}
+	if !strings.Contains(err.Error(), "identifier not found: y") {
+		t.Errorf("wrong error. got=%q", err.Error())
+	}
+}
+
+func TestCompileWATAssignUndeclared(t *testing.T) {
+	l := NewLexer("x = 1;")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err := CompileWAT(program)
+	if err == nil || !strings.Contains(err.Error(), "cannot assign to undeclared identifier: x") {
+		t.Fatalf("expected an undeclared assignment error. got=%v", err)
+	}
+}
+
+func TestCompileWATBooleans(t *testing.T) {
+	input := `
+    cheese big = icaco >= 10;
+    cheese same = big == broccoli;
+    pizza !same;
+    pizza 1 < 2;
+    `
+
+	wat := compileWATInput(t, input)
+
+	if err := checkWAT(wat); err != nil {
+		t.Fatalf("generated module is not well formed: %s\n%s", err, wat)
+	}
+
+	expected := []string{
+		`(import "goofy" "pizza_bool" (func $pizza_bool (param i64)))`,
+		"i64.ge_s",
+		"i64.eq",
+		"i64.eqz",
+		"i64.lt_s",
+		"i64.extend_i32_u",
+		"call $pizza_bool",
+	}
+	for _, want := range expected {
+		if !strings.Contains(wat, want) {
+			t.Errorf("module does not contain %q\n%s", want, wat)
+		}
+	}
+
+	// Modules that never print a boolean do not need the extra import.
+	if wat := compileWATInput(t, "pizza 1;"); strings.Contains(wat, "pizza_bool") {
+		t.Errorf("unexpected pizza_bool import\n%s", wat)
+	}
+}
+
+func TestCompileWATTypeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"pizza 1 + cake;", "wat: type mismatch: nuggets + boolean"},
+		{"cheese b = cake; b -= 1;", "wat: type mismatch: boolean - nuggets"},
+		{"pizza cake < broccoli;", "wat: unknown operator: boolean < boolean"},
+		{"pizza !icaco;", "wat: unknown operator: !nuggets"},
+		{"cheese f = burrito() { takeout 1; }; pizza f();", "wat: burritos are not supported"},
+		{"pizza \"hi\";", "wat: strings are not supported"},
+		{"cheese a = [1, 2]; pizza a[0];", "wat: arrays and hashes are not supported"},
+		{"pizza tacos 1;", "wat: tacos is not supported"},
+		{"tacos 1 { nuggets { pizza 1; } }", "wat: tacos is not supported"},
+		{"cheese nuggets b = icaco < 1;", "wat: nuggets b cannot hold boolean"},
+		{"cheese nuggets n = 1; waffles icaco { n = cake; }", "wat: nuggets n cannot hold boolean"},
+	}
+
+	for _, tt := range tests {
+		l := NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		_, err := CompileWAT(program)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCompileWATIfStatements(t *testing.T) {
+	input := `
+    cheese x = icaco;
+    waffles x > 1 {
+        cheese x = 2;
+        pizza x;
+    } fries waffles x {
+        x = 3;
+    }
+    pizza x;
+    `
+
+	wat := compileWATInput(t, input)
+
+	if err := checkWAT(wat); err != nil {
+		t.Fatalf("generated module is not well formed: %s\n%s", err, wat)
+	}
+
+	expected := []string{
+		"(global $x (mut i64) (i64.const 0))",
+		"(global $x.1 (mut i64) (i64.const 0))",
+		"global.set $x.1",
+		"i32.eqz\n    if\n",
+		"    else\n      global.get $x\n",
+		"        global.set $x\n      end\n    end\n",
+	}
+	for _, want := range expected {
+		if !strings.Contains(wat, want) {
+			t.Errorf("module does not contain %q\n%s", want, wat)
+		}
+	}
+
+	l := NewLexer("cheese x = 1; waffles icaco { x = cake; } pizza x;")
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	_, err := CompileWAT(program)
+	if err == nil || err.Error() != "wat: x holds BOOLEAN on one path and INTEGER on another" {
+		t.Errorf("expected a type error for x. got=%v", err)
+	}
+}
+
+func TestCompileWATLoops(t *testing.T) {
+	input := `
+    cheese total = 0;
+    noodles total < 100 {
+        donuts i = 0, icaco {
+            waffles i == 3 { seconds; }
+            waffles i > 5 { dessert; }
+            total apple= i;
+        }
+    }
+    pizza total;
+    `
+
+	wat := compileWATInput(t, input)
+
+	if err := checkWAT(wat); err != nil {
+		t.Fatalf("generated module is not well formed: %s\n%s", err, wat)
+	}
+
+	expected := []string{
+		"(global $donuts.2 (mut i64) (i64.const 0))",
+		"(global $donuts.2.end (mut i64) (i64.const 0))",
+		"(global $i.1 (mut i64) (i64.const 0))",
+		"block $break_1\n      loop $loop_1\n",
+		"i64.eqz\n        br_if $break_1\n",
+		"global.get $donuts.2.end\n              i64.ge_s\n              br_if $break_2\n",
+		"br $continue_2",
+		"br $break_2",
+		"global.set $donuts.2\n              br $loop_2\n            end\n          end\n        end\n        br $loop_1\n",
+	}
+	for _, want := range expected {
+		if !strings.Contains(wat, want) {
+			t.Errorf("module does not contain %q\n%s", want, wat)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"cheese x = 1; noodles x { x = cake; }", "wat: x holds INTEGER on one path and BOOLEAN on another"},
+		{"cheese x = 1; noodles x { waffles icaco { x = cake; dessert; } fries { x = cake; } x = 1; }", "wat: x holds INTEGER on one path and BOOLEAN on another"},
+		{"donuts i = cake, 3 { }", "wat: donuts expected an integer start, got boolean"},
+		{"donuts i = 0, broccoli { }", "wat: donuts expected an integer end, got boolean"},
+	}
+	for i, tt := range tests {
+		l := NewLexer(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		_, err := CompileWAT(program)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("tests[%d] - expected error %q. got=%v", i, tt.expected, err)
+		}
+	}
+}
+
+func TestCheckWATRejectsBrokenModules(t *testing.T) {
+	tests := []string{
+		"(module",
+		"(module (func $main (i64.add)))",
+		"(module (func $main\n call $missing\n))",
+		"(module (func $main\n global.get $nope\n drop\n))",
+		"(module (func $main\n i64.const 1\n))",
+		"(func $main)",
+		"(module (func $main\n i64.const 1\n i32.eqz\n if\n i64.const 2\n end\n))",
+		"(module (func $main\n i64.const 1\n i32.eqz\n if\n))",
+		"(module (func $main\n block $a\n br $b\n end\n))",
+		"(module (func $main\n loop $a\n i64.const 1\n end\n))",
+		"(module (func $main\n block $a\n br_if $a\n end\n))",
+		"(module (func $main\n block $a\n else\n end\n))",
+	}
+
+	for i, input := range tests {
+		if err := checkWAT(input); err == nil {
+			t.Errorf("tests[%d] - expected %q to be rejected", i, input)
+		}
+	}
+}
+
+func TestWATCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		helper string
+		a, b   int64
+	}{
+		{"add", 2, 3}, {"add", math.MaxInt64, 1}, {"add", math.MinInt64, -1}, {"add", math.MaxInt64, math.MinInt64},
+		{"add", -5, math.MinInt64 + 5}, {"add", -5, math.MinInt64 + 4},
+		{"sub", 2, 3}, {"sub", math.MinInt64, 1}, {"sub", math.MaxInt64, -1}, {"sub", 0, math.MinInt64},
+		{"sub", -1, math.MinInt64}, {"sub", 0, math.MaxInt64},
+		{"mul", 6, 7}, {"mul", -1, math.MinInt64}, {"mul", math.MinInt64, -1}, {"mul", -1, math.MaxInt64},
+		{"mul", 1 << 32, 1 << 31}, {"mul", 1 << 32, 1 << 30}, {"mul", 0, math.MinInt64}, {"mul", math.MinInt64, 1},
+		{"mul", 3037000500, 3037000500}, {"mul", -3037000499, 3037000499},
+	}
+	operators := map[string]TokenType{"add": TOKEN_APPLE, "sub": TOKEN_SALMON, "mul": TOKEN_PANCAKES}
+
+	for _, tt := range tests {
+		got, trapped, err := runWATHelper(watCheckedHelpers[tt.helper], tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.helper, err)
+		}
+		expected := evalInfixExpression(Token{Type: operators[tt.helper]}, &Integer{Value: tt.a}, &Integer{Value: tt.b})
+		if errObj, ok := expected.(*Error); ok {
+			if !trapped {
+				t.Errorf("%s(%d, %d) = %d, expected a trap like the interpreter's %q", tt.helper, tt.a, tt.b, got, errObj.Message)
+			}
+			continue
+		}
+		if trapped || got != expected.(*Integer).Value {
+			t.Errorf("%s(%d, %d) = %d (trapped %t), expected %s", tt.helper, tt.a, tt.b, got, trapped, expected.Inspect())
+		}
+	}
+}
+
+// runWATHelper runs a flat two-parameter helper function on a and b, with just the
+// instructions the checked arithmetic helpers use. It reports whether it trapped.
+func runWATHelper(source string, a, b int64) (int64, bool, error) {
+	fn, err := parseSexpr(source)
+	if err != nil {
+		return 0, false, err
+	}
+	var code []string
+	for _, child := range fn.list[1:] {
+		if !child.isList() {
+			code = append(code, child.atom)
+		}
+	}
+	code = code[1:] // The name of the function.
+
+	locals := map[string]int64{"$a": a, "$b": b}
+	var stack []int64
+	pop := func() int64 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	push := func(v int64) { stack = append(stack, v) }
+	flag := func(ok bool) {
+		if ok {
+			push(1)
+		} else {
+			push(0)
+		}
+	}
+	// skip moves past the else or end that closes the arm starting after pc.
+	skip := func(pc int, toElse bool) int {
+		depth := 0
+		for pc++; pc < len(code); pc++ {
+			switch code[pc] {
+			case "if":
+				depth++
+			case "else":
+				if depth == 0 && toElse {
+					return pc
+				}
+			case "end":
+				if depth == 0 {
+					return pc
+				}
+				depth--
+			}
+		}
+		return pc
+	}
+
+	for pc := 0; pc < len(code); pc++ {
+		switch code[pc] {
+		case "local.get":
+			pc++
+			push(locals[code[pc]])
+		case "local.set":
+			pc++
+			locals[code[pc]] = pop()
+		case "i64.const":
+			pc++
+			v, err := strconv.ParseInt(code[pc], 10, 64)
+			if err != nil {
+				return 0, false, err
+			}
+			push(v)
+		case "i64.add":
+			y, x := pop(), pop()
+			push(x + y)
+		case "i64.sub":
+			y, x := pop(), pop()
+			push(x - y)
+		case "i64.mul":
+			y, x := pop(), pop()
+			push(x * y)
+		case "i64.div_s":
+			y, x := pop(), pop()
+			if y == 0 || x == math.MinInt64 && y == -1 {
+				return 0, true, nil
+			}
+			push(x / y)
+		case "i64.xor":
+			y, x := pop(), pop()
+			push(x ^ y)
+		case "i64.and":
+			y, x := pop(), pop()
+			push(x & y)
+		case "i64.eq":
+			y, x := pop(), pop()
+			flag(x == y)
+		case "i64.ne":
+			y, x := pop(), pop()
+			flag(x != y)
+		case "i64.lt_s":
+			y, x := pop(), pop()
+			flag(x < y)
+		case "i64.eqz", "i32.eqz":
+			flag(pop() == 0)
+		case "if":
+			if pop() == 0 {
+				pc = skip(pc, true)
+			}
+		case "else":
+			pc = skip(pc, false)
+		case "end":
+		case "unreachable":
+			return 0, true, nil
+		default:
+			return 0, false, fmt.Errorf("unsupported instruction %s", code[pc])
+		}
+	}
+	if len(stack) != 1 {
+		return 0, false, fmt.Errorf("the helper left %d values", len(stack))
+	}
+	return stack[0], false, nil
+}
+
+func compileWATInput(t *testing.T, input string) string {
+	l := NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	wat, err := CompileWAT(program)
+	if err != nil {
+		t.Fatalf("CompileWAT returned an error: %s", err)
+	}
+	return wat
+}
+
+// sexpr is a parsed WAT s-expression: either an atom or a list of children.
+type sexpr struct {
+	atom string
+	list []*sexpr
+}
+
+func (s *sexpr) isList() bool {
+	return s.list != nil
+}
+
+func (s *sexpr) head() string {
+	if len(s.list) == 0 || s.list[0].isList() {
+		return ""
+	}
+	return s.list[0].atom
+}
+
+// checkWAT is a small structural validator for the modules produced by CompileWAT.
+// It checks that parentheses balance, that every referenced function and global is
+// declared, and that each function body leaves the i64 value stack balanced.
+func checkWAT(input string) error {
+	root, err := parseSexpr(input)
+	if err != nil {
+		return err
+	}
+	if root.head() != "module" {
+		return fmt.Errorf("top level form is %q, not module", root.head())
+	}
+
+	funcs := map[string][2]int{} // name -> {params, results}
+	globals := map[string]bool{}
+	for _, field := range root.list[1:] {
+		switch field.head() {
+		case "import":
+			if len(field.list) != 4 || field.list[3].head() != "func" {
+				return fmt.Errorf("malformed import")
+			}
+			name, params, results := funcSignature(field.list[3])
+			funcs[name] = [2]int{params, results}
+		case "func":
+			name, params, results := funcSignature(field)
+			funcs[name] = [2]int{params, results}
+		case "global":
+			if len(field.list) < 3 {
+				return fmt.Errorf("malformed global")
+			}
+			globals[field.list[1].atom] = true
+		default:
+			return fmt.Errorf("unexpected module field %q", field.head())
+		}
+	}
+
+	for _, field := range root.list[1:] {
+		if field.head() == "func" {
+			if err := checkWATFunc(field, funcs, globals); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// funcSignature returns the name and the number of params and results of a func form.
+func funcSignature(fn *sexpr) (string, int, int) {
+	name := ""
+	params, results := 0, 0
+	for _, child := range fn.list[1:] {
+		if !child.isList() {
+			if strings.HasPrefix(child.atom, "$") && name == "" {
+				name = child.atom
+			}
+			continue
+		}
+		switch child.head() {
+		case "param":
+			if strings.HasPrefix(child.list[1].atom, "$") {
+				params++ // A named param declares one value.
+			} else {
+				params += len(child.list) - 1
+			}
+		case "result":
+			results += len(child.list) - 1
+		}
+	}
+	return name, params, results
+}
+
+// watFrame is an if, block or loop whose end has not been reached yet.
+type watFrame struct {
+	kind  string
+	label string
+	depth int // The stack depth when it started.
+}
+
+// checkWATFunc simulates the value stack of a flat function body.
+func checkWATFunc(fn *sexpr, funcs map[string][2]int, globals map[string]bool) error {
+	name, _, results := funcSignature(fn)
+	locals := map[string]bool{}
+	depth := 0
+	var frames []watFrame // The structured instructions still open.
+
+	children := fn.list[1:]
+	for i := 0; i < len(children); i++ {
+		child := children[i]
+		if child.isList() {
+			switch child.head() {
+			case "export", "result":
+				continue
+			case "param", "local":
+				locals[child.list[1].atom] = true
+				continue
+			default:
+				return fmt.Errorf("%s: folded instruction %q is not supported", name, child.head())
+			}
+		}
+		if strings.HasPrefix(child.atom, "$") {
+			continue
+		}
+
+		operand := func() (string, error) {
+			if i+1 >= len(children) || children[i+1].isList() {
+				return "", fmt.Errorf("%s: %s is missing its operand", name, child.atom)
+			}
+			i++
+			return children[i].atom, nil
+		}
+
+		pop, push := 0, 0
+		label := ""
+		switch child.atom {
+		case "i64.const":
+			if _, err := operand(); err != nil {
+				return err
+			}
+			push = 1
+		case "global.get", "global.set":
+			ref, err := operand()
+			if err != nil {
+				return err
+			}
+			if !globals[ref] {
+				return fmt.Errorf("%s: unknown global %s", name, ref)
+			}
+			if child.atom == "global.get" {
+				push = 1
+			} else {
+				pop = 1
+			}
+		case "local.get", "local.set":
+			ref, err := operand()
+			if err != nil {
+				return err
+			}
+			if !locals[ref] {
+				return fmt.Errorf("%s: unknown local %s", name, ref)
+			}
+			if child.atom == "local.get" {
+				push = 1
+			} else {
+				pop = 1
+			}
+		case "call":
+			ref, err := operand()
+			if err != nil {
+				return err
+			}
+			sig, ok := funcs[ref]
+			if !ok {
+				return fmt.Errorf("%s: call to unknown function %s", name, ref)
+			}
+			pop, push = sig[0], sig[1]
+		case "i64.add", "i64.sub", "i64.mul", "i64.div_s", "i64.rem_s", "i64.and", "i64.xor",
+			"i64.eq", "i64.ne", "i64.lt_s", "i64.gt_s", "i64.le_s", "i64.ge_s":
+			pop, push = 2, 1
+		case "i64.eqz", "i64.extend_i32_u":
+			pop, push = 1, 1
+		case "i32.eqz":
+			pop, push = 1, 1
+		case "if":
+			pop = 1
+		case "block", "loop":
+			if i+1 < len(children) && strings.HasPrefix(children[i+1].atom, "$") {
+				i++
+				label = children[i].atom
+			}
+		case "br", "br_if":
+			ref, err := operand()
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, frame := range frames {
+				found = found || frame.label == ref
+			}
+			if !found {
+				return fmt.Errorf("%s: %s to unknown label %s", name, child.atom, ref)
+			}
+			if child.atom == "br_if" {
+				pop = 1
+			}
+		case "else", "end", "unreachable":
+		case "drop":
+			pop = 1
+		default:
+			return fmt.Errorf("%s: unknown instruction %q", name, child.atom)
+		}
+
+		if depth < pop {
+			return fmt.Errorf("%s: %s needs %d values but the stack has %d", name, child.atom, pop, depth)
+		}
+		depth = depth - pop + push
+
+		// Each arm of an if, block or loop starts from, and has to return to, the depth
+		// the instruction left.
+		switch child.atom {
+		case "if", "block", "loop":
+			frames = append(frames, watFrame{kind: child.atom, label: label, depth: depth})
+		case "else", "end":
+			if len(frames) == 0 {
+				return fmt.Errorf("%s: %s without an if", name, child.atom)
+			}
+			frame := frames[len(frames)-1]
+			if child.atom == "else" && frame.kind != "if" {
+				return fmt.Errorf("%s: else inside a %s", name, frame.kind)
+			}
+			if depth != frame.depth {
+				return fmt.Errorf("%s: %s leaves %d values on the stack, expected %d", name, child.atom, depth, frame.depth)
+			}
+			if child.atom == "end" {
+				frames = frames[:len(frames)-1]
+			}
+		}
+	}
+
+	if len(frames) > 0 {
+		return fmt.Errorf("%s: %s is missing its end", name, frames[len(frames)-1].kind)
+	}
+	if depth != results {
+		return fmt.Errorf("%s: body leaves %d values on the stack, expected %d", name, depth, results)
+	}
+	return nil
+}
+
+// parseSexpr reads exactly one s-expression from the input.
+func parseSexpr(input string) (*sexpr, error) {
+	tokens := tokenizeSexpr(input)
+	pos := 0
+
+	var parse func() (*sexpr, error)
+	parse = func() (*sexpr, error) {
+		if pos >= len(tokens) {
+			return nil, fmt.Errorf("unexpected end of input")
+		}
+		tok := tokens[pos]
+		pos++
+		switch tok {
+		case ")":
+			return nil, fmt.Errorf("unbalanced )")
+		case "(":
+			node := &sexpr{list: []*sexpr{}}
+			for {
+				if pos >= len(tokens) {
+					return nil, fmt.Errorf("unbalanced (")
+				}
+				if tokens[pos] == ")" {
+					pos++
+					return node, nil
+				}
+				child, err := parse()
+				if err != nil {
+					return nil, err
+				}
+				node.list = append(node.list, child)
+			}
+		default:
+			return &sexpr{atom: tok}, nil
+		}
+	}
+
+	node, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	if pos != len(tokens) {
+		return nil, fmt.Errorf("trailing input after the module")
+	}
+	return node, nil
+}
+
+// tokenizeSexpr splits WAT text into parentheses, strings and atoms.
+func tokenizeSexpr(input string) []string {
+	var tokens []string
+	for i := 0; i < len(input); {
+		ch := input[i]
+		switch {
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, string(ch))
+			i++
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '"':
+			j := i + 1
+			for j < len(input) && input[j] != '"' {
+				j++
+			}
+			if j >= len(input) {
+				j = len(input) - 1
+			}
+			tokens = append(tokens, input[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for j < len(input) && !strings.ContainsRune("() \t\n\r\"", rune(input[j])) {
+				j++
+			}
+			tokens = append(tokens, input[i:j])
+			i = j
+		}
+	}
+	return tokens
+}